@@ -2,7 +2,11 @@ package main
 
 import (
 	"context"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
 	"house-of-neural-networks/internal/config"
+	"house-of-neural-networks/internal/mailer"
+	"house-of-neural-networks/internal/oidc"
 	"house-of-neural-networks/internal/repository"
 	"house-of-neural-networks/internal/service"
 	"house-of-neural-networks/internal/transport/grpc/auth"
@@ -10,11 +14,15 @@ import (
 	"house-of-neural-networks/pkg/logger"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 )
 
 const (
 	serviceName = "auth"
+	// keyCheckInterval is how often the signing keys are reloaded and rotated when due
+	keyCheckInterval = time.Minute
 )
 
 func main() {
@@ -35,6 +43,26 @@ func main() {
 
 	repo := repository.NewAuthRepository(db)
 	serv := service.NewAuthService(repo, cfg.JWTSecret)
+	if cfg.JWTAlgorithm != jwt.SigningMethodHS256.Alg() {
+		serv.Keys, err = service.NewKeyring(repo, cfg.JWTAlgorithm, cfg.JWTKeyRotation)
+		if err != nil {
+			mainLogger.Fatal(ctx, err.Error())
+		}
+		if err = serv.Keys.Rotate(ctx); err != nil {
+			mainLogger.Fatal(ctx, err.Error())
+		}
+		go rotateKeys(ctx, serv.Keys)
+	}
+	if cfg.OIDCConfig.Enabled() {
+		serv.OIDC = oidc.NewProvider(cfg.OIDCConfig, nil)
+	}
+	serv.Mailer = mailer.New(cfg.MailerConfig)
+	serv.PublicURL = strings.TrimSuffix(cfg.PublicURL, "/")
+	serv.Throttle = service.LoginThrottle{Attempts: cfg.LoginAttempts, IPAttempts: cfg.LoginIPAttempts, Window: cfg.LoginWindow}
+	serv.Audit = repository.NewAuditRepository(db)
+	if err = serv.GrantAdmins(ctx, cfg.AdminUsernames); err != nil {
+		mainLogger.Fatal(ctx, err.Error())
+	}
 
 	grpcServer, err := auth.New(ctx, cfg.GRPCServerPort, serv)
 	if err != nil {
@@ -54,3 +82,18 @@ func main() {
 	grpcServer.Stop(ctx)
 	mainLogger.Info(ctx, "Server Stopped")
 }
+
+func rotateKeys(ctx context.Context, keys *service.Keyring) {
+	ticker := time.NewTicker(keyCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := keys.Rotate(ctx); err != nil {
+				logger.GetLoggerFromCtx(ctx).Error(ctx, fmt.Sprintf("failed to rotate signing keys: %s", err.Error()))
+			}
+		}
+	}
+}
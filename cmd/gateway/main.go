@@ -22,6 +22,10 @@ const (
 // @description This is the API documentation for the services.
 // @host localhost:80
 // @BasePath /
+// @securityDefinitions.apikey TokenAuth
+// @in header
+// @name Authorization
+// @description "Bearer <access token or API key>". Browsers use the token cookie set by /login, API keys may also be sent in X-API-Key.
 func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
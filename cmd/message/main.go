@@ -36,13 +36,20 @@ func main() {
 	}
 
 	tritonClient := triton.NewTritonClient(cfg.TritonConfig.Host, cfg.TritonConfig.Port)
-	serverLiveResponse := triton.ServerLiveRequest(tritonClient.Client)
-	mainLogger.Info(ctx, fmt.Sprintf("Triton Health - Live: %v", serverLiveResponse.Live))
-	serverReadyResponse := triton.ServerReadyRequest(tritonClient.Client)
-	mainLogger.Info(ctx, fmt.Sprintf("Triton Health - Ready: %v", serverReadyResponse.Ready))
+	serverLiveResponse, err := triton.ServerLiveRequest(tritonClient.Client)
+	if err != nil {
+		mainLogger.Error(ctx, err.Error())
+	}
+	mainLogger.Info(ctx, fmt.Sprintf("Triton Health - Live: %v", serverLiveResponse.GetLive()))
+	serverReadyResponse, err := triton.ServerReadyRequest(tritonClient.Client)
+	if err != nil {
+		mainLogger.Error(ctx, err.Error())
+	}
+	mainLogger.Info(ctx, fmt.Sprintf("Triton Health - Ready: %v", serverReadyResponse.GetReady()))
 
 	repo := repository.NewMessageRepository(db)
 	serv := service.NewMessageService(repo, tritonClient)
+	serv.Audit = repository.NewAuditRepository(db)
 
 	grpcServer, err := message.New(ctx, cfg.GRPCServerPort, serv)
 	if err != nil {
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"house-of-neural-networks/internal/config"
+	"house-of-neural-networks/internal/modelstore"
 	"house-of-neural-networks/internal/repository"
 	"house-of-neural-networks/internal/service"
 	"house-of-neural-networks/internal/transport/grpc/model"
@@ -12,11 +13,17 @@ import (
 	"house-of-neural-networks/pkg/logger"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
+	"time"
 )
 
 const (
 	serviceName = "model"
+	// uploadCollectInterval is how often expired uploads are deleted
+	uploadCollectInterval = time.Hour
+	// accountDeletionInterval is how often deletions of accounts that failed are retried
+	accountDeletionInterval = 5 * time.Minute
 )
 
 func main() {
@@ -36,13 +43,31 @@ func main() {
 	}
 
 	tritonClient := triton.NewTritonClient(cfg.TritonConfig.Host, cfg.TritonConfig.Port)
-	serverLiveResponse := triton.ServerLiveRequest(tritonClient.Client)
-	mainLogger.Info(ctx, fmt.Sprintf("Triton Health - Live: %v", serverLiveResponse.Live))
-	serverReadyResponse := triton.ServerReadyRequest(tritonClient.Client)
-	mainLogger.Info(ctx, fmt.Sprintf("Triton Health - Ready: %v", serverReadyResponse.Ready))
+	serverLiveResponse, err := triton.ServerLiveRequest(tritonClient.Client)
+	if err != nil {
+		mainLogger.Error(ctx, err.Error())
+	}
+	mainLogger.Info(ctx, fmt.Sprintf("Triton Health - Live: %v", serverLiveResponse.GetLive()))
+	serverReadyResponse, err := triton.ServerReadyRequest(tritonClient.Client)
+	if err != nil {
+		mainLogger.Error(ctx, err.Error())
+	}
+	mainLogger.Info(ctx, fmt.Sprintf("Triton Health - Ready: %v", serverReadyResponse.GetReady()))
 
 	repo := repository.NewModelRepository(db)
 	serv := service.NewModelService(repo, tritonClient)
+	serv.Audit = repository.NewAuditRepository(db)
+	serv.UploadExpiry = cfg.UploadExpiry
+	serv.Store, err = modelstore.New(cfg.ModelStoreConfig)
+	if err != nil {
+		mainLogger.Fatal(ctx, err.Error())
+	}
+	serv.UploadDir = cfg.UploadDir
+	if serv.UploadDir == "" {
+		serv.UploadDir = filepath.Join(cfg.ModelStoreConfig.Root, ".uploads")
+	}
+	go runPeriodically(ctx, uploadCollectInterval, "collect expired uploads", serv.CollectUploads)
+	go runPeriodically(ctx, accountDeletionInterval, "delete requested accounts", serv.DeleteRequestedAccounts)
 
 	grpcServer, err := model.New(ctx, cfg.GRPCServerPort, serv)
 	if err != nil {
@@ -62,3 +87,19 @@ func main() {
 	grpcServer.Stop(ctx)
 	mainLogger.Info(ctx, "Server Stopped")
 }
+
+// runPeriodically runs the job every interval until ctx is done.
+func runPeriodically(ctx context.Context, interval time.Duration, name string, job func(context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := job(ctx); err != nil {
+				logger.GetLoggerFromCtx(ctx).Error(ctx, fmt.Sprintf("failed to %s: %s", name, err.Error()))
+			}
+		}
+	}
+}
@@ -15,96 +15,103 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/chat/{model_id}": {
+        "/.well-known/jwks.json": {
+            "get": {
+                "description": "Возвращает публичные ключи (JWKS), которыми подписываются access-токены, чтобы проверять их без обращения к сервису авторизации. Пустой список означает, что токены подписываются общим секретом.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth service"
+                ],
+                "summary": "Ключи проверки токенов",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/jwks.Set"
+                        }
+                    }
+                }
+            }
+        },
+        "/account": {
             "get": {
                 "security": [
                     {
                         "TokenAuth": []
                     }
                 ],
-                "description": "This endpoint retrieves all messages associated with a specific model ID and user ID.",
+                "description": "Returns the account of the current user.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Message service"
-                ],
-                "summary": "Get messages",
-                "parameters": [
-                    {
-                        "type": "integer",
-                        "description": "Model ID",
-                        "name": "model_id",
-                        "in": "path",
-                        "required": true
-                    }
+                    "Account"
                 ],
+                "summary": "Get the profile",
                 "responses": {
                     "200": {
-                        "description": "List of messages",
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.GetMessagesResponse"
+                            "$ref": "#/definitions/models.Profile"
                         }
                     }
                 }
-            }
-        },
-        "/chat/{model_id}/{version_id}": {
-            "post": {
+            },
+            "delete": {
                 "security": [
                     {
                         "TokenAuth": []
                     }
                 ],
-                "description": "This endpoint allows a user to send a request to a specific model identified by its ID and receive a response.",
+                "description": "Deletes the account with its models, their files, the chat history and the organizations nobody else is a member of. Organizations left without an owner get a new one. Takes the password, and a code if two-factor authentication is enabled.",
                 "consumes": [
                     "application/json"
                 ],
-                "produces": [
-                    "application/json"
-                ],
                 "tags": [
-                    "Message service"
+                    "Account"
                 ],
-                "summary": "Send a message to a model",
+                "summary": "Delete the account",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "Model ID",
-                        "name": "model_id",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "type": "integer",
-                        "description": "Version ID of model",
-                        "name": "version_id",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "description": "Request to model",
+                        "description": "Password and code",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/models.SendMessageRequest"
+                            "$ref": "#/definitions/models.DeleteAccountRequest"
                         }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "Response from the model",
+                    "202": {
+                        "description": "Account locked, the data is deleted shortly"
+                    },
+                    "204": {
+                        "description": "Deleted"
+                    },
+                    "403": {
+                        "description": "Wrong password or code",
                         "schema": {
-                            "$ref": "#/definitions/models.SendMessageResponse"
+                            "type": "string"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many failed attempts",
+                        "schema": {
+                            "type": "string"
                         }
                     }
                 }
-            }
-        },
-        "/login": {
-            "post": {
-                "description": "Авторизует пользователей",
+            },
+            "patch": {
+                "security": [
+                    {
+                        "TokenAuth": []
+                    }
+                ],
+                "description": "Changes the username and email to the ones that are set. A new email has to be verified again, the link is sent right away.",
                 "consumes": [
                     "application/json"
                 ],
@@ -112,17 +119,17 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Auth service"
+                    "Account"
                 ],
-                "summary": "Авторизация пользователя",
+                "summary": "Update the profile",
                 "parameters": [
                     {
-                        "description": "LogIn data",
-                        "name": "register",
+                        "description": "Fields to change",
+                        "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/models.LogInRequest"
+                            "$ref": "#/definitions/models.UpdateProfileRequest"
                         }
                     }
                 ],
@@ -130,20 +137,26 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.LogInResponse"
+                            "$ref": "#/definitions/models.Profile"
+                        }
+                    },
+                    "409": {
+                        "description": "Username is taken",
+                        "schema": {
+                            "type": "string"
                         }
                     }
                 }
             }
         },
-        "/models": {
-            "get": {
+        "/account/password": {
+            "post": {
                 "security": [
                     {
                         "TokenAuth": []
                     }
                 ],
-                "description": "Возвращает список моделей, связанных с пользователем",
+                "description": "Sets a new password and ends every other session. Returns a new session for the caller.",
                 "consumes": [
                     "application/json"
                 ],
@@ -151,171 +164,219 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Model service"
+                    "Account"
+                ],
+                "summary": "Change the password",
+                "parameters": [
+                    {
+                        "description": "Old and new password",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.ChangePasswordRequest"
+                        }
+                    }
                 ],
-                "summary": "Получение списка моделей",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.ListModelsResponse"
+                            "$ref": "#/definitions/models.LogInResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Wrong password",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many failed attempts",
+                        "schema": {
+                            "type": "string"
                         }
                     }
                 }
-            },
-            "post": {
+            }
+        },
+        "/admin/audit": {
+            "get": {
                 "security": [
                     {
                         "TokenAuth": []
                     }
                 ],
-                "description": "This endpoint allows the user to upload a model file with a name and a file. It expects a multipart form with fields \"name\" and \"file\".",
-                "consumes": [
-                    "multipart/form-data"
-                ],
+                "description": "Returns the security audit log of all services, newest first: logins, account and permission changes, uploads and deletions. Requires the admin role.\nEvents are returned page by page; pass nextPageToken of the response as page_token to get the next page.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Model service"
+                    "Admin"
                 ],
-                "summary": "Upload a model to the service",
+                "summary": "List audit events",
                 "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Only events of this user",
+                        "name": "actor_id",
+                        "in": "query"
+                    },
                     {
                         "type": "string",
-                        "description": "Name of the model",
-                        "name": "name",
-                        "in": "formData",
-                        "required": true
+                        "description": "Only events of this action, e.g. auth.login or model.delete",
+                        "name": "action",
+                        "in": "query"
                     },
                     {
-                        "type": "file",
-                        "description": "Config file",
-                        "name": "file",
-                        "in": "formData",
-                        "required": true
+                        "type": "string",
+                        "description": "Only events at or after this time (RFC 3339)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only events before this time (RFC 3339)",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Events per page, 50 by default, at most 500",
+                        "name": "page_size",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Token of the page returned as nextPageToken",
+                        "name": "page_token",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Model upload successful",
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.UploadModelResponse"
+                            "$ref": "#/definitions/models.ListAuditEventsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid filter or page token",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Admin role required",
+                        "schema": {
+                            "type": "string"
                         }
                     }
                 }
-            },
-            "delete": {
+            }
+        },
+        "/admin/models": {
+            "get": {
                 "security": [
                     {
                         "TokenAuth": []
                     }
                 ],
-                "description": "This endpoint allows the user to unload a model from the service by providing the model ID in the request body.",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Returns the models of every user, or of one user. Requires the admin role.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Model service"
+                    "Admin"
                 ],
-                "summary": "Unload a model",
+                "summary": "List all models",
                 "parameters": [
                     {
-                        "description": "Unload model request body",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/models.UnloadModelRequest"
-                        }
+                        "type": "integer",
+                        "description": "Owner of the models",
+                        "name": "user_id",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Model unload successful",
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.UnloadModelResponse"
+                            "$ref": "#/definitions/models.ListModelsResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Admin role required",
+                        "schema": {
+                            "type": "string"
                         }
                     }
                 }
             }
         },
-        "/models/version": {
-            "post": {
+        "/admin/models/{id}": {
+            "get": {
                 "security": [
                     {
                         "TokenAuth": []
                     }
                 ],
-                "description": "This endpoint allows the user to upload a new version of an existing model. The request includes version number, model ID, and one or more files.",
-                "consumes": [
-                    "multipart/form-data"
-                ],
+                "description": "Returns a model regardless of its owner. Requires the admin role.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Model service"
+                    "Admin"
                 ],
-                "summary": "Upload a new version of a model",
+                "summary": "Get any model",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Version number of the model",
-                        "name": "version",
-                        "in": "formData",
-                        "required": true
-                    },
-                    {
-                        "type": "integer",
-                        "description": "ID of the model",
-                        "name": "model_id",
-                        "in": "formData",
-                        "required": true
-                    },
-                    {
-                        "type": "file",
-                        "description": "Files for the new version model",
-                        "name": "files",
-                        "in": "formData",
+                        "description": "Model ID",
+                        "name": "id",
+                        "in": "path",
                         "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Version upload successful",
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.UploadVersionResponse"
+                            "$ref": "#/definitions/models.GetModelResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Admin role required",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Model not found",
+                        "schema": {
+                            "type": "string"
                         }
                     }
                 }
-            }
-        },
-        "/models/{id}": {
-            "get": {
+            },
+            "delete": {
                 "security": [
                     {
                         "TokenAuth": []
                     }
                 ],
-                "description": "Возвращает данные о модели по ее идентификатору",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Unloads and deletes a model regardless of its owner. Requires the admin role.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Model service"
+                    "Admin"
                 ],
-                "summary": "Получение модели",
+                "summary": "Delete any model",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Идентификатор модели",
+                        "description": "Model ID",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -325,74 +386,2439 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.GetModelResponse"
+                            "$ref": "#/definitions/models.UnloadModelResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Admin role required",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Model not found",
+                        "schema": {
+                            "type": "string"
                         }
                     }
                 }
             }
         },
-        "/signup": {
-            "post": {
-                "description": "Регистрирует новых пользователей",
-                "consumes": [
-                    "application/json"
+        "/admin/users": {
+            "get": {
+                "security": [
+                    {
+                        "TokenAuth": []
+                    }
                 ],
+                "description": "Returns every user with their roles and whether the account is disabled. Requires the admin role.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Auth service"
+                    "Admin"
                 ],
-                "summary": "Регистрация пользователя",
-                "parameters": [
-                    {
-                        "description": "SignUp data",
-                        "name": "register",
-                        "in": "body",
-                        "required": true,
+                "summary": "List users",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.ListUsersResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Admin role required",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/users/{id}/disable": {
+            "post": {
+                "security": [
+                    {
+                        "TokenAuth": []
+                    }
+                ],
+                "description": "Blocks the account: the user can no longer log in, tokens and API keys already issued are rejected. Requires the admin role.",
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Disable a user",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Disabled"
+                    },
+                    "400": {
+                        "description": "Administrators cannot disable themselves",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Admin role required",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "User not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/users/{id}/enable": {
+            "post": {
+                "security": [
+                    {
+                        "TokenAuth": []
+                    }
+                ],
+                "description": "Unblocks a disabled account. Requires the admin role.",
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Enable a user",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Enabled"
+                    },
+                    "403": {
+                        "description": "Admin role required",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "User not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/users/{id}/roles": {
+            "put": {
+                "security": [
+                    {
+                        "TokenAuth": []
+                    }
+                ],
+                "description": "Replaces the roles of the user, the user role is always kept. Takes effect on the next login or token refresh. Requires the admin role.",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Set the roles of a user",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Roles",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.SetUserRolesRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Roles set"
+                    },
+                    "400": {
+                        "description": "Unknown role",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Admin role required",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "User not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/chat": {
+            "get": {
+                "security": [
+                    {
+                        "TokenAuth": []
+                    }
+                ],
+                "description": "This endpoint lists every model version the user has talked to with the model name, the number of messages and the time of the last message, most recently active first.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Message service"
+                ],
+                "summary": "List chats",
+                "responses": {
+                    "200": {
+                        "description": "List of chats",
+                        "schema": {
+                            "$ref": "#/definitions/models.ListChatsResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/chat/messages/{message_id}": {
+            "delete": {
+                "security": [
+                    {
+                        "TokenAuth": []
+                    }
+                ],
+                "description": "This endpoint deletes a message from the chat history of the user.",
+                "tags": [
+                    "Message service"
+                ],
+                "summary": "Delete a message",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Message ID",
+                        "name": "message_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Message deleted"
+                    },
+                    "404": {
+                        "description": "Message not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/chat/{model_id}": {
+            "get": {
+                "security": [
+                    {
+                        "TokenAuth": []
+                    }
+                ],
+                "description": "This endpoint retrieves messages associated with a specific model ID and user ID, with the input and output tensors of every message.\nMessages are ordered by creation time and returned page by page; pass nextPageToken of the response as page_token to get the next page.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Message service"
+                ],
+                "summary": "Get messages",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Model ID",
+                        "name": "model_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Only messages sent to this version",
+                        "name": "version_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only messages created at or after this time (RFC 3339)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only messages created before this time (RFC 3339)",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "asc",
+                            "desc"
+                        ],
+                        "type": "string",
+                        "default": "asc",
+                        "description": "Order by creation time",
+                        "name": "order",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Messages per page, 50 by default, at most 500",
+                        "name": "page_size",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Token of the page returned as nextPageToken",
+                        "name": "page_token",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Page of messages",
+                        "schema": {
+                            "$ref": "#/definitions/models.GetMessagesResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid model ID, filter or page token",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "TokenAuth": []
+                    }
+                ],
+                "description": "This endpoint deletes every message the user sent to the model, or only to one of its versions when version_id is given.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Message service"
+                ],
+                "summary": "Delete a chat",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Model ID",
+                        "name": "model_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Only delete messages sent to this version",
+                        "name": "version_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Number of deleted messages",
+                        "schema": {
+                            "$ref": "#/definitions/models.DeleteChatResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/chat/{model_id}/export": {
+            "get": {
+                "security": [
+                    {
+                        "TokenAuth": []
+                    }
+                ],
+                "description": "This endpoint streams every message matching the filter in chronological order, either as JSON lines (one message per line) or as CSV with inputs and outputs encoded as JSON.",
+                "produces": [
+                    "application/x-ndjson",
+                    "text/csv"
+                ],
+                "tags": [
+                    "Message service"
+                ],
+                "summary": "Export messages",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Model ID",
+                        "name": "model_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "jsonl",
+                            "csv"
+                        ],
+                        "type": "string",
+                        "default": "jsonl",
+                        "description": "Export format",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Only messages sent to this version",
+                        "name": "version_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only messages created at or after this time (RFC 3339)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only messages created before this time (RFC 3339)",
+                        "name": "to",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Messages",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.ChatMessage"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid filter or format",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/chat/{model_id}/{version_id}": {
+            "post": {
+                "security": [
+                    {
+                        "TokenAuth": []
+                    }
+                ],
+                "description": "This endpoint allows a user to send named input tensors to a specific model version and receive its outputs keyed by output name.\nDatatype and shape of an input are taken from the model schema when omitted. Data is a (nested) array of numbers, booleans or strings; binary BYTES elements are passed as base64 strings with \"encoding\": \"base64\".",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Message service"
+                ],
+                "summary": "Send a message to a model",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Model ID",
+                        "name": "model_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Version ID of model",
+                        "name": "version_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Request to model",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.SendMessageRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Response from the model",
+                        "schema": {
+                            "$ref": "#/definitions/models.SendMessageResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Inputs do not match the model schema",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Model belongs to another user",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Model or version not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "503": {
+                        "description": "Inference server is unavailable",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "504": {
+                        "description": "Inference timed out",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/email/verification": {
+            "post": {
+                "security": [
+                    {
+                        "TokenAuth": []
+                    }
+                ],
+                "description": "Sends a new verification link to the email of the user. Earlier links stop working.",
+                "tags": [
+                    "Auth service"
+                ],
+                "summary": "Send an email verification link",
+                "responses": {
+                    "202": {
+                        "description": "Email sent"
+                    },
+                    "400": {
+                        "description": "No email or email already verified",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/email/verify": {
+            "get": {
+                "description": "Target of the link sent by email. A link can be used once.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth service"
+                ],
+                "summary": "Verify an email",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Token from the link",
+                        "name": "token",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.VerifyEmailResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid or expired token",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/keys": {
+            "get": {
+                "security": [
+                    {
+                        "TokenAuth": []
+                    }
+                ],
+                "description": "Returns the keys of the user that have not been revoked, without the keys themselves",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth service"
+                ],
+                "summary": "List API keys",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.ListAPIKeysResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "TokenAuth": []
+                    }
+                ],
+                "description": "Issues a key for scripts and CI. The key is sent as \"Authorization: Bearer \u003ckey\u003e\" or \"X-API-Key: \u003ckey\u003e\", is limited to the given scopes and, if model_ids is set, to those models. The key is returned only once. Keys cannot be managed with a key.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth service"
+                ],
+                "summary": "Create an API key",
+                "parameters": [
+                    {
+                        "description": "Key description",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.CreateAPIKeyRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.CreateAPIKeyResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Missing name, unknown scope or expiry in the past",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/keys/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "TokenAuth": []
+                    }
+                ],
+                "tags": [
+                    "Auth service"
+                ],
+                "summary": "Revoke an API key",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Key ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Key revoked"
+                    },
+                    "404": {
+                        "description": "Key not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/login": {
+            "post": {
+                "description": "Авторизует пользователей. Если включена двухфакторная аутентификация, вместо токенов возвращается mfa_required и challenge_token, вход завершается через /login/totp.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth service"
+                ],
+                "summary": "Авторизация пользователя",
+                "parameters": [
+                    {
+                        "description": "LogIn data",
+                        "name": "register",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.LogInRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.LogInResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid username or password",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many failed attempts",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/login/oidc": {
+            "get": {
+                "description": "Перенаправляет на страницу входа внешнего OpenID Connect провайдера. После входа провайдер возвращает пользователя на /login/oidc/callback.",
+                "tags": [
+                    "Auth service"
+                ],
+                "summary": "Вход через SSO",
+                "responses": {
+                    "302": {
+                        "description": "Redirect to the identity provider"
+                    },
+                    "400": {
+                        "description": "OpenID Connect login is not configured",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/login/oidc/callback": {
+            "get": {
+                "description": "Принимает перенаправление от OpenID Connect провайдера и выдает токены, как /login. При первом входе пользователь создается автоматически. Если включена двухфакторная аутентификация, вместо токенов возвращается mfa_required и challenge_token, вход завершается через /login/totp.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth service"
+                ],
+                "summary": "Завершение входа через SSO",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Authorization code",
+                        "name": "code",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "State of the login",
+                        "name": "state",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.LogInResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Login was denied, has expired or was started in another browser",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/login/totp": {
+            "post": {
+                "description": "Завершает вход пользователя с двухфакторной аутентификацией кодом из приложения-аутентификатора или кодом восстановления. Токен challenge_token действует 5 минут и допускает 5 неверных кодов.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth service"
+                ],
+                "summary": "Второй шаг входа",
+                "parameters": [
+                    {
+                        "description": "Challenge token from /login and the code",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.VerifyLogInRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.LogInResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid code or challenge token",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many failed attempts",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/logout": {
+            "post": {
+                "description": "Отзывает access-токен и refresh-токен из cookie и удаляет cookie",
+                "tags": [
+                    "Auth service"
+                ],
+                "summary": "Выход из системы",
+                "responses": {
+                    "204": {
+                        "description": "Logged out"
+                    }
+                }
+            }
+        },
+        "/models": {
+            "get": {
+                "security": [
+                    {
+                        "TokenAuth": []
+                    }
+                ],
+                "description": "Возвращает список моделей пользователя и моделей, к которым ему открыт доступ, с его ролью в каждой",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Model service"
+                ],
+                "summary": "Получение списка моделей",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.ListModelsResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "TokenAuth": []
+                    }
+                ],
+                "description": "This endpoint allows the user to upload a model file with a name and a file. It expects a multipart form with fields \"name\" and \"file\".",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Model service"
+                ],
+                "summary": "Upload a model to the service",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Name of the model",
+                        "name": "name",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "file",
+                        "description": "Config file",
+                        "name": "file",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Organization owning the model, requires the maintainer or owner role in it",
+                        "name": "organization_id",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Model upload successful",
+                        "schema": {
+                            "$ref": "#/definitions/models.UploadModelResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Not a maintainer of the organization",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Model name is taken",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "TokenAuth": []
+                    }
+                ],
+                "description": "This endpoint allows the user to unload a model from the service by providing the model ID in the request body.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Model service"
+                ],
+                "summary": "Unload a model",
+                "parameters": [
+                    {
+                        "description": "Unload model request body",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.UnloadModelRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Model unload successful",
+                        "schema": {
+                            "$ref": "#/definitions/models.UnloadModelResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Model belongs to another user",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Model not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/models/version": {
+            "post": {
+                "security": [
+                    {
+                        "TokenAuth": []
+                    }
+                ],
+                "description": "This endpoint allows the user to upload a new version of an existing model. The request includes version number, model ID, and one or more files.\nFiles are streamed to the model service as they arrive, so version and model_id have to come before the files in the form, or be passed in the query.",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Model service"
+                ],
+                "summary": "Upload a new version of a model",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Version number of the model",
+                        "name": "version",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "ID of the model",
+                        "name": "model_id",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "file",
+                        "description": "Files for the new version model",
+                        "name": "files",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Version upload successful",
+                        "schema": {
+                            "$ref": "#/definitions/models.UploadVersionResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid form data",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Model belongs to another user",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Model not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "413": {
+                        "description": "Upload too large",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/models/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "TokenAuth": []
+                    }
+                ],
+                "description": "Возвращает данные о модели по ее идентификатору",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Model service"
+                ],
+                "summary": "Получение модели",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Идентификатор модели",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.GetModelResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Model belongs to another user",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Model not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/models/{id}/access": {
+            "post": {
+                "security": [
+                    {
+                        "TokenAuth": []
+                    }
+                ],
+                "description": "Grants a user, or everyone when public is set, read, infer or manage access to the model. Requires manage access; only the owner may grant manage. Public access is limited to read and infer.",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Model service"
+                ],
+                "summary": "Share a model",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Model ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Grantee and permission",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.GrantAccessRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Access granted"
+                    },
+                    "400": {
+                        "description": "Invalid grantee or permission",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Not allowed to share the model",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Model or user not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "TokenAuth": []
+                    }
+                ],
+                "description": "Takes away the access of a user, or public access when public is set. Requires manage access; only the owner may revoke manage.",
+                "tags": [
+                    "Model service"
+                ],
+                "summary": "Revoke access to a model",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Model ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "User to revoke access from",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Revoke public access",
+                        "name": "public",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Access revoked"
+                    },
+                    "403": {
+                        "description": "Not allowed to manage access",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Model not found or user has no access",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/organizations": {
+            "get": {
+                "security": [
+                    {
+                        "TokenAuth": []
+                    }
+                ],
+                "description": "Returns the organizations the caller is a member of with their role in each",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Organizations"
+                ],
+                "summary": "List organizations",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.ListOrganizationsResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "TokenAuth": []
+                    }
+                ],
+                "description": "Creates an organization with the caller as its owner. Models uploaded to the organization are shared by all its members.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Organizations"
+                ],
+                "summary": "Create an organization",
+                "parameters": [
+                    {
+                        "description": "Organization",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.CreateOrganizationRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.CreateOrganizationResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Name is empty",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Organization already exists",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/organizations/{id}/members": {
+            "get": {
+                "security": [
+                    {
+                        "TokenAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Organizations"
+                ],
+                "summary": "List organization members",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Organization ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.ListMembersResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Not a member of the organization",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/organizations/{id}/members/{user_id}": {
+            "put": {
+                "security": [
+                    {
+                        "TokenAuth": []
+                    }
+                ],
+                "description": "Owners manage every membership, maintainers may only add members with the member role. The last owner cannot be demoted.",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Organizations"
+                ],
+                "summary": "Add a member or change their role",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Organization ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Role",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.SetMemberRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Member saved"
+                    },
+                    "400": {
+                        "description": "Unknown role or the organization would be left without an owner",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Not allowed to manage members",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "User not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "TokenAuth": []
+                    }
+                ],
+                "description": "Removes the user from the organization. Any member may leave on their own, maintainers may remove members, owners anyone.",
+                "tags": [
+                    "Organizations"
+                ],
+                "summary": "Remove a member",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Organization ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Member removed"
+                    },
+                    "400": {
+                        "description": "The organization would be left without an owner",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Not allowed to manage members",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "User is not a member",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/password/forgot": {
+            "post": {
+                "description": "Emails a password reset token to every account registered with the email. The response does not reveal whether there is one.",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth service"
+                ],
+                "summary": "Request a password reset",
+                "parameters": [
+                    {
+                        "description": "Email of the account",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.PasswordResetRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Request accepted"
+                    }
+                }
+            }
+        },
+        "/password/reset": {
+            "post": {
+                "description": "Sets a new password with the token from the password reset email and logs the user out everywhere. A token can be used once.",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth service"
+                ],
+                "summary": "Reset the password",
+                "parameters": [
+                    {
+                        "description": "Token and new password",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.ResetPasswordRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Password changed"
+                    },
+                    "400": {
+                        "description": "Invalid or expired token",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/refresh": {
+            "post": {
+                "description": "Выдает новый access-токен и новый refresh-токен взамен переданного. Refresh-токен берется из cookie refresh_token или из тела запроса и может быть использован только один раз.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth service"
+                ],
+                "summary": "Обновление токена",
+                "parameters": [
+                    {
+                        "description": "Refresh token, if not sent in the cookie",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/models.RefreshRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.LogInResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Refresh token is invalid, expired or revoked",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/signup": {
+            "post": {
+                "description": "Регистрирует новых пользователей",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth service"
+                ],
+                "summary": "Регистрация пользователя",
+                "parameters": [
+                    {
+                        "description": "SignUp data",
+                        "name": "register",
+                        "in": "body",
+                        "required": true,
                         "schema": {
                             "$ref": "#/definitions/models.SignUpRequest"
                         }
                     }
-                ],
-                "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {
-                            "$ref": "#/definitions/models.SignUpResponse"
-                        }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.SignUpResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/totp": {
+            "post": {
+                "security": [
+                    {
+                        "TokenAuth": []
+                    }
+                ],
+                "description": "Generates a new TOTP secret and returns it with the otpauth URI to add to an authenticator app. Two-factor authentication is enabled by /totp/confirm.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth service"
+                ],
+                "summary": "Start enabling two-factor authentication",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.EnrollTOTPResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Already enabled",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "TokenAuth": []
+                    }
+                ],
+                "description": "Disables two-factor authentication and drops the recovery codes. Takes a code from the authenticator app or a recovery code.",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth service"
+                ],
+                "summary": "Disable two-factor authentication",
+                "parameters": [
+                    {
+                        "description": "Code from the authenticator app or a recovery code",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.TOTPCodeRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Disabled"
+                    },
+                    "400": {
+                        "description": "Invalid code or not enabled",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/totp/confirm": {
+            "post": {
+                "security": [
+                    {
+                        "TokenAuth": []
+                    }
+                ],
+                "description": "Enables two-factor authentication with a code from the authenticator app and returns the recovery codes. They are shown only once, each can replace a code once.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth service"
+                ],
+                "summary": "Enable two-factor authentication",
+                "parameters": [
+                    {
+                        "description": "Code from the authenticator app",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.TOTPCodeRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.RecoveryCodesResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid code or no enrolment",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/uploads": {
+            "post": {
+                "security": [
+                    {
+                        "TokenAuth": []
+                    }
+                ],
+                "description": "Starts a resumable upload of a file for a new version of the model, for files too large to upload in one go. The content is sent with PATCH /uploads/{id}, and once all files of the version are uploaded the version is created with POST /uploads/finish. Uploads expire 24 hours after data last arrived. Requires manage access to the model.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Model service"
+                ],
+                "summary": "Start a resumable upload",
+                "parameters": [
+                    {
+                        "description": "Model, file name and size in bytes",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.CreateUploadRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Upload started",
+                        "schema": {
+                            "$ref": "#/definitions/models.Upload"
+                        },
+                        "headers": {
+                            "Location": {
+                                "type": "string",
+                                "description": "Address of the upload"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid file name or size",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Not allowed to manage the model",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Model not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/uploads/finish": {
+            "post": {
+                "security": [
+                    {
+                        "TokenAuth": []
+                    }
+                ],
+                "description": "Creates a new version of the model with the files of the uploads, which have to be complete. The uploads are consumed.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Model service"
+                ],
+                "summary": "Create a version from uploads",
+                "parameters": [
+                    {
+                        "description": "Model, version number and uploads",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.FinishUploadRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Version created",
+                        "schema": {
+                            "$ref": "#/definitions/models.UploadVersionResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Incomplete upload, upload of another model or duplicate file name",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Not allowed to manage the model",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Model or upload not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/uploads/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "TokenAuth": []
+                    }
+                ],
+                "description": "Cancels the upload and drops the data received.",
+                "tags": [
+                    "Model service"
+                ],
+                "summary": "Cancel an upload",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Upload ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Upload cancelled"
+                    },
+                    "404": {
+                        "description": "Upload not found or expired",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "The upload is being written",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "head": {
+                "security": [
+                    {
+                        "TokenAuth": []
+                    }
+                ],
+                "description": "Returns the number of bytes of the upload received so far in Upload-Offset, an interrupted upload carries on from there.",
+                "tags": [
+                    "Model service"
+                ],
+                "summary": "Get the offset of an upload",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Upload ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Upload found",
+                        "headers": {
+                            "Upload-Expires": {
+                                "type": "string",
+                                "description": "Time the upload expires at"
+                            },
+                            "Upload-Length": {
+                                "type": "integer",
+                                "description": "Size of the file"
+                            },
+                            "Upload-Offset": {
+                                "type": "integer",
+                                "description": "Bytes received so far"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Upload not found or expired",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "security": [
+                    {
+                        "TokenAuth": []
+                    }
+                ],
+                "description": "Appends the request body to the upload. Upload-Offset has to be the number of bytes received so far, otherwise 409 is returned. If the transfer breaks, the data that arrived is kept: ask for the offset with HEAD /uploads/{id} and send the rest from there.",
+                "consumes": [
+                    "application/offset+octet-stream"
+                ],
+                "tags": [
+                    "Model service"
+                ],
+                "summary": "Send a part of an upload",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Upload ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Offset to append at",
+                        "name": "Upload-Offset",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Part received",
+                        "headers": {
+                            "Upload-Offset": {
+                                "type": "integer",
+                                "description": "Bytes received so far"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Missing offset or content beyond the size of the file",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Upload not found or expired",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Offset does not match or the upload is being written",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "jwks.Key": {
+            "type": "object",
+            "properties": {
+                "alg": {
+                    "type": "string"
+                },
+                "crv": {
+                    "type": "string"
+                },
+                "e": {
+                    "type": "string"
+                },
+                "kid": {
+                    "type": "string"
+                },
+                "kty": {
+                    "type": "string"
+                },
+                "n": {
+                    "type": "string"
+                },
+                "use": {
+                    "type": "string"
+                },
+                "x": {
+                    "type": "string"
+                },
+                "y": {
+                    "type": "string"
+                }
+            }
+        },
+        "jwks.Set": {
+            "type": "object",
+            "properties": {
+                "keys": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/jwks.Key"
+                    }
+                }
+            }
+        },
+        "models.APIKey": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "expires_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "last_used_at": {
+                    "type": "string"
+                },
+                "model_ids": {
+                    "description": "Empty gives access to every model of the user",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "name": {
+                    "type": "string"
+                },
+                "prefix": {
+                    "description": "Beginning of the key, shown to tell keys apart",
+                    "type": "string"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "models.AdminUser": {
+            "type": "object",
+            "properties": {
+                "disabled": {
+                    "type": "boolean"
+                },
+                "email": {
+                    "type": "string"
+                },
+                "email_verified": {
+                    "type": "boolean"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "roles": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.AuditEventResponse": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "type": "string",
+                    "example": "model.create"
+                },
+                "actor_id": {
+                    "type": "integer"
+                },
+                "client_ip": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "outcome": {
+                    "type": "string",
+                    "example": "success"
+                },
+                "request_id": {
+                    "type": "string"
+                },
+                "target": {
+                    "type": "string",
+                    "example": "model:12"
+                }
+            }
+        },
+        "models.ChangePasswordRequest": {
+            "type": "object",
+            "properties": {
+                "new_password": {
+                    "type": "string",
+                    "example": "evenmoresecure456"
+                },
+                "old_password": {
+                    "type": "string",
+                    "example": "securepassword123"
+                }
+            }
+        },
+        "models.Chat": {
+            "type": "object",
+            "properties": {
+                "lastMessageAt": {
+                    "type": "string"
+                },
+                "messageCount": {
+                    "type": "integer"
+                },
+                "modelId": {
+                    "type": "integer"
+                },
+                "modelName": {
+                    "type": "string"
+                },
+                "versionId": {
+                    "type": "integer"
+                },
+                "versionNumber": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.ChatMessage": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "inputs": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Tensor"
+                    }
+                },
+                "modelId": {
+                    "type": "integer"
+                },
+                "outputs": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Tensor"
                     }
+                },
+                "versionId": {
+                    "type": "integer"
                 }
             }
-        }
-    },
-    "definitions": {
+        },
+        "models.CreateAPIKeyRequest": {
+            "type": "object",
+            "properties": {
+                "expires_at": {
+                    "type": "string"
+                },
+                "model_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    },
+                    "example": [
+                        1
+                    ]
+                },
+                "name": {
+                    "type": "string",
+                    "example": "ci"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string",
+                        "enum": [
+                            "models:read",
+                            "models:write",
+                            "chat:read",
+                            "chat:write"
+                        ]
+                    },
+                    "example": [
+                        "chat:write"
+                    ]
+                }
+            }
+        },
+        "models.CreateAPIKeyResponse": {
+            "type": "object",
+            "properties": {
+                "api_key": {
+                    "$ref": "#/definitions/models.APIKey"
+                },
+                "key": {
+                    "description": "Shown only once, only its hash is stored",
+                    "type": "string"
+                }
+            }
+        },
+        "models.CreateOrganizationRequest": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string",
+                    "example": "research"
+                }
+            }
+        },
+        "models.CreateOrganizationResponse": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.CreateUploadRequest": {
+            "type": "object",
+            "properties": {
+                "filename": {
+                    "type": "string",
+                    "example": "model.onnx"
+                },
+                "model_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "size": {
+                    "type": "integer",
+                    "example": 4294967296
+                }
+            }
+        },
+        "models.DeleteAccountRequest": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "description": "Code from the authenticator or a recovery code, if two-factor authentication is enabled",
+                    "type": "string",
+                    "example": "123456"
+                },
+                "password": {
+                    "type": "string",
+                    "example": "securepassword123"
+                }
+            }
+        },
+        "models.DeleteChatResponse": {
+            "type": "object",
+            "properties": {
+                "deletedCount": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.EnrollTOTPResponse": {
+            "type": "object",
+            "properties": {
+                "secret": {
+                    "type": "string"
+                },
+                "uri": {
+                    "type": "string",
+                    "example": "otpauth://totp/House%20of%20Neural%20Networks:john_doe?secret=..."
+                }
+            }
+        },
+        "models.FinishUploadRequest": {
+            "type": "object",
+            "properties": {
+                "model_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "upload_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    },
+                    "example": [
+                        1
+                    ]
+                },
+                "version": {
+                    "type": "integer",
+                    "example": 2
+                }
+            }
+        },
         "models.GetMessagesResponse": {
             "type": "object",
             "properties": {
                 "messages": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/models.Message"
+                        "$ref": "#/definitions/models.ChatMessage"
+                    }
+                },
+                "nextPageToken": {
+                    "type": "string"
+                },
+                "totalCount": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.GetModelResponse": {
+            "type": "object",
+            "properties": {
+                "model": {
+                    "$ref": "#/definitions/models.Model"
+                }
+            }
+        },
+        "models.GrantAccessRequest": {
+            "type": "object",
+            "properties": {
+                "permission": {
+                    "type": "string",
+                    "enum": [
+                        "read",
+                        "infer",
+                        "manage"
+                    ],
+                    "example": "infer"
+                },
+                "public": {
+                    "type": "boolean"
+                },
+                "user_id": {
+                    "type": "integer",
+                    "example": 2
+                }
+            }
+        },
+        "models.ListAPIKeysResponse": {
+            "type": "object",
+            "properties": {
+                "keys": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.APIKey"
+                    }
+                }
+            }
+        },
+        "models.ListAuditEventsResponse": {
+            "type": "object",
+            "properties": {
+                "events": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.AuditEventResponse"
+                    }
+                },
+                "nextPageToken": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.ListChatsResponse": {
+            "type": "object",
+            "properties": {
+                "chats": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Chat"
+                    }
+                }
+            }
+        },
+        "models.ListMembersResponse": {
+            "type": "object",
+            "properties": {
+                "members": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Member"
+                    }
+                }
+            }
+        },
+        "models.ListModelsResponse": {
+            "type": "object",
+            "properties": {
+                "models": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Model"
                     }
                 }
             }
         },
-        "models.GetModelResponse": {
+        "models.ListOrganizationsResponse": {
             "type": "object",
             "properties": {
-                "model": {
-                    "$ref": "#/definitions/models.Model"
+                "organizations": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Organization"
+                    }
                 }
             }
         },
-        "models.ListModelsResponse": {
+        "models.ListUsersResponse": {
             "type": "object",
             "properties": {
-                "models": {
+                "users": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/models.Model"
+                        "$ref": "#/definitions/models.AdminUser"
                     }
                 }
             }
@@ -413,79 +2839,255 @@ const docTemplate = `{
         "models.LogInResponse": {
             "type": "object",
             "properties": {
+                "challenge_expires_at": {
+                    "type": "string"
+                },
+                "challenge_token": {
+                    "type": "string"
+                },
+                "expires_at": {
+                    "type": "string"
+                },
                 "jwt": {
                     "type": "string"
                 },
+                "mfa_required": {
+                    "type": "boolean"
+                },
+                "refresh_expires_at": {
+                    "type": "string"
+                },
+                "refresh_token": {
+                    "type": "string"
+                },
                 "userId": {
                     "type": "integer"
                 }
             }
         },
-        "models.Message": {
+        "models.Member": {
             "type": "object",
             "properties": {
-                "createdAt": {
+                "role": {
                     "type": "string"
                 },
+                "user_id": {
+                    "type": "integer"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.Model": {
+            "type": "object",
+            "properties": {
                 "id": {
                     "type": "integer"
                 },
-                "input1": {
-                    "type": "array",
-                    "items": {
-                        "type": "integer"
-                    }
+                "name": {
+                    "type": "string"
                 },
-                "input2": {
-                    "type": "array",
-                    "items": {
-                        "type": "integer"
-                    }
+                "organization_id": {
+                    "type": "integer"
+                },
+                "public_permission": {
+                    "type": "string"
+                },
+                "role": {
+                    "type": "string"
                 },
-                "modelID": {
+                "user_id": {
                     "type": "integer"
                 },
-                "results": {
+                "versions": {
                     "type": "array",
                     "items": {
-                        "type": "string"
+                        "$ref": "#/definitions/models.Version"
                     }
+                }
+            }
+        },
+        "models.Organization": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
                 },
-                "userID": {
+                "id": {
                     "type": "integer"
                 },
-                "versionID": {
-                    "type": "integer"
+                "name": {
+                    "type": "string"
+                },
+                "role": {
+                    "description": "Role of the caller in the organization",
+                    "type": "string"
                 }
             }
         },
-        "models.Model": {
+        "models.PasswordResetRequest": {
+            "type": "object",
+            "properties": {
+                "email": {
+                    "type": "string",
+                    "example": "john@example.com"
+                }
+            }
+        },
+        "models.Profile": {
             "type": "object",
             "properties": {
+                "email": {
+                    "type": "string"
+                },
+                "email_verified": {
+                    "type": "boolean"
+                },
                 "id": {
                     "type": "integer"
                 },
-                "name": {
+                "last_login_at": {
                     "type": "string"
                 },
-                "user_id": {
-                    "type": "integer"
+                "roles": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
                 },
-                "versions": {
+                "totp_enabled": {
+                    "type": "boolean"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.RecoveryCodesResponse": {
+            "type": "object",
+            "properties": {
+                "recovery_codes": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/models.Version"
+                        "type": "string"
                     }
                 }
             }
         },
+        "models.RefreshRequest": {
+            "type": "object",
+            "properties": {
+                "refresh_token": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.ResetPasswordRequest": {
+            "type": "object",
+            "properties": {
+                "password": {
+                    "type": "string",
+                    "example": "newpassword123"
+                },
+                "token": {
+                    "type": "string"
+                }
+            }
+        },
         "models.SendMessageRequest": {
             "type": "object",
             "properties": {
-                "input1": {
+                "inputs": {
                     "type": "array",
                     "items": {
-                        "type": "integer"
+                        "$ref": "#/definitions/models.Tensor"
+                    }
+                }
+            }
+        },
+        "models.SendMessageResponse": {
+            "type": "object",
+            "properties": {
+                "outputs": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/models.Tensor"
+                    }
+                }
+            }
+        },
+        "models.SetMemberRequest": {
+            "type": "object",
+            "properties": {
+                "role": {
+                    "type": "string",
+                    "enum": [
+                        "owner",
+                        "maintainer",
+                        "member"
+                    ],
+                    "example": "member"
+                }
+            }
+        },
+        "models.SetUserRolesRequest": {
+            "type": "object",
+            "properties": {
+                "roles": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "user",
+                        "admin"
+                    ]
+                }
+            }
+        },
+        "models.SignUpRequest": {
+            "type": "object",
+            "properties": {
+                "email": {
+                    "type": "string",
+                    "example": "john@example.com"
+                },
+                "password": {
+                    "type": "string",
+                    "example": "securepassword123"
+                },
+                "username": {
+                    "type": "string",
+                    "example": "john_doe"
+                }
+            }
+        },
+        "models.SignUpResponse": {
+            "type": "object",
+            "properties": {
+                "success": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "models.TOTPCodeRequest": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "description": "Code from the authenticator, or a recovery code where accepted",
+                    "type": "string",
+                    "example": "123456"
+                }
+            }
+        },
+        "models.Tensor": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "type": "number"
                     },
                     "example": [
                         0,
@@ -506,73 +3108,86 @@ const docTemplate = `{
                         15
                     ]
                 },
-                "input2": {
+                "datatype": {
+                    "type": "string",
+                    "example": "INT32"
+                },
+                "encoding": {
+                    "type": "string",
+                    "enum": [
+                        "base64"
+                    ]
+                },
+                "name": {
+                    "type": "string",
+                    "example": "INPUT0"
+                },
+                "shape": {
                     "type": "array",
                     "items": {
                         "type": "integer"
                     },
                     "example": [
                         1,
-                        2,
-                        3,
-                        4,
-                        5,
-                        6,
-                        7,
-                        8,
-                        9,
-                        10,
-                        11,
-                        12,
-                        13,
-                        14,
-                        15,
                         16
                     ]
                 }
             }
         },
-        "models.SendMessageResponse": {
+        "models.UnloadModelRequest": {
             "type": "object",
             "properties": {
-                "results": {
-                    "type": "array",
-                    "items": {
-                        "type": "string"
-                    },
-                    "example": [
-                        "1 + 1 = 2",
-                        "1 - 1 = 0"
-                    ]
+                "id": {
+                    "type": "integer"
                 }
             }
         },
-        "models.SignUpRequest": {
+        "models.UnloadModelResponse": {
+            "type": "object",
+            "properties": {
+                "success": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "models.UpdateProfileRequest": {
             "type": "object",
             "properties": {
                 "email": {
                     "type": "string",
                     "example": "john@example.com"
                 },
-                "password": {
-                    "type": "string",
-                    "example": "securepassword123"
-                },
                 "username": {
                     "type": "string",
                     "example": "john_doe"
                 }
             }
         },
-        "models.SignUpResponse": {
+        "models.Upload": {
             "type": "object",
             "properties": {
-                "success": {
-                    "type": "boolean"
+                "expires_at": {
+                    "type": "string"
+                },
+                "filename": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "model_id": {
+                    "type": "integer"
+                },
+                "offset": {
+                    "description": "Number of bytes received so far, the upload is complete when it reaches Size",
+                    "type": "integer"
+                },
+                "size": {
+                    "type": "integer"
                 }
             }
         },
-        "models.UnloadModelRequest": {
+        "models.UploadModelResponse": {
             "type": "object",
             "properties": {
                 "id": {
@@ -580,27 +3195,31 @@ const docTemplate = `{
                 }
             }
         },
-        "models.UnloadModelResponse": {
+        "models.UploadVersionResponse": {
             "type": "object",
             "properties": {
-                "success": {
-                    "type": "boolean"
+                "id": {
+                    "type": "integer"
                 }
             }
         },
-        "models.UploadModelResponse": {
+        "models.VerifyEmailResponse": {
             "type": "object",
             "properties": {
-                "id": {
-                    "type": "integer"
+                "verified": {
+                    "type": "boolean"
                 }
             }
         },
-        "models.UploadVersionResponse": {
+        "models.VerifyLogInRequest": {
             "type": "object",
             "properties": {
-                "id": {
-                    "type": "integer"
+                "challenge_token": {
+                    "type": "string"
+                },
+                "code": {
+                    "type": "string",
+                    "example": "123456"
                 }
             }
         },
@@ -618,6 +3237,14 @@ const docTemplate = `{
                 }
             }
         }
+    },
+    "securityDefinitions": {
+        "TokenAuth": {
+            "description": "\"Bearer \u003caccess token or API key\u003e\". Browsers use the token cookie set by /login, API keys may also be sent in X-API-Key.",
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
+        }
     }
 }`
 
@@ -1,9 +1,13 @@
 package config
 
 import (
+	"house-of-neural-networks/internal/mailer"
+	"house-of-neural-networks/internal/modelstore"
+	"house-of-neural-networks/internal/oidc"
 	"house-of-neural-networks/internal/triton"
 	"house-of-neural-networks/pkg/db/cache"
 	"house-of-neural-networks/pkg/db/postgres"
+	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
 )
@@ -12,15 +16,37 @@ type Config struct {
 	postgres.Config
 	cache.RedisConfig
 	triton.TritonConfig
+	oidc.OIDCConfig
+	mailer.MailerConfig
+	modelstore.ModelStoreConfig
 
 	GRPCServerPort int    `env:"GRPC_SERVER_PORT" env-default:"50051"`
 	JWTSecret      string `env:"JWT_SECRET" env-default:""`
+	// HS256 signs with JWTSecret, RS256 and EdDSA with generated keys published as a JWKS
+	JWTAlgorithm   string        `env:"JWT_ALGORITHM" env-default:"HS256"`
+	JWTKeyRotation time.Duration `env:"JWT_KEY_ROTATION" env-default:"720h"`
+	// Users made administrators on startup, e.g. to bootstrap the first one
+	AdminUsernames []string `env:"ADMIN_USERNAMES" env-default:""`
+	// Address of the gateway as seen by users, links sent by email point there
+	PublicURL string `env:"PUBLIC_URL" env-default:"http://localhost"`
+	// Failed logins allowed per account and per client address within LoginWindow before logins are slowed down
+	LoginAttempts   int           `env:"LOGIN_ATTEMPTS" env-default:"5"`
+	LoginIPAttempts int           `env:"LOGIN_IP_ATTEMPTS" env-default:"20"`
+	LoginWindow     time.Duration `env:"LOGIN_WINDOW" env-default:"15m"`
+	// How long resumable uploads of model files are kept since data last arrived
+	UploadExpiry time.Duration `env:"UPLOAD_EXPIRY" env-default:"24h"`
+	// Local directory of the model service uploads are received in, .uploads in MODEL_STORE_ROOT if empty
+	UploadDir string `env:"UPLOAD_DIR" env-default:""`
 
 	// For Gateway
 	HTTPServerPort    int    `env:"HTTP_SERVER_PORT" env-default:"8080"`
 	AuthServiceURL    string `env:"AUTH_SERVICE_URL" env-default:"localhost:50051"`
 	ModelServiceURL   string `env:"MODEL_SERVICE_URL" env-default:"localhost:50052"`
 	MessageServiceURL string `env:"MESSAGE_SERVICE_URL" env-default:"localhost:50053"`
+	// How often the gateway reloads the keys and the revoked tokens it verifies access tokens with
+	JWKSRefreshInterval time.Duration `env:"JWKS_REFRESH_INTERVAL" env-default:"30s"`
+	// Set when the gateway is behind a reverse proxy, the client address is then read from X-Real-IP
+	TrustProxyHeaders bool `env:"TRUST_PROXY_HEADERS" env-default:"false"`
 }
 
 func New() *Config {
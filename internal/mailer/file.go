@@ -0,0 +1,60 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	"house-of-neural-networks/pkg/logger"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// FileMailer is for local development and tests: instead of sending messages it writes each of them to a .eml
+// file in dir, or logs them when dir is empty.
+type FileMailer struct {
+	from string
+	dir  string
+	seq  atomic.Int64
+}
+
+func NewFileMailer(from, dir string) *FileMailer {
+	return &FileMailer{from: from, dir: dir}
+}
+
+func (m *FileMailer) Send(ctx context.Context, msg Message) error {
+	if err := validAddress(msg.To); err != nil {
+		return fmt.Errorf("mailer.Send: %w", err)
+	}
+
+	now := time.Now()
+	if m.dir == "" {
+		logger.GetLoggerFromCtx(ctx).Info(ctx, "email",
+			zap.String("to", msg.To),
+			zap.String("subject", msg.Subject),
+			zap.String("body", msg.Body),
+		)
+		return nil
+	}
+
+	if err := os.MkdirAll(m.dir, 0o755); err != nil {
+		return fmt.Errorf("mailer.Send: %w", err)
+	}
+	// Named so that the files sort in the order the messages were sent
+	name := fmt.Sprintf("%s-%04d-%s.eml", now.UTC().Format("20060102T150405.000000000"), m.seq.Add(1), safeName(msg.To))
+	if err := os.WriteFile(filepath.Join(m.dir, name), format(m.from, msg, now), 0o644); err != nil {
+		return fmt.Errorf("mailer.Send: %w", err)
+	}
+	return nil
+}
+
+func safeName(address string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '@' || r == '.' || r == '-' || r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, address)
+}
@@ -0,0 +1,62 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"strings"
+	"time"
+)
+
+// MailerConfig selects how email is delivered: through the SMTP server when SMTPHost is set, otherwise messages
+// are written to Dir or, without a directory, logged.
+type MailerConfig struct {
+	SMTPHost     string `env:"SMTP_HOST" env-default:""`
+	SMTPPort     int    `env:"SMTP_PORT" env-default:"587"`
+	SMTPUsername string `env:"SMTP_USERNAME" env-default:""`
+	SMTPPassword string `env:"SMTP_PASSWORD" env-default:""`
+	From         string `env:"MAIL_FROM" env-default:"House of Neural Networks <no-reply@localhost>"`
+	Dir          string `env:"MAIL_DIR" env-default:""`
+}
+
+// Message is a plain text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers email.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// New returns the mailer chosen by the config.
+func New(cfg MailerConfig) Mailer {
+	if cfg.SMTPHost != "" {
+		return NewSMTPMailer(cfg)
+	}
+	return NewFileMailer(cfg.From, cfg.Dir)
+}
+
+// format renders the message in the Internet Message Format.
+func format(from string, msg Message, date time.Time) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// validAddress rejects addresses that would inject headers into the message.
+func validAddress(address string) error {
+	if address == "" || strings.ContainsAny(address, "\r\n") {
+		return fmt.Errorf("invalid address %q", address)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+// SMTPMailer sends email through an SMTP server, upgrading the connection with STARTTLS when the server offers it.
+type SMTPMailer struct {
+	addr     string
+	host     string
+	from     string
+	username string
+	password string
+}
+
+func NewSMTPMailer(cfg MailerConfig) *SMTPMailer {
+	return &SMTPMailer{
+		addr:     net.JoinHostPort(cfg.SMTPHost, strconv.Itoa(cfg.SMTPPort)),
+		host:     cfg.SMTPHost,
+		from:     cfg.From,
+		username: cfg.SMTPUsername,
+		password: cfg.SMTPPassword,
+	}
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	if err := validAddress(msg.To); err != nil {
+		return fmt.Errorf("mailer.Send: %w", err)
+	}
+	from, err := mail.ParseAddress(m.from)
+	if err != nil {
+		return fmt.Errorf("mailer.Send: sender: %w", err)
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", m.addr)
+	if err != nil {
+		return fmt.Errorf("mailer.Send: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	client, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("mailer.Send: %w", err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err = client.StartTLS(&tls.Config{ServerName: m.host}); err != nil {
+			return fmt.Errorf("mailer.Send: %w", err)
+		}
+	}
+	if m.username != "" {
+		// PlainAuth refuses to send the password over an unencrypted connection to another host
+		if err = client.Auth(smtp.PlainAuth("", m.username, m.password, m.host)); err != nil {
+			return fmt.Errorf("mailer.Send: %w", err)
+		}
+	}
+	if err = client.Mail(from.Address); err != nil {
+		return fmt.Errorf("mailer.Send: %w", err)
+	}
+	if err = client.Rcpt(msg.To); err != nil {
+		return fmt.Errorf("mailer.Send: %w", err)
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("mailer.Send: %w", err)
+	}
+	if _, err = w.Write(format(m.from, msg, time.Now())); err != nil {
+		return fmt.Errorf("mailer.Send: %w", err)
+	}
+	if err = w.Close(); err != nil {
+		return fmt.Errorf("mailer.Send: %w", err)
+	}
+	return client.Quit()
+}
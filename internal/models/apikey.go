@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// APIKeyPrefix starts every API key, which tells keys apart from access tokens.
+const APIKeyPrefix = "hnn_"
+
+// Operations an API key may be allowed to perform.
+const (
+	ScopeModelsRead  = "models:read"
+	ScopeModelsWrite = "models:write"
+	ScopeChatRead    = "chat:read"
+	ScopeChatWrite   = "chat:write"
+)
+
+type APIKey struct {
+	ID     int64  `json:"id" db:"id"`
+	UserID int64  `json:"-" db:"user_id"`
+	Name   string `json:"name" db:"name"`
+	// Beginning of the key, shown to tell keys apart
+	Prefix  string   `json:"prefix" db:"prefix"`
+	KeyHash string   `json:"-" db:"key_hash"`
+	Scopes  []string `json:"scopes" db:"scopes"`
+	// Empty gives access to every model of the user
+	ModelIDs   []int64    `json:"model_ids,omitempty" db:"model_ids"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
+}
+
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" example:"ci"`
+	Scopes    []string   `json:"scopes" enums:"models:read,models:write,chat:read,chat:write" example:"chat:write"`
+	ModelIDs  []int64    `json:"model_ids,omitempty" example:"1"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+type CreateAPIKeyResponse struct {
+	// Shown only once, only its hash is stored
+	Key    string `json:"key"`
+	APIKey APIKey `json:"api_key"`
+}
+
+type ListAPIKeysResponse struct {
+	Keys []APIKey `json:"keys"`
+}
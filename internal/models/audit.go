@@ -0,0 +1,82 @@
+package models
+
+import "time"
+
+// Actions recorded in the audit log.
+const (
+	AuditSignUp               = "auth.signup"
+	AuditLogIn                = "auth.login"
+	AuditLogInSecondFactor    = "auth.login_totp"
+	AuditLogInOIDC            = "auth.login_oidc"
+	AuditPasswordResetRequest = "auth.password_reset_request"
+	AuditPasswordReset        = "auth.password_reset"
+	AuditPasswordChange       = "auth.password_change"
+	AuditProfileUpdate        = "auth.profile_update"
+	AuditTOTPEnable           = "auth.totp_enable"
+	AuditTOTPDisable          = "auth.totp_disable"
+	AuditAPIKeyCreate         = "auth.api_key_create"
+	AuditAPIKeyRevoke         = "auth.api_key_revoke"
+	AuditAccountDelete        = "auth.account_delete"
+	AuditUserDisable          = "admin.user_disable"
+	AuditUserEnable           = "admin.user_enable"
+	AuditUserRoles            = "admin.user_roles"
+	AuditModelCreate          = "model.create"
+	AuditVersionCreate        = "model.version_create"
+	AuditModelDelete          = "model.delete"
+	AuditAccessGrant          = "model.access_grant"
+	AuditAccessRevoke         = "model.access_revoke"
+	AuditAccountDataDelete    = "model.account_data_delete"
+	AuditOrganizationCreate   = "organization.create"
+	AuditMemberSet            = "organization.member_set"
+	AuditMemberRemove         = "organization.member_remove"
+	AuditMessageDelete        = "chat.message_delete"
+	AuditChatDelete           = "chat.delete"
+)
+
+// Outcomes of audited actions.
+const (
+	AuditSuccess = "success"
+	// AuditDenied actions were refused for lack of credentials or permissions.
+	AuditDenied  = "denied"
+	AuditFailure = "failure"
+)
+
+// AuditEvent is a record of the audit log. ActorID is nil when nobody is authenticated, e.g. a failed login of
+// an unknown user. Target names what the action was performed on, like "model:12" or a username.
+type AuditEvent struct {
+	ID        int64     `db:"id"`
+	ActorID   *int64    `db:"actor_id"`
+	Action    string    `db:"action"`
+	Target    string    `db:"target"`
+	RequestID string    `db:"request_id"`
+	ClientIP  string    `db:"client_ip"`
+	Outcome   string    `db:"outcome"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// AuditFilter selects audit events, zero fields match everything. Events are listed newest first, BeforeID
+// continues a listing after the last event returned.
+type AuditFilter struct {
+	ActorID  int64
+	Action   string
+	From     time.Time
+	To       time.Time
+	BeforeID int64
+	Limit    uint64
+}
+
+type AuditEventResponse struct {
+	ID        int64     `json:"id"`
+	ActorID   *int64    `json:"actor_id,omitempty"`
+	Action    string    `json:"action" example:"model.create"`
+	Target    string    `json:"target" example:"model:12"`
+	RequestID string    `json:"request_id"`
+	ClientIP  string    `json:"client_ip"`
+	Outcome   string    `json:"outcome" example:"success"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ListAuditEventsResponse struct {
+	Events        []AuditEventResponse `json:"events"`
+	NextPageToken string               `json:"nextPageToken,omitempty"`
+}
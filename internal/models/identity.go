@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// Identity links a user to an account at an OpenID provider.
+type Identity struct {
+	UserID  int64  `db:"user_id"`
+	Issuer  string `db:"issuer"`
+	Subject string `db:"subject"`
+	Email   string `db:"email"`
+}
+
+// OIDCState is a pending authorization request, looked up by the state the provider redirects back with.
+type OIDCState struct {
+	State        string    `db:"state"`
+	Nonce        string    `db:"nonce"`
+	CodeVerifier string    `db:"code_verifier"`
+	ExpiresAt    time.Time `db:"expires_at"`
+}
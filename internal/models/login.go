@@ -0,0 +1,68 @@
+package models
+
+import "time"
+
+// Reasons a login failed, as recorded in LoginFailure.
+const (
+	LoginUnknownUser   = "unknown_user"
+	LoginWrongPassword = "wrong_password"
+	LoginDisabled      = "disabled"
+	// LoginLocked attempts were rejected without checking the password and are not counted.
+	LoginLocked = "locked"
+)
+
+// LoginFailure is the audit record of a failed login.
+type LoginFailure struct {
+	ID        int64     `db:"id"`
+	Username  string    `db:"username"`
+	UserID    *int64    `db:"user_id"`
+	ClientIP  string    `db:"client_ip"`
+	Reason    string    `db:"reason"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// LoginFailureCount is the number of counted failures of an account or a client address and when the last
+// of them happened.
+type LoginFailureCount struct {
+	Count int
+	Last  *time.Time
+}
+
+// LoginWrongCode is recorded when the second factor of a login is wrong.
+const LoginWrongCode = "wrong_code"
+
+// TOTP is the two-factor authentication setup of a user. Secret is set on enrolment, EnabledAt once the
+// user has confirmed it with a code.
+type TOTP struct {
+	UserID    int64      `db:"id"`
+	Secret    *string    `db:"totp_secret"`
+	EnabledAt *time.Time `db:"totp_enabled_at"`
+	LastStep  *int64     `db:"totp_last_step"`
+}
+
+// LoginChallenge is a login waiting for the second factor, only the hash of its token is stored.
+type LoginChallenge struct {
+	TokenHash string    `db:"token_hash"`
+	UserID    int64     `db:"user_id"`
+	Attempts  int       `db:"attempts"`
+	ExpiresAt time.Time `db:"expires_at"`
+}
+
+type VerifyLogInRequest struct {
+	ChallengeToken string `json:"challenge_token"`
+	Code           string `json:"code" example:"123456"`
+}
+
+type EnrollTOTPResponse struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri" example:"otpauth://totp/House%20of%20Neural%20Networks:john_doe?secret=..."`
+}
+
+type TOTPCodeRequest struct {
+	// Code from the authenticator, or a recovery code where accepted
+	Code string `json:"code" example:"123456"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
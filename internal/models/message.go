@@ -3,25 +3,93 @@ package models
 import "time"
 
 type Message struct {
-	ID        int64     `json:"id" db:"id"`
-	UserID    int64     `json:"userID" db:"user_id"`
-	ModelID   int64     `json:"modelID" db:"model_id"`
-	VersionID int64     `json:"versionID" db:"version_id"`
-	Input1    []byte    `json:"input1" db:"input1"`
-	Input2    []byte    `json:"input2" db:"input2"`
-	Results   []string  `json:"results" db:"results"`
-	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+	ID        int64              `json:"id" db:"id"`
+	UserID    int64              `json:"userID" db:"user_id"`
+	ModelID   int64              `json:"modelID" db:"model_id"`
+	VersionID int64              `json:"versionID" db:"version_id"`
+	Inputs    []TensorDescriptor `json:"inputs" db:"inputs"`
+	Outputs   []TensorDescriptor `json:"outputs" db:"outputs"`
+	CreatedAt time.Time          `json:"createdAt" db:"created_at"`
+}
+
+// MessageFilter selects messages of a chat. Zero VersionID, From and To are not applied.
+// From is inclusive, To is exclusive.
+type MessageFilter struct {
+	UserID    int64
+	ModelID   int64
+	VersionID int64
+	From      time.Time
+	To        time.Time
+}
+
+// MessageCursor points at the last message of the previous page.
+type MessageCursor struct {
+	CreatedAt time.Time
+	ID        int64
+}
+
+type MessagePage struct {
+	Filter     MessageFilter
+	After      *MessageCursor
+	Descending bool
+	Limit      uint64
+}
+
+// Chat is a conversation of a user with a single model version.
+type Chat struct {
+	ModelID       int64     `json:"modelId" db:"model_id"`
+	ModelName     string    `json:"modelName" db:"model_name"`
+	VersionID     int64     `json:"versionId" db:"version_id"`
+	VersionNumber int32     `json:"versionNumber" db:"version_number"`
+	LastMessageAt time.Time `json:"lastMessageAt" db:"last_message_at"`
+	MessageCount  int64     `json:"messageCount" db:"message_count"`
+}
+
+// TensorDescriptor is a tensor persisted with a message. Raw contents are kept inline in Data
+// unless External is set, in which case they are stored in the message_blobs table.
+type TensorDescriptor struct {
+	Name     string  `json:"name"`
+	Datatype string  `json:"datatype"`
+	Shape    []int64 `json:"shape"`
+	Data     []byte  `json:"data,omitempty"`
+	External bool    `json:"external,omitempty"`
+}
+
+type Tensor struct {
+	Name     string      `json:"name" example:"INPUT0"`
+	Datatype string      `json:"datatype,omitempty" example:"INT32"`
+	Shape    []int64     `json:"shape,omitempty" example:"1,16"`
+	Encoding string      `json:"encoding,omitempty" enums:"base64"`
+	Data     interface{} `json:"data" swaggertype:"array,number" example:"0,1,2,3,4,5,6,7,8,9,10,11,12,13,14,15"`
 }
 
 type SendMessageRequest struct {
-	Input1 []int32 `json:"input1" example:"0,1,2,3,4,5,6,7,8,9,10,11,12,13,14,15"`
-	Input2 []int32 `json:"input2" example:"1,2,3,4,5,6,7,8,9,10,11,12,13,14,15,16"`
+	Inputs []Tensor `json:"inputs"`
 }
 
 type SendMessageResponse struct {
-	Results []string `json:"results" example:"1 + 1 = 2,1 - 1 = 0"`
+	Outputs map[string]Tensor `json:"outputs"`
+}
+
+type ChatMessage struct {
+	ID        int64     `json:"id"`
+	ModelID   int64     `json:"modelId"`
+	VersionID int64     `json:"versionId"`
+	Inputs    []Tensor  `json:"inputs"`
+	Outputs   []Tensor  `json:"outputs"`
+	CreatedAt time.Time `json:"createdAt"`
 }
 
 type GetMessagesResponse struct {
-	Messages []Message `json:"messages"`
+	Messages      []ChatMessage `json:"messages"`
+	NextPageToken string        `json:"nextPageToken,omitempty"`
+	TotalCount    int64         `json:"totalCount"`
+}
+
+type ListChatsResponse struct {
+	Chats []Chat `json:"chats"`
+}
+
+type DeleteChatResponse struct {
+	DeletedCount int64 `json:"deletedCount"`
 }
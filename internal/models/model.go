@@ -1,10 +1,21 @@
 package models
 
+// Access levels to a model, each one includes the previous ones.
+const (
+	PermissionRead   = "read"
+	PermissionInfer  = "infer"
+	PermissionManage = "manage"
+	RoleOwner        = "owner"
+)
+
 type Model struct {
-	ID       int64      `json:"id" db:"id"`
-	Name     string     `json:"name" db:"name"`
-	UserID   int64      `json:"user_id" db:"user_id"`
-	Versions []*Version `json:"versions"`
+	ID               int64      `json:"id" db:"id"`
+	Name             string     `json:"name" db:"name"`
+	UserID           int64      `json:"user_id" db:"user_id"`
+	OrganizationID   int64      `json:"organization_id,omitempty" db:"organization_id"`
+	PublicPermission string     `json:"public_permission,omitempty" db:"public_permission"`
+	Role             string     `json:"role,omitempty" db:"role"`
+	Versions         []*Version `json:"versions"`
 }
 
 type GetModelResponse struct {
@@ -30,3 +41,9 @@ type UnloadModelRequest struct {
 type UnloadModelResponse struct {
 	Success bool `json:"success"`
 }
+
+type GrantAccessRequest struct {
+	UserId     int64  `json:"user_id" example:"2"`
+	Public     bool   `json:"public"`
+	Permission string `json:"permission" enums:"read,infer,manage" example:"infer"`
+}
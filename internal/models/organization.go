@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// Roles of organization members. Owners manage the organization and own its models,
+// maintainers upload models and versions, members run them.
+const (
+	OrgRoleOwner      = "owner"
+	OrgRoleMaintainer = "maintainer"
+	OrgRoleMember     = "member"
+)
+
+type Organization struct {
+	ID        int64     `json:"id" db:"id"`
+	Name      string    `json:"name" db:"name"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	// Role of the caller in the organization
+	Role string `json:"role,omitempty" db:"role"`
+}
+
+type Member struct {
+	UserID   int64  `json:"user_id" db:"user_id"`
+	Username string `json:"username" db:"username"`
+	Role     string `json:"role" db:"role"`
+}
+
+type CreateOrganizationRequest struct {
+	Name string `json:"name" example:"research"`
+}
+
+type CreateOrganizationResponse struct {
+	Id int64 `json:"id"`
+}
+
+type ListOrganizationsResponse struct {
+	Organizations []Organization `json:"organizations"`
+}
+
+type ListMembersResponse struct {
+	Members []Member `json:"members"`
+}
+
+type SetMemberRequest struct {
+	Role string `json:"role" enums:"owner,maintainer,member" example:"member"`
+}
@@ -0,0 +1,69 @@
+package models
+
+import "time"
+
+type RefreshToken struct {
+	ID        int64      `db:"id"`
+	UserID    int64      `db:"user_id"`
+	TokenHash string     `db:"token_hash"`
+	FamilyID  string     `db:"family_id"`
+	ExpiresAt time.Time  `db:"expires_at"`
+	RevokedAt *time.Time `db:"revoked_at"`
+}
+
+// Session is a pair of tokens issued on login or refresh. A login that needs the second factor issues a
+// challenge token instead.
+type Session struct {
+	UserID             int64
+	AccessToken        string
+	AccessExpiresAt    time.Time
+	RefreshToken       string
+	RefreshExpiresAt   time.Time
+	ChallengeToken     string
+	ChallengeExpiresAt time.Time
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token,omitempty"`
+}
+
+// TokenIssuer is the iss claim of access tokens.
+const TokenIssuer = "auth-service"
+
+// SigningKey is a key access tokens are signed with, PrivateKey is PKCS #8 DER.
+type SigningKey struct {
+	ID         string    `db:"kid"`
+	Algorithm  string    `db:"algorithm"`
+	PrivateKey []byte    `db:"private_key"`
+	CreatedAt  time.Time `db:"created_at"`
+	ExpiresAt  time.Time `db:"expires_at"`
+}
+
+const (
+	// PurposeVerifyEmail tokens confirm that the user owns the email address.
+	PurposeVerifyEmail = "verify_email"
+	// PurposeResetPassword tokens let the user set a new password.
+	PurposeResetPassword = "reset_password"
+)
+
+// EmailToken is a single-use token sent to Email, only its hash is stored.
+type EmailToken struct {
+	TokenHash string    `db:"token_hash"`
+	UserID    int64     `db:"user_id"`
+	Purpose   string    `db:"purpose"`
+	Email     string    `db:"email"`
+	ExpiresAt time.Time `db:"expires_at"`
+}
+
+type VerifyEmailResponse struct {
+	Verified bool `json:"verified"`
+}
+
+type PasswordResetRequest struct {
+	Email string `json:"email" example:"john@example.com"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password" example:"newpassword123"`
+}
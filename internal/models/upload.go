@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Upload is a resumable upload of a file for a new version of a model.
+type Upload struct {
+	ID       int64  `json:"id" db:"id"`
+	UserID   int64  `json:"-" db:"user_id"`
+	ModelID  int64  `json:"model_id" db:"model_id"`
+	Filename string `json:"filename" db:"filename"`
+	Size     int64  `json:"size" db:"size"`
+	// Number of bytes received so far, the upload is complete when it reaches Size
+	Offset    int64     `json:"offset" db:"-"`
+	CreatedAt time.Time `json:"-" db:"created_at"`
+	ExpiresAt time.Time `json:"expires_at" db:"expires_at"`
+}
+
+type CreateUploadRequest struct {
+	ModelID  int64  `json:"model_id" example:"1"`
+	Filename string `json:"filename" example:"model.onnx"`
+	Size     int64  `json:"size" example:"4294967296"`
+}
+
+type FinishUploadRequest struct {
+	ModelID   int64   `json:"model_id" example:"1"`
+	Version   int32   `json:"version" example:"2"`
+	UploadIDs []int64 `json:"upload_ids" example:"1"`
+}
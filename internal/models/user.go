@@ -1,10 +1,26 @@
 package models
 
+import "time"
+
+const (
+	// RoleUser is granted to every registered user.
+	RoleUser = "user"
+	// RoleAdmin may manage users and every model.
+	RoleAdmin = "admin"
+)
+
 type User struct {
-	ID       int64  `json:"id" db:"id"`
-	Username string `json:"username" db:"username"`
-	Password string `json:"password" db:"password"`
-	Email    string `json:"email" db:"email"`
+	ID         int64      `json:"id" db:"id"`
+	Username   string     `json:"username" db:"username"`
+	Password   string     `json:"password" db:"password"`
+	Email      string     `json:"email" db:"email"`
+	Roles      []string   `json:"roles" db:"roles"`
+	DisabledAt *time.Time `json:"disabled_at,omitempty" db:"disabled_at"`
+	// EmailVerifiedAt is set once the user follows the link sent to Email
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty" db:"email_verified_at"`
+	LastLoginAt     *time.Time `json:"last_login_at,omitempty" db:"last_login_at"`
+	// TOTPEnabledAt is set while the user logs in with a second factor
+	TOTPEnabledAt *time.Time `json:"totp_enabled_at,omitempty" db:"totp_enabled_at"`
 }
 
 type SignUpRequest struct {
@@ -22,7 +38,60 @@ type LogInRequest struct {
 	Password string `json:"password" example:"securepassword123"`
 }
 
+// LogInResponse holds either the tokens or, when MFARequired, the challenge to complete with /login/totp.
 type LogInResponse struct {
-	Jwt    string `json:"jwt"`
-	UserId int64  `json:"userId"`
+	Jwt                string    `json:"jwt"`
+	UserId             int64     `json:"userId"`
+	RefreshToken       string    `json:"refresh_token"`
+	ExpiresAt          time.Time `json:"expires_at"`
+	RefreshExpiresAt   time.Time `json:"refresh_expires_at"`
+	MFARequired        bool      `json:"mfa_required,omitempty"`
+	ChallengeToken     string    `json:"challenge_token,omitempty"`
+	ChallengeExpiresAt time.Time `json:"challenge_expires_at,omitempty"`
+}
+
+// Profile is the account of the current user as shown to them.
+type Profile struct {
+	ID            int64      `json:"id"`
+	Username      string     `json:"username"`
+	Email         string     `json:"email"`
+	EmailVerified bool       `json:"email_verified"`
+	Roles         []string   `json:"roles"`
+	TOTPEnabled   bool       `json:"totp_enabled"`
+	LastLoginAt   *time.Time `json:"last_login_at,omitempty"`
+}
+
+// UpdateProfileRequest changes the fields that are set. A new email has to be verified again.
+type UpdateProfileRequest struct {
+	Username string `json:"username,omitempty" example:"john_doe"`
+	Email    string `json:"email,omitempty" example:"john@example.com"`
+}
+
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password" example:"securepassword123"`
+	NewPassword string `json:"new_password" example:"evenmoresecure456"`
+}
+
+type DeleteAccountRequest struct {
+	Password string `json:"password" example:"securepassword123"`
+	// Code from the authenticator or a recovery code, if two-factor authentication is enabled
+	Code string `json:"code,omitempty" example:"123456"`
+}
+
+// AdminUser is a user as shown to administrators.
+type AdminUser struct {
+	ID            int64    `json:"id"`
+	Username      string   `json:"username"`
+	Email         string   `json:"email"`
+	EmailVerified bool     `json:"email_verified"`
+	Roles         []string `json:"roles"`
+	Disabled      bool     `json:"disabled"`
+}
+
+type ListUsersResponse struct {
+	Users []AdminUser `json:"users"`
+}
+
+type SetUserRolesRequest struct {
+	Roles []string `json:"roles" example:"user,admin"`
 }
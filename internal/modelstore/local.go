@@ -0,0 +1,87 @@
+package modelstore
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// LocalStore keeps the model repository in a directory shared with Triton, e.g. through a docker volume.
+type LocalStore struct {
+	root string
+}
+
+func NewLocalStore(root string) *LocalStore {
+	return &LocalStore{root: root}
+}
+
+func (s *LocalStore) Put(ctx context.Context, path string, content []byte) error {
+	name, err := s.resolve(path)
+	if err != nil {
+		return fmt.Errorf("modelstore.Put: %w", err)
+	}
+	if err = os.MkdirAll(filepath.Dir(name), os.ModePerm); err != nil {
+		return fmt.Errorf("modelstore.Put: %w", err)
+	}
+	if err = os.WriteFile(name, content, 0644); err != nil {
+		return fmt.Errorf("modelstore.Put: %w", err)
+	}
+	return nil
+}
+
+// MoveFile renames src, or copies it when it is on another file system.
+func (s *LocalStore) MoveFile(ctx context.Context, path, src string) error {
+	name, err := s.resolve(path)
+	if err != nil {
+		return fmt.Errorf("modelstore.MoveFile: %w", err)
+	}
+	if err = os.MkdirAll(filepath.Dir(name), os.ModePerm); err != nil {
+		return fmt.Errorf("modelstore.MoveFile: %w", err)
+	}
+	if os.Rename(src, name) == nil {
+		return nil
+	}
+	if err = copyFile(name, src); err != nil {
+		os.Remove(name)
+		return fmt.Errorf("modelstore.MoveFile: %w", err)
+	}
+	return os.Remove(src)
+}
+
+func (s *LocalStore) RemoveAll(ctx context.Context, path string) error {
+	name, err := s.resolve(path)
+	if err != nil {
+		return fmt.Errorf("modelstore.RemoveAll: %w", err)
+	}
+	if err = os.RemoveAll(name); err != nil {
+		return fmt.Errorf("modelstore.RemoveAll: %w", err)
+	}
+	return nil
+}
+
+// resolve returns the file name of path, which must not point outside the root.
+func (s *LocalStore) resolve(path string) (string, error) {
+	if err := validPath(path); err != nil {
+		return "", err
+	}
+	return filepath.Join(s.root, filepath.FromSlash(path)), nil
+}
+
+func copyFile(dst, src string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,53 @@
+package modelstore
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+)
+
+// ModelStoreConfig selects where the model repository Triton loads models from is kept: in the directory Root
+// or, when Backend is s3, in a bucket of an S3-compatible storage such as MinIO.
+type ModelStoreConfig struct {
+	Backend string `env:"MODEL_STORE" env-default:"local"`
+	Root    string `env:"MODEL_STORE_ROOT" env-default:"/models"`
+
+	S3Endpoint  string `env:"S3_ENDPOINT" env-default:""`
+	S3Region    string `env:"S3_REGION" env-default:"us-east-1"`
+	S3Bucket    string `env:"S3_BUCKET" env-default:""`
+	S3Prefix    string `env:"S3_PREFIX" env-default:""`
+	S3AccessKey string `env:"S3_ACCESS_KEY" env-default:""`
+	S3SecretKey string `env:"S3_SECRET_KEY" env-default:""`
+	// Files larger than this are uploaded in parts of this size, S3 requires at least 5 MB
+	S3PartSize int64 `env:"S3_PART_SIZE" env-default:"67108864"`
+}
+
+// ModelStore is the model repository. Paths are relative to its root and separated by slashes, e.g.
+// "simple/1/model.graphdef".
+type ModelStore interface {
+	// Put stores the content at path, replacing the file that was there.
+	Put(ctx context.Context, path string, content []byte) error
+	// MoveFile moves the local file src to path. It is meant for large files, which are not read into memory.
+	MoveFile(ctx context.Context, path, src string) error
+	// RemoveAll removes path and everything under it, it is not an error if there is nothing.
+	RemoveAll(ctx context.Context, path string) error
+}
+
+// New returns the store chosen by the config.
+func New(cfg ModelStoreConfig) (ModelStore, error) {
+	switch cfg.Backend {
+	case "", "local":
+		return NewLocalStore(cfg.Root), nil
+	case "s3":
+		return NewS3Store(cfg)
+	}
+	return nil, fmt.Errorf("modelstore.New: unknown backend %q", cfg.Backend)
+}
+
+// validPath rejects paths that are not relative to the root or point outside of it.
+func validPath(path string) error {
+	if !fs.ValidPath(path) || path == "." {
+		return fmt.Errorf("invalid path %q", path)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package modelstore
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"net/url"
+	"os"
+	pathpkg "path"
+	"strings"
+)
+
+// S3Store keeps the model repository in a bucket of an S3-compatible storage, Triton reads it from
+// s3://<endpoint host>/<bucket>/<prefix>. Buckets are addressed by path, which both MinIO and AWS accept.
+type S3Store struct {
+	client   *minio.Client
+	bucket   string
+	prefix   string
+	partSize int64
+}
+
+func NewS3Store(cfg ModelStoreConfig) (*S3Store, error) {
+	if cfg.S3Endpoint == "" || cfg.S3Bucket == "" {
+		return nil, fmt.Errorf("modelstore.NewS3Store: S3_ENDPOINT and S3_BUCKET are required")
+	}
+	endpoint, err := url.Parse(cfg.S3Endpoint)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return nil, fmt.Errorf("modelstore.NewS3Store: invalid endpoint %q", cfg.S3Endpoint)
+	}
+	client, err := minio.New(endpoint.Host, &minio.Options{
+		Creds:        credentials.NewStaticV4(cfg.S3AccessKey, cfg.S3SecretKey, ""),
+		Secure:       endpoint.Scheme == "https",
+		Region:       cfg.S3Region,
+		BucketLookup: minio.BucketLookupPath,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("modelstore.NewS3Store: %w", err)
+	}
+	partSize := cfg.S3PartSize
+	if partSize <= 0 {
+		partSize = 64 << 20
+	}
+	return &S3Store{
+		client:   client,
+		bucket:   cfg.S3Bucket,
+		prefix:   strings.Trim(cfg.S3Prefix, "/"),
+		partSize: partSize,
+	}, nil
+}
+
+func (s *S3Store) Put(ctx context.Context, path string, content []byte) error {
+	if err := validPath(path); err != nil {
+		return fmt.Errorf("modelstore.Put: %w", err)
+	}
+	_, err := s.client.PutObject(ctx, s.bucket, s.key(path), bytes.NewReader(content), int64(len(content)), minio.PutObjectOptions{})
+	if err != nil {
+		return fmt.Errorf("modelstore.Put: %w", err)
+	}
+	return nil
+}
+
+// MoveFile uploads src and removes it. Files larger than the part size are uploaded in parts.
+func (s *S3Store) MoveFile(ctx context.Context, path, src string) error {
+	if err := validPath(path); err != nil {
+		return fmt.Errorf("modelstore.MoveFile: %w", err)
+	}
+	_, err := s.client.FPutObject(ctx, s.bucket, s.key(path), src, minio.PutObjectOptions{PartSize: uint64(s.partSize)})
+	if err != nil {
+		return fmt.Errorf("modelstore.MoveFile: %w", err)
+	}
+	return os.Remove(src)
+}
+
+// RemoveAll removes the object at path and the objects under it.
+func (s *S3Store) RemoveAll(ctx context.Context, path string) error {
+	if err := validPath(path); err != nil {
+		return fmt.Errorf("modelstore.RemoveAll: %w", err)
+	}
+	// Deleting a missing object succeeds
+	objects := make(chan minio.ObjectInfo)
+	listErr := make(chan error, 1)
+	go func() {
+		defer close(objects)
+		objects <- minio.ObjectInfo{Key: s.key(path)}
+		for object := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: s.key(path) + "/", Recursive: true}) {
+			if object.Err != nil {
+				listErr <- object.Err
+				return
+			}
+			objects <- object
+		}
+	}()
+	// The results are drained even after an error, so that the listing runs to the end
+	var err error
+	for result := range s.client.RemoveObjects(ctx, s.bucket, objects, minio.RemoveObjectsOptions{}) {
+		if result.Err != nil && err == nil {
+			err = result.Err
+		}
+	}
+	if err == nil {
+		select {
+		case err = <-listErr:
+		default:
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("modelstore.RemoveAll: %w", err)
+	}
+	return nil
+}
+
+func (s *S3Store) key(path string) string {
+	return strings.TrimPrefix(pathpkg.Join(s.prefix, path), "/")
+}
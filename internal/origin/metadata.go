@@ -0,0 +1,31 @@
+package origin
+
+import (
+	"context"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// Metadata keys used to pass the origin of a request from the gateway to the internal services.
+const (
+	requestIDKey = "x-request-id"
+	clientIPKey  = "x-client-ip"
+)
+
+// AppendToOutgoingContext adds o to the metadata of outgoing gRPC calls made with ctx.
+func AppendToOutgoingContext(ctx context.Context, o Origin) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, requestIDKey, o.RequestID, clientIPKey, o.ClientIP)
+}
+
+// FromIncomingContext reads the origin from the metadata of an incoming gRPC call.
+func FromIncomingContext(ctx context.Context) (Origin, bool) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return Origin{}, false
+	}
+	requestID, clientIP := md.Get(requestIDKey), md.Get(clientIPKey)
+	if len(requestID) != 1 || len(clientIP) != 1 {
+		return Origin{}, false
+	}
+	return Origin{RequestID: requestID[0], ClientIP: clientIP[0]}, true
+}
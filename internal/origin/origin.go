@@ -0,0 +1,23 @@
+package origin
+
+import "context"
+
+// Origin tells where a request comes from: the id the gateway gave the HTTP request and the address of the
+// client. The services log the request id and record both in the audit log.
+type Origin struct {
+	RequestID string
+	ClientIP  string
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying o.
+func NewContext(ctx context.Context, o Origin) context.Context {
+	return context.WithValue(ctx, contextKey{}, o)
+}
+
+// FromContext returns the origin stored in ctx by NewContext, or the zero Origin.
+func FromContext(ctx context.Context) Origin {
+	o, _ := ctx.Value(contextKey{}).(Origin)
+	return o
+}
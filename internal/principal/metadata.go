@@ -0,0 +1,81 @@
+package principal
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// Metadata keys used to pass the principal verified by the gateway to the internal services.
+const (
+	userIDKey   = "x-user-id"
+	rolesKey    = "x-user-roles"
+	scopesKey   = "x-api-scopes"
+	modelIDsKey = "x-api-model-ids"
+)
+
+// AppendToOutgoingContext adds p to the metadata of outgoing gRPC calls made with ctx.
+func AppendToOutgoingContext(ctx context.Context, p Principal) context.Context {
+	kv := []string{
+		userIDKey, strconv.FormatInt(p.UserID, 10),
+		rolesKey, strings.Join(p.Roles, ","),
+	}
+	// Restrictions are only sent when present, an empty value restricts to nothing
+	if p.Scopes != nil {
+		kv = append(kv, scopesKey, strings.Join(p.Scopes, ","))
+	}
+	if p.ModelIDs != nil {
+		ids := make([]string, 0, len(p.ModelIDs))
+		for _, id := range p.ModelIDs {
+			ids = append(ids, strconv.FormatInt(id, 10))
+		}
+		kv = append(kv, modelIDsKey, strings.Join(ids, ","))
+	}
+	return metadata.AppendToOutgoingContext(ctx, kv...)
+}
+
+// FromIncomingContext reads the principal from the metadata of an incoming gRPC call.
+func FromIncomingContext(ctx context.Context) (Principal, bool) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return Principal{}, false
+	}
+	values := md.Get(userIDKey)
+	if len(values) != 1 {
+		return Principal{}, false
+	}
+	userID, err := strconv.ParseInt(values[0], 10, 64)
+	if err != nil || userID <= 0 {
+		return Principal{}, false
+	}
+
+	p := Principal{UserID: userID, Roles: splitList(md.Get(rolesKey))}
+	if values := md.Get(scopesKey); len(values) > 0 {
+		p.Scopes = append(make([]string, 0), splitList(values)...)
+	}
+	if values := md.Get(modelIDsKey); len(values) > 0 {
+		p.ModelIDs = make([]int64, 0)
+		for _, value := range splitList(values) {
+			id, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return Principal{}, false
+			}
+			p.ModelIDs = append(p.ModelIDs, id)
+		}
+	}
+	return p, true
+}
+
+func splitList(values []string) []string {
+	var result []string
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item != "" {
+				result = append(result, item)
+			}
+		}
+	}
+	return result
+}
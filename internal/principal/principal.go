@@ -0,0 +1,68 @@
+package principal
+
+import (
+	"context"
+	"time"
+)
+
+// Principal is the authenticated user a request is made on behalf of.
+type Principal struct {
+	UserID    int64
+	Roles     []string
+	ExpiresAt time.Time
+	// Scopes and ModelIDs restrict requests made with an API key, nil means no restriction.
+	Scopes   []string
+	ModelIDs []int64
+}
+
+// AllowsScope reports whether the principal may perform operations of the scope.
+func (p Principal) AllowsScope(scope string) bool {
+	if p.Scopes == nil {
+		return true
+	}
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsModel reports whether the principal may access the model at all.
+func (p Principal) AllowsModel(modelID int64) bool {
+	if p.ModelIDs == nil {
+		return true
+	}
+	for _, id := range p.ModelIDs {
+		if id == modelID {
+			return true
+		}
+	}
+	return false
+}
+
+// HasRole reports whether the principal has the role. Roles are not delegated to API keys.
+func (p Principal) HasRole(role string) bool {
+	if p.Scopes != nil {
+		return false
+	}
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying p.
+func NewContext(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, contextKey{}, p)
+}
+
+// FromContext returns the principal stored in ctx by NewContext.
+func FromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(contextKey{}).(Principal)
+	return p, ok
+}
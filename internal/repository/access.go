@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/AlekSi/pointer"
+	"github.com/Masterminds/squirrel"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"house-of-neural-networks/pkg/db/postgres"
+)
+
+// modelRole is the access level of a user to a model: "owner" of a personal model, the level given by their role
+// in the organization owning the model (owner, maintainer - manage, member - infer) or the explicitly granted
+// permission, whichever is the strongest. Public access is not taken into account.
+const modelRole = "CASE WHEN models.organization_id IS NULL AND models.user_id = ? THEN 'owner' " +
+	"WHEN organization_members.role = 'owner' THEN 'owner' " +
+	"WHEN organization_members.role = 'maintainer' OR model_permissions.permission = 'manage' THEN 'manage' " +
+	"WHEN organization_members.role = 'member' OR model_permissions.permission = 'infer' THEN 'infer' " +
+	"ELSE model_permissions.permission END"
+
+// withModelRole adds the access level of the user to the models of the query as the role column.
+func withModelRole(query squirrel.SelectBuilder, userID int64) squirrel.SelectBuilder {
+	return joinModelAccess(query.Column(squirrel.Expr(modelRole+" AS role", userID)), userID)
+}
+
+// joinModelAccess joins the grants and the organization membership of the user that modelRole is computed from.
+func joinModelAccess(query squirrel.SelectBuilder, userID int64) squirrel.SelectBuilder {
+	return query.
+		LeftJoin("model_permissions ON model_permissions.model_id = models.id AND model_permissions.user_id = ?", userID).
+		LeftJoin("organization_members ON organization_members.organization_id = models.organization_id AND organization_members.user_id = ?", userID)
+}
+
+// getModelRole returns the access level of the user to the model, empty if they have none.
+func getModelRole(ctx context.Context, db *postgres.DB, modelID, userID int64) (string, error) {
+	var role *string
+	err := withModelRole(squirrel.Select(), userID).
+		From("models").
+		Where(squirrel.Eq{"models.id": modelID}).
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(db.Db).
+		QueryRowContext(ctx).
+		Scan(&role)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", status.Error(codes.Internal, fmt.Sprintf("repository.GetRole: %s", err))
+	}
+	return pointer.Get(role), nil
+}
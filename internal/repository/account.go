@@ -0,0 +1,193 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/AlekSi/pointer"
+	"github.com/Masterminds/squirrel"
+	"github.com/lib/pq"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"house-of-neural-networks/internal/models"
+)
+
+// UpdateProfile sets the username and email of the user. The email has to be verified again if it changes.
+func (s *AuthRepository) UpdateProfile(ctx context.Context, user models.User) error {
+	result, err := squirrel.Update("users").
+		Set("username", user.Username).
+		Set("email", user.Email).
+		Set("email_verified_at", squirrel.Expr("CASE WHEN email = ? THEN email_verified_at END", user.Email)).
+		Where(squirrel.Eq{"id": user.ID}).
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(s.db.Db).
+		ExecContext(ctx)
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+		return status.Errorf(codes.AlreadyExists, "repository.UpdateProfile: username %q is taken", user.Username)
+	}
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.UpdateProfile: %s", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.UpdateProfile: %s", err)
+	}
+	if rowsAffected == 0 {
+		return status.Errorf(codes.NotFound, "repository.UpdateProfile: user (id %d) not found", user.ID)
+	}
+	return nil
+}
+
+// ListAccountModels returns the models deleted along with the account of the user: their personal models and
+// the models of the organizations nobody else is a member of.
+func (s *ModelRepository) ListAccountModels(ctx context.Context, userID int64) ([]*models.Model, error) {
+	rows, err := squirrel.Select("id", "name", "user_id", "organization_id").
+		From("models").
+		Where(squirrel.Or{
+			squirrel.Eq{"user_id": userID, "organization_id": nil},
+			squirrel.Expr("organization_id IN ("+soleMemberOrganizations+")", userID, userID),
+		}).
+		OrderBy("id").
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(s.db.Db).
+		QueryContext(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Internal, fmt.Sprintf("repository.ListAccountModels: %s", err.Error()))
+	}
+	defer rows.Close()
+
+	result := make([]*models.Model, 0)
+	for rows.Next() {
+		var model models.Model
+		var organizationID *int64
+		if err = rows.Scan(&model.ID, &model.Name, &model.UserID, &organizationID); err != nil {
+			return nil, status.Error(codes.Internal, fmt.Sprintf("repository.ListAccountModels: %s", err.Error()))
+		}
+		model.OrganizationID = pointer.Get(organizationID)
+		result = append(result, &model)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, status.Error(codes.Internal, fmt.Sprintf("repository.ListAccountModels: %s", err.Error()))
+	}
+	return result, nil
+}
+
+// soleMemberOrganizations selects the organizations the user is the only member of, it takes the user id twice.
+const soleMemberOrganizations = `SELECT organization_id FROM organization_members WHERE user_id = ?
+	AND NOT EXISTS (SELECT 1 FROM organization_members others
+		WHERE others.organization_id = organization_members.organization_id AND others.user_id <> ?)`
+
+// orphanedOwners selects, for every organization the user is the only owner of, the member to become the
+// next owner: a maintainer if there is one, any member otherwise, the earliest registered first. It takes
+// the user id three times.
+const orphanedOwners = `SELECT DISTINCT ON (candidates.organization_id) candidates.organization_id, candidates.user_id
+	FROM organization_members candidates
+	WHERE candidates.user_id <> ?
+		AND candidates.organization_id IN (SELECT organization_id FROM organization_members WHERE user_id = ? AND role = 'owner')
+		AND NOT EXISTS (SELECT 1 FROM organization_members owners
+			WHERE owners.organization_id = candidates.organization_id AND owners.role = 'owner' AND owners.user_id <> ?)
+	ORDER BY candidates.organization_id, candidates.role = 'maintainer' DESC, candidates.user_id`
+
+// DeleteAccount deletes the user with the models listed by ListAccountModels, everything the user wrote in
+// chats and the organizations nobody else is a member of. Organizations that would be left without an owner
+// get a new one, and the models the user uploaded to organizations pass to an owner of the organization.
+// Only users whose deletion has been requested can be deleted, so that the account is locked while its data is
+// removed.
+func (s *ModelRepository) DeleteAccount(ctx context.Context, userID int64, modelIDs []int64) error {
+	tx, err := s.db.Db.BeginTxx(ctx, nil)
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.DeleteAccount: %s", err)
+	}
+	defer tx.Rollback()
+
+	_, err = squirrel.Delete("messages").
+		Where(squirrel.Or{squirrel.Eq{"user_id": userID}, squirrel.Eq{"model_id": modelIDs}}).
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(tx).
+		ExecContext(ctx)
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.DeleteAccount: failed to delete messages: %s", err)
+	}
+
+	_, err = squirrel.Delete("models").
+		Where(squirrel.Eq{"id": modelIDs}).
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(tx).
+		ExecContext(ctx)
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.DeleteAccount: failed to delete models: %s", err)
+	}
+
+	_, err = squirrel.Delete("organizations").
+		Where(squirrel.Expr("id IN ("+soleMemberOrganizations+")", userID, userID)).
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(tx).
+		ExecContext(ctx)
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.DeleteAccount: failed to delete organizations: %s", err)
+	}
+
+	_, err = squirrel.Update("organization_members").
+		Set("role", models.OrgRoleOwner).
+		Where(squirrel.Expr("(organization_id, user_id) IN ("+orphanedOwners+")", userID, userID, userID)).
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(tx).
+		ExecContext(ctx)
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.DeleteAccount: failed to appoint owners: %s", err)
+	}
+
+	_, err = squirrel.Update("models").
+		Set("user_id", squirrel.Expr(`(SELECT owners.user_id FROM organization_members owners
+			WHERE owners.organization_id = models.organization_id AND owners.role = 'owner' AND owners.user_id <> ?
+			ORDER BY owners.user_id LIMIT 1)`, userID)).
+		Where(squirrel.Eq{"user_id": userID}).
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(tx).
+		ExecContext(ctx)
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.DeleteAccount: failed to transfer models: %s", err)
+	}
+
+	result, err := squirrel.Delete("users").
+		Where(squirrel.Eq{"id": userID}).
+		Where(squirrel.NotEq{"deletion_requested_at": nil}).
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(tx).
+		ExecContext(ctx)
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.DeleteAccount: %s", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.DeleteAccount: %s", err)
+	}
+	if rowsAffected == 0 {
+		return status.Errorf(codes.FailedPrecondition, "repository.DeleteAccount: deletion of user (id %d) has not been requested", userID)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return status.Errorf(codes.Internal, "repository.DeleteAccount: %s", err)
+	}
+	return nil
+}
+
+// ListRequestedDeletions returns the ids of the users whose deletion has been requested, oldest request first.
+func (s *ModelRepository) ListRequestedDeletions(ctx context.Context) ([]int64, error) {
+	query, args, err := squirrel.Select("id").
+		From("users").
+		Where(squirrel.NotEq{"deletion_requested_at": nil}).
+		OrderBy("deletion_requested_at").
+		PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "repository.ListRequestedDeletions: %s", err)
+	}
+
+	ids := make([]int64, 0)
+	if err = s.db.Db.SelectContext(ctx, &ids, query, args...); err != nil {
+		return nil, status.Errorf(codes.Internal, "repository.ListRequestedDeletions: %s", err)
+	}
+	return ids, nil
+}
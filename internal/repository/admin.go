@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"github.com/AlekSi/pointer"
+	"github.com/Masterminds/squirrel"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"house-of-neural-networks/internal/models"
+)
+
+// ListAllModels returns the models of every user, or of the given user when userID is set, for administrators.
+func (s *ModelRepository) ListAllModels(ctx context.Context, userID int64) ([]*models.Model, error) {
+	query := squirrel.Select("id", "name", "user_id", "organization_id", "public_permission").
+		From("models").
+		OrderBy("id")
+	if userID != 0 {
+		query = query.Where(squirrel.Eq{"user_id": userID})
+	}
+	rows, err := query.
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(s.db.Db).
+		QueryContext(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Internal, fmt.Sprintf("repository.ListAllModels: %s", err.Error()))
+	}
+	defer rows.Close()
+
+	result := make([]*models.Model, 0)
+	for rows.Next() {
+		var model models.Model
+		var organizationID *int64
+		var publicPermission *string
+		if err = rows.Scan(&model.ID, &model.Name, &model.UserID, &organizationID, &publicPermission); err != nil {
+			return nil, status.Error(codes.Internal, fmt.Sprintf("repository.ListAllModels: %s", err.Error()))
+		}
+		model.OrganizationID = pointer.Get(organizationID)
+		model.PublicPermission = pointer.Get(publicPermission)
+		result = append(result, &model)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, status.Error(codes.Internal, fmt.Sprintf("repository.ListAllModels: %s", err.Error()))
+	}
+	return result, nil
+}
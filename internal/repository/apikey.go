@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/Masterminds/squirrel"
+	"github.com/lib/pq"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"house-of-neural-networks/internal/models"
+)
+
+var apiKeyColumns = []string{"id", "user_id", "name", "prefix", "key_hash", "scopes", "model_ids", "expires_at", "created_at", "last_used_at"}
+
+func (s *AuthRepository) CreateAPIKey(ctx context.Context, key models.APIKey) (*models.APIKey, error) {
+	var modelIDs interface{}
+	if len(key.ModelIDs) > 0 {
+		modelIDs = pq.Array(key.ModelIDs)
+	}
+	err := squirrel.Insert("api_keys").
+		Columns("user_id", "name", "prefix", "key_hash", "scopes", "model_ids", "expires_at").
+		Values(key.UserID, key.Name, key.Prefix, key.KeyHash, pq.Array(key.Scopes), modelIDs, key.ExpiresAt).
+		Suffix("returning id, created_at").
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(s.db.Db).
+		QueryRowContext(ctx).
+		Scan(&key.ID, &key.CreatedAt)
+
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "repository.CreateAPIKey: %s", err)
+	}
+	return &key, nil
+}
+
+// ListAPIKeys returns the keys of the user that have not been revoked.
+func (s *AuthRepository) ListAPIKeys(ctx context.Context, userID int64) ([]models.APIKey, error) {
+	rows, err := squirrel.Select(apiKeyColumns...).
+		From("api_keys").
+		Where(squirrel.Eq{"user_id": userID, "revoked_at": nil}).
+		OrderBy("id").
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(s.db.Db).
+		QueryContext(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "repository.ListAPIKeys: %s", err)
+	}
+	defer rows.Close()
+
+	keys := make([]models.APIKey, 0)
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "repository.ListAPIKeys: %s", err)
+		}
+		keys = append(keys, key)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, status.Errorf(codes.Internal, "repository.ListAPIKeys: %s", err)
+	}
+	return keys, nil
+}
+
+// GetAPIKey returns the key with the hash unless it has been revoked.
+func (s *AuthRepository) GetAPIKey(ctx context.Context, keyHash string) (models.APIKey, error) {
+	// Keys of disabled users are as good as revoked
+	row := squirrel.Select(apiKeyColumns...).
+		From("api_keys").
+		Where(squirrel.Eq{"key_hash": keyHash, "revoked_at": nil}).
+		Where(squirrel.Expr("NOT EXISTS (SELECT 1 FROM users WHERE users.id = api_keys.user_id AND users.disabled_at IS NOT NULL)")).
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(s.db.Db).
+		QueryRowContext(ctx)
+
+	key, err := scanAPIKey(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.APIKey{}, status.Error(codes.NotFound, "repository.GetAPIKey: unknown api key")
+	}
+	if err != nil {
+		return models.APIKey{}, status.Errorf(codes.Internal, "repository.GetAPIKey: %s", err)
+	}
+	return key, nil
+}
+
+func (s *AuthRepository) TouchAPIKey(ctx context.Context, keyID int64) error {
+	_, err := squirrel.Update("api_keys").
+		Set("last_used_at", squirrel.Expr("now()")).
+		Where(squirrel.Eq{"id": keyID}).
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(s.db.Db).
+		ExecContext(ctx)
+
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.TouchAPIKey: %s", err)
+	}
+	return nil
+}
+
+func (s *AuthRepository) RevokeAPIKey(ctx context.Context, userID, keyID int64) error {
+	result, err := squirrel.Update("api_keys").
+		Set("revoked_at", squirrel.Expr("now()")).
+		Where(squirrel.Eq{"id": keyID, "user_id": userID, "revoked_at": nil}).
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(s.db.Db).
+		ExecContext(ctx)
+
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.RevokeAPIKey: %s", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.RevokeAPIKey: %s", err)
+	}
+	if rowsAffected == 0 {
+		return status.Errorf(codes.NotFound, "repository.RevokeAPIKey: api key (id %d) not found", keyID)
+	}
+	return nil
+}
+
+func scanAPIKey(row squirrel.RowScanner) (models.APIKey, error) {
+	var key models.APIKey
+	var scopes pq.StringArray
+	var modelIDs pq.Int64Array
+	err := row.Scan(&key.ID, &key.UserID, &key.Name, &key.Prefix, &key.KeyHash, &scopes, &modelIDs, &key.ExpiresAt, &key.CreatedAt, &key.LastUsedAt)
+	if err != nil {
+		return models.APIKey{}, err
+	}
+	key.Scopes = scopes
+	if len(modelIDs) > 0 {
+		key.ModelIDs = modelIDs
+	}
+	return key, nil
+}
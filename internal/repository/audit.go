@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"github.com/Masterminds/squirrel"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"house-of-neural-networks/internal/models"
+	"house-of-neural-networks/pkg/db/postgres"
+)
+
+// AuditRepository stores the audit log shared by all services. Events are only ever inserted, the table
+// rejects changes.
+type AuditRepository struct {
+	db *postgres.DB
+}
+
+func NewAuditRepository(db *postgres.DB) *AuditRepository {
+	return &AuditRepository{db}
+}
+
+func (s *AuditRepository) RecordAuditEvent(ctx context.Context, event models.AuditEvent) error {
+	_, err := squirrel.Insert("audit_events").
+		Columns("actor_id", "action", "target", "request_id", "client_ip", "outcome").
+		Values(event.ActorID, event.Action, event.Target, event.RequestID, event.ClientIP, event.Outcome).
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(s.db.Db).
+		ExecContext(ctx)
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.RecordAuditEvent: %s", err)
+	}
+	return nil
+}
+
+// ListAuditEvents returns the events matching the filter, newest first.
+func (s *AuditRepository) ListAuditEvents(ctx context.Context, filter models.AuditFilter) ([]models.AuditEvent, error) {
+	query := squirrel.Select("id", "actor_id", "action", "target", "request_id", "client_ip", "outcome", "created_at").
+		From("audit_events").
+		OrderBy("id DESC")
+	if filter.ActorID != 0 {
+		query = query.Where(squirrel.Eq{"actor_id": filter.ActorID})
+	}
+	if filter.Action != "" {
+		query = query.Where(squirrel.Eq{"action": filter.Action})
+	}
+	if !filter.From.IsZero() {
+		query = query.Where(squirrel.GtOrEq{"created_at": filter.From})
+	}
+	if !filter.To.IsZero() {
+		query = query.Where(squirrel.Lt{"created_at": filter.To})
+	}
+	if filter.BeforeID != 0 {
+		query = query.Where(squirrel.Lt{"id": filter.BeforeID})
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+	rows, err := query.
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(s.db.Db).
+		QueryContext(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "repository.ListAuditEvents: %s", err)
+	}
+	defer rows.Close()
+
+	result := make([]models.AuditEvent, 0)
+	for rows.Next() {
+		var event models.AuditEvent
+		err = rows.Scan(&event.ID, &event.ActorID, &event.Action, &event.Target, &event.RequestID, &event.ClientIP, &event.Outcome, &event.CreatedAt)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "repository.ListAuditEvents: %s", err)
+		}
+		result = append(result, event)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, status.Errorf(codes.Internal, "repository.ListAuditEvents: %s", err)
+	}
+	return result, nil
+}
@@ -2,12 +2,16 @@ package repository
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"github.com/Masterminds/squirrel"
+	"github.com/lib/pq"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"house-of-neural-networks/internal/models"
 	"house-of-neural-networks/pkg/db/postgres"
+	"time"
 )
 
 type AuthRepository struct {
@@ -18,42 +22,232 @@ func NewAuthRepository(db *postgres.DB) *AuthRepository {
 	return &AuthRepository{db}
 }
 
-func (s *AuthRepository) CreateUser(ctx context.Context, user models.User) (bool, error) {
-	result, err := squirrel.Insert("users").
+// CreateUser returns the ID of the new user, or 0 when the username is taken.
+func (s *AuthRepository) CreateUser(ctx context.Context, user models.User) (int64, error) {
+	var id int64
+	err := squirrel.Insert("users").
 		Columns("username", "password", "email").
 		Values(user.Username, user.Password, user.Email).
-		Suffix(`ON CONFLICT (username) DO NOTHING`).
+		Suffix(`ON CONFLICT (username) DO NOTHING RETURNING id`).
 		PlaceholderFormat(squirrel.Dollar).
 		RunWith(s.db.Db).
-		ExecContext(ctx)
+		QueryRowContext(ctx).
+		Scan(&id)
 
-	if err != nil {
-		return false, status.Error(codes.Internal, fmt.Sprintf("repository.CreateUser: %s", err.Error()))
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
 	}
-
-	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return false, status.Error(codes.Internal, fmt.Sprintf("repository.CreateUser: %s", err.Error()))
-	}
-
-	if rowsAffected == 0 {
-		return false, nil
+		return 0, status.Error(codes.Internal, fmt.Sprintf("repository.CreateUser: %s", err.Error()))
 	}
-	return true, nil
+	return id, nil
 }
 
 func (s *AuthRepository) GetUser(ctx context.Context, user models.User) (models.User, error) {
 	var result models.User
-	err := squirrel.Select("id", "username", "password").
+	err := squirrel.Select("id", "username", "password", "roles", "disabled_at", "last_login_at", "totp_enabled_at").
 		From("users").
 		Where(squirrel.Eq{"username": user.Username}).
 		PlaceholderFormat(squirrel.Dollar).
 		RunWith(s.db.Db).
 		QueryRowContext(ctx).
-		Scan(&result.ID, &result.Username, &result.Password)
+		Scan(&result.ID, &result.Username, &result.Password, (*pq.StringArray)(&result.Roles), &result.DisabledAt, &result.LastLoginAt, &result.TOTPEnabledAt)
 
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.User{}, status.Errorf(codes.NotFound, "repository.GetUser: user %q not found", user.Username)
+	}
 	if err != nil {
 		return models.User{}, status.Error(codes.Internal, fmt.Sprintf("repository.GetUser: %s", err.Error()))
 	}
 	return result, nil
 }
+
+func (s *AuthRepository) GetUserByID(ctx context.Context, userID int64) (models.User, error) {
+	var result models.User
+	err := squirrel.Select("id", "username", "email", "roles", "disabled_at", "email_verified_at", "last_login_at", "totp_enabled_at").
+		From("users").
+		Where(squirrel.Eq{"id": userID}).
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(s.db.Db).
+		QueryRowContext(ctx).
+		Scan(&result.ID, &result.Username, &result.Email, (*pq.StringArray)(&result.Roles), &result.DisabledAt, &result.EmailVerifiedAt, &result.LastLoginAt, &result.TOTPEnabledAt)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.User{}, status.Errorf(codes.NotFound, "repository.GetUserByID: user (id %d) not found", userID)
+	}
+	if err != nil {
+		return models.User{}, status.Errorf(codes.Internal, "repository.GetUserByID: %s", err)
+	}
+	return result, nil
+}
+
+func (s *AuthRepository) ListUsers(ctx context.Context) ([]models.User, error) {
+	rows, err := squirrel.Select("id", "username", "email", "roles", "disabled_at", "email_verified_at").
+		From("users").
+		OrderBy("id").
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(s.db.Db).
+		QueryContext(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "repository.ListUsers: %s", err)
+	}
+	defer rows.Close()
+
+	users := make([]models.User, 0)
+	for rows.Next() {
+		var user models.User
+		if err = rows.Scan(&user.ID, &user.Username, &user.Email, (*pq.StringArray)(&user.Roles), &user.DisabledAt, &user.EmailVerifiedAt); err != nil {
+			return nil, status.Errorf(codes.Internal, "repository.ListUsers: %s", err)
+		}
+		users = append(users, user)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, status.Errorf(codes.Internal, "repository.ListUsers: %s", err)
+	}
+	return users, nil
+}
+
+// SetUserDisabled disables or re-enables the user. Disabling revokes every refresh token of the user
+// so that no new access token can be issued.
+func (s *AuthRepository) SetUserDisabled(ctx context.Context, userID int64, disabled bool) error {
+	update := squirrel.Update("users").Where(squirrel.Eq{"id": userID})
+	if disabled {
+		update = update.Set("disabled_at", squirrel.Expr("COALESCE(disabled_at, now())"))
+	} else {
+		update = update.Set("disabled_at", nil)
+	}
+	return s.updateUser(ctx, "repository.SetUserDisabled", userID, update, disabled)
+}
+
+// RequestAccountDeletion disables the user and marks the account for deletion, the model service deletes it
+// with its data afterwards, retrying until it succeeds.
+func (s *AuthRepository) RequestAccountDeletion(ctx context.Context, userID int64) error {
+	update := squirrel.Update("users").
+		Set("disabled_at", squirrel.Expr("COALESCE(disabled_at, now())")).
+		Set("deletion_requested_at", squirrel.Expr("COALESCE(deletion_requested_at, now())")).
+		Where(squirrel.Eq{"id": userID})
+	return s.updateUser(ctx, "repository.RequestAccountDeletion", userID, update, true)
+}
+
+// updateUser runs the update of the user, revoking every refresh token of the user in the same transaction
+// if revokeTokens is set.
+func (s *AuthRepository) updateUser(ctx context.Context, method string, userID int64, update squirrel.UpdateBuilder, revokeTokens bool) error {
+	tx, err := s.db.Db.BeginTxx(ctx, nil)
+	if err != nil {
+		return status.Errorf(codes.Internal, "%s: %s", method, err)
+	}
+	defer tx.Rollback()
+
+	result, err := update.
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(tx).
+		ExecContext(ctx)
+	if err != nil {
+		return status.Errorf(codes.Internal, "%s: %s", method, err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return status.Errorf(codes.Internal, "%s: %s", method, err)
+	}
+	if rowsAffected == 0 {
+		return status.Errorf(codes.NotFound, "%s: user (id %d) not found", method, userID)
+	}
+
+	if revokeTokens {
+		_, err = squirrel.Update("refresh_tokens").
+			Set("revoked_at", squirrel.Expr("now()")).
+			Where(squirrel.Eq{"user_id": userID, "revoked_at": nil}).
+			PlaceholderFormat(squirrel.Dollar).
+			RunWith(tx).
+			ExecContext(ctx)
+		if err != nil {
+			return status.Errorf(codes.Internal, "%s: %s", method, err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return status.Errorf(codes.Internal, "%s: %s", method, err)
+	}
+	return nil
+}
+
+func (s *AuthRepository) SetUserRoles(ctx context.Context, userID int64, roles []string) error {
+	result, err := squirrel.Update("users").
+		Set("roles", pq.StringArray(roles)).
+		Where(squirrel.Eq{"id": userID}).
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(s.db.Db).
+		ExecContext(ctx)
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.SetUserRoles: %s", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.SetUserRoles: %s", err)
+	}
+	if rowsAffected == 0 {
+		return status.Errorf(codes.NotFound, "repository.SetUserRoles: user (id %d) not found", userID)
+	}
+	return nil
+}
+
+// GrantRole adds the role to the users with the given usernames that do not have it yet.
+func (s *AuthRepository) GrantRole(ctx context.Context, usernames []string, role string) error {
+	_, err := squirrel.Update("users").
+		Set("roles", squirrel.Expr("array_append(roles, ?)", role)).
+		Where(squirrel.Eq{"username": usernames}).
+		Where(squirrel.Expr("NOT (? = ANY(roles))", role)).
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(s.db.Db).
+		ExecContext(ctx)
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.GrantRole: %s", err)
+	}
+	return nil
+}
+
+// ListTokensValidAfter returns the time access tokens of a user are valid after, for the users who changed
+// their password after since.
+func (s *AuthRepository) ListTokensValidAfter(ctx context.Context, since time.Time) (map[int64]time.Time, error) {
+	rows, err := squirrel.Select("id", "tokens_valid_after").
+		From("users").
+		Where(squirrel.Gt{"tokens_valid_after": since}).
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(s.db.Db).
+		QueryContext(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "repository.ListTokensValidAfter: %s", err)
+	}
+	defer rows.Close()
+
+	validAfter := make(map[int64]time.Time)
+	for rows.Next() {
+		var userID int64
+		var after time.Time
+		if err = rows.Scan(&userID, &after); err != nil {
+			return nil, status.Errorf(codes.Internal, "repository.ListTokensValidAfter: %s", err)
+		}
+		validAfter[userID] = after
+	}
+	if err = rows.Err(); err != nil {
+		return nil, status.Errorf(codes.Internal, "repository.ListTokensValidAfter: %s", err)
+	}
+	return validAfter, nil
+}
+
+// ListDisabledUsers returns the ids of the users disabled after since.
+func (s *AuthRepository) ListDisabledUsers(ctx context.Context, since time.Time) ([]int64, error) {
+	query, args, err := squirrel.Select("id").
+		From("users").
+		Where(squirrel.Gt{"disabled_at": since}).
+		PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "repository.ListDisabledUsers: %s", err)
+	}
+
+	ids := make([]int64, 0)
+	if err = s.db.Db.SelectContext(ctx, &ids, query, args...); err != nil {
+		return nil, status.Errorf(codes.Internal, "repository.ListDisabledUsers: %s", err)
+	}
+	return ids, nil
+}
@@ -0,0 +1,163 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/Masterminds/squirrel"
+	"github.com/lib/pq"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"house-of-neural-networks/internal/models"
+	"time"
+)
+
+// CreateEmailToken stores the token, replacing earlier tokens of the user for the same purpose so that only
+// the latest email works. Expired tokens are dropped on the way.
+func (s *AuthRepository) CreateEmailToken(ctx context.Context, token models.EmailToken) error {
+	tx, err := s.db.Db.BeginTxx(ctx, nil)
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.CreateEmailToken: %s", err)
+	}
+	defer tx.Rollback()
+
+	_, err = squirrel.Delete("email_tokens").
+		Where(squirrel.Or{
+			squirrel.Lt{"expires_at": time.Now()},
+			squirrel.Eq{"user_id": token.UserID, "purpose": token.Purpose},
+		}).
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(tx).
+		ExecContext(ctx)
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.CreateEmailToken: %s", err)
+	}
+
+	_, err = squirrel.Insert("email_tokens").
+		Columns("token_hash", "user_id", "purpose", "email", "expires_at").
+		Values(token.TokenHash, token.UserID, token.Purpose, token.Email, token.ExpiresAt).
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(tx).
+		ExecContext(ctx)
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.CreateEmailToken: %s", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return status.Errorf(codes.Internal, "repository.CreateEmailToken: %s", err)
+	}
+	return nil
+}
+
+// TakeEmailToken removes the token and returns it, so that a token can be used once.
+func (s *AuthRepository) TakeEmailToken(ctx context.Context, tokenHash, purpose string) (models.EmailToken, error) {
+	result := models.EmailToken{TokenHash: tokenHash, Purpose: purpose}
+	err := squirrel.Delete("email_tokens").
+		Where(squirrel.Eq{"token_hash": tokenHash, "purpose": purpose}).
+		Suffix("RETURNING user_id, email, expires_at").
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(s.db.Db).
+		QueryRowContext(ctx).
+		Scan(&result.UserID, &result.Email, &result.ExpiresAt)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.EmailToken{}, status.Error(codes.NotFound, "repository.TakeEmailToken: unknown token")
+	}
+	if err != nil {
+		return models.EmailToken{}, status.Errorf(codes.Internal, "repository.TakeEmailToken: %s", err)
+	}
+	return result, nil
+}
+
+// SetEmailVerified marks the email of the user as verified, unless it has been changed since it was sent to.
+func (s *AuthRepository) SetEmailVerified(ctx context.Context, userID int64, email string) error {
+	result, err := squirrel.Update("users").
+		Set("email_verified_at", squirrel.Expr("COALESCE(email_verified_at, now())")).
+		Where(squirrel.Eq{"id": userID, "email": email}).
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(s.db.Db).
+		ExecContext(ctx)
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.SetEmailVerified: %s", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.SetEmailVerified: %s", err)
+	}
+	if rowsAffected == 0 {
+		return status.Errorf(codes.NotFound, "repository.SetEmailVerified: user (id %d) with this email not found", userID)
+	}
+	return nil
+}
+
+// ListUsersByEmail returns the enabled users registered with the email, compared case-insensitively.
+func (s *AuthRepository) ListUsersByEmail(ctx context.Context, email string) ([]models.User, error) {
+	rows, err := squirrel.Select("id", "username", "email", "roles", "disabled_at", "email_verified_at").
+		From("users").
+		Where(squirrel.Expr("lower(email) = lower(?)", email)).
+		Where(squirrel.Eq{"disabled_at": nil}).
+		OrderBy("id").
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(s.db.Db).
+		QueryContext(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "repository.ListUsersByEmail: %s", err)
+	}
+	defer rows.Close()
+
+	users := make([]models.User, 0)
+	for rows.Next() {
+		var user models.User
+		if err = rows.Scan(&user.ID, &user.Username, &user.Email, (*pq.StringArray)(&user.Roles), &user.DisabledAt, &user.EmailVerifiedAt); err != nil {
+			return nil, status.Errorf(codes.Internal, "repository.ListUsersByEmail: %s", err)
+		}
+		users = append(users, user)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, status.Errorf(codes.Internal, "repository.ListUsersByEmail: %s", err)
+	}
+	return users, nil
+}
+
+// SetPassword replaces the password hash of the user and ends the sessions started with the old password:
+// every refresh token of the user is revoked and access tokens issued before tokensValidAfter are rejected.
+func (s *AuthRepository) SetPassword(ctx context.Context, userID int64, passwordHash string, tokensValidAfter time.Time) error {
+	tx, err := s.db.Db.BeginTxx(ctx, nil)
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.SetPassword: %s", err)
+	}
+	defer tx.Rollback()
+
+	result, err := squirrel.Update("users").
+		Set("password", passwordHash).
+		Set("tokens_valid_after", tokensValidAfter).
+		Where(squirrel.Eq{"id": userID}).
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(tx).
+		ExecContext(ctx)
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.SetPassword: %s", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.SetPassword: %s", err)
+	}
+	if rowsAffected == 0 {
+		return status.Errorf(codes.NotFound, "repository.SetPassword: user (id %d) not found", userID)
+	}
+
+	_, err = squirrel.Update("refresh_tokens").
+		Set("revoked_at", squirrel.Expr("now()")).
+		Where(squirrel.Eq{"user_id": userID, "revoked_at": nil}).
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(tx).
+		ExecContext(ctx)
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.SetPassword: %s", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return status.Errorf(codes.Internal, "repository.SetPassword: %s", err)
+	}
+	return nil
+}
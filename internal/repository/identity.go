@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/Masterminds/squirrel"
+	"github.com/lib/pq"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"house-of-neural-networks/internal/models"
+	"time"
+)
+
+// CreateOIDCState stores a pending authorization request. Requests that were never completed are dropped
+// on the way.
+func (s *AuthRepository) CreateOIDCState(ctx context.Context, state models.OIDCState) error {
+	_, err := squirrel.Delete("oidc_states").
+		Where(squirrel.Lt{"expires_at": time.Now()}).
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(s.db.Db).
+		ExecContext(ctx)
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.CreateOIDCState: %s", err)
+	}
+
+	_, err = squirrel.Insert("oidc_states").
+		Columns("state", "nonce", "code_verifier", "expires_at").
+		Values(state.State, state.Nonce, state.CodeVerifier, state.ExpiresAt).
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(s.db.Db).
+		ExecContext(ctx)
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.CreateOIDCState: %s", err)
+	}
+	return nil
+}
+
+// TakeOIDCState removes the pending authorization request and returns it, so that a state can be used once.
+func (s *AuthRepository) TakeOIDCState(ctx context.Context, state string) (models.OIDCState, error) {
+	result := models.OIDCState{State: state}
+	err := squirrel.Delete("oidc_states").
+		Where(squirrel.Eq{"state": state}).
+		Suffix("RETURNING nonce, code_verifier, expires_at").
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(s.db.Db).
+		QueryRowContext(ctx).
+		Scan(&result.Nonce, &result.CodeVerifier, &result.ExpiresAt)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.OIDCState{}, status.Error(codes.NotFound, "repository.TakeOIDCState: unknown state")
+	}
+	if err != nil {
+		return models.OIDCState{}, status.Errorf(codes.Internal, "repository.TakeOIDCState: %s", err)
+	}
+	return result, nil
+}
+
+// GetUserByIdentity returns the user linked to the account at the provider.
+func (s *AuthRepository) GetUserByIdentity(ctx context.Context, issuer, subject string) (models.User, error) {
+	var result models.User
+	err := squirrel.Select("users.id", "users.username", "users.email", "users.roles", "users.disabled_at", "users.totp_enabled_at").
+		From("user_identities").
+		Join("users ON users.id = user_identities.user_id").
+		Where(squirrel.Eq{"user_identities.issuer": issuer, "user_identities.subject": subject}).
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(s.db.Db).
+		QueryRowContext(ctx).
+		Scan(&result.ID, &result.Username, &result.Email, (*pq.StringArray)(&result.Roles), &result.DisabledAt, &result.TOTPEnabledAt)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.User{}, status.Error(codes.NotFound, "repository.GetUserByIdentity: unknown identity")
+	}
+	if err != nil {
+		return models.User{}, status.Errorf(codes.Internal, "repository.GetUserByIdentity: %s", err)
+	}
+	return result, nil
+}
+
+// CreateIdentityUser creates a user linked to the account at the provider. It fails with AlreadyExists when
+// the username is taken and with Aborted when the account has been linked in the meantime.
+func (s *AuthRepository) CreateIdentityUser(ctx context.Context, user models.User, identity models.Identity) (int64, error) {
+	tx, err := s.db.Db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, status.Errorf(codes.Internal, "repository.CreateIdentityUser: %s", err)
+	}
+	defer tx.Rollback()
+
+	var userID int64
+	err = squirrel.Insert("users").
+		Columns("username", "password", "email", "email_verified_at").
+		Values(user.Username, user.Password, user.Email, user.EmailVerifiedAt).
+		Suffix("ON CONFLICT (username) DO NOTHING RETURNING id").
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(tx).
+		QueryRowContext(ctx).
+		Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, status.Error(codes.AlreadyExists, "repository.CreateIdentityUser: username is taken")
+	}
+	if err != nil {
+		return 0, status.Errorf(codes.Internal, "repository.CreateIdentityUser: %s", err)
+	}
+
+	_, err = squirrel.Insert("user_identities").
+		Columns("user_id", "issuer", "subject", "email").
+		Values(userID, identity.Issuer, identity.Subject, identity.Email).
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(tx).
+		ExecContext(ctx)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+		return 0, status.Error(codes.Aborted, "repository.CreateIdentityUser: identity is already linked")
+	}
+	if err != nil {
+		return 0, status.Errorf(codes.Internal, "repository.CreateIdentityUser: %s", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, status.Errorf(codes.Internal, "repository.CreateIdentityUser: %s", err)
+	}
+	return userID, nil
+}
@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"context"
+	"github.com/Masterminds/squirrel"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"house-of-neural-networks/internal/models"
+	"time"
+)
+
+func (s *AuthRepository) RecordLoginFailure(ctx context.Context, failure models.LoginFailure) error {
+	_, err := squirrel.Insert("login_failures").
+		Columns("username", "user_id", "client_ip", "reason").
+		Values(failure.Username, failure.UserID, failure.ClientIP, failure.Reason).
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(s.db.Db).
+		ExecContext(ctx)
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.RecordLoginFailure: %s", err)
+	}
+	return nil
+}
+
+// CountLoginFailures counts the failed logins made after since, those of the account only after accountSince.
+// Locked attempts are not counted.
+func (s *AuthRepository) CountLoginFailures(ctx context.Context, username, clientIP string, since, accountSince time.Time) (account, client models.LoginFailureCount, err error) {
+	err = squirrel.Select().
+		Column(squirrel.Expr("count(*) FILTER (WHERE username = ? AND created_at > ?)", username, accountSince)).
+		Column(squirrel.Expr("max(created_at) FILTER (WHERE username = ? AND created_at > ?)", username, accountSince)).
+		Column(squirrel.Expr("count(*) FILTER (WHERE client_ip = ?)", clientIP)).
+		Column(squirrel.Expr("max(created_at) FILTER (WHERE client_ip = ?)", clientIP)).
+		From("login_failures").
+		Where(squirrel.Gt{"created_at": since}).
+		Where(squirrel.NotEq{"reason": models.LoginLocked}).
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(s.db.Db).
+		QueryRowContext(ctx).
+		Scan(&account.Count, &account.Last, &client.Count, &client.Last)
+	if err != nil {
+		return models.LoginFailureCount{}, models.LoginFailureCount{}, status.Errorf(codes.Internal, "repository.CountLoginFailures: %s", err)
+	}
+	return account, client, nil
+}
+
+// SetLastLogin records a successful login, which resets the failures counted against the account.
+func (s *AuthRepository) SetLastLogin(ctx context.Context, userID int64) error {
+	_, err := squirrel.Update("users").
+		Set("last_login_at", squirrel.Expr("now()")).
+		Where(squirrel.Eq{"id": userID}).
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(s.db.Db).
+		ExecContext(ctx)
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.SetLastLogin: %s", err)
+	}
+	return nil
+}
@@ -3,9 +3,11 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/AlekSi/pointer"
 	"github.com/Masterminds/squirrel"
-	"github.com/lib/pq"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"house-of-neural-networks/internal/models"
@@ -20,31 +22,84 @@ func NewMessageRepository(db *postgres.DB) *MessageRepository {
 	return &MessageRepository{db: db}
 }
 
+// Tensors larger than inlineTensorLimit bytes are moved out of the message row into message_blobs
+const inlineTensorLimit = 64 << 10
+
+const (
+	directionInput  = "input"
+	directionOutput = "output"
+)
+
+type messageBlob struct {
+	direction string
+	name      string
+	data      []byte
+}
+
 func (r *MessageRepository) SaveMessage(ctx context.Context, msg models.Message) error {
-	result, err := squirrel.Insert("messages").Columns("user_id", "model_id", "version_id", "input1", "input2", "results", "created_at").
-		Values(msg.UserID, msg.ModelID, msg.VersionID, msg.Input1, msg.Input2, pq.Array(msg.Results), msg.CreatedAt).
-		PlaceholderFormat(squirrel.Dollar).RunWith(r.db.Db).ExecContext(ctx)
+	inputs, blobs := offloadTensors(directionInput, msg.Inputs, nil)
+	outputs, blobs := offloadTensors(directionOutput, msg.Outputs, blobs)
+	inputsJson, err := json.Marshal(inputs)
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.SaveMessage: %s", err)
+	}
+	outputsJson, err := json.Marshal(outputs)
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.SaveMessage: %s", err)
+	}
 
+	tx, err := r.db.Db.BeginTxx(ctx, nil)
 	if err != nil {
 		return status.Errorf(codes.Internal, "repository.SaveMessage: %s", err)
 	}
+	defer tx.Rollback()
 
-	rowsAffected, err := result.RowsAffected()
+	var id int64
+	err = squirrel.Insert("messages").Columns("user_id", "model_id", "version_id", "inputs", "outputs", "created_at").
+		Values(msg.UserID, msg.ModelID, msg.VersionID, inputsJson, outputsJson, msg.CreatedAt).
+		Suffix("RETURNING id").
+		PlaceholderFormat(squirrel.Dollar).RunWith(tx).QueryRowContext(ctx).Scan(&id)
 	if err != nil {
 		return status.Errorf(codes.Internal, "repository.SaveMessage: %s", err)
 	}
 
-	if rowsAffected == 0 {
-		return status.Error(codes.Internal, "repository.SaveMessage: Can't insert row")
+	if len(blobs) > 0 {
+		query := squirrel.Insert("message_blobs").Columns("message_id", "direction", "name", "data")
+		for _, blob := range blobs {
+			query = query.Values(id, blob.direction, blob.name, blob.data)
+		}
+		if _, err = query.PlaceholderFormat(squirrel.Dollar).RunWith(tx).ExecContext(ctx); err != nil {
+			return status.Errorf(codes.Internal, "repository.SaveMessage: failed to save blobs: %s", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return status.Errorf(codes.Internal, "repository.SaveMessage: %s", err)
 	}
 	return nil
 }
 
-func (r *MessageRepository) GetMessages(ctx context.Context, userID, modelID int64) ([]models.Message, error) {
-	rows, err := squirrel.Select("id", "user_id", "model_id", "version_id", "input1", "input2", "results", "created_at").
+func (r *MessageRepository) GetMessages(ctx context.Context, page models.MessagePage) ([]models.Message, error) {
+	order := "ASC"
+	if page.Descending {
+		order = "DESC"
+	}
+	query := squirrel.Select("id", "user_id", "model_id", "version_id", "inputs", "outputs", "created_at").
 		From("messages").
-		Where(squirrel.And{squirrel.Eq{"user_id": userID}, squirrel.Eq{"model_id": modelID}}).
-		PlaceholderFormat(squirrel.Dollar).RunWith(r.db.Db).QueryContext(ctx)
+		Where(messageFilter(page.Filter)).
+		OrderBy("created_at "+order, "id "+order)
+	if page.After != nil {
+		// Row value comparison keeps the (created_at, id) index usable
+		if page.Descending {
+			query = query.Where("(created_at, id) < (?, ?)", page.After.CreatedAt, page.After.ID)
+		} else {
+			query = query.Where("(created_at, id) > (?, ?)", page.After.CreatedAt, page.After.ID)
+		}
+	}
+	if page.Limit > 0 {
+		query = query.Limit(page.Limit)
+	}
+	rows, err := query.PlaceholderFormat(squirrel.Dollar).RunWith(r.db.Db).QueryContext(ctx)
 
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "repository.GetMessages: %s", err)
@@ -59,12 +114,10 @@ func (r *MessageRepository) GetMessages(ctx context.Context, userID, modelID int
 	var result []models.Message
 
 	for rows.Next() {
-		var msg models.Message
-		var results pq.StringArray
-		if err = rows.Scan(&msg.ID, &msg.UserID, &msg.ModelID, &msg.VersionID, &msg.Input1, &msg.Input2, &results, &msg.CreatedAt); err != nil {
+		msg, err := scanMessage(rows)
+		if err != nil {
 			return nil, status.Errorf(codes.Internal, "repository.GetMessages: %s", err)
 		}
-		msg.Results = results
 		result = append(result, msg)
 	}
 
@@ -72,38 +125,234 @@ func (r *MessageRepository) GetMessages(ctx context.Context, userID, modelID int
 		return nil, status.Errorf(codes.Internal, "repository.GetMessages: %s", err)
 	}
 
+	if err = r.loadBlobs(ctx, result); err != nil {
+		return nil, status.Errorf(codes.Internal, "repository.GetMessages: %s", err)
+	}
+
 	return result, nil
 }
 
-func (s *MessageRepository) GetModelName(ctx context.Context, model models.Model) (string, error) {
-	var name string
-	err := squirrel.Select("name").
+func (r *MessageRepository) CountMessages(ctx context.Context, filter models.MessageFilter) (int64, error) {
+	var count int64
+	err := squirrel.Select("COUNT(*)").
+		From("messages").
+		Where(messageFilter(filter)).
+		PlaceholderFormat(squirrel.Dollar).RunWith(r.db.Db).QueryRowContext(ctx).Scan(&count)
+	if err != nil {
+		return 0, status.Errorf(codes.Internal, "repository.CountMessages: %s", err)
+	}
+	return count, nil
+}
+
+// DeleteMessage removes a message of the user. Unless modelIDs is nil, only messages sent to those models are removed.
+func (r *MessageRepository) DeleteMessage(ctx context.Context, userID, messageID int64, modelIDs []int64) error {
+	conditions := squirrel.And{squirrel.Eq{"id": messageID}, squirrel.Eq{"user_id": userID}}
+	if modelIDs != nil {
+		conditions = append(conditions, squirrel.Eq{"model_id": modelIDs})
+	}
+	result, err := squirrel.Delete("messages").
+		Where(conditions).
+		PlaceholderFormat(squirrel.Dollar).RunWith(r.db.Db).ExecContext(ctx)
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.DeleteMessage: %s", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.DeleteMessage: %s", err)
+	}
+	if affected == 0 {
+		return status.Errorf(codes.NotFound, "repository.DeleteMessage: message %d not found", messageID)
+	}
+	return nil
+}
+
+// DeleteMessages removes every message matching the filter, blobs are removed by cascade.
+func (r *MessageRepository) DeleteMessages(ctx context.Context, filter models.MessageFilter) (int64, error) {
+	result, err := squirrel.Delete("messages").
+		Where(messageFilter(filter)).
+		PlaceholderFormat(squirrel.Dollar).RunWith(r.db.Db).ExecContext(ctx)
+	if err != nil {
+		return 0, status.Errorf(codes.Internal, "repository.DeleteMessages: %s", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, status.Errorf(codes.Internal, "repository.DeleteMessages: %s", err)
+	}
+	return affected, nil
+}
+
+// ListChats groups messages of the user by model version, most recently active first.
+// Chats with models the user no longer has access to, e.g. after leaving an organization, are left out.
+func (r *MessageRepository) ListChats(ctx context.Context, userID int64) ([]models.Chat, error) {
+	chatsQuery := squirrel.Select(
+		"m.model_id", "models.name AS model_name", "m.version_id", "v.number AS version_number",
+		"MAX(m.created_at) AS last_message_at", "COUNT(*) AS message_count").
+		From("messages m").
+		Join("models ON models.id = m.model_id").
+		Join("versions v ON v.id = m.version_id")
+	query, args, err := joinModelAccess(chatsQuery, userID).
+		Where(squirrel.Eq{"m.user_id": userID}).
+		Where(squirrel.Expr("COALESCE("+modelRole+", models.public_permission) IS NOT NULL", userID)).
+		GroupBy("m.model_id", "models.name", "m.version_id", "v.number").
+		OrderBy("last_message_at DESC").
+		PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "repository.ListChats: %s", err)
+	}
+
+	chats := make([]models.Chat, 0)
+	if err = r.db.Db.SelectContext(ctx, &chats, query, args...); err != nil {
+		return nil, status.Errorf(codes.Internal, "repository.ListChats: %s", err)
+	}
+	return chats, nil
+}
+
+func messageFilter(filter models.MessageFilter) squirrel.And {
+	conditions := squirrel.And{squirrel.Eq{"user_id": filter.UserID}, squirrel.Eq{"model_id": filter.ModelID}}
+	if filter.VersionID != 0 {
+		conditions = append(conditions, squirrel.Eq{"version_id": filter.VersionID})
+	}
+	if !filter.From.IsZero() {
+		conditions = append(conditions, squirrel.GtOrEq{"created_at": filter.From})
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, squirrel.Lt{"created_at": filter.To})
+	}
+	return conditions
+}
+
+func scanMessage(rows squirrel.RowScanner) (models.Message, error) {
+	var msg models.Message
+	var inputs, outputs []byte
+	if err := rows.Scan(&msg.ID, &msg.UserID, &msg.ModelID, &msg.VersionID, &inputs, &outputs, &msg.CreatedAt); err != nil {
+		return models.Message{}, err
+	}
+	if err := json.Unmarshal(inputs, &msg.Inputs); err != nil {
+		return models.Message{}, fmt.Errorf("invalid inputs of message %d: %w", msg.ID, err)
+	}
+	if err := json.Unmarshal(outputs, &msg.Outputs); err != nil {
+		return models.Message{}, fmt.Errorf("invalid outputs of message %d: %w", msg.ID, err)
+	}
+	return msg, nil
+}
+
+// loadBlobs fills in the contents of external tensors.
+func (r *MessageRepository) loadBlobs(ctx context.Context, messages []models.Message) error {
+	ids := make([]int64, 0)
+	for _, msg := range messages {
+		for _, tensor := range append(msg.Inputs, msg.Outputs...) {
+			if tensor.External {
+				ids = append(ids, msg.ID)
+				break
+			}
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	rows, err := squirrel.Select("message_id", "direction", "name", "data").
+		From("message_blobs").
+		Where(squirrel.Eq{"message_id": ids}).
+		PlaceholderFormat(squirrel.Dollar).RunWith(r.db.Db).QueryContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load blobs: %w", err)
+	}
+	defer rows.Close()
+
+	type blobKey struct {
+		messageID int64
+		direction string
+		name      string
+	}
+	blobs := make(map[blobKey][]byte)
+	for rows.Next() {
+		var key blobKey
+		var data []byte
+		if err = rows.Scan(&key.messageID, &key.direction, &key.name, &data); err != nil {
+			return fmt.Errorf("failed to load blobs: %w", err)
+		}
+		blobs[key] = data
+	}
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("failed to load blobs: %w", err)
+	}
+
+	for _, msg := range messages {
+		for direction, tensors := range map[string][]models.TensorDescriptor{directionInput: msg.Inputs, directionOutput: msg.Outputs} {
+			for i := range tensors {
+				if !tensors[i].External {
+					continue
+				}
+				data, ok := blobs[blobKey{msg.ID, direction, tensors[i].Name}]
+				if !ok {
+					return fmt.Errorf("blob of %s %s of message %d not found", direction, tensors[i].Name, msg.ID)
+				}
+				tensors[i].Data = data
+				tensors[i].External = false
+			}
+		}
+	}
+	return nil
+}
+
+// offloadTensors strips large contents from the descriptors and appends them to blobs.
+func offloadTensors(direction string, tensors []models.TensorDescriptor, blobs []messageBlob) ([]models.TensorDescriptor, []messageBlob) {
+	result := make([]models.TensorDescriptor, 0, len(tensors))
+	for _, tensor := range tensors {
+		if len(tensor.Data) > inlineTensorLimit {
+			blobs = append(blobs, messageBlob{direction: direction, name: tensor.Name, data: tensor.Data})
+			tensor.Data = nil
+			tensor.External = true
+		}
+		result = append(result, tensor)
+	}
+	return result, blobs
+}
+
+func (s *MessageRepository) GetModel(ctx context.Context, model models.Model) (*models.Model, error) {
+	var result models.Model
+	var organizationID *int64
+	var publicPermission *string
+	err := squirrel.Select("id", "name", "user_id", "organization_id", "public_permission").
 		From("models").
 		Where(squirrel.Eq{"id": model.ID}).
 		PlaceholderFormat(squirrel.Dollar).
 		RunWith(s.db.Db).
-		QueryRow().
-		Scan(&name)
+		QueryRowContext(ctx).
+		Scan(&result.ID, &result.Name, &result.UserID, &organizationID, &publicPermission)
 
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, status.Error(codes.NotFound, fmt.Sprintf("repository.GetModel: model (id %d) not found", model.ID))
+	}
 	if err != nil {
-		return "", status.Error(codes.Internal, fmt.Sprintf("repository.GetModelName: %s", err))
+		return nil, status.Error(codes.Internal, fmt.Sprintf("repository.GetModel: %s", err))
 	}
+	result.OrganizationID = pointer.Get(organizationID)
+	result.PublicPermission = pointer.Get(publicPermission)
 
-	return name, nil
+	return &result, nil
 }
 
-func (s *MessageRepository) GetVersionNumber(ctx context.Context, version models.Version) (int, error) {
-	var number int
-	err := squirrel.Select("number").
+func (s *MessageRepository) GetVersion(ctx context.Context, version models.Version) (*models.Version, error) {
+	var result models.Version
+	err := squirrel.Select("id", "number", "model_id").
 		From("versions").
 		Where(squirrel.Eq{"id": version.ID}).
 		PlaceholderFormat(squirrel.Dollar).
 		RunWith(s.db.Db).
-		QueryRow().
-		Scan(&number)
+		QueryRowContext(ctx).
+		Scan(&result.ID, &result.Number, &result.ModelID)
 
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, status.Error(codes.NotFound, fmt.Sprintf("repository.GetVersion: version (id %d) not found", version.ID))
+	}
 	if err != nil {
-		return number, status.Error(codes.Internal, fmt.Sprintf("repository.GetModelName: %s", err))
+		return nil, status.Error(codes.Internal, fmt.Sprintf("repository.GetVersion: %s", err))
 	}
-	return number, nil
+	return &result, nil
+}
+
+func (s *MessageRepository) GetRole(ctx context.Context, modelID, userID int64) (string, error) {
+	return getModelRole(ctx, s.db, modelID, userID)
 }
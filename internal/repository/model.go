@@ -2,14 +2,21 @@ package repository
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"github.com/AlekSi/pointer"
 	"github.com/Masterminds/squirrel"
+	"github.com/lib/pq"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"house-of-neural-networks/internal/models"
 	"house-of-neural-networks/pkg/db/postgres"
 )
 
+// foreignKeyViolation is the Postgres error code of a missing referenced row
+const foreignKeyViolation = "23503"
+
 type ModelRepository struct {
 	db *postgres.DB
 }
@@ -20,24 +27,30 @@ func NewModelRepository(db *postgres.DB) *ModelRepository {
 
 func (s *ModelRepository) CreateModel(ctx context.Context, model models.Model) (*models.Model, error) {
 	var result models.Model
+	var organizationID *int64
 	err := squirrel.Insert("models").
-		Columns("name", "user_id").
-		Values(model.Name, model.UserID).
-		Suffix("returning *").
+		Columns("name", "user_id", "organization_id").
+		Values(model.Name, model.UserID, nullableID(model.OrganizationID)).
+		Suffix("returning id, name, user_id, organization_id").
 		PlaceholderFormat(squirrel.Dollar).
 		RunWith(s.db.Db).
 		QueryRowContext(ctx).
-		Scan(&result.ID, &result.Name, &result.UserID)
+		Scan(&result.ID, &result.Name, &result.UserID, &organizationID)
 
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+		return nil, status.Errorf(codes.AlreadyExists, "repository.CreateModel: model %q already exists", model.Name)
+	}
 	if err != nil {
 		return nil, status.Error(codes.Internal, fmt.Sprintf("repository.CreateModel: %s", err.Error()))
 	}
+	result.OrganizationID = pointer.Get(organizationID)
 
 	return &result, nil
 }
 
 func (s *ModelRepository) GetModel(ctx context.Context, model models.Model) (*models.Model, error) {
-	rows, err := squirrel.Select("models.id", "models.name", "models.user_id", "versions.id as version_id", "versions.number as version_number", "versions.model_id as version_model_id").
+	rows, err := squirrel.Select("models.id", "models.name", "models.user_id", "models.organization_id", "models.public_permission", "versions.id as version_id", "versions.number as version_number", "versions.model_id as version_model_id").
 		From("models").
 		LeftJoin("versions ON models.id = versions.model_id").
 		Where(squirrel.Eq{"models.id": model.ID}).
@@ -56,9 +69,13 @@ func (s *ModelRepository) GetModel(ctx context.Context, model models.Model) (*mo
 		var version models.Version
 		var versionID, VersionModelId *int64
 		var versionNumber *int32
-		if err = rows.Scan(&result.ID, &result.Name, &result.UserID, &versionID, &versionNumber, &VersionModelId); err != nil {
+		var organizationID *int64
+		var publicPermission *string
+		if err = rows.Scan(&result.ID, &result.Name, &result.UserID, &organizationID, &publicPermission, &versionID, &versionNumber, &VersionModelId); err != nil {
 			return nil, status.Error(codes.Internal, fmt.Sprintf("repository.GetModel: %s", err.Error()))
 		}
+		result.OrganizationID = pointer.Get(organizationID)
+		result.PublicPermission = pointer.Get(publicPermission)
 		if versionID != nil && versionNumber != nil && VersionModelId != nil {
 			version.ID = *versionID
 			version.Number = *versionNumber
@@ -70,6 +87,9 @@ func (s *ModelRepository) GetModel(ctx context.Context, model models.Model) (*mo
 	if err = rows.Err(); err != nil {
 		return nil, status.Error(codes.Internal, fmt.Sprintf("repository.GetModel: %s", err.Error()))
 	}
+	if result.ID == 0 {
+		return nil, status.Error(codes.NotFound, fmt.Sprintf("repository.GetModel: model (id %d) not found", model.ID))
+	}
 
 	return &result, nil
 
@@ -119,7 +139,7 @@ func (s *ModelRepository) DeleteModel(ctx context.Context, model models.Model) (
 		return false, status.Error(codes.Internal, fmt.Sprintf("repository.DeleteModel: %s", err.Error()))
 	}
 	if rowsAffected == 0 {
-		return false, status.Error(codes.Internal, fmt.Sprintf("repository.DeleteModel: order (id %d) not found", model.ID))
+		return false, status.Error(codes.NotFound, fmt.Sprintf("repository.DeleteModel: model (id %d) not found", model.ID))
 	}
 
 	return true, nil
@@ -143,11 +163,31 @@ func (s *ModelRepository) CreateVersion(ctx context.Context, version models.Vers
 	return &result, nil
 }
 
+// DeleteVersion deletes the version, e.g. one whose files could not be stored.
+func (s *ModelRepository) DeleteVersion(ctx context.Context, versionID int64) error {
+	_, err := squirrel.Delete("versions").
+		Where(squirrel.Eq{"id": versionID}).
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(s.db.Db).
+		ExecContext(ctx)
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.DeleteVersion: %s", err)
+	}
+	return nil
+}
+
+// ListModels returns the personal models of the user, the models of their organizations and the models shared
+// with them. Public models are only listed when shared explicitly.
 func (s *ModelRepository) ListModels(ctx context.Context, userID int64) ([]*models.Model, error) {
 	var result []*models.Model
-	rows, err := squirrel.Select("*").
+	rows, err := withModelRole(squirrel.Select("models.id", "models.name", "models.user_id", "models.organization_id", "models.public_permission"), userID).
 		From("models").
-		Where(squirrel.Eq{"user_id": userID}).
+		Where(squirrel.Or{
+			squirrel.Eq{"models.user_id": userID, "models.organization_id": nil},
+			squirrel.NotEq{"model_permissions.user_id": nil},
+			squirrel.NotEq{"organization_members.user_id": nil},
+		}).
+		OrderBy("models.id").
 		PlaceholderFormat(squirrel.Dollar).
 		RunWith(s.db.Db).
 		QueryContext(ctx)
@@ -159,9 +199,14 @@ func (s *ModelRepository) ListModels(ctx context.Context, userID int64) ([]*mode
 
 	for rows.Next() {
 		var model models.Model
-		if err = rows.Scan(&model.ID, &model.Name, &model.UserID); err != nil {
+		var organizationID *int64
+		var publicPermission, role *string
+		if err = rows.Scan(&model.ID, &model.Name, &model.UserID, &organizationID, &publicPermission, &role); err != nil {
 			return nil, status.Error(codes.Internal, fmt.Sprintf("repository.ListModels: %s", err.Error()))
 		}
+		model.OrganizationID = pointer.Get(organizationID)
+		model.PublicPermission = pointer.Get(publicPermission)
+		model.Role = pointer.Get(role)
 		result = append(result, &model)
 	}
 
@@ -171,3 +216,86 @@ func (s *ModelRepository) ListModels(ctx context.Context, userID int64) ([]*mode
 
 	return result, nil
 }
+
+// GetRole returns the access level of the user to the model, empty if they have none.
+func (s *ModelRepository) GetRole(ctx context.Context, modelID, userID int64) (string, error) {
+	return getModelRole(ctx, s.db, modelID, userID)
+}
+
+// GetPermission returns the permission granted to the user on the model, empty if there is none.
+func (s *ModelRepository) GetPermission(ctx context.Context, modelID, userID int64) (string, error) {
+	var permission string
+	err := squirrel.Select("permission").
+		From("model_permissions").
+		Where(squirrel.Eq{"model_id": modelID, "user_id": userID}).
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(s.db.Db).
+		QueryRowContext(ctx).
+		Scan(&permission)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", status.Error(codes.Internal, fmt.Sprintf("repository.GetPermission: %s", err.Error()))
+	}
+	return permission, nil
+}
+
+func (s *ModelRepository) GrantPermission(ctx context.Context, modelID, userID int64, permission string) error {
+	_, err := squirrel.Insert("model_permissions").
+		Columns("model_id", "user_id", "permission").
+		Values(modelID, userID, permission).
+		Suffix("ON CONFLICT (model_id, user_id) DO UPDATE SET permission = EXCLUDED.permission").
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(s.db.Db).
+		ExecContext(ctx)
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == foreignKeyViolation {
+		return status.Error(codes.NotFound, fmt.Sprintf("repository.GrantPermission: user (id %d) not found", userID))
+	}
+	if err != nil {
+		return status.Error(codes.Internal, fmt.Sprintf("repository.GrantPermission: %s", err.Error()))
+	}
+	return nil
+}
+
+func (s *ModelRepository) RevokePermission(ctx context.Context, modelID, userID int64) error {
+	result, err := squirrel.Delete("model_permissions").
+		Where(squirrel.Eq{"model_id": modelID, "user_id": userID}).
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(s.db.Db).
+		ExecContext(ctx)
+
+	if err != nil {
+		return status.Error(codes.Internal, fmt.Sprintf("repository.RevokePermission: %s", err.Error()))
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return status.Error(codes.Internal, fmt.Sprintf("repository.RevokePermission: %s", err.Error()))
+	}
+	if rowsAffected == 0 {
+		return status.Error(codes.NotFound, fmt.Sprintf("repository.RevokePermission: user (id %d) has no access to model (id %d)", userID, modelID))
+	}
+	return nil
+}
+
+// SetPublicPermission opens the model to everyone with the given permission, an empty one makes it private.
+func (s *ModelRepository) SetPublicPermission(ctx context.Context, modelID int64, permission string) error {
+	var value interface{}
+	if permission != "" {
+		value = permission
+	}
+	_, err := squirrel.Update("models").
+		Set("public_permission", value).
+		Where(squirrel.Eq{"id": modelID}).
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(s.db.Db).
+		ExecContext(ctx)
+
+	if err != nil {
+		return status.Error(codes.Internal, fmt.Sprintf("repository.SetPublicPermission: %s", err.Error()))
+	}
+	return nil
+}
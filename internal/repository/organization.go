@@ -0,0 +1,180 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/Masterminds/squirrel"
+	"github.com/lib/pq"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"house-of-neural-networks/internal/models"
+)
+
+// uniqueViolation is the Postgres error code of a duplicate key
+const uniqueViolation = "23505"
+
+// CreateOrganization creates the organization with the user as its first owner.
+func (s *ModelRepository) CreateOrganization(ctx context.Context, organization models.Organization, ownerID int64) (*models.Organization, error) {
+	tx, err := s.db.Db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "repository.CreateOrganization: %s", err)
+	}
+	defer tx.Rollback()
+
+	var result models.Organization
+	err = squirrel.Insert("organizations").
+		Columns("name").
+		Values(organization.Name).
+		Suffix("returning id, name, created_at").
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(tx).
+		QueryRowContext(ctx).
+		Scan(&result.ID, &result.Name, &result.CreatedAt)
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+		return nil, status.Errorf(codes.AlreadyExists, "repository.CreateOrganization: organization %q already exists", organization.Name)
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "repository.CreateOrganization: %s", err)
+	}
+
+	_, err = squirrel.Insert("organization_members").
+		Columns("organization_id", "user_id", "role").
+		Values(result.ID, ownerID, models.OrgRoleOwner).
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(tx).
+		ExecContext(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "repository.CreateOrganization: failed to add owner: %s", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, status.Errorf(codes.Internal, "repository.CreateOrganization: %s", err)
+	}
+	result.Role = models.OrgRoleOwner
+	return &result, nil
+}
+
+// ListOrganizations returns the organizations the user is a member of along with their role.
+func (s *ModelRepository) ListOrganizations(ctx context.Context, userID int64) ([]models.Organization, error) {
+	query, args, err := squirrel.Select("o.id", "o.name", "o.created_at", "om.role").
+		From("organizations o").
+		Join("organization_members om ON om.organization_id = o.id").
+		Where(squirrel.Eq{"om.user_id": userID}).
+		OrderBy("o.id").
+		PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "repository.ListOrganizations: %s", err)
+	}
+
+	organizations := make([]models.Organization, 0)
+	if err = s.db.Db.SelectContext(ctx, &organizations, query, args...); err != nil {
+		return nil, status.Errorf(codes.Internal, "repository.ListOrganizations: %s", err)
+	}
+	return organizations, nil
+}
+
+// GetMemberRole returns the role of the user in the organization, empty if they are not a member.
+func (s *ModelRepository) GetMemberRole(ctx context.Context, organizationID, userID int64) (string, error) {
+	var role string
+	err := squirrel.Select("role").
+		From("organization_members").
+		Where(squirrel.Eq{"organization_id": organizationID, "user_id": userID}).
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(s.db.Db).
+		QueryRowContext(ctx).
+		Scan(&role)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", status.Errorf(codes.Internal, "repository.GetMemberRole: %s", err)
+	}
+	return role, nil
+}
+
+func (s *ModelRepository) ListMembers(ctx context.Context, organizationID int64) ([]models.Member, error) {
+	query, args, err := squirrel.Select("om.user_id", "u.username", "om.role").
+		From("organization_members om").
+		Join("users u ON u.id = om.user_id").
+		Where(squirrel.Eq{"om.organization_id": organizationID}).
+		OrderBy("om.user_id").
+		PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "repository.ListMembers: %s", err)
+	}
+
+	members := make([]models.Member, 0)
+	if err = s.db.Db.SelectContext(ctx, &members, query, args...); err != nil {
+		return nil, status.Errorf(codes.Internal, "repository.ListMembers: %s", err)
+	}
+	return members, nil
+}
+
+// CountOwners returns the number of owners of the organization.
+func (s *ModelRepository) CountOwners(ctx context.Context, organizationID int64) (int64, error) {
+	var count int64
+	err := squirrel.Select("COUNT(*)").
+		From("organization_members").
+		Where(squirrel.Eq{"organization_id": organizationID, "role": models.OrgRoleOwner}).
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(s.db.Db).
+		QueryRowContext(ctx).
+		Scan(&count)
+
+	if err != nil {
+		return 0, status.Errorf(codes.Internal, "repository.CountOwners: %s", err)
+	}
+	return count, nil
+}
+
+// SetMember adds the user to the organization or changes their role.
+func (s *ModelRepository) SetMember(ctx context.Context, organizationID, userID int64, role string) error {
+	_, err := squirrel.Insert("organization_members").
+		Columns("organization_id", "user_id", "role").
+		Values(organizationID, userID, role).
+		Suffix("ON CONFLICT (organization_id, user_id) DO UPDATE SET role = EXCLUDED.role").
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(s.db.Db).
+		ExecContext(ctx)
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == foreignKeyViolation {
+		return status.Errorf(codes.NotFound, "repository.SetMember: user (id %d) not found", userID)
+	}
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.SetMember: %s", err)
+	}
+	return nil
+}
+
+func (s *ModelRepository) RemoveMember(ctx context.Context, organizationID, userID int64) error {
+	result, err := squirrel.Delete("organization_members").
+		Where(squirrel.Eq{"organization_id": organizationID, "user_id": userID}).
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(s.db.Db).
+		ExecContext(ctx)
+
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.RemoveMember: %s", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.RemoveMember: %s", err)
+	}
+	if rowsAffected == 0 {
+		return status.Errorf(codes.NotFound, "repository.RemoveMember: user (id %d) is not a member of organization (id %d)", userID, organizationID)
+	}
+	return nil
+}
+
+// nullableID stores a zero id as NULL
+func nullableID(id int64) interface{} {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
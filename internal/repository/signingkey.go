@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+	"github.com/Masterminds/squirrel"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"house-of-neural-networks/internal/models"
+	"time"
+)
+
+// CreateSigningKey stores a new signing key. Expired keys are dropped on the way since they no longer
+// verify any token.
+func (s *AuthRepository) CreateSigningKey(ctx context.Context, key models.SigningKey) error {
+	_, err := squirrel.Delete("signing_keys").
+		Where(squirrel.Lt{"expires_at": time.Now()}).
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(s.db.Db).
+		ExecContext(ctx)
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.CreateSigningKey: %s", err)
+	}
+
+	_, err = squirrel.Insert("signing_keys").
+		Columns("kid", "algorithm", "private_key", "created_at", "expires_at").
+		Values(key.ID, key.Algorithm, key.PrivateKey, key.CreatedAt, key.ExpiresAt).
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(s.db.Db).
+		ExecContext(ctx)
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.CreateSigningKey: %s", err)
+	}
+	return nil
+}
+
+// ListSigningKeys returns the keys that have not expired yet, newest first.
+func (s *AuthRepository) ListSigningKeys(ctx context.Context) ([]models.SigningKey, error) {
+	query, args, err := squirrel.Select("kid", "algorithm", "private_key", "created_at", "expires_at").
+		From("signing_keys").
+		Where(squirrel.Gt{"expires_at": time.Now()}).
+		OrderBy("created_at DESC").
+		PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "repository.ListSigningKeys: %s", err)
+	}
+
+	keys := make([]models.SigningKey, 0)
+	if err = s.db.Db.SelectContext(ctx, &keys, query, args...); err != nil {
+		return nil, status.Errorf(codes.Internal, "repository.ListSigningKeys: %s", err)
+	}
+	return keys, nil
+}
+
+// ListRevokedTokens returns the ids of revoked access tokens that have not expired yet.
+func (s *AuthRepository) ListRevokedTokens(ctx context.Context) ([]string, error) {
+	query, args, err := squirrel.Select("jti").
+		From("revoked_tokens").
+		Where(squirrel.Gt{"expires_at": time.Now()}).
+		PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "repository.ListRevokedTokens: %s", err)
+	}
+
+	ids := make([]string, 0)
+	if err = s.db.Db.SelectContext(ctx, &ids, query, args...); err != nil {
+		return nil, status.Errorf(codes.Internal, "repository.ListRevokedTokens: %s", err)
+	}
+	return ids, nil
+}
@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/Masterminds/squirrel"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"house-of-neural-networks/internal/models"
+	"time"
+)
+
+func (s *AuthRepository) CreateRefreshToken(ctx context.Context, token models.RefreshToken) error {
+	_, err := squirrel.Insert("refresh_tokens").
+		Columns("user_id", "token_hash", "family_id", "expires_at").
+		Values(token.UserID, token.TokenHash, token.FamilyID, token.ExpiresAt).
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(s.db.Db).
+		ExecContext(ctx)
+
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.CreateRefreshToken: %s", err)
+	}
+	return nil
+}
+
+func (s *AuthRepository) GetRefreshToken(ctx context.Context, tokenHash string) (models.RefreshToken, error) {
+	var result models.RefreshToken
+	err := squirrel.Select("id", "user_id", "token_hash", "family_id", "expires_at", "revoked_at").
+		From("refresh_tokens").
+		Where(squirrel.Eq{"token_hash": tokenHash}).
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(s.db.Db).
+		QueryRowContext(ctx).
+		Scan(&result.ID, &result.UserID, &result.TokenHash, &result.FamilyID, &result.ExpiresAt, &result.RevokedAt)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.RefreshToken{}, status.Error(codes.NotFound, "repository.GetRefreshToken: unknown refresh token")
+	}
+	if err != nil {
+		return models.RefreshToken{}, status.Errorf(codes.Internal, "repository.GetRefreshToken: %s", err)
+	}
+	return result, nil
+}
+
+// RotateRefreshToken revokes the token and stores its replacement. It fails with Aborted when the token
+// has been revoked in the meantime, e.g. by a concurrent refresh.
+func (s *AuthRepository) RotateRefreshToken(ctx context.Context, tokenID int64, replacement models.RefreshToken) error {
+	tx, err := s.db.Db.BeginTxx(ctx, nil)
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.RotateRefreshToken: %s", err)
+	}
+	defer tx.Rollback()
+
+	result, err := squirrel.Update("refresh_tokens").
+		Set("revoked_at", squirrel.Expr("now()")).
+		Where(squirrel.Eq{"id": tokenID, "revoked_at": nil}).
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(tx).
+		ExecContext(ctx)
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.RotateRefreshToken: %s", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.RotateRefreshToken: %s", err)
+	}
+	if rowsAffected == 0 {
+		return status.Error(codes.Aborted, "repository.RotateRefreshToken: refresh token already used")
+	}
+
+	_, err = squirrel.Insert("refresh_tokens").
+		Columns("user_id", "token_hash", "family_id", "expires_at").
+		Values(replacement.UserID, replacement.TokenHash, replacement.FamilyID, replacement.ExpiresAt).
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(tx).
+		ExecContext(ctx)
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.RotateRefreshToken: %s", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return status.Errorf(codes.Internal, "repository.RotateRefreshToken: %s", err)
+	}
+	return nil
+}
+
+// RevokeTokenFamily revokes every refresh token descending from the same login.
+func (s *AuthRepository) RevokeTokenFamily(ctx context.Context, familyID string) error {
+	_, err := squirrel.Update("refresh_tokens").
+		Set("revoked_at", squirrel.Expr("now()")).
+		Where(squirrel.Eq{"family_id": familyID, "revoked_at": nil}).
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(s.db.Db).
+		ExecContext(ctx)
+
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.RevokeTokenFamily: %s", err)
+	}
+	return nil
+}
+
+// RevokeAccessToken puts the token on the revocation list until it expires. Entries of expired tokens
+// are dropped on the way since they are rejected anyway.
+func (s *AuthRepository) RevokeAccessToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	_, err := squirrel.Delete("revoked_tokens").
+		Where(squirrel.Lt{"expires_at": time.Now()}).
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(s.db.Db).
+		ExecContext(ctx)
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.RevokeAccessToken: %s", err)
+	}
+
+	_, err = squirrel.Insert("revoked_tokens").
+		Columns("jti", "expires_at").
+		Values(jti, expiresAt).
+		Suffix("ON CONFLICT (jti) DO NOTHING").
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(s.db.Db).
+		ExecContext(ctx)
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.RevokeAccessToken: %s", err)
+	}
+	return nil
+}
+
+// IsAccessTokenRevoked reports whether the token has been revoked, its user has been disabled or it was issued
+// before the user changed the password.
+func (s *AuthRepository) IsAccessTokenRevoked(ctx context.Context, jti string, userID int64, issuedAt time.Time) (bool, error) {
+	var revoked bool
+	err := squirrel.Select().
+		Column(squirrel.Expr("EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = ?) OR EXISTS (SELECT 1 FROM users WHERE id = ? AND (disabled_at IS NOT NULL OR tokens_valid_after > ?))", jti, userID, issuedAt)).
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(s.db.Db).
+		QueryRowContext(ctx).
+		Scan(&revoked)
+
+	if err != nil {
+		return false, status.Errorf(codes.Internal, "repository.IsAccessTokenRevoked: %s", err)
+	}
+	return revoked, nil
+}
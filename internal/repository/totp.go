@@ -0,0 +1,274 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/Masterminds/squirrel"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"house-of-neural-networks/internal/models"
+)
+
+func (s *AuthRepository) GetTOTP(ctx context.Context, userID int64) (models.TOTP, error) {
+	result := models.TOTP{UserID: userID}
+	err := squirrel.Select("totp_secret", "totp_enabled_at", "totp_last_step").
+		From("users").
+		Where(squirrel.Eq{"id": userID}).
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(s.db.Db).
+		QueryRowContext(ctx).
+		Scan(&result.Secret, &result.EnabledAt, &result.LastStep)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.TOTP{}, status.Errorf(codes.NotFound, "repository.GetTOTP: user (id %d) not found", userID)
+	}
+	if err != nil {
+		return models.TOTP{}, status.Errorf(codes.Internal, "repository.GetTOTP: %s", err)
+	}
+	return result, nil
+}
+
+// SetTOTPSecret stores the secret of a new enrolment, replacing one that has not been confirmed.
+func (s *AuthRepository) SetTOTPSecret(ctx context.Context, userID int64, secret string) error {
+	result, err := squirrel.Update("users").
+		Set("totp_secret", secret).
+		Set("totp_last_step", nil).
+		Where(squirrel.Eq{"id": userID, "totp_enabled_at": nil}).
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(s.db.Db).
+		ExecContext(ctx)
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.SetTOTPSecret: %s", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.SetTOTPSecret: %s", err)
+	}
+	if rowsAffected == 0 {
+		return status.Errorf(codes.Aborted, "repository.SetTOTPSecret: two-factor authentication of user (id %d) has been enabled meanwhile", userID)
+	}
+	return nil
+}
+
+// EnableTOTP turns two-factor authentication on, with step as the last used one, and replaces the recovery
+// codes of the user.
+func (s *AuthRepository) EnableTOTP(ctx context.Context, userID int64, step int64, codeHashes []string) error {
+	tx, err := s.db.Db.BeginTxx(ctx, nil)
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.EnableTOTP: %s", err)
+	}
+	defer tx.Rollback()
+
+	result, err := squirrel.Update("users").
+		Set("totp_enabled_at", squirrel.Expr("now()")).
+		Set("totp_last_step", step).
+		Where(squirrel.Eq{"id": userID, "totp_enabled_at": nil}).
+		Where(squirrel.NotEq{"totp_secret": nil}).
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(tx).
+		ExecContext(ctx)
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.EnableTOTP: %s", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.EnableTOTP: %s", err)
+	}
+	if rowsAffected == 0 {
+		return status.Errorf(codes.Aborted, "repository.EnableTOTP: two-factor authentication of user (id %d) has been changed meanwhile", userID)
+	}
+
+	if err = replaceRecoveryCodes(ctx, tx, userID, codeHashes); err != nil {
+		return status.Errorf(codes.Internal, "repository.EnableTOTP: %s", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return status.Errorf(codes.Internal, "repository.EnableTOTP: %s", err)
+	}
+	return nil
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx squirrel.BaseRunner, userID int64, codeHashes []string) error {
+	_, err := squirrel.Delete("recovery_codes").
+		Where(squirrel.Eq{"user_id": userID}).
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(tx).
+		ExecContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	insert := squirrel.Insert("recovery_codes").Columns("user_id", "code_hash")
+	for _, hash := range codeHashes {
+		insert = insert.Values(userID, hash)
+	}
+	_, err = insert.PlaceholderFormat(squirrel.Dollar).RunWith(tx).ExecContext(ctx)
+	return err
+}
+
+// DisableTOTP turns two-factor authentication off and drops the recovery codes and pending logins of the user.
+func (s *AuthRepository) DisableTOTP(ctx context.Context, userID int64) error {
+	tx, err := s.db.Db.BeginTxx(ctx, nil)
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.DisableTOTP: %s", err)
+	}
+	defer tx.Rollback()
+
+	_, err = squirrel.Update("users").
+		Set("totp_secret", nil).
+		Set("totp_enabled_at", nil).
+		Set("totp_last_step", nil).
+		Where(squirrel.Eq{"id": userID}).
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(tx).
+		ExecContext(ctx)
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.DisableTOTP: %s", err)
+	}
+	for _, table := range []string{"recovery_codes", "login_challenges"} {
+		_, err = squirrel.Delete(table).
+			Where(squirrel.Eq{"user_id": userID}).
+			PlaceholderFormat(squirrel.Dollar).
+			RunWith(tx).
+			ExecContext(ctx)
+		if err != nil {
+			return status.Errorf(codes.Internal, "repository.DisableTOTP: %s", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return status.Errorf(codes.Internal, "repository.DisableTOTP: %s", err)
+	}
+	return nil
+}
+
+// UseTOTPStep records that the code of step has been used. Codes of that step and earlier ones are rejected
+// afterwards with Aborted.
+func (s *AuthRepository) UseTOTPStep(ctx context.Context, userID int64, step int64) error {
+	result, err := squirrel.Update("users").
+		Set("totp_last_step", step).
+		Where(squirrel.Eq{"id": userID}).
+		Where(squirrel.Or{squirrel.Eq{"totp_last_step": nil}, squirrel.Lt{"totp_last_step": step}}).
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(s.db.Db).
+		ExecContext(ctx)
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.UseTOTPStep: %s", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.UseTOTPStep: %s", err)
+	}
+	if rowsAffected == 0 {
+		return status.Error(codes.Aborted, "repository.UseTOTPStep: code has already been used")
+	}
+	return nil
+}
+
+// UseRecoveryCode marks the recovery code as used, NotFound when the user has no such unused code.
+func (s *AuthRepository) UseRecoveryCode(ctx context.Context, userID int64, codeHash string) error {
+	result, err := squirrel.Update("recovery_codes").
+		Set("used_at", squirrel.Expr("now()")).
+		Where(squirrel.Eq{"user_id": userID, "code_hash": codeHash, "used_at": nil}).
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(s.db.Db).
+		ExecContext(ctx)
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.UseRecoveryCode: %s", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.UseRecoveryCode: %s", err)
+	}
+	if rowsAffected == 0 {
+		return status.Error(codes.NotFound, "repository.UseRecoveryCode: unknown or used recovery code")
+	}
+	return nil
+}
+
+// CreateLoginChallenge stores the challenge, dropping expired ones on the way.
+func (s *AuthRepository) CreateLoginChallenge(ctx context.Context, challenge models.LoginChallenge) error {
+	_, err := squirrel.Delete("login_challenges").
+		Where("expires_at < now()").
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(s.db.Db).
+		ExecContext(ctx)
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.CreateLoginChallenge: %s", err)
+	}
+
+	_, err = squirrel.Insert("login_challenges").
+		Columns("token_hash", "user_id", "expires_at").
+		Values(challenge.TokenHash, challenge.UserID, challenge.ExpiresAt).
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(s.db.Db).
+		ExecContext(ctx)
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.CreateLoginChallenge: %s", err)
+	}
+	return nil
+}
+
+func (s *AuthRepository) GetLoginChallenge(ctx context.Context, tokenHash string) (models.LoginChallenge, error) {
+	result := models.LoginChallenge{TokenHash: tokenHash}
+	err := squirrel.Select("user_id", "attempts", "expires_at").
+		From("login_challenges").
+		Where(squirrel.Eq{"token_hash": tokenHash}).
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(s.db.Db).
+		QueryRowContext(ctx).
+		Scan(&result.UserID, &result.Attempts, &result.ExpiresAt)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.LoginChallenge{}, status.Error(codes.NotFound, "repository.GetLoginChallenge: unknown challenge")
+	}
+	if err != nil {
+		return models.LoginChallenge{}, status.Errorf(codes.Internal, "repository.GetLoginChallenge: %s", err)
+	}
+	return result, nil
+}
+
+// FailLoginChallenge counts a wrong code and deletes the challenge once it has had maxAttempts of them.
+func (s *AuthRepository) FailLoginChallenge(ctx context.Context, tokenHash string, maxAttempts int) error {
+	_, err := squirrel.Update("login_challenges").
+		Set("attempts", squirrel.Expr("attempts + 1")).
+		Where(squirrel.Eq{"token_hash": tokenHash}).
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(s.db.Db).
+		ExecContext(ctx)
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.FailLoginChallenge: %s", err)
+	}
+
+	_, err = squirrel.Delete("login_challenges").
+		Where(squirrel.Eq{"token_hash": tokenHash}).
+		Where(squirrel.GtOrEq{"attempts": maxAttempts}).
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(s.db.Db).
+		ExecContext(ctx)
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.FailLoginChallenge: %s", err)
+	}
+	return nil
+}
+
+// DeleteLoginChallenge completes the challenge, Aborted when it has been completed meanwhile.
+func (s *AuthRepository) DeleteLoginChallenge(ctx context.Context, tokenHash string) error {
+	result, err := squirrel.Delete("login_challenges").
+		Where(squirrel.Eq{"token_hash": tokenHash}).
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(s.db.Db).
+		ExecContext(ctx)
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.DeleteLoginChallenge: %s", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.DeleteLoginChallenge: %s", err)
+	}
+	if rowsAffected == 0 {
+		return status.Error(codes.Aborted, "repository.DeleteLoginChallenge: challenge has been completed meanwhile")
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/Masterminds/squirrel"
+	"github.com/lib/pq"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"house-of-neural-networks/internal/models"
+	"time"
+)
+
+func (s *ModelRepository) CreateUpload(ctx context.Context, upload models.Upload) (*models.Upload, error) {
+	err := squirrel.Insert("uploads").
+		Columns("user_id", "model_id", "filename", "size", "expires_at").
+		Values(upload.UserID, upload.ModelID, upload.Filename, upload.Size, upload.ExpiresAt).
+		Suffix("returning id, created_at").
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(s.db.Db).
+		QueryRowContext(ctx).
+		Scan(&upload.ID, &upload.CreatedAt)
+
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "repository.CreateUpload: %s", err)
+	}
+	return &upload, nil
+}
+
+func (s *ModelRepository) GetUpload(ctx context.Context, id int64) (*models.Upload, error) {
+	var upload models.Upload
+	err := squirrel.Select("id", "user_id", "model_id", "filename", "size", "created_at", "expires_at").
+		From("uploads").
+		Where(squirrel.Eq{"id": id}).
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(s.db.Db).
+		QueryRowContext(ctx).
+		Scan(&upload.ID, &upload.UserID, &upload.ModelID, &upload.Filename, &upload.Size, &upload.CreatedAt, &upload.ExpiresAt)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, status.Errorf(codes.NotFound, "repository.GetUpload: upload (id %d) not found", id)
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "repository.GetUpload: %s", err)
+	}
+	return &upload, nil
+}
+
+// ExtendUpload moves the expiry of the upload, it is extended whenever data arrives.
+func (s *ModelRepository) ExtendUpload(ctx context.Context, id int64, expiresAt time.Time) error {
+	_, err := squirrel.Update("uploads").
+		Set("expires_at", expiresAt).
+		Where(squirrel.Eq{"id": id}).
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(s.db.Db).
+		ExecContext(ctx)
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.ExtendUpload: %s", err)
+	}
+	return nil
+}
+
+func (s *ModelRepository) DeleteUploads(ctx context.Context, ids []int64) error {
+	_, err := squirrel.Delete("uploads").
+		Where("id = ANY(?)", pq.Array(ids)).
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(s.db.Db).
+		ExecContext(ctx)
+	if err != nil {
+		return status.Errorf(codes.Internal, "repository.DeleteUploads: %s", err)
+	}
+	return nil
+}
+
+// DeleteExpiredUploads deletes the uploads that expired before now and returns the ids of the uploads left.
+func (s *ModelRepository) DeleteExpiredUploads(ctx context.Context, now time.Time) ([]int64, error) {
+	_, err := squirrel.Delete("uploads").
+		Where(squirrel.LtOrEq{"expires_at": now}).
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(s.db.Db).
+		ExecContext(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "repository.DeleteExpiredUploads: %s", err)
+	}
+
+	rows, err := squirrel.Select("id").
+		From("uploads").
+		PlaceholderFormat(squirrel.Dollar).
+		RunWith(s.db.Db).
+		QueryContext(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "repository.DeleteExpiredUploads: %s", err)
+	}
+	defer rows.Close()
+
+	ids := make([]int64, 0)
+	for rows.Next() {
+		var id int64
+		if err = rows.Scan(&id); err != nil {
+			return nil, status.Errorf(codes.Internal, "repository.DeleteExpiredUploads: %s", err)
+		}
+		ids = append(ids, id)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, status.Errorf(codes.Internal, "repository.DeleteExpiredUploads: %s", err)
+	}
+	return ids, nil
+}
@@ -0,0 +1,227 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"house-of-neural-networks/internal/models"
+	"house-of-neural-networks/internal/triton"
+	"house-of-neural-networks/pkg/logger"
+	"strings"
+	"time"
+)
+
+// GetProfile returns the account of the current user.
+func (s *AuthService) GetProfile(ctx context.Context) (models.User, error) {
+	userID, err := currentUser(ctx, "service.GetProfile")
+	if err != nil {
+		return models.User{}, err
+	}
+	return s.Repo.GetUserByID(ctx, userID)
+}
+
+// UpdateProfile changes the username and email of the current user to the ones that are set. A new email
+// has to be verified again, the verification link is sent right away.
+func (s *AuthService) UpdateProfile(ctx context.Context, update models.User) (_ models.User, err error) {
+	defer func() { s.audit(ctx, models.AuditEvent{Action: models.AuditProfileUpdate}, err) }()
+
+	userID, err := sessionUser(ctx, "service.UpdateProfile")
+	if err != nil {
+		return models.User{}, err
+	}
+	user, err := s.Repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	username, email := strings.TrimSpace(update.Username), strings.TrimSpace(update.Email)
+	if username == "" && email == "" {
+		return models.User{}, status.Error(codes.InvalidArgument, "service.UpdateProfile: nothing to update")
+	}
+	emailChanged := email != "" && email != user.Email
+	if username != "" {
+		user.Username = username
+	}
+	if emailChanged {
+		user.Email = email
+		user.EmailVerifiedAt = nil
+	}
+	if err = s.Repo.UpdateProfile(ctx, user); err != nil {
+		return models.User{}, err
+	}
+
+	if emailChanged && s.Mailer != nil {
+		if err = s.sendVerification(ctx, user); err != nil {
+			logger.GetLoggerFromCtx(ctx).Error(ctx, err.Error(), zap.String("Function", logger.GetFunctionName()))
+		}
+	}
+	return user, nil
+}
+
+// ChangePassword sets a new password for the current user and ends every other session at once: refresh
+// tokens already issued are revoked and access tokens issued before the change are rejected. The caller gets
+// a new session. Wrong old passwords count as failed logins.
+func (s *AuthService) ChangePassword(ctx context.Context, oldPassword, newPassword string) (_ *models.Session, err error) {
+	defer func() { s.audit(ctx, models.AuditEvent{Action: models.AuditPasswordChange}, err) }()
+
+	userID, err := sessionUser(ctx, "service.ChangePassword")
+	if err != nil {
+		return nil, err
+	}
+	if oldPassword == "" || newPassword == "" {
+		return nil, status.Error(codes.InvalidArgument, "service.ChangePassword: old or new password is empty")
+	}
+	user, err := s.checkPassword(ctx, userID, oldPassword, "service.ChangePassword")
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "service.ChangePassword: %s", err)
+	}
+	if err = s.Repo.SetPassword(ctx, userID, string(hash), tokensValidAfter()); err != nil {
+		return nil, err
+	}
+	return s.startSession(ctx, user)
+}
+
+// DeleteAccount checks the password of the current user, and the second factor if enabled, and locks the
+// account for deletion: it is disabled, every session ends and the deletion is recorded. The model service
+// deletes it along with its data afterwards, see ModelService.DeleteAccountData, and keeps retrying with
+// DeleteRequestedAccounts if that fails.
+func (s *AuthService) DeleteAccount(ctx context.Context, password, code string) (err error) {
+	defer func() { s.audit(ctx, models.AuditEvent{Action: models.AuditAccountDelete}, err) }()
+
+	userID, err := sessionUser(ctx, "service.DeleteAccount")
+	if err != nil {
+		return err
+	}
+	if password == "" {
+		return status.Error(codes.InvalidArgument, "service.DeleteAccount: password is empty")
+	}
+	user, err := s.checkPassword(ctx, userID, password, "service.DeleteAccount")
+	if err != nil {
+		return err
+	}
+
+	if user.TOTPEnabledAt != nil {
+		current, err := s.Repo.GetTOTP(ctx, userID)
+		if err != nil {
+			return err
+		}
+		ok, err := s.checkSecondFactor(ctx, current, code)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			failure := models.LoginFailure{Username: user.Username, UserID: &user.ID, Reason: models.LoginWrongCode}
+			return s.loginFailed(ctx, failure, status.Error(codes.PermissionDenied, "service.DeleteAccount: invalid code"))
+		}
+	}
+	return s.Repo.RequestAccountDeletion(ctx, userID)
+}
+
+// checkPassword verifies the password of the user for a sensitive change, subject to the login throttle.
+func (s *AuthService) checkPassword(ctx context.Context, userID int64, password, method string) (models.User, error) {
+	byID, err := s.Repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return models.User{}, err
+	}
+	user, err := s.Repo.GetUser(ctx, byID)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	failure := models.LoginFailure{Username: user.Username, UserID: &user.ID}
+	retry, err := s.retryAfter(ctx, user, "")
+	if err != nil {
+		return models.User{}, err
+	}
+	if retry > 0 {
+		failure.Reason = models.LoginLocked
+		return models.User{}, s.loginFailed(ctx, failure, status.Errorf(codes.ResourceExhausted,
+			"%s: too many failed attempts, retry in %s", method, retry.Round(time.Second)))
+	}
+	if err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		failure.Reason = models.LoginWrongPassword
+		return models.User{}, s.loginFailed(ctx, failure, status.Errorf(codes.PermissionDenied, "%s: wrong password", method))
+	}
+	return user, nil
+}
+
+// DeleteAccountData deletes the account of the current user with their models, chat history and the
+// organizations nobody else is a member of. The account has to be locked by AuthService.DeleteAccount
+// first. Returns the number of deleted models.
+func (s *ModelService) DeleteAccountData(ctx context.Context) (int, error) {
+	userID, err := sessionUser(ctx, "service.DeleteAccountData")
+	if err != nil {
+		s.audit(ctx, models.AuditEvent{Action: models.AuditAccountDataDelete}, err)
+		return 0, err
+	}
+	return s.deleteAccountData(ctx, userID)
+}
+
+// DeleteRequestedAccounts deletes the accounts locked for deletion whose data has not been deleted yet, e.g.
+// because the service was unavailable when the user asked. Every account is tried, the first error is
+// returned.
+func (s *ModelService) DeleteRequestedAccounts(ctx context.Context) error {
+	userIDs, err := s.Repo.ListRequestedDeletions(ctx)
+	if err != nil {
+		return err
+	}
+	var firstErr error
+	for _, userID := range userIDs {
+		if _, err = s.deleteAccountData(ctx, userID); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (s *ModelService) deleteAccountData(ctx context.Context, userID int64) (_ int, err error) {
+	defer func() {
+		s.audit(ctx, models.AuditEvent{Action: models.AuditAccountDataDelete, ActorID: &userID, Target: auditTarget("user", userID)}, err)
+	}()
+
+	list, err := s.Repo.ListAccountModels(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	modelIDs := make([]int64, 0, len(list))
+	for _, model := range list {
+		modelIDs = append(modelIDs, model.ID)
+	}
+	if err = s.Repo.DeleteAccount(ctx, userID, modelIDs); err != nil {
+		return 0, err
+	}
+
+	// The account is gone at this point, leftovers are logged rather than failing the request
+	for _, model := range list {
+		if err = s.removeModelFiles(ctx, model.Name); err != nil {
+			logger.GetLoggerFromCtx(ctx).Error(ctx, err.Error(), zap.String("Function", logger.GetFunctionName()))
+		}
+	}
+	return len(list), nil
+}
+
+// removeModelFiles unloads the model from Triton and removes its directory. The directory is removed even if
+// Triton cannot be reached, as there is no model left to retry with.
+func (s *ModelService) removeModelFiles(ctx context.Context, name string) error {
+	unloadErr := unloadModel(s.TritonClient, name)
+	if err := s.Store.RemoveAll(ctx, name); err != nil {
+		return status.Errorf(codes.Internal, "service.removeModelFiles: %s", errors.Join(unloadErr, err))
+	}
+	return unloadErr
+}
+
+func unloadModel(client *triton.TritonClient, name string) error {
+	ready, err := triton.ModelReadyRequest(client.Client, name, "")
+	if err != nil || !ready {
+		return err
+	}
+	return triton.UnloadModelRequest(client.Client, name)
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"house-of-neural-networks/internal/models"
+)
+
+// The methods below are restricted to administrators by the per-method roles the gRPC servers enforce.
+
+var knownRoles = map[string]bool{
+	models.RoleUser:  true,
+	models.RoleAdmin: true,
+}
+
+func (s *AuthService) ListUsers(ctx context.Context) ([]models.User, error) {
+	return s.Repo.ListUsers(ctx)
+}
+
+// SetUserDisabled disables or re-enables the user. A disabled user can no longer log in, refresh tokens
+// or use API keys, and access tokens already issued are rejected.
+func (s *AuthService) SetUserDisabled(ctx context.Context, userID int64, disabled bool) (err error) {
+	action := models.AuditUserEnable
+	if disabled {
+		action = models.AuditUserDisable
+	}
+	defer func() { s.audit(ctx, models.AuditEvent{Action: action, Target: auditTarget("user", userID)}, err) }()
+
+	adminID, err := currentUser(ctx, "service.SetUserDisabled")
+	if err != nil {
+		return err
+	}
+	if userID == 0 {
+		return status.Error(codes.InvalidArgument, "service.SetUserDisabled: user_id is empty")
+	}
+	if userID == adminID && disabled {
+		return status.Error(codes.FailedPrecondition, "service.SetUserDisabled: administrators cannot disable themselves")
+	}
+	return s.Repo.SetUserDisabled(ctx, userID, disabled)
+}
+
+// SetUserRoles replaces the roles of the user, which take effect when the user next logs in or refreshes.
+func (s *AuthService) SetUserRoles(ctx context.Context, userID int64, roles []string) (err error) {
+	defer func() {
+		s.audit(ctx, models.AuditEvent{Action: models.AuditUserRoles, Target: auditTarget("user", userID)}, err)
+	}()
+
+	adminID, err := currentUser(ctx, "service.SetUserRoles")
+	if err != nil {
+		return err
+	}
+	if userID == 0 {
+		return status.Error(codes.InvalidArgument, "service.SetUserRoles: user_id is empty")
+	}
+
+	// Every user keeps the user role
+	unique := []string{models.RoleUser}
+	seen := map[string]bool{models.RoleUser: true}
+	for _, role := range roles {
+		if !knownRoles[role] {
+			return status.Errorf(codes.InvalidArgument, "service.SetUserRoles: unknown role %q", role)
+		}
+		if !seen[role] {
+			seen[role] = true
+			unique = append(unique, role)
+		}
+	}
+	if userID == adminID && !seen[models.RoleAdmin] {
+		return status.Error(codes.FailedPrecondition, "service.SetUserRoles: administrators cannot revoke their own admin role")
+	}
+	return s.Repo.SetUserRoles(ctx, userID, unique)
+}
+
+// GrantAdmins makes the users with the given usernames administrators, e.g. to bootstrap the first one.
+func (s *AuthService) GrantAdmins(ctx context.Context, usernames []string) error {
+	if len(usernames) == 0 {
+		return nil
+	}
+	return s.Repo.GrantRole(ctx, usernames, models.RoleAdmin)
+}
+
+// ListAllModels returns the models of every user, or of the given user when userID is set.
+func (s *ModelService) ListAllModels(ctx context.Context, userID int64) ([]*models.Model, error) {
+	return s.Repo.ListAllModels(ctx, userID)
+}
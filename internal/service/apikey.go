@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"house-of-neural-networks/internal/models"
+	"house-of-neural-networks/internal/principal"
+)
+
+var apiKeyScopes = map[string]bool{
+	models.ScopeModelsRead:  true,
+	models.ScopeModelsWrite: true,
+	models.ScopeChatRead:    true,
+	models.ScopeChatWrite:   true,
+}
+
+// apiKeyPrefixLength is the length of the displayed beginning of a key, long enough to tell keys apart.
+const apiKeyPrefixLength = 12
+
+// CreateAPIKey issues a key of the current user and returns it in plain text along with its description.
+// The key itself is not stored and cannot be shown again.
+func (s *AuthService) CreateAPIKey(ctx context.Context, key models.APIKey) (_ string, created *models.APIKey, err error) {
+	defer func() {
+		event := models.AuditEvent{Action: models.AuditAPIKeyCreate}
+		if created != nil {
+			event.Target = auditTarget("api_key", created.ID)
+		}
+		s.audit(ctx, event, err)
+	}()
+
+	userID, err := sessionUser(ctx, "service.CreateAPIKey")
+	if err != nil {
+		return "", nil, err
+	}
+	if key.Name == "" {
+		return "", nil, status.Error(codes.InvalidArgument, "service.CreateAPIKey: name is empty")
+	}
+	if len(key.Scopes) == 0 {
+		return "", nil, status.Error(codes.InvalidArgument, "service.CreateAPIKey: at least one scope is required")
+	}
+	for _, scope := range key.Scopes {
+		if !apiKeyScopes[scope] {
+			return "", nil, status.Errorf(codes.InvalidArgument, "service.CreateAPIKey: unknown scope %q", scope)
+		}
+	}
+	if key.ExpiresAt != nil && !key.ExpiresAt.After(time.Now()) {
+		return "", nil, status.Error(codes.InvalidArgument, "service.CreateAPIKey: expiry is in the past")
+	}
+
+	raw := make([]byte, 32)
+	if _, err = rand.Read(raw); err != nil {
+		return "", nil, status.Errorf(codes.Internal, "service.CreateAPIKey: %s", err)
+	}
+	plain := models.APIKeyPrefix + base64.RawURLEncoding.EncodeToString(raw)
+
+	key.UserID = userID
+	key.Prefix = plain[:apiKeyPrefixLength]
+	key.KeyHash = hashToken(plain)
+	created, err = s.Repo.CreateAPIKey(ctx, key)
+	if err != nil {
+		return "", nil, err
+	}
+	return plain, created, nil
+}
+
+func (s *AuthService) ListAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	userID, err := sessionUser(ctx, "service.ListAPIKeys")
+	if err != nil {
+		return nil, err
+	}
+	return s.Repo.ListAPIKeys(ctx, userID)
+}
+
+func (s *AuthService) RevokeAPIKey(ctx context.Context, keyID int64) (err error) {
+	defer func() {
+		s.audit(ctx, models.AuditEvent{Action: models.AuditAPIKeyRevoke, Target: auditTarget("api_key", keyID)}, err)
+	}()
+
+	userID, err := sessionUser(ctx, "service.RevokeAPIKey")
+	if err != nil {
+		return err
+	}
+	return s.Repo.RevokeAPIKey(ctx, userID, keyID)
+}
+
+// ValidateAPIKey returns the principal the key acts as, restricted to the scopes and models of the key.
+func (s *AuthService) ValidateAPIKey(ctx context.Context, plain string) (principal.Principal, error) {
+	key, err := s.Repo.GetAPIKey(ctx, hashToken(plain))
+	if status.Code(err) == codes.NotFound {
+		return principal.Principal{}, status.Error(codes.Unauthenticated, "service.ValidateAPIKey: invalid api key")
+	}
+	if err != nil {
+		return principal.Principal{}, err
+	}
+	if key.ExpiresAt != nil && time.Now().After(*key.ExpiresAt) {
+		return principal.Principal{}, status.Error(codes.Unauthenticated, "service.ValidateAPIKey: api key has expired")
+	}
+	if err = s.Repo.TouchAPIKey(ctx, key.ID); err != nil {
+		return principal.Principal{}, err
+	}
+
+	p := principal.Principal{
+		UserID:   key.UserID,
+		Roles:    []string{models.RoleUser},
+		Scopes:   key.Scopes,
+		ModelIDs: key.ModelIDs,
+	}
+	if key.ExpiresAt != nil {
+		p.ExpiresAt = *key.ExpiresAt
+	}
+	return p, nil
+}
+
+// sessionUser is currentUser for operations that an API key may not perform, such as managing keys.
+func sessionUser(ctx context.Context, method string) (int64, error) {
+	userID, err := currentUser(ctx, method)
+	if err != nil {
+		return 0, err
+	}
+	if p, _ := principal.FromContext(ctx); p.Scopes != nil {
+		return 0, status.Errorf(codes.PermissionDenied, "%s: not allowed with an api key", method)
+	}
+	return userID, nil
+}
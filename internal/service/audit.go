@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"house-of-neural-networks/internal/models"
+	"house-of-neural-networks/internal/origin"
+	"house-of-neural-networks/internal/principal"
+	"house-of-neural-networks/pkg/logger"
+	"strconv"
+)
+
+// AuditLog stores the security audit log shared by the services. Services without one record nothing.
+type AuditLog interface {
+	RecordAuditEvent(ctx context.Context, event models.AuditEvent) error
+	ListAuditEvents(ctx context.Context, filter models.AuditFilter) ([]models.AuditEvent, error)
+}
+
+// recordAudit records the outcome of the action in log. The actor defaults to the principal of the request,
+// the request id and client address come from its origin. The action has already happened or failed, so
+// failing to record it is logged rather than returned.
+func recordAudit(ctx context.Context, log AuditLog, event models.AuditEvent, err error) {
+	if log == nil {
+		return
+	}
+	if p, ok := principal.FromContext(ctx); ok && event.ActorID == nil {
+		event.ActorID = &p.UserID
+	}
+	o := origin.FromContext(ctx)
+	event.RequestID, event.ClientIP = o.RequestID, o.ClientIP
+	switch status.Code(err) {
+	case codes.OK:
+		event.Outcome = models.AuditSuccess
+	case codes.Unauthenticated, codes.PermissionDenied:
+		event.Outcome = models.AuditDenied
+	default:
+		event.Outcome = models.AuditFailure
+	}
+
+	if err := log.RecordAuditEvent(ctx, event); err != nil {
+		logger.GetLoggerFromCtx(ctx).Error(ctx, err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("action", event.Action),
+			zap.String("target", event.Target),
+		)
+	}
+}
+
+// auditTarget names an object of the kind, e.g. "model:12".
+func auditTarget(kind string, id int64) string {
+	return fmt.Sprintf("%s:%d", kind, id)
+}
+
+func (s *AuthService) audit(ctx context.Context, event models.AuditEvent, err error) {
+	recordAudit(ctx, s.Audit, event, err)
+}
+
+func (s *ModelService) audit(ctx context.Context, event models.AuditEvent, err error) {
+	recordAudit(ctx, s.Audit, event, err)
+}
+
+func (s *MessageService) audit(ctx context.Context, event models.AuditEvent, err error) {
+	recordAudit(ctx, s.Audit, event, err)
+}
+
+// ListAuditEvents returns a page of the audit events matching the filter, newest first, and a token of the
+// next page, empty on the last one. Restricted to administrators.
+func (s *AuthService) ListAuditEvents(ctx context.Context, filter models.AuditFilter, pageSize int32, pageToken string) ([]models.AuditEvent, string, error) {
+	if s.Audit == nil {
+		return nil, "", status.Error(codes.Unimplemented, "service.ListAuditEvents: the audit log is disabled")
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return nil, "", status.Error(codes.InvalidArgument, "service.ListAuditEvents: empty time range")
+	}
+	switch {
+	case pageSize < 0:
+		return nil, "", status.Error(codes.InvalidArgument, "service.ListAuditEvents: negative page size")
+	case pageSize == 0:
+		pageSize = defaultPageSize
+	case pageSize > maxPageSize:
+		pageSize = maxPageSize
+	}
+	// One extra event tells whether there is a next page
+	filter.Limit = uint64(pageSize) + 1
+	if pageToken != "" {
+		beforeID, err := decodeAuditPageToken(pageToken)
+		if err != nil {
+			return nil, "", status.Error(codes.InvalidArgument, "service.ListAuditEvents: invalid page token")
+		}
+		filter.BeforeID = beforeID
+	}
+
+	events, err := s.Audit.ListAuditEvents(ctx, filter)
+	if err != nil {
+		return nil, "", err
+	}
+	nextPageToken := ""
+	if len(events) > int(pageSize) {
+		events = events[:pageSize]
+		nextPageToken = encodeAuditPageToken(events[len(events)-1].ID)
+	}
+	return events, nextPageToken, nil
+}
+
+// Audit page tokens hold the id of the last returned event, events are listed by descending id
+func encodeAuditPageToken(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(id, 10)))
+}
+
+func decodeAuditPageToken(token string) (int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, err
+	}
+	id, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("malformed page token")
+	}
+	return id, nil
+}
@@ -2,38 +2,111 @@ package service
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
 	"golang.org/x/crypto/bcrypt"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"house-of-neural-networks/internal/mailer"
 	"house-of-neural-networks/internal/models"
+	"house-of-neural-networks/internal/oidc"
+	"house-of-neural-networks/internal/principal"
+	"house-of-neural-networks/pkg/jwks"
+	"house-of-neural-networks/pkg/logger"
 	"time"
 )
 
 type AuthRepo interface {
-	CreateUser(ctx context.Context, user models.User) (bool, error)
+	CreateUser(ctx context.Context, user models.User) (int64, error)
 	GetUser(ctx context.Context, user models.User) (models.User, error)
+	CreateRefreshToken(ctx context.Context, token models.RefreshToken) error
+	GetRefreshToken(ctx context.Context, tokenHash string) (models.RefreshToken, error)
+	RotateRefreshToken(ctx context.Context, tokenID int64, replacement models.RefreshToken) error
+	RevokeTokenFamily(ctx context.Context, familyID string) error
+	RevokeAccessToken(ctx context.Context, jti string, expiresAt time.Time) error
+	IsAccessTokenRevoked(ctx context.Context, jti string, userID int64, issuedAt time.Time) (bool, error)
+	CreateAPIKey(ctx context.Context, key models.APIKey) (*models.APIKey, error)
+	ListAPIKeys(ctx context.Context, userID int64) ([]models.APIKey, error)
+	GetAPIKey(ctx context.Context, keyHash string) (models.APIKey, error)
+	TouchAPIKey(ctx context.Context, keyID int64) error
+	RevokeAPIKey(ctx context.Context, userID, keyID int64) error
+	CreateSigningKey(ctx context.Context, key models.SigningKey) error
+	ListSigningKeys(ctx context.Context) ([]models.SigningKey, error)
+	ListRevokedTokens(ctx context.Context) ([]string, error)
+	CreateOIDCState(ctx context.Context, state models.OIDCState) error
+	TakeOIDCState(ctx context.Context, state string) (models.OIDCState, error)
+	GetUserByIdentity(ctx context.Context, issuer, subject string) (models.User, error)
+	CreateIdentityUser(ctx context.Context, user models.User, identity models.Identity) (int64, error)
+	GetUserByID(ctx context.Context, userID int64) (models.User, error)
+	ListUsers(ctx context.Context) ([]models.User, error)
+	SetUserDisabled(ctx context.Context, userID int64, disabled bool) error
+	RequestAccountDeletion(ctx context.Context, userID int64) error
+	SetUserRoles(ctx context.Context, userID int64, roles []string) error
+	GrantRole(ctx context.Context, usernames []string, role string) error
+	ListDisabledUsers(ctx context.Context, since time.Time) ([]int64, error)
+	ListTokensValidAfter(ctx context.Context, since time.Time) (map[int64]time.Time, error)
+	CreateEmailToken(ctx context.Context, token models.EmailToken) error
+	TakeEmailToken(ctx context.Context, tokenHash, purpose string) (models.EmailToken, error)
+	SetEmailVerified(ctx context.Context, userID int64, email string) error
+	ListUsersByEmail(ctx context.Context, email string) ([]models.User, error)
+	SetPassword(ctx context.Context, userID int64, passwordHash string, tokensValidAfter time.Time) error
+	RecordLoginFailure(ctx context.Context, failure models.LoginFailure) error
+	CountLoginFailures(ctx context.Context, username, clientIP string, since, accountSince time.Time) (account, client models.LoginFailureCount, err error)
+	SetLastLogin(ctx context.Context, userID int64) error
+	GetTOTP(ctx context.Context, userID int64) (models.TOTP, error)
+	SetTOTPSecret(ctx context.Context, userID int64, secret string) error
+	EnableTOTP(ctx context.Context, userID int64, step int64, codeHashes []string) error
+	DisableTOTP(ctx context.Context, userID int64) error
+	UseTOTPStep(ctx context.Context, userID int64, step int64) error
+	UseRecoveryCode(ctx context.Context, userID int64, codeHash string) error
+	CreateLoginChallenge(ctx context.Context, challenge models.LoginChallenge) error
+	GetLoginChallenge(ctx context.Context, tokenHash string) (models.LoginChallenge, error)
+	FailLoginChallenge(ctx context.Context, tokenHash string, maxAttempts int) error
+	DeleteLoginChallenge(ctx context.Context, tokenHash string) error
+	UpdateProfile(ctx context.Context, user models.User) error
 }
 
+const (
+	accessTokenTTL  = time.Hour
+	refreshTokenTTL = 30 * 24 * time.Hour
+)
+
+// AuthService signs access tokens with the keys of Keys when it is set and with JWTSecret (HS256) otherwise.
+// OIDC is the identity provider users may log in with, if any. Mailer sends the verification and password
+// reset emails, which link to PublicURL. Throttle limits failed logins. Audit records security events.
 type AuthService struct {
 	Repo      AuthRepo
 	JWTSecret string
+	Keys      *Keyring
+	OIDC      *oidc.Provider
+	Mailer    mailer.Mailer
+	PublicURL string
+	Throttle  LoginThrottle
+	Audit     AuditLog
 }
 
 type CustomClaims struct {
-	UserID int64 `json:"user_id"`
+	UserID int64    `json:"user_id"`
+	Roles  []string `json:"roles,omitempty"`
 	jwt.RegisteredClaims
 }
 
 func NewAuthService(repo AuthRepo, secret string) *AuthService {
-	return &AuthService{Repo: repo, JWTSecret: secret}
+	return &AuthService{Repo: repo, JWTSecret: secret, Throttle: DefaultLoginThrottle}
 }
 
-func NewCustomClaims(userId int64, claims jwt.RegisteredClaims) *CustomClaims {
-	return &CustomClaims{UserID: userId, RegisteredClaims: claims}
+func NewCustomClaims(userId int64, roles []string, claims jwt.RegisteredClaims) *CustomClaims {
+	return &CustomClaims{UserID: userId, Roles: roles, RegisteredClaims: claims}
 }
 
+// SignUp creates the user and sends the email verification link. The user is created even if the email
+// cannot be sent, a new link can be requested later.
 func (s *AuthService) SignUp(ctx context.Context, user models.User) (bool, error) {
 	if user.Password == "" || user.Username == "" {
 		return false, status.Error(codes.InvalidArgument, "username or password is empty")
@@ -44,72 +117,323 @@ func (s *AuthService) SignUp(ctx context.Context, user models.User) (bool, error
 		return false, status.Error(codes.Internal, fmt.Sprintf("service.SingUp: %s", err.Error()))
 	}
 	user.Password = string(bytes)
-	return s.Repo.CreateUser(ctx, user)
+	user.ID, err = s.Repo.CreateUser(ctx, user)
+	if err != nil || user.ID == 0 {
+		return false, err
+	}
+	s.audit(ctx, models.AuditEvent{ActorID: &user.ID, Action: models.AuditSignUp, Target: auditTarget("user", user.ID)}, nil)
+
+	if s.Mailer != nil && user.Email != "" {
+		if err = s.sendVerification(ctx, user); err != nil {
+			logger.GetLoggerFromCtx(ctx).Error(ctx, err.Error(), zap.String("Function", logger.GetFunctionName()))
+		}
+	}
+	return true, nil
 }
 
-func (s *AuthService) LogIn(ctx context.Context, user models.User) (string, int64, error) {
+// LogIn checks the password of the user. Unknown users and wrong passwords get the same reply, every failure
+// is recorded and too many of them from the account or the client address make it wait, see LoginThrottle.
+// Users with two-factor authentication get a challenge token to pass to VerifyLogIn instead of a session.
+func (s *AuthService) LogIn(ctx context.Context, user models.User, clientIP string) (session *models.Session, err error) {
+	event := models.AuditEvent{Action: models.AuditLogIn, Target: user.Username}
+	defer func() {
+		// Logins with a second factor are recorded once it has been checked
+		if session == nil || session.ChallengeToken == "" {
+			s.audit(ctx, event, err)
+		}
+	}()
+
 	if user.Password == "" || user.Username == "" {
-		return "", 0, status.Error(codes.InvalidArgument, "username or password is empty")
+		return nil, status.Error(codes.InvalidArgument, "username or password is empty")
 	}
 
 	result, err := s.Repo.GetUser(ctx, user)
+	found := err == nil
+	if status.Code(err) == codes.NotFound {
+		result = models.User{Username: user.Username}
+	} else if err != nil {
+		return nil, err
+	}
+
+	failure := models.LoginFailure{Username: user.Username, ClientIP: clientIP}
+	if found {
+		failure.UserID = &result.ID
+		event.ActorID = &result.ID
+	}
+	retry, err := s.retryAfter(ctx, result, clientIP)
 	if err != nil {
-		return "", 0, status.Error(codes.Internal, fmt.Sprintf("service.LogIn: %s", err.Error()))
+		return nil, err
+	}
+	if retry > 0 {
+		failure.Reason = models.LoginLocked
+		return nil, s.loginFailed(ctx, failure, status.Errorf(codes.ResourceExhausted,
+			"service.LogIn: too many failed attempts, retry in %s", retry.Round(time.Second)))
+	}
+
+	if !found {
+		bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(user.Password))
+		failure.Reason = models.LoginUnknownUser
+		return nil, s.loginFailed(ctx, failure, errInvalidCredentials)
+	}
+	if err = bcrypt.CompareHashAndPassword([]byte(result.Password), []byte(user.Password)); err != nil {
+		failure.Reason = models.LoginWrongPassword
+		return nil, s.loginFailed(ctx, failure, errInvalidCredentials)
+	}
+	if result.DisabledAt != nil {
+		failure.Reason = models.LoginDisabled
+		return nil, s.loginFailed(ctx, failure, status.Error(codes.Unauthenticated, "service.LogIn: account is disabled"))
 	}
 
-	err = bcrypt.CompareHashAndPassword([]byte(result.Password), []byte(user.Password))
+	if result.TOTPEnabledAt != nil {
+		return s.loginChallenge(ctx, result.ID)
+	}
+	return s.startSession(ctx, result)
+}
+
+// startSession completes a login of the user.
+func (s *AuthService) startSession(ctx context.Context, user models.User) (*models.Session, error) {
+	session, refreshToken, err := s.newSession(user, uuid.NewString())
 	if err != nil {
-		return "", 0, status.Error(codes.Unauthenticated, "password does not match")
+		return nil, err
+	}
+	if err = s.Repo.CreateRefreshToken(ctx, refreshToken); err != nil {
+		return nil, err
+	}
+	if err = s.Repo.SetLastLogin(ctx, user.ID); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// Refresh exchanges a refresh token for a new session. Refresh tokens are single-use: presenting one that has
+// already been exchanged means it has leaked, so every token of its family is revoked.
+func (s *AuthService) Refresh(ctx context.Context, token string) (*models.Session, error) {
+	if token == "" {
+		return nil, status.Error(codes.InvalidArgument, "service.Refresh: refresh token is empty")
+	}
+	stored, err := s.Repo.GetRefreshToken(ctx, hashToken(token))
+	if status.Code(err) == codes.NotFound {
+		return nil, status.Error(codes.Unauthenticated, "service.Refresh: invalid refresh token")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if stored.RevokedAt != nil {
+		if err = s.Repo.RevokeTokenFamily(ctx, stored.FamilyID); err != nil {
+			return nil, err
+		}
+		return nil, status.Error(codes.Unauthenticated, "service.Refresh: refresh token has been revoked")
+	}
+	if time.Now().After(stored.ExpiresAt) {
+		return nil, status.Error(codes.Unauthenticated, "service.Refresh: refresh token has expired")
 	}
 
-	token, err := s.GenerateToken(result)
+	// Roles are read again so that changes take effect on the next refresh
+	user, err := s.Repo.GetUserByID(ctx, stored.UserID)
 	if err != nil {
-		return "", 0, status.Error(codes.Internal, fmt.Sprintf("service.LogIn: %s", err.Error()))
+		return nil, err
+	}
+	if user.DisabledAt != nil {
+		return nil, status.Error(codes.Unauthenticated, "service.Refresh: account is disabled")
 	}
 
-	return token, result.ID, nil
+	session, replacement, err := s.newSession(user, stored.FamilyID)
+	if err != nil {
+		return nil, err
+	}
+	err = s.Repo.RotateRefreshToken(ctx, stored.ID, replacement)
+	if status.Code(err) == codes.Aborted {
+		// Lost the race against another refresh with the same token, which is reuse as well
+		if err = s.Repo.RevokeTokenFamily(ctx, stored.FamilyID); err != nil {
+			return nil, err
+		}
+		return nil, status.Error(codes.Unauthenticated, "service.Refresh: refresh token has been revoked")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
 }
 
-func (s *AuthService) GenerateToken(user models.User) (string, error) {
+// LogOut revokes the access token until it expires and the refresh token along with its family.
+// Tokens that are already invalid are skipped, so logging out twice is not an error.
+func (s *AuthService) LogOut(ctx context.Context, accessToken, refreshToken string) error {
+	if accessToken == "" && refreshToken == "" {
+		return status.Error(codes.InvalidArgument, "service.LogOut: no token to revoke")
+	}
+	if accessToken != "" {
+		if claims, err := s.parseToken(accessToken); err == nil && claims.ID != "" {
+			if err = s.Repo.RevokeAccessToken(ctx, claims.ID, claims.ExpiresAt.Time); err != nil {
+				return err
+			}
+		}
+	}
+	if refreshToken != "" {
+		stored, err := s.Repo.GetRefreshToken(ctx, hashToken(refreshToken))
+		if status.Code(err) == codes.NotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return s.Repo.RevokeTokenFamily(ctx, stored.FamilyID)
+	}
+	return nil
+}
+
+// GenerateToken signs an access token of the user and returns it with its expiry.
+func (s *AuthService) GenerateToken(userID int64, roles []string) (string, time.Time, error) {
+	now := time.Now()
 	claims := NewCustomClaims(
-		user.ID,
+		userID,
+		roles,
 		jwt.RegisteredClaims{
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
-			Issuer:    "auth-service",
+			ID:        uuid.NewString(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(accessTokenTTL)),
+			Issuer:    models.TokenIssuer,
 		})
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	var signedToken string
+	var err error
+	if s.Keys != nil {
+		signedToken, err = s.Keys.sign(claims)
+	} else {
+		signedToken, err = jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(s.JWTSecret))
+	}
+	if err != nil {
+		return "", time.Time{}, status.Error(codes.Internal, fmt.Sprintf("service.GenerateToken: %s", err.Error()))
+	}
+
+	return signedToken, claims.ExpiresAt.Time, nil
+}
+
+// newSession issues an access token and a refresh token of the given family. The refresh token is returned
+// both in plain text for the client and hashed for storage.
+func (s *AuthService) newSession(user models.User, familyID string) (*models.Session, models.RefreshToken, error) {
+	roles := user.Roles
+	if len(roles) == 0 {
+		roles = []string{models.RoleUser}
+	}
+	accessToken, accessExpiresAt, err := s.GenerateToken(user.ID, roles)
+	if err != nil {
+		return nil, models.RefreshToken{}, err
+	}
+
+	raw := make([]byte, 32)
+	if _, err = rand.Read(raw); err != nil {
+		return nil, models.RefreshToken{}, status.Error(codes.Internal, fmt.Sprintf("service.newSession: %s", err.Error()))
+	}
+	refreshToken := base64.RawURLEncoding.EncodeToString(raw)
+	refreshExpiresAt := time.Now().Add(refreshTokenTTL)
+
+	return &models.Session{
+		UserID:           user.ID,
+		AccessToken:      accessToken,
+		AccessExpiresAt:  accessExpiresAt,
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: refreshExpiresAt,
+	}, models.RefreshToken{
+		UserID:    user.ID,
+		TokenHash: hashToken(refreshToken),
+		FamilyID:  familyID,
+		ExpiresAt: refreshExpiresAt,
+	}, nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
 
-	signedToken, err := token.SignedString([]byte(s.JWTSecret))
+// ValidateToken verifies the signature and expiry of the token, checks that it has not been revoked
+// and returns the user it was issued to.
+func (s *AuthService) ValidateToken(ctx context.Context, tokenString string) (principal.Principal, error) {
+	claims, err := s.parseToken(tokenString)
 	if err != nil {
-		return "", status.Error(codes.Internal, fmt.Sprintf("service.GenerateToken: %s", err.Error()))
+		return principal.Principal{}, err
 	}
 
-	return signedToken, nil
+	if claims.ID != "" {
+		var issuedAt time.Time
+		if claims.IssuedAt != nil {
+			issuedAt = claims.IssuedAt.Time
+		}
+		revoked, err := s.Repo.IsAccessTokenRevoked(ctx, claims.ID, claims.UserID, issuedAt)
+		if err != nil {
+			return principal.Principal{}, err
+		}
+		if revoked {
+			return principal.Principal{}, status.Error(codes.Unauthenticated, "service.ValidateToken: token has been revoked")
+		}
+	}
+
+	return principal.Principal{
+		UserID:    claims.UserID,
+		Roles:     claims.Roles,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}, nil
 }
 
-func (s *AuthService) ValidateToken(tokenString string) (bool, error) {
+func (s *AuthService) parseToken(tokenString string) (*CustomClaims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &CustomClaims{}, func(token *jwt.Token) (interface{}, error) {
+		if s.Keys != nil {
+			return s.Keys.verificationKey(token)
+		}
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
 		return []byte(s.JWTSecret), nil
-	})
+	}, jwt.WithIssuer(models.TokenIssuer), jwt.WithExpirationRequired())
 
 	if err != nil {
-		return false, status.Error(codes.Internal, fmt.Sprintf("service.ValidateToken: %s", err.Error()))
+		return nil, status.Error(codes.Unauthenticated, fmt.Sprintf("service.ValidateToken: %s", err.Error()))
 	}
 
-	_, ok := token.Claims.(*CustomClaims)
+	claims, ok := token.Claims.(*CustomClaims)
 
-	if !ok {
-		return false, status.Error(codes.Unauthenticated, "invalid token claims")
+	if !ok || claims.UserID == 0 {
+		return nil, status.Error(codes.Unauthenticated, "invalid token claims")
 	}
 
 	if !token.Valid {
-		return false, status.Error(codes.Unauthenticated, "invalid token")
+		return nil, status.Error(codes.Unauthenticated, "invalid token")
 	}
 
-	return true, nil
+	return claims, nil
+}
+
+// JWKS returns the public keys access tokens are verified with. The set is empty when tokens are signed
+// with the shared secret, which cannot be published.
+func (s *AuthService) JWKS() (jwks.Set, error) {
+	if s.Keys == nil {
+		return jwks.Set{Keys: make([]jwks.Key, 0)}, nil
+	}
+	return s.Keys.JWKS()
+}
+
+// RevokedTokens returns the ids of revoked access tokens that have not expired yet, the users disabled while
+// tokens issued to them may still be valid and the time tokens are valid after for users who changed their
+// password within that time, for verifiers that check tokens against the JWKS instead of calling ValidateToken.
+func (s *AuthService) RevokedTokens(ctx context.Context) ([]string, []int64, map[int64]time.Time, error) {
+	ids, err := s.Repo.ListRevokedTokens(ctx)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	since := time.Now().Add(-accessTokenTTL)
+	disabledUsers, err := s.Repo.ListDisabledUsers(ctx, since)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	validAfter, err := s.Repo.ListTokensValidAfter(ctx, since)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return ids, disabledUsers, validAfter, nil
+}
+
+// tokensValidAfter is the time access tokens have to be issued after to outlive a password change made now.
+// Tokens carry their issue time in whole seconds, so the session started right after the change is kept.
+func tokensValidAfter() time.Time {
+	return time.Now().Truncate(time.Second)
 }
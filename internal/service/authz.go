@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"house-of-neural-networks/internal/models"
+	"house-of-neural-networks/internal/principal"
+)
+
+var permissionRank = map[string]int{
+	models.PermissionRead:   1,
+	models.PermissionInfer:  2,
+	models.PermissionManage: 3,
+	models.RoleOwner:        4,
+}
+
+// roleLoader returns the access level of the user to the model through an organization or a grant,
+// empty if there is none.
+type roleLoader func(ctx context.Context, modelID, userID int64) (string, error)
+
+// currentUser returns the id of the user the request is made on behalf of.
+func currentUser(ctx context.Context, method string) (int64, error) {
+	p, ok := principal.FromContext(ctx)
+	if !ok {
+		return 0, status.Errorf(codes.Unauthenticated, "%s: no authenticated user", method)
+	}
+	return p.UserID, nil
+}
+
+// allowedModels is the subset of the models the current principal may access.
+func allowedModels[T any](ctx context.Context, list []T, modelID func(T) int64) []T {
+	p, _ := principal.FromContext(ctx)
+	if p.ModelIDs == nil {
+		return list
+	}
+	result := make([]T, 0, len(list))
+	for _, item := range list {
+		if p.AllowsModel(modelID(item)) {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// authorizeModel checks that the current user has at least the required access level to the model
+// and sets model.Role to the level they have.
+// Models of an organization are owned by the organization, not by the member who uploaded them.
+// Administrators have owner access to every model.
+// API keys restricted to other models get no access at all.
+func authorizeModel(ctx context.Context, method string, model *models.Model, required string, loadRole roleLoader) error {
+	userID, err := currentUser(ctx, method)
+	if err != nil {
+		return err
+	}
+	p, _ := principal.FromContext(ctx)
+	if !p.AllowsModel(model.ID) {
+		return status.Errorf(codes.PermissionDenied, "%s: api key does not give access to model (id %d)", method, model.ID)
+	}
+
+	if p.HasRole(models.RoleAdmin) || model.OrganizationID == 0 && model.UserID == userID {
+		model.Role = models.RoleOwner
+	} else {
+		role, err := loadRole(ctx, model.ID, userID)
+		if err != nil {
+			return err
+		}
+		model.Role = strongestPermission(role, model.PublicPermission)
+	}
+
+	if permissionRank[model.Role] < permissionRank[required] {
+		return status.Errorf(codes.PermissionDenied, "%s: %s access to model (id %d) required", method, required, model.ID)
+	}
+	return nil
+}
+
+func strongestPermission(a, b string) string {
+	if permissionRank[a] >= permissionRank[b] {
+		return a
+	}
+	return b
+}
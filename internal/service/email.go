@@ -0,0 +1,189 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"house-of-neural-networks/internal/mailer"
+	"house-of-neural-networks/internal/models"
+	"house-of-neural-networks/pkg/logger"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	emailVerificationTTL = 24 * time.Hour
+	passwordResetTTL     = time.Hour
+)
+
+// SendVerificationEmail sends a new verification link to the email of the current user.
+func (s *AuthService) SendVerificationEmail(ctx context.Context) error {
+	userID, err := currentUser(ctx, "service.SendVerificationEmail")
+	if err != nil {
+		return err
+	}
+	if s.Mailer == nil {
+		return status.Error(codes.FailedPrecondition, "service.SendVerificationEmail: email is not configured")
+	}
+
+	user, err := s.Repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.Email == "" {
+		return status.Error(codes.FailedPrecondition, "service.SendVerificationEmail: user has no email")
+	}
+	if user.EmailVerifiedAt != nil {
+		return status.Error(codes.FailedPrecondition, "service.SendVerificationEmail: email is already verified")
+	}
+	return s.sendVerification(ctx, user)
+}
+
+// VerifyEmail redeems the token of a verification link.
+func (s *AuthService) VerifyEmail(ctx context.Context, token string) error {
+	if token == "" {
+		return status.Error(codes.InvalidArgument, "service.VerifyEmail: token is empty")
+	}
+
+	stored, err := s.takeEmailToken(ctx, token, models.PurposeVerifyEmail, "service.VerifyEmail")
+	if err != nil {
+		return err
+	}
+	err = s.Repo.SetEmailVerified(ctx, stored.UserID, stored.Email)
+	if status.Code(err) == codes.NotFound {
+		return status.Error(codes.InvalidArgument, "service.VerifyEmail: email has been changed")
+	}
+	return err
+}
+
+// RequestPasswordReset sends a password reset link to every account registered with the email. Whether
+// there is such an account is not revealed, so failures to send a link are logged rather than returned.
+func (s *AuthService) RequestPasswordReset(ctx context.Context, email string) (err error) {
+	defer func() { s.audit(ctx, models.AuditEvent{Action: models.AuditPasswordResetRequest, Target: email}, err) }()
+
+	email = strings.TrimSpace(email)
+	if email == "" {
+		return status.Error(codes.InvalidArgument, "service.RequestPasswordReset: email is empty")
+	}
+	if s.Mailer == nil {
+		return status.Error(codes.FailedPrecondition, "service.RequestPasswordReset: email is not configured")
+	}
+
+	users, err := s.Repo.ListUsersByEmail(ctx, email)
+	if err != nil {
+		return err
+	}
+	for _, user := range users {
+		if err := s.sendPasswordReset(ctx, user); err != nil {
+			logger.GetLoggerFromCtx(ctx).Error(ctx, err.Error(),
+				zap.String("Function", logger.GetFunctionName()),
+				zap.Int64("user_id", user.ID),
+			)
+		}
+	}
+	return nil
+}
+
+func (s *AuthService) sendPasswordReset(ctx context.Context, user models.User) error {
+	token, err := s.createEmailToken(ctx, user, models.PurposeResetPassword, passwordResetTTL)
+	if err != nil {
+		return err
+	}
+	err = s.Mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Password reset",
+		Body: fmt.Sprintf("Someone asked to reset the password of %s.\n\n"+
+			"To set a new one, send this token along with the new password to %s/password/reset:\n\n%s\n\n"+
+			"The token expires in %s. If you did not ask for it, ignore this email.\n",
+			user.Username, s.PublicURL, token, passwordResetTTL),
+	})
+	if err != nil {
+		return status.Errorf(codes.Unavailable, "service.RequestPasswordReset: %s", err)
+	}
+	return nil
+}
+
+// ResetPassword sets a new password with the token of a password reset email and ends every session of the
+// user: refresh tokens are revoked and access tokens already issued are rejected. Receiving the token proves
+// the email as well.
+func (s *AuthService) ResetPassword(ctx context.Context, token, password string) (err error) {
+	event := models.AuditEvent{Action: models.AuditPasswordReset}
+	defer func() { s.audit(ctx, event, err) }()
+
+	if token == "" || password == "" {
+		return status.Error(codes.InvalidArgument, "service.ResetPassword: token or password is empty")
+	}
+
+	stored, err := s.takeEmailToken(ctx, token, models.PurposeResetPassword, "service.ResetPassword")
+	if err != nil {
+		return err
+	}
+	event.ActorID, event.Target = &stored.UserID, auditTarget("user", stored.UserID)
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return status.Errorf(codes.Internal, "service.ResetPassword: %s", err)
+	}
+	if err = s.Repo.SetPassword(ctx, stored.UserID, string(hash), tokensValidAfter()); err != nil {
+		return err
+	}
+	if err = s.Repo.SetEmailVerified(ctx, stored.UserID, stored.Email); status.Code(err) != codes.NotFound {
+		return err
+	}
+	return nil
+}
+
+func (s *AuthService) sendVerification(ctx context.Context, user models.User) error {
+	token, err := s.createEmailToken(ctx, user, models.PurposeVerifyEmail, emailVerificationTTL)
+	if err != nil {
+		return err
+	}
+	link := fmt.Sprintf("%s/email/verify?token=%s", s.PublicURL, url.QueryEscape(token))
+	err = s.Mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Confirm your email",
+		Body: fmt.Sprintf("Hello %s,\n\nconfirm your email by opening the link below:\n\n%s\n\n"+
+			"The link expires in %s.\n", user.Username, link, emailVerificationTTL),
+	})
+	if err != nil {
+		return status.Errorf(codes.Unavailable, "service.sendVerification: %s", err)
+	}
+	return nil
+}
+
+// createEmailToken stores a new token for the user and returns it.
+func (s *AuthService) createEmailToken(ctx context.Context, user models.User, purpose string, ttl time.Duration) (string, error) {
+	token, err := randomToken(32)
+	if err != nil {
+		return "", status.Errorf(codes.Internal, "service.createEmailToken: %s", err)
+	}
+	err = s.Repo.CreateEmailToken(ctx, models.EmailToken{
+		TokenHash: hashToken(token),
+		UserID:    user.ID,
+		Purpose:   purpose,
+		Email:     user.Email,
+		ExpiresAt: time.Now().Add(ttl),
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// takeEmailToken redeems the token, which is rejected when it is unknown, used or expired.
+func (s *AuthService) takeEmailToken(ctx context.Context, token, purpose, method string) (models.EmailToken, error) {
+	stored, err := s.Repo.TakeEmailToken(ctx, hashToken(token), purpose)
+	if status.Code(err) == codes.NotFound {
+		return models.EmailToken{}, status.Errorf(codes.InvalidArgument, "%s: invalid token", method)
+	}
+	if err != nil {
+		return models.EmailToken{}, err
+	}
+	if time.Now().After(stored.ExpiresAt) {
+		return models.EmailToken{}, status.Errorf(codes.InvalidArgument, "%s: token has expired", method)
+	}
+	return stored, nil
+}
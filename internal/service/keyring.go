@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"house-of-neural-networks/internal/models"
+	"house-of-neural-networks/pkg/jwks"
+	"sync"
+	"time"
+)
+
+// Keyring holds the asymmetric keys access tokens are signed with. Tokens are signed with the newest key,
+// older keys are kept until every token they signed has expired so that verifiers can still check them.
+// Keys live in the database, which lets every instance of the auth service sign with the same keys.
+type Keyring struct {
+	repo      AuthRepo
+	algorithm string
+	rotation  time.Duration
+
+	mu   sync.RWMutex
+	keys []signingKey // newest first
+}
+
+type signingKey struct {
+	id        string
+	algorithm string
+	private   crypto.Signer
+	createdAt time.Time
+}
+
+func NewKeyring(repo AuthRepo, algorithm string, rotation time.Duration) (*Keyring, error) {
+	if algorithm != jwks.RS256 && algorithm != jwks.EdDSA {
+		return nil, fmt.Errorf("unsupported signing algorithm %q", algorithm)
+	}
+	if rotation <= 0 {
+		return nil, fmt.Errorf("key rotation period must be positive, got %s", rotation)
+	}
+	return &Keyring{repo: repo, algorithm: algorithm, rotation: rotation}, nil
+}
+
+// Rotate reloads the keys and generates a new one when the newest key is older than the rotation period
+// or signs with another algorithm. It is meant to be called periodically, more often than the rotation period.
+func (k *Keyring) Rotate(ctx context.Context) error {
+	stored, err := k.repo.ListSigningKeys(ctx)
+	if err != nil {
+		return err
+	}
+	keys := make([]signingKey, 0, len(stored)+1)
+	for _, key := range stored {
+		private, err := x509.ParsePKCS8PrivateKey(key.PrivateKey)
+		if err != nil {
+			return status.Errorf(codes.Internal, "service.Keyring.Rotate: key %s: %s", key.ID, err)
+		}
+		signer, ok := private.(crypto.Signer)
+		if !ok {
+			return status.Errorf(codes.Internal, "service.Keyring.Rotate: key %s: unsupported key type %T", key.ID, private)
+		}
+		keys = append(keys, signingKey{id: key.ID, algorithm: key.Algorithm, private: signer, createdAt: key.CreatedAt})
+	}
+
+	if len(keys) == 0 || keys[0].algorithm != k.algorithm || time.Since(keys[0].createdAt) >= k.rotation {
+		key, err := k.generate(ctx)
+		if err != nil {
+			return err
+		}
+		keys = append([]signingKey{key}, keys...)
+	}
+
+	k.mu.Lock()
+	k.keys = keys
+	k.mu.Unlock()
+	return nil
+}
+
+func (k *Keyring) generate(ctx context.Context) (signingKey, error) {
+	var private crypto.Signer
+	var err error
+	switch k.algorithm {
+	case jwks.RS256:
+		private, err = rsa.GenerateKey(rand.Reader, 2048)
+	case jwks.EdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	}
+	if err != nil {
+		return signingKey{}, status.Errorf(codes.Internal, "service.Keyring.generate: %s", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return signingKey{}, status.Errorf(codes.Internal, "service.Keyring.generate: %s", err)
+	}
+
+	key := signingKey{id: uuid.NewString(), algorithm: k.algorithm, private: private, createdAt: time.Now()}
+	err = k.repo.CreateSigningKey(ctx, models.SigningKey{
+		ID:         key.id,
+		Algorithm:  key.algorithm,
+		PrivateKey: der,
+		CreatedAt:  key.createdAt,
+		// The key signs until the next rotation, the tokens signed last expire an access token TTL later.
+		// Another TTL covers a rotation that runs late.
+		ExpiresAt: key.createdAt.Add(k.rotation + 2*accessTokenTTL),
+	})
+	if err != nil {
+		return signingKey{}, err
+	}
+	return key, nil
+}
+
+// sign signs the claims with the newest key and names the key in the kid header.
+func (k *Keyring) sign(claims jwt.Claims) (string, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	if len(k.keys) == 0 {
+		return "", errors.New("no signing key has been loaded")
+	}
+	key := k.keys[0]
+
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(key.algorithm), claims)
+	token.Header["kid"] = key.id
+	return token.SignedString(key.private)
+}
+
+// verificationKey returns the public key of the kid header of the token.
+func (k *Keyring) verificationKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	for _, key := range k.keys {
+		if key.id == kid {
+			if token.Method.Alg() != key.algorithm {
+				return nil, fmt.Errorf("key %s signs with %s, not %s", kid, key.algorithm, token.Method.Alg())
+			}
+			return key.private.Public(), nil
+		}
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// JWKS returns the public keys of the tokens that have not expired yet.
+func (k *Keyring) JWKS() (jwks.Set, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	set := jwks.Set{Keys: make([]jwks.Key, 0, len(k.keys))}
+	for _, key := range k.keys {
+		public, err := jwks.NewKey(key.id, key.algorithm, key.private.Public())
+		if err != nil {
+			return jwks.Set{}, status.Errorf(codes.Internal, "service.Keyring.JWKS: %s", err)
+		}
+		set.Keys = append(set.Keys, public)
+	}
+	return set, nil
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"house-of-neural-networks/internal/models"
+	"house-of-neural-networks/pkg/logger"
+	"sync"
+	"time"
+)
+
+// LoginThrottle slows down password guessing. Once an account has had Attempts failed logins within Window,
+// or a client address IPAttempts, every further attempt has to wait twice as long after the last failure as
+// the one before, starting at a second and up to Window.
+type LoginThrottle struct {
+	Attempts   int
+	IPAttempts int
+	Window     time.Duration
+}
+
+var DefaultLoginThrottle = LoginThrottle{Attempts: 5, IPAttempts: 20, Window: 15 * time.Minute}
+
+// errInvalidCredentials is returned for unknown users and wrong passwords alike, so that the response does
+// not reveal which usernames exist.
+var errInvalidCredentials = status.Error(codes.Unauthenticated, "service.LogIn: invalid username or password")
+
+// dummyPasswordHash is compared against when the user does not exist, so that the response takes as long
+// as for a wrong password.
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+	return hash
+})
+
+// wait returns how long after the last counted failure the next attempt is allowed.
+func (t LoginThrottle) wait(failures models.LoginFailureCount, free int) time.Duration {
+	if failures.Last == nil || failures.Count < free {
+		return 0
+	}
+	wait := time.Second
+	for i := free; i < failures.Count && wait < t.Window; i++ {
+		wait *= 2
+	}
+	return min(wait, t.Window)
+}
+
+// retryAfter returns how long the client has to wait before logging in to the account again, zero if it
+// may try now.
+func (s *AuthService) retryAfter(ctx context.Context, user models.User, clientIP string) (time.Duration, error) {
+	now := time.Now()
+	since := now.Add(-s.Throttle.Window)
+	accountSince := since
+	if user.LastLoginAt != nil && user.LastLoginAt.After(since) {
+		accountSince = *user.LastLoginAt
+	}
+	account, client, err := s.Repo.CountLoginFailures(ctx, user.Username, clientIP, since, accountSince)
+	if err != nil {
+		return 0, err
+	}
+
+	var retry time.Duration
+	if wait := s.Throttle.wait(account, s.Throttle.Attempts); wait > 0 {
+		retry = max(retry, account.Last.Add(wait).Sub(now))
+	}
+	// Calls that do not come through the gateway carry no address
+	if wait := s.Throttle.wait(client, s.Throttle.IPAttempts); wait > 0 && clientIP != "" {
+		retry = max(retry, client.Last.Add(wait).Sub(now))
+	}
+	return max(retry, 0), nil
+}
+
+// loginFailed records the failure and returns the error to reply with.
+func (s *AuthService) loginFailed(ctx context.Context, failure models.LoginFailure, reply error) error {
+	logger.GetLoggerFromCtx(ctx).Info(ctx, "login failed",
+		zap.String("username", failure.Username),
+		zap.String("client_ip", failure.ClientIP),
+		zap.String("reason", failure.Reason),
+	)
+	if err := s.Repo.RecordLoginFailure(ctx, failure); err != nil {
+		return err
+	}
+	return reply
+}
@@ -2,114 +2,406 @@ package service
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/timestamppb"
 	"house-of-neural-networks/internal/models"
+	"house-of-neural-networks/internal/principal"
 	"house-of-neural-networks/internal/triton"
 	client "house-of-neural-networks/pkg/api/message"
+	"house-of-neural-networks/pkg/tensor"
+	"strconv"
+	"strings"
 	"time"
 )
 
 type MessageRepo interface {
 	SaveMessage(ctx context.Context, msg models.Message) error
-	GetMessages(ctx context.Context, userID, modelID int64) ([]models.Message, error)
-	GetModelName(ctx context.Context, model models.Model) (string, error)
-	GetVersionNumber(ctx context.Context, version models.Version) (int, error)
+	GetMessages(ctx context.Context, page models.MessagePage) ([]models.Message, error)
+	CountMessages(ctx context.Context, filter models.MessageFilter) (int64, error)
+	ListChats(ctx context.Context, userID int64) ([]models.Chat, error)
+	DeleteMessage(ctx context.Context, userID, messageID int64, modelIDs []int64) error
+	DeleteMessages(ctx context.Context, filter models.MessageFilter) (int64, error)
+	GetModel(ctx context.Context, model models.Model) (*models.Model, error)
+	GetVersion(ctx context.Context, version models.Version) (*models.Version, error)
+	GetRole(ctx context.Context, modelID, userID int64) (string, error)
 }
 
 type TritonClient interface {
 	RequestAnswer(ctx context.Context, question string) (string, error)
 }
 
+// MessageService records deletions of the chat history in Audit, if set.
 type MessageService struct {
 	Repo   MessageRepo
 	triton *triton.TritonClient
+	Audit  AuditLog
 }
 
 func NewMessageService(repo MessageRepo, triton *triton.TritonClient) *MessageService {
 	return &MessageService{Repo: repo, triton: triton}
 }
 
-func (s *MessageService) ProcessMessage(ctx context.Context, userID, modelID, versionID int64, inputs []*client.Input) ([]string, error) {
-	modelName, err := s.Repo.GetModelName(ctx, models.Model{ID: modelID})
+func (s *MessageService) ProcessMessage(ctx context.Context, modelID, versionID int64, inputs []*client.Tensor) (map[string]*client.Tensor, error) {
+	userID, err := currentUser(ctx, "SendMessage")
 	if err != nil {
-		return []string{}, status.Errorf(codes.Internal, "SendMessage: %s", err)
+		return nil, err
 	}
-	versionNumber, err := s.Repo.GetVersionNumber(ctx, models.Version{ID: versionID})
+	model, err := s.Repo.GetModel(ctx, models.Model{ID: modelID})
 	if err != nil {
-		return []string{}, status.Errorf(codes.Internal, "SendMessage: %s", err)
+		return nil, wrapError("SendMessage", err)
 	}
+	if err = authorizeModel(ctx, "SendMessage", model, models.PermissionInfer, s.Repo.GetRole); err != nil {
+		return nil, wrapError("SendMessage", err)
+	}
+	version, err := s.Repo.GetVersion(ctx, models.Version{ID: versionID})
+	if err != nil {
+		return nil, wrapError("SendMessage", err)
+	}
+	if version.ModelID != modelID {
+		return nil, status.Errorf(codes.NotFound, "SendMessage: version (id %d) of model (id %d) not found", versionID, modelID)
+	}
+	modelName, versionNumber := model.Name, version.Number
 	ready, err := triton.ModelReadyRequest(s.triton.Client, modelName, fmt.Sprint(versionNumber))
 	if err != nil {
-		return []string{}, status.Errorf(codes.Internal, "SendMessage: %s", err)
+		return nil, wrapError("SendMessage", err)
 	}
 	if !ready {
 		err = triton.LoadModelRequest(s.triton.Client, modelName)
 		if err != nil {
-			return []string{}, status.Errorf(codes.Internal, "SendMessage: %s", err)
+			return nil, wrapError("SendMessage", err)
 		}
 	}
-	inputsInt := make([][]int32, 0, len(inputs))
-	for i, input := range inputs {
-		inputsInt = append(inputsInt, make([]int32, 0, len(input.GetValues())))
-		for _, val := range input.GetValues() {
-			inputsInt[i] = append(inputsInt[i], val)
-		}
+
+	schema, err := triton.GetModelSchema(s.triton.Client, modelName, fmt.Sprint(versionNumber))
+	if err != nil {
+		return nil, wrapError("SendMessage", err)
+	}
+	tensors := make([]triton.Tensor, 0, len(inputs))
+	for _, input := range inputs {
+		tensors = append(tensors, triton.Tensor{
+			Name:     input.GetName(),
+			Datatype: input.GetDatatype(),
+			Shape:    input.GetShape(),
+			Contents: tensor.Contents{
+				Bools:  input.GetContents().GetBoolContents(),
+				Ints:   input.GetContents().GetIntContents(),
+				Uints:  input.GetContents().GetUintContents(),
+				Floats: input.GetContents().GetFpContents(),
+				Bytes:  input.GetContents().GetBytesContents(),
+			},
+		})
+	}
+	inferRequest, err := triton.BuildInferRequest(schema, tensors)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "SendMessage: %s", err)
+	}
+	inferResponse, err := triton.ModelInferRequest(s.triton.Client, inferRequest)
+	if err != nil {
+		return nil, wrapError("SendMessage", err)
 	}
-	rawInput := triton.Preprocess(inputsInt)
-	inferResponse := triton.ModelInferRequest(s.triton.Client, rawInput, modelName, fmt.Sprint(versionNumber))
-	outputs := triton.Postprocess(inferResponse)
-	outputData0 := outputs[0]
-	outputData1 := outputs[1]
-	resultsStr := make([]string, 0, len(outputData0)*2)
-	for i := 0; i < len(outputData0); i++ {
-		resultsStr = append(resultsStr, fmt.Sprintf("%d + %d = %d", inputsInt[0][i], inputsInt[1][i], outputData0[i]))
-		resultsStr = append(resultsStr, fmt.Sprintf("%d - %d = %d", inputsInt[0][i], inputsInt[1][i], outputData1[i]))
-	}
-	err = s.Repo.SaveMessage(ctx, models.Message{
+	tensors, err = triton.ParseInferResponse(inferResponse)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "SendMessage: %s", err)
+	}
+
+	outputs := make(map[string]*client.Tensor, len(tensors))
+	for _, output := range tensors {
+		outputs[output.Name] = tensorToProto(output)
+	}
+
+	msg := models.Message{
 		UserID:    userID,
 		ModelID:   modelID,
 		VersionID: versionID,
-		Input1:    rawInput[0],
-		Input2:    rawInput[1],
-		Results:   resultsStr,
+		Inputs:    make([]models.TensorDescriptor, 0, len(inferRequest.GetInputs())),
+		Outputs:   make([]models.TensorDescriptor, 0, len(inferResponse.GetOutputs())),
 		CreatedAt: time.Now(),
-	})
+	}
+	for i, input := range inferRequest.GetInputs() {
+		msg.Inputs = append(msg.Inputs, models.TensorDescriptor{
+			Name:     input.GetName(),
+			Datatype: input.GetDatatype(),
+			Shape:    input.GetShape(),
+			Data:     inferRequest.GetRawInputContents()[i],
+		})
+	}
+	for i, output := range inferResponse.GetOutputs() {
+		msg.Outputs = append(msg.Outputs, models.TensorDescriptor{
+			Name:     output.GetName(),
+			Datatype: output.GetDatatype(),
+			Shape:    output.GetShape(),
+			Data:     inferResponse.GetRawOutputContents()[i],
+		})
+	}
+	err = s.Repo.SaveMessage(ctx, msg)
 	if err != nil {
-		return []string{}, status.Errorf(codes.Internal, "SendMessage: %s", err)
+		return nil, wrapError("SendMessage", err)
 	}
 
-	return resultsStr, nil
+	return outputs, nil
 }
 
-func (s *MessageService) GetMessages(ctx context.Context, userID, modelID int64) ([]*client.Message, error) {
-	dialog, err := s.Repo.GetMessages(ctx, userID, modelID)
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+	exportBatchSize = 500
+)
+
+// GetMessages returns a page of the chat history and a token of the next page, empty on the last one.
+func (s *MessageService) GetMessages(ctx context.Context, filter models.MessageFilter, pageSize int32, pageToken string, descending bool) ([]*client.Message, string, int64, error) {
+	userID, err := currentUser(ctx, "GetMessages")
+	if err != nil {
+		return nil, "", 0, err
+	}
+	filter.UserID = userID
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return nil, "", 0, status.Errorf(codes.InvalidArgument, "GetMessages: empty time range")
+	}
+	if err = s.authorizeHistory(ctx, "GetMessages", filter.ModelID); err != nil {
+		return nil, "", 0, err
+	}
+	switch {
+	case pageSize < 0:
+		return nil, "", 0, status.Errorf(codes.InvalidArgument, "GetMessages: negative page size")
+	case pageSize == 0:
+		pageSize = defaultPageSize
+	case pageSize > maxPageSize:
+		pageSize = maxPageSize
+	}
+	page := models.MessagePage{
+		Filter:     filter,
+		Descending: descending,
+		// One extra message tells whether there is a next page
+		Limit: uint64(pageSize) + 1,
+	}
+	if pageToken != "" {
+		cursor, err := decodePageToken(pageToken)
+		if err != nil {
+			return nil, "", 0, status.Errorf(codes.InvalidArgument, "GetMessages: invalid page token")
+		}
+		page.After = cursor
+	}
+
+	dialog, err := s.Repo.GetMessages(ctx, page)
+	if err != nil {
+		return nil, "", 0, wrapError("GetMessages", err)
+	}
+	total, err := s.Repo.CountMessages(ctx, filter)
 	if err != nil {
-		return nil, status.Errorf(codes.Unknown, "GetMessages: %s", err)
+		return nil, "", 0, wrapError("GetMessages", err)
+	}
+
+	nextPageToken := ""
+	if len(dialog) > int(pageSize) {
+		dialog = dialog[:pageSize]
+		last := dialog[len(dialog)-1]
+		nextPageToken = encodePageToken(models.MessageCursor{CreatedAt: last.CreatedAt, ID: last.ID})
 	}
 
 	var messages = make([]*client.Message, 0)
 	for _, msg := range dialog {
-		rawInput := [][]byte{msg.Input1, msg.Input2}
-		inputsInt := triton.BytesToInt32(rawInput)
-		messages = append(messages, &client.Message{
-			Id:        msg.ID,
-			UserId:    msg.UserID,
-			ModelId:   msg.ModelID,
-			VersionId: msg.VersionID,
-			Inputs: []*client.Input{
-				{
-					Values: inputsInt[0],
-				},
-				{
-					Values: inputsInt[1],
-				},
-			},
-			Results:   msg.Results,
-			CreatedAt: timestamppb.New(msg.CreatedAt),
+		message, err := messageToProto(msg)
+		if err != nil {
+			return nil, "", 0, status.Errorf(codes.Internal, "GetMessages: %s", err)
+		}
+		messages = append(messages, message)
+	}
+	return messages, nextPageToken, total, nil
+}
+
+func (s *MessageService) ListChats(ctx context.Context) ([]*client.Chat, error) {
+	userID, err := currentUser(ctx, "ListChats")
+	if err != nil {
+		return nil, err
+	}
+	list, err := s.Repo.ListChats(ctx, userID)
+	if err != nil {
+		return nil, wrapError("ListChats", err)
+	}
+	list = allowedModels(ctx, list, func(chat models.Chat) int64 { return chat.ModelID })
+
+	chats := make([]*client.Chat, 0, len(list))
+	for _, chat := range list {
+		chats = append(chats, &client.Chat{
+			ModelId:       chat.ModelID,
+			ModelName:     chat.ModelName,
+			VersionId:     chat.VersionID,
+			VersionNumber: chat.VersionNumber,
+			LastMessageAt: timestamppb.New(chat.LastMessageAt),
+			MessageCount:  chat.MessageCount,
 		})
 	}
-	return messages, nil
+	return chats, nil
+}
+
+func (s *MessageService) DeleteMessage(ctx context.Context, messageID int64) (err error) {
+	defer func() {
+		s.audit(ctx, models.AuditEvent{Action: models.AuditMessageDelete, Target: auditTarget("message", messageID)}, err)
+	}()
+
+	userID, err := currentUser(ctx, "DeleteMessage")
+	if err != nil {
+		return err
+	}
+	p, _ := principal.FromContext(ctx)
+	if err = s.Repo.DeleteMessage(ctx, userID, messageID, p.ModelIDs); err != nil {
+		return wrapError("DeleteMessage", err)
+	}
+	return nil
+}
+
+func (s *MessageService) DeleteChat(ctx context.Context, modelID, versionID int64) (_ int64, err error) {
+	defer func() {
+		target := auditTarget("model", modelID)
+		if versionID != 0 {
+			target = fmt.Sprintf("%s/version:%d", target, versionID)
+		}
+		s.audit(ctx, models.AuditEvent{Action: models.AuditChatDelete, Target: target}, err)
+	}()
+
+	userID, err := currentUser(ctx, "DeleteChat")
+	if err != nil {
+		return 0, err
+	}
+	if p, _ := principal.FromContext(ctx); !p.AllowsModel(modelID) {
+		return 0, status.Errorf(codes.PermissionDenied, "DeleteChat: api key does not give access to model (id %d)", modelID)
+	}
+	deleted, err := s.Repo.DeleteMessages(ctx, models.MessageFilter{UserID: userID, ModelID: modelID, VersionID: versionID})
+	if err != nil {
+		return 0, wrapError("DeleteChat", err)
+	}
+	return deleted, nil
+}
+
+// ExportMessages passes every message matching the filter to send in chronological order.
+// Messages are read in batches so that the whole history is never held in memory.
+func (s *MessageService) ExportMessages(ctx context.Context, filter models.MessageFilter, send func(*client.Message) error) error {
+	userID, err := currentUser(ctx, "ExportMessages")
+	if err != nil {
+		return err
+	}
+	filter.UserID = userID
+	if err = s.authorizeHistory(ctx, "ExportMessages", filter.ModelID); err != nil {
+		return err
+	}
+	page := models.MessagePage{Filter: filter, Limit: exportBatchSize}
+	for {
+		batch, err := s.Repo.GetMessages(ctx, page)
+		if err != nil {
+			return wrapError("ExportMessages", err)
+		}
+		for _, msg := range batch {
+			message, err := messageToProto(msg)
+			if err != nil {
+				return status.Errorf(codes.Internal, "ExportMessages: %s", err)
+			}
+			if err = send(message); err != nil {
+				return wrapError("ExportMessages", err)
+			}
+		}
+		if len(batch) < exportBatchSize {
+			return nil
+		}
+		last := batch[len(batch)-1]
+		page.After = &models.MessageCursor{CreatedAt: last.CreatedAt, ID: last.ID}
+	}
+}
+
+// authorizeHistory checks that the user may still read the model, so that e.g. a former member of an organization
+// loses the history of its models. Deleting the history stays possible without access.
+func (s *MessageService) authorizeHistory(ctx context.Context, method string, modelID int64) error {
+	model, err := s.Repo.GetModel(ctx, models.Model{ID: modelID})
+	if err != nil {
+		return wrapError(method, err)
+	}
+	if err = authorizeModel(ctx, method, model, models.PermissionRead, s.Repo.GetRole); err != nil {
+		return wrapError(method, err)
+	}
+	return nil
+}
+
+// Page tokens are opaque to clients and hold the position of the last returned message
+func encodePageToken(cursor models.MessageCursor) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d:%d", cursor.CreatedAt.UnixNano(), cursor.ID)))
+}
+
+func decodePageToken(token string) (*models.MessageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, err
+	}
+	createdAt, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return nil, fmt.Errorf("malformed page token")
+	}
+	nanos, err := strconv.ParseInt(createdAt, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	cursor := &models.MessageCursor{CreatedAt: time.Unix(0, nanos)}
+	if cursor.ID, err = strconv.ParseInt(id, 10, 64); err != nil {
+		return nil, err
+	}
+	return cursor, nil
+}
+
+func messageToProto(msg models.Message) (*client.Message, error) {
+	inputs, err := decodeDescriptors(msg.Inputs)
+	if err != nil {
+		return nil, fmt.Errorf("message %d: %w", msg.ID, err)
+	}
+	outputs, err := decodeDescriptors(msg.Outputs)
+	if err != nil {
+		return nil, fmt.Errorf("message %d: %w", msg.ID, err)
+	}
+	return &client.Message{
+		Id:        msg.ID,
+		UserId:    msg.UserID,
+		ModelId:   msg.ModelID,
+		VersionId: msg.VersionID,
+		Inputs:    inputs,
+		Outputs:   outputs,
+		CreatedAt: timestamppb.New(msg.CreatedAt),
+	}, nil
+}
+
+func decodeDescriptors(descriptors []models.TensorDescriptor) ([]*client.Tensor, error) {
+	tensors := make([]*client.Tensor, 0, len(descriptors))
+	for _, descriptor := range descriptors {
+		contents, err := tensor.Decode(descriptor.Datatype, descriptor.Shape, descriptor.Data)
+		if err != nil {
+			return nil, fmt.Errorf("tensor %s: %w", descriptor.Name, err)
+		}
+		tensors = append(tensors, tensorToProto(triton.Tensor{
+			Name:     descriptor.Name,
+			Datatype: descriptor.Datatype,
+			Shape:    descriptor.Shape,
+			Contents: contents,
+		}))
+	}
+	return tensors, nil
+}
+
+func tensorToProto(t triton.Tensor) *client.Tensor {
+	return &client.Tensor{
+		Name:     t.Name,
+		Datatype: t.Datatype,
+		Shape:    t.Shape,
+		Contents: &client.TensorContents{
+			BoolContents:  t.Contents.Bools,
+			IntContents:   t.Contents.Ints,
+			UintContents:  t.Contents.Uints,
+			FpContents:    t.Contents.Floats,
+			BytesContents: t.Contents.Bytes,
+		},
+	}
+}
+
+// wrapError keeps the gRPC code of err so that it reaches the caller unchanged.
+func wrapError(method string, err error) error {
+	st := status.Convert(err)
+	return status.Errorf(st.Code(), "%s: %s", method, st.Message())
 }
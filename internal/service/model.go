@@ -1,13 +1,21 @@
 package service
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"go.uber.org/zap"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"house-of-neural-networks/internal/models"
+	"house-of-neural-networks/internal/modelstore"
+	"house-of-neural-networks/internal/principal"
 	"house-of-neural-networks/internal/triton"
-	"os"
+	"house-of-neural-networks/pkg/logger"
+	"io"
+	"path"
+	"strings"
+	"time"
 )
 
 type ModelRepo interface {
@@ -15,84 +23,260 @@ type ModelRepo interface {
 	GetModel(ctx context.Context, model models.Model) (*models.Model, error)
 	DeleteModel(ctx context.Context, model models.Model) (bool, error)
 	CreateVersion(ctx context.Context, version models.Version) (*models.Version, error)
+	DeleteVersion(ctx context.Context, versionID int64) error
 	ListModels(ctx context.Context, userID int64) ([]*models.Model, error)
+	GetRole(ctx context.Context, modelID, userID int64) (string, error)
+	GetPermission(ctx context.Context, modelID, userID int64) (string, error)
+	GrantPermission(ctx context.Context, modelID, userID int64, permission string) error
+	RevokePermission(ctx context.Context, modelID, userID int64) error
+	SetPublicPermission(ctx context.Context, modelID int64, permission string) error
+	CreateOrganization(ctx context.Context, organization models.Organization, ownerID int64) (*models.Organization, error)
+	ListOrganizations(ctx context.Context, userID int64) ([]models.Organization, error)
+	GetMemberRole(ctx context.Context, organizationID, userID int64) (string, error)
+	ListMembers(ctx context.Context, organizationID int64) ([]models.Member, error)
+	CountOwners(ctx context.Context, organizationID int64) (int64, error)
+	SetMember(ctx context.Context, organizationID, userID int64, role string) error
+	RemoveMember(ctx context.Context, organizationID, userID int64) error
+	ListAllModels(ctx context.Context, userID int64) ([]*models.Model, error)
+	ListAccountModels(ctx context.Context, userID int64) ([]*models.Model, error)
+	DeleteAccount(ctx context.Context, userID int64, modelIDs []int64) error
+	ListRequestedDeletions(ctx context.Context) ([]int64, error)
+	CreateUpload(ctx context.Context, upload models.Upload) (*models.Upload, error)
+	GetUpload(ctx context.Context, id int64) (*models.Upload, error)
+	ExtendUpload(ctx context.Context, id int64, expiresAt time.Time) error
+	DeleteUploads(ctx context.Context, ids []int64) error
+	DeleteExpiredUploads(ctx context.Context, now time.Time) ([]int64, error)
 }
 
+// ModelService records changes of models and organizations in Audit, if set.
 type ModelService struct {
 	Repo         ModelRepo
 	TritonClient *triton.TritonClient
+	Audit        AuditLog
+	// Model repository Triton loads the models from
+	Store modelstore.ModelStore
+	// Local directory uploads are received in before they are moved to Store
+	UploadDir string
+	// How long resumable uploads are kept since data last arrived, defaultUploadExpiry if zero
+	UploadExpiry time.Duration
+
+	uploads uploadLocks
 }
 
 func NewModelService(repo ModelRepo, tritonClient *triton.TritonClient) *ModelService {
-	return &ModelService{repo, tritonClient}
+	return &ModelService{Repo: repo, TritonClient: tritonClient, Store: modelstore.NewLocalStore("/models"), UploadDir: "/models/.uploads"}
 }
 
-func (s *ModelService) CreateModel(ctx context.Context, model models.Model, filename string, content []byte) (*models.Model, error) {
-	res, err := s.Repo.CreateModel(ctx, model)
+func (s *ModelService) CreateModel(ctx context.Context, model models.Model, filename string, content []byte) (res *models.Model, err error) {
+	defer func() {
+		event := models.AuditEvent{Action: models.AuditModelCreate, Target: model.Name}
+		if res != nil {
+			event.Target = auditTarget("model", res.ID)
+		}
+		s.audit(ctx, event, err)
+	}()
+
+	userID, err := currentUser(ctx, "service.UploadModel")
 	if err != nil {
 		return nil, err
 	}
+	if model.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "service.UploadModel: name is empty")
+	}
+	// The name is the directory of the model in the repository, uploads are kept in a hidden one by default
+	if strings.HasPrefix(model.Name, ".") {
+		return nil, status.Error(codes.InvalidArgument, "service.UploadModel: name must not start with a dot")
+	}
+	if strings.ContainsAny(model.Name, `/\`) {
+		return nil, status.Error(codes.InvalidArgument, "service.UploadModel: name must not contain slashes")
+	}
+	if err = validateFilename("service.UploadModel", filename); err != nil {
+		return nil, err
+	}
+	model.UserID = userID
+	if p, _ := principal.FromContext(ctx); p.ModelIDs != nil {
+		return nil, status.Error(codes.PermissionDenied, "service.UploadModel: api key is restricted to existing models")
+	}
+	if model.OrganizationID != 0 {
+		if _, err = s.authorizeMember(ctx, "service.UploadModel", model.OrganizationID, models.OrgRoleMaintainer); err != nil {
+			return nil, err
+		}
+	}
 
-	err = os.MkdirAll(fmt.Sprintf("/models/%s", res.Name), os.ModePerm)
+	res, err = s.Repo.CreateModel(ctx, model)
 	if err != nil {
-		return nil, status.Error(codes.Internal, fmt.Sprintf("service.UploadModel: %s", err.Error()))
+		return nil, err
 	}
 
-	err = os.WriteFile(fmt.Sprintf("/models/%s/%s", res.Name, filename), content, 0644)
+	err = s.Store.Put(ctx, path.Join(res.Name, filename), content)
 	if err != nil {
+		// Otherwise the name stays taken by a model without a config
+		s.discardModel(ctx, *res)
 		return nil, status.Error(codes.Internal, fmt.Sprintf("service.UploadModel: failed to save config %s: %v", filename, err))
 	}
 
 	return res, nil
 }
 
+// discardModel deletes a model that could not be stored, errors are logged as the request has failed already.
+func (s *ModelService) discardModel(ctx context.Context, model models.Model) {
+	ctx = context.WithoutCancel(ctx)
+	if err := s.Store.RemoveAll(ctx, model.Name); err != nil {
+		logger.GetLoggerFromCtx(ctx).Error(ctx, err.Error(), zap.String("Function", logger.GetFunctionName()))
+	}
+	if _, err := s.Repo.DeleteModel(ctx, model); err != nil {
+		logger.GetLoggerFromCtx(ctx).Error(ctx, err.Error(), zap.String("Function", logger.GetFunctionName()))
+	}
+}
+
 func (s *ModelService) GetModel(ctx context.Context, model models.Model) (*models.Model, error) {
-	return s.Repo.GetModel(ctx, model)
+	res, err := s.Repo.GetModel(ctx, model)
+	if err != nil {
+		return nil, err
+	}
+	if err = authorizeModel(ctx, "service.GetModel", res, models.PermissionRead, s.Repo.GetRole); err != nil {
+		return nil, err
+	}
+	return res, nil
 }
 
+// CreateVersion stores a new version of the model with files held in memory, see UploadVersion.
 func (s *ModelService) CreateVersion(ctx context.Context, version models.Version, files []models.File) (*models.Version, error) {
-	res, err := s.Repo.CreateVersion(ctx, version)
+	i := 0
+	return s.UploadVersion(ctx, version, func() (string, io.Reader, error) {
+		if i == len(files) {
+			return "", nil, io.EOF
+		}
+		i++
+		return files[i-1].Filename, bytes.NewReader(files[i-1].Content), nil
+	})
+}
+
+func (s *ModelService) DeleteModel(ctx context.Context, model models.Model) (_ bool, err error) {
+	defer func() {
+		s.audit(ctx, models.AuditEvent{Action: models.AuditModelDelete, Target: auditTarget("model", model.ID)}, err)
+	}()
+
+	if model.ID == 0 {
+		return false, status.Error(codes.InvalidArgument, "service.UnloadModel: id is empty")
+	}
+	respModel, err := s.Repo.GetModel(ctx, model)
+	if err != nil {
+		return false, err
+	}
+	if err = authorizeModel(ctx, "service.UnloadModel", respModel, models.RoleOwner, s.Repo.GetRole); err != nil {
+		return false, err
+	}
+	deleted, err := s.Repo.DeleteModel(ctx, model)
+	if err != nil {
+		return false, err
+	}
+	// The name may be taken again, so the files must not outlive the model
+	if err = s.removeModelFiles(ctx, respModel.Name); err != nil {
+		return deleted, err
+	}
+	return deleted, nil
+}
+
+func (s *ModelService) ListModels(ctx context.Context) ([]*models.Model, error) {
+	userID, err := currentUser(ctx, "service.ListModels")
 	if err != nil {
 		return nil, err
 	}
-	model, err := s.Repo.GetModel(ctx, models.Model{ID: version.ModelID})
+	list, err := s.Repo.ListModels(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
+	list = allowedModels(ctx, list, func(model *models.Model) int64 { return model.ID })
+	for _, model := range list {
+		model.Role = strongestPermission(model.Role, model.PublicPermission)
+	}
+	return list, nil
+}
 
-	for _, file := range files {
-		err = os.MkdirAll(fmt.Sprintf("/models/%s/%d", model.Name, version.Number), os.ModePerm)
-		if err != nil {
-			return nil, status.Error(codes.Internal, fmt.Sprintf("service.UploadVersion: %s", err.Error()))
-		}
+// GrantAccess gives the user, or everyone when public is set, the permission on the model.
+// Managers may share the model for reading and inference, only the owner may appoint other managers.
+func (s *ModelService) GrantAccess(ctx context.Context, modelID, userID int64, public bool, permission string) (err error) {
+	defer func() {
+		s.audit(ctx, models.AuditEvent{Action: models.AuditAccessGrant, Target: accessTarget(modelID, userID, public) + "/" + permission}, err)
+	}()
 
-		err = os.WriteFile(fmt.Sprintf("/models/%s/%d/%s", model.Name, version.Number, file.Filename), file.Content, 0644)
-		if err != nil {
-			return nil, status.Error(codes.Internal, fmt.Sprintf("service.UploadVersion: failed to save file %s: %v", file.Filename, err))
-		}
+	if err := validateGrantee(userID, public); err != nil {
+		return err
+	}
+	switch {
+	case permission != models.PermissionRead && permission != models.PermissionInfer && permission != models.PermissionManage:
+		return status.Errorf(codes.InvalidArgument, "service.GrantAccess: unknown permission %q", permission)
+	case public && permission == models.PermissionManage:
+		return status.Error(codes.InvalidArgument, "service.GrantAccess: public access is limited to read and infer")
 	}
 
-	return res, nil
+	model, err := s.Repo.GetModel(ctx, models.Model{ID: modelID})
+	if err != nil {
+		return err
+	}
+	required := models.PermissionManage
+	if permission == models.PermissionManage {
+		required = models.RoleOwner
+	}
+	if err = authorizeModel(ctx, "service.GrantAccess", model, required, s.Repo.GetRole); err != nil {
+		return err
+	}
+
+	if public {
+		return s.Repo.SetPublicPermission(ctx, modelID, permission)
+	}
+	if model.OrganizationID == 0 && userID == model.UserID {
+		return status.Error(codes.InvalidArgument, "service.GrantAccess: the owner already has full access")
+	}
+	return s.Repo.GrantPermission(ctx, modelID, userID, permission)
 }
 
-func (s *ModelService) DeleteModel(ctx context.Context, model models.Model) (bool, error) {
-	respModel, err := s.Repo.GetModel(ctx, model)
-	if err != nil {
-		return false, err
+// RevokeAccess takes away the permission of the user, or public access when public is set.
+func (s *ModelService) RevokeAccess(ctx context.Context, modelID, userID int64, public bool) (err error) {
+	defer func() {
+		s.audit(ctx, models.AuditEvent{Action: models.AuditAccessRevoke, Target: accessTarget(modelID, userID, public)}, err)
+	}()
+
+	if err := validateGrantee(userID, public); err != nil {
+		return err
 	}
-	ready, err := triton.ModelReadyRequest(s.TritonClient.Client, respModel.Name, "")
+
+	model, err := s.Repo.GetModel(ctx, models.Model{ID: modelID})
 	if err != nil {
-		return false, err
+		return err
 	}
-	if ready {
-		err = triton.UnloadModelRequest(s.TritonClient.Client, respModel.Name)
+	required := models.PermissionManage
+	if !public {
+		granted, err := s.Repo.GetPermission(ctx, modelID, userID)
 		if err != nil {
-			return false, err
+			return err
 		}
+		if granted == models.PermissionManage {
+			required = models.RoleOwner
+		}
+	}
+	if err = authorizeModel(ctx, "service.RevokeAccess", model, required, s.Repo.GetRole); err != nil {
+		return err
+	}
+
+	if public {
+		return s.Repo.SetPublicPermission(ctx, modelID, "")
 	}
-	return s.Repo.DeleteModel(ctx, model)
+	return s.Repo.RevokePermission(ctx, modelID, userID)
 }
 
-func (s *ModelService) ListModels(ctx context.Context, userID int64) ([]*models.Model, error) {
-	return s.Repo.ListModels(ctx, userID)
+// accessTarget names the access of the user, or public access, to the model in the audit log.
+func accessTarget(modelID, userID int64, public bool) string {
+	if public {
+		return auditTarget("model", modelID) + "/public"
+	}
+	return auditTarget("model", modelID) + "/" + auditTarget("user", userID)
+}
+
+func validateGrantee(userID int64, public bool) error {
+	if public == (userID != 0) {
+		return status.Error(codes.InvalidArgument, "service: either user_id or public must be set")
+	}
+	return nil
 }
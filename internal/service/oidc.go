@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"house-of-neural-networks/internal/models"
+	"house-of-neural-networks/internal/oidc"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// oidcStateTTL is how long the user has to sign in at the provider.
+const oidcStateTTL = 10 * time.Minute
+
+// usernameChars are the characters kept when a username is derived from provider claims.
+var usernameChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// StartOIDCLogin begins the authorization code flow and returns the provider URL to send the user to along with
+// the state the provider redirects back with.
+func (s *AuthService) StartOIDCLogin(ctx context.Context) (string, string, error) {
+	if s.OIDC == nil {
+		return "", "", status.Error(codes.FailedPrecondition, "service.StartOIDCLogin: OpenID Connect login is not configured")
+	}
+
+	state := models.OIDCState{ExpiresAt: time.Now().Add(oidcStateTTL)}
+	var err error
+	for _, value := range []*string{&state.State, &state.Nonce, &state.CodeVerifier} {
+		if *value, err = randomToken(32); err != nil {
+			return "", "", status.Errorf(codes.Internal, "service.StartOIDCLogin: %s", err)
+		}
+	}
+
+	authURL, err := s.OIDC.AuthCodeURL(ctx, state.State, state.Nonce, state.CodeVerifier)
+	if err != nil {
+		return "", "", status.Errorf(codes.Unavailable, "service.StartOIDCLogin: %s", err)
+	}
+	if err = s.Repo.CreateOIDCState(ctx, state); err != nil {
+		return "", "", err
+	}
+	return authURL, state.State, nil
+}
+
+// FinishOIDCLogin redeems the code the provider redirected back with and logs in the user the ID token
+// identifies, creating the user on the first login. Users with two-factor authentication enabled get a
+// challenge to complete with VerifyLogIn, the same as after a password.
+func (s *AuthService) FinishOIDCLogin(ctx context.Context, code, state string) (session *models.Session, err error) {
+	event := models.AuditEvent{Action: models.AuditLogInOIDC}
+	defer func() {
+		// Logins with a second factor are recorded once it has been checked
+		if session == nil || session.ChallengeToken == "" {
+			s.audit(ctx, event, err)
+		}
+	}()
+
+	if s.OIDC == nil {
+		return nil, status.Error(codes.FailedPrecondition, "service.FinishOIDCLogin: OpenID Connect login is not configured")
+	}
+	if code == "" || state == "" {
+		return nil, status.Error(codes.InvalidArgument, "service.FinishOIDCLogin: code or state is empty")
+	}
+
+	pending, err := s.Repo.TakeOIDCState(ctx, state)
+	if status.Code(err) == codes.NotFound {
+		return nil, status.Error(codes.Unauthenticated, "service.FinishOIDCLogin: unknown state")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if time.Now().After(pending.ExpiresAt) {
+		return nil, status.Error(codes.Unauthenticated, "service.FinishOIDCLogin: login has expired")
+	}
+
+	idToken, err := s.OIDC.Exchange(ctx, code, pending.CodeVerifier)
+	if err != nil {
+		return nil, status.Errorf(codes.Unauthenticated, "service.FinishOIDCLogin: %s", err)
+	}
+	claims, err := s.OIDC.VerifyIDToken(ctx, idToken, pending.Nonce)
+	if errors.Is(err, oidc.ErrInvalidToken) {
+		return nil, status.Errorf(codes.Unauthenticated, "service.FinishOIDCLogin: %s", err)
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "service.FinishOIDCLogin: %s", err)
+	}
+
+	user, err := s.identityUser(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+	event.ActorID, event.Target = &user.ID, user.Username
+	if user.DisabledAt != nil {
+		return nil, status.Error(codes.Unauthenticated, "service.FinishOIDCLogin: account is disabled")
+	}
+
+	if user.TOTPEnabledAt != nil {
+		return s.loginChallenge(ctx, user.ID)
+	}
+	return s.startSession(ctx, user)
+}
+
+// identityUser returns the user linked to the provider account, provisioning one on the first login.
+// Taken usernames get a random suffix.
+func (s *AuthService) identityUser(ctx context.Context, claims *oidc.Claims) (models.User, error) {
+	issuer := s.OIDC.Issuer()
+	user, err := s.Repo.GetUserByIdentity(ctx, issuer, claims.Subject)
+	if status.Code(err) != codes.NotFound {
+		return user, err
+	}
+
+	email := ""
+	if claims.EmailVerified {
+		email = claims.Email
+	}
+	identity := models.Identity{Issuer: issuer, Subject: claims.Subject, Email: email}
+	base := identityUsername(claims)
+	username := base
+	for attempt := 0; attempt < 5; attempt++ {
+		// Provisioned users have no password and can only log in through the provider
+		user = models.User{Username: username, Email: email, Roles: []string{models.RoleUser}}
+		if email != "" {
+			// The provider has verified the email already
+			verifiedAt := time.Now()
+			user.EmailVerifiedAt = &verifiedAt
+		}
+		user.ID, err = s.Repo.CreateIdentityUser(ctx, user, identity)
+		switch status.Code(err) {
+		case codes.OK:
+			return user, nil
+		case codes.AlreadyExists:
+			suffix, err := randomToken(4)
+			if err != nil {
+				return models.User{}, status.Errorf(codes.Internal, "service.identityUser: %s", err)
+			}
+			username = fmt.Sprintf("%s-%s", base, strings.ToLower(suffix))
+		case codes.Aborted:
+			// A concurrent login of the same account provisioned the user first
+			return s.Repo.GetUserByIdentity(ctx, issuer, claims.Subject)
+		default:
+			return models.User{}, err
+		}
+	}
+	return models.User{}, status.Error(codes.AlreadyExists, "service.identityUser: no free username")
+}
+
+// identityUsername derives a username from the preferred username or the email.
+func identityUsername(claims *oidc.Claims) string {
+	candidate := claims.PreferredUsername
+	if candidate == "" {
+		candidate, _, _ = strings.Cut(claims.Email, "@")
+	}
+	candidate = usernameChars.ReplaceAllString(candidate, "")
+	if candidate == "" {
+		candidate = "user"
+	}
+	// Leaves room for the suffix of a taken username within the 50 characters of the column
+	if len(candidate) > 40 {
+		candidate = candidate[:40]
+	}
+	return candidate
+}
+
+// randomToken returns n random bytes encoded as unpadded base64url.
+func randomToken(n int) (string, error) {
+	raw := make([]byte, n)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"house-of-neural-networks/internal/models"
+)
+
+var orgRoleRank = map[string]int{
+	models.OrgRoleMember:     1,
+	models.OrgRoleMaintainer: 2,
+	models.OrgRoleOwner:      3,
+}
+
+func (s *ModelService) CreateOrganization(ctx context.Context, name string) (organization *models.Organization, err error) {
+	defer func() {
+		event := models.AuditEvent{Action: models.AuditOrganizationCreate, Target: name}
+		if organization != nil {
+			event.Target = auditTarget("organization", organization.ID)
+		}
+		s.audit(ctx, event, err)
+	}()
+
+	userID, err := currentUser(ctx, "service.CreateOrganization")
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		return nil, status.Error(codes.InvalidArgument, "service.CreateOrganization: name is empty")
+	}
+	return s.Repo.CreateOrganization(ctx, models.Organization{Name: name}, userID)
+}
+
+func (s *ModelService) ListOrganizations(ctx context.Context) ([]models.Organization, error) {
+	userID, err := currentUser(ctx, "service.ListOrganizations")
+	if err != nil {
+		return nil, err
+	}
+	return s.Repo.ListOrganizations(ctx, userID)
+}
+
+func (s *ModelService) ListMembers(ctx context.Context, organizationID int64) ([]models.Member, error) {
+	if _, err := s.authorizeMember(ctx, "service.ListMembers", organizationID, models.OrgRoleMember); err != nil {
+		return nil, err
+	}
+	return s.Repo.ListMembers(ctx, organizationID)
+}
+
+// SetMember adds the user to the organization or changes their role.
+// Owners manage every membership, maintainers may only add and keep plain members.
+func (s *ModelService) SetMember(ctx context.Context, organizationID, userID int64, role string) (err error) {
+	defer func() {
+		target := auditTarget("organization", organizationID) + "/" + auditTarget("user", userID) + "/" + role
+		s.audit(ctx, models.AuditEvent{Action: models.AuditMemberSet, Target: target}, err)
+	}()
+
+	if organizationID == 0 || userID == 0 {
+		return status.Error(codes.InvalidArgument, "service.SetMember: organization_id or user_id is empty")
+	}
+	if _, ok := orgRoleRank[role]; !ok {
+		return status.Errorf(codes.InvalidArgument, "service.SetMember: unknown role %q", role)
+	}
+	callerRole, err := s.authorizeMember(ctx, "service.SetMember", organizationID, models.OrgRoleMaintainer)
+	if err != nil {
+		return err
+	}
+	current, err := s.Repo.GetMemberRole(ctx, organizationID, userID)
+	if err != nil {
+		return err
+	}
+	if callerRole != models.OrgRoleOwner && (role != models.OrgRoleMember || orgRoleRank[current] > orgRoleRank[models.OrgRoleMember]) {
+		return status.Error(codes.PermissionDenied, "service.SetMember: only owners may appoint maintainers and owners")
+	}
+	if current == models.OrgRoleOwner && role != models.OrgRoleOwner {
+		if err = s.keepOwner(ctx, "service.SetMember", organizationID); err != nil {
+			return err
+		}
+	}
+	return s.Repo.SetMember(ctx, organizationID, userID, role)
+}
+
+// RemoveMember takes the user out of the organization. Any member may leave on their own.
+func (s *ModelService) RemoveMember(ctx context.Context, organizationID, userID int64) (err error) {
+	defer func() {
+		target := auditTarget("organization", organizationID) + "/" + auditTarget("user", userID)
+		s.audit(ctx, models.AuditEvent{Action: models.AuditMemberRemove, Target: target}, err)
+	}()
+
+	if organizationID == 0 || userID == 0 {
+		return status.Error(codes.InvalidArgument, "service.RemoveMember: organization_id or user_id is empty")
+	}
+	callerID, err := currentUser(ctx, "service.RemoveMember")
+	if err != nil {
+		return err
+	}
+	required := models.OrgRoleMaintainer
+	if callerID == userID {
+		required = models.OrgRoleMember
+	}
+	callerRole, err := s.authorizeMember(ctx, "service.RemoveMember", organizationID, required)
+	if err != nil {
+		return err
+	}
+	current, err := s.Repo.GetMemberRole(ctx, organizationID, userID)
+	if err != nil {
+		return err
+	}
+	if callerID != userID && callerRole != models.OrgRoleOwner && orgRoleRank[current] > orgRoleRank[models.OrgRoleMember] {
+		return status.Error(codes.PermissionDenied, "service.RemoveMember: only owners may remove maintainers and owners")
+	}
+	if current == models.OrgRoleOwner {
+		if err = s.keepOwner(ctx, "service.RemoveMember", organizationID); err != nil {
+			return err
+		}
+	}
+	return s.Repo.RemoveMember(ctx, organizationID, userID)
+}
+
+// authorizeMember checks that the current user has at least the required role in the organization and returns it.
+func (s *ModelService) authorizeMember(ctx context.Context, method string, organizationID int64, required string) (string, error) {
+	userID, err := currentUser(ctx, method)
+	if err != nil {
+		return "", err
+	}
+	role, err := s.Repo.GetMemberRole(ctx, organizationID, userID)
+	if err != nil {
+		return "", err
+	}
+	if orgRoleRank[role] < orgRoleRank[required] {
+		return "", status.Errorf(codes.PermissionDenied, "%s: %s role in organization (id %d) required", method, required, organizationID)
+	}
+	return role, nil
+}
+
+// keepOwner refuses to demote or remove the last owner of the organization.
+func (s *ModelService) keepOwner(ctx context.Context, method string, organizationID int64) error {
+	owners, err := s.Repo.CountOwners(ctx, organizationID)
+	if err != nil {
+		return err
+	}
+	if owners <= 1 {
+		return status.Errorf(codes.FailedPrecondition, "%s: organization (id %d) must keep an owner", method, organizationID)
+	}
+	return nil
+}
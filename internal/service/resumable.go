@@ -0,0 +1,298 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"house-of-neural-networks/internal/models"
+	"house-of-neural-networks/pkg/logger"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultUploadExpiry = 24 * time.Hour
+
+// CreateUpload starts a resumable upload of a file for a new version of the model. The content is sent with
+// WriteUpload, in as many parts as it takes, and the version is created from complete uploads by FinishUpload.
+func (s *ModelService) CreateUpload(ctx context.Context, upload models.Upload) (*models.Upload, error) {
+	userID, err := currentUser(ctx, "service.CreateUpload")
+	if err != nil {
+		return nil, err
+	}
+	if upload.ModelID == 0 {
+		return nil, status.Error(codes.InvalidArgument, "service.CreateUpload: model_id is empty")
+	}
+	if upload.Size < 0 {
+		return nil, status.Error(codes.InvalidArgument, "service.CreateUpload: size is negative")
+	}
+	if err = validateFilename("service.CreateUpload", upload.Filename); err != nil {
+		return nil, err
+	}
+	model, err := s.Repo.GetModel(ctx, models.Model{ID: upload.ModelID})
+	if err != nil {
+		return nil, err
+	}
+	if err = authorizeModel(ctx, "service.CreateUpload", model, models.PermissionManage, s.Repo.GetRole); err != nil {
+		return nil, err
+	}
+
+	upload.UserID = userID
+	upload.ExpiresAt = time.Now().Add(s.uploadExpiry())
+	return s.Repo.CreateUpload(ctx, upload)
+}
+
+// GetUpload returns the upload of the current user with the number of bytes received so far.
+func (s *ModelService) GetUpload(ctx context.Context, id int64) (*models.Upload, error) {
+	return s.ownUpload(ctx, "service.GetUpload", id)
+}
+
+// WriteUpload appends content to the upload at offset, which has to be the number of bytes received so far.
+// Whatever arrives is kept even if the transfer breaks, so the client asks for the offset and carries on
+// from there. Every write extends the expiry of the upload.
+func (s *ModelService) WriteUpload(ctx context.Context, id, offset int64, content io.Reader) (*models.Upload, error) {
+	upload, err := s.ownUpload(ctx, "service.WriteUpload", id)
+	if err != nil {
+		return nil, err
+	}
+	if !s.uploads.lock(id) {
+		return nil, status.Errorf(codes.Aborted, "service.WriteUpload: upload (id %d) is being written", id)
+	}
+	defer s.uploads.unlock(id)
+
+	// The offset may have moved while the upload was locked by another write
+	if upload.Offset, err = s.uploadOffset(id); err != nil {
+		return nil, status.Errorf(codes.Internal, "service.WriteUpload: %s", err)
+	}
+	if offset != upload.Offset {
+		return nil, status.Errorf(codes.Aborted, "service.WriteUpload: offset %d does not match the %d bytes received", offset, upload.Offset)
+	}
+
+	if err = os.MkdirAll(s.UploadDir, os.ModePerm); err != nil {
+		return nil, status.Errorf(codes.Internal, "service.WriteUpload: %s", err)
+	}
+	file, err := os.OpenFile(s.uploadPath(id), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "service.WriteUpload: %s", err)
+	}
+	written, copyErr := io.Copy(file, io.LimitReader(content, upload.Size-upload.Offset))
+	if err = file.Close(); err != nil && copyErr == nil {
+		copyErr = err
+	}
+	upload.Offset += written
+
+	// The request may have been cancelled halfway, what has arrived is kept all the same
+	upload.ExpiresAt = time.Now().Add(s.uploadExpiry())
+	if err = s.Repo.ExtendUpload(context.WithoutCancel(ctx), id, upload.ExpiresAt); err != nil {
+		return nil, err
+	}
+	if copyErr != nil {
+		if _, ok := status.FromError(copyErr); ok {
+			return nil, copyErr
+		}
+		return nil, status.Errorf(codes.Internal, "service.WriteUpload: failed to save upload (id %d): %v", id, copyErr)
+	}
+	if n, _ := content.Read(make([]byte, 1)); n > 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "service.WriteUpload: content exceeds the size of the upload (%d bytes)", upload.Size)
+	}
+	return upload, nil
+}
+
+// DeleteUpload cancels the upload and drops what has been received.
+func (s *ModelService) DeleteUpload(ctx context.Context, id int64) error {
+	if _, err := s.ownUpload(ctx, "service.DeleteUpload", id); err != nil {
+		return err
+	}
+	if !s.uploads.lock(id) {
+		return status.Errorf(codes.Aborted, "service.DeleteUpload: upload (id %d) is being written", id)
+	}
+	defer s.uploads.unlock(id)
+
+	if err := s.Repo.DeleteUploads(ctx, []int64{id}); err != nil {
+		return err
+	}
+	if err := os.Remove(s.uploadPath(id)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return status.Errorf(codes.Internal, "service.DeleteUpload: %s", err)
+	}
+	return nil
+}
+
+// FinishUpload creates a new version of the model with the files of the uploads, which have to be complete.
+func (s *ModelService) FinishUpload(ctx context.Context, version models.Version, uploadIDs []int64) (_ *models.Version, err error) {
+	defer func() { s.auditVersion(ctx, version, err) }()
+
+	model, err := s.versionModel(ctx, "service.FinishUpload", version)
+	if err != nil {
+		return nil, err
+	}
+	if len(uploadIDs) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "service.FinishUpload: upload_ids is empty")
+	}
+	if !s.uploads.lock(uploadIDs...) {
+		return nil, status.Error(codes.Aborted, "service.FinishUpload: an upload is being written")
+	}
+	defer s.uploads.unlock(uploadIDs...)
+
+	files := make([]stagedFile, 0, len(uploadIDs))
+	seen := make(map[string]bool)
+	for _, id := range uploadIDs {
+		upload, err := s.ownUpload(ctx, "service.FinishUpload", id)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case upload.ModelID != version.ModelID:
+			return nil, status.Errorf(codes.InvalidArgument, "service.FinishUpload: upload (id %d) is for another model", id)
+		case upload.Offset < upload.Size:
+			return nil, status.Errorf(codes.FailedPrecondition, "service.FinishUpload: upload (id %d) is incomplete, %d of %d bytes received", id, upload.Offset, upload.Size)
+		case seen[upload.Filename]:
+			return nil, status.Errorf(codes.InvalidArgument, "service.FinishUpload: file %s is uploaded twice", upload.Filename)
+		}
+		seen[upload.Filename] = true
+
+		if upload.Size == 0 {
+			// Nothing has been written to empty files
+			if err = os.MkdirAll(s.UploadDir, os.ModePerm); err == nil {
+				err = os.WriteFile(s.uploadPath(id), nil, 0644)
+			}
+			if err != nil {
+				return nil, status.Errorf(codes.Internal, "service.FinishUpload: %s", err)
+			}
+		}
+		files = append(files, stagedFile{filename: upload.Filename, path: s.uploadPath(id)})
+	}
+
+	res, err := s.publishVersion(ctx, "service.FinishUpload", model, version, files)
+	if err != nil {
+		return nil, err
+	}
+	// The files have been moved, leftover uploads are collected once they expire
+	if err := s.Repo.DeleteUploads(ctx, uploadIDs); err != nil {
+		logger.GetLoggerFromCtx(ctx).Error(ctx, err.Error(), zap.String("Function", logger.GetFunctionName()))
+	}
+	return res, nil
+}
+
+// CollectUploads deletes expired uploads along with their content, content left by uploads deleted with their
+// model or user, and files of versions whose upload broke off without cleaning up.
+func (s *ModelService) CollectUploads(ctx context.Context) error {
+	// Content is created after the upload, so listing it first keeps new uploads from being collected
+	entries, err := os.ReadDir(s.UploadDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return status.Errorf(codes.Internal, "service.CollectUploads: %s", err)
+	}
+	now := time.Now()
+	ids, err := s.Repo.DeleteExpiredUploads(ctx, now)
+	if err != nil {
+		return err
+	}
+	live := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		live[strconv.FormatInt(id, 10)] = true
+	}
+	for _, entry := range entries {
+		if live[entry.Name()] {
+			continue
+		}
+		if strings.HasPrefix(entry.Name(), versionStagingPrefix) {
+			// Versions being uploaded right now
+			info, err := entry.Info()
+			if err != nil || now.Sub(info.ModTime()) < s.uploadExpiry() {
+				continue
+			}
+		}
+		if err = os.RemoveAll(filepath.Join(s.UploadDir, entry.Name())); err != nil {
+			return status.Errorf(codes.Internal, "service.CollectUploads: %s", err)
+		}
+	}
+	return nil
+}
+
+// ownUpload returns the upload if it belongs to the current user and has not expired.
+func (s *ModelService) ownUpload(ctx context.Context, method string, id int64) (*models.Upload, error) {
+	userID, err := currentUser(ctx, method)
+	if err != nil {
+		return nil, err
+	}
+	if id == 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "%s: id is empty", method)
+	}
+	upload, err := s.Repo.GetUpload(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	// Uploads of other users are not revealed
+	if upload.UserID != userID || time.Now().After(upload.ExpiresAt) {
+		return nil, status.Errorf(codes.NotFound, "%s: upload (id %d) not found", method, id)
+	}
+	if upload.Offset, err = s.uploadOffset(id); err != nil {
+		return nil, status.Errorf(codes.Internal, "%s: %s", method, err)
+	}
+	return upload, nil
+}
+
+func (s *ModelService) uploadExpiry() time.Duration {
+	if s.UploadExpiry > 0 {
+		return s.UploadExpiry
+	}
+	return defaultUploadExpiry
+}
+
+// uploadPath is the file the content of the upload is received in.
+func (s *ModelService) uploadPath(id int64) string {
+	return filepath.Join(s.UploadDir, strconv.FormatInt(id, 10))
+}
+
+// uploadOffset returns the number of bytes of the upload received so far.
+func (s *ModelService) uploadOffset(id int64) (int64, error) {
+	info, err := os.Stat(s.uploadPath(id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// uploadLocks keeps an upload from being written by two requests at once.
+type uploadLocks struct {
+	mu     sync.Mutex
+	locked map[int64]bool
+}
+
+// lock locks all the uploads, or none if one of them is locked already.
+func (l *uploadLocks) lock(ids ...int64) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, id := range ids {
+		if l.locked[id] {
+			return false
+		}
+	}
+	if l.locked == nil {
+		l.locked = make(map[int64]bool)
+	}
+	for _, id := range ids {
+		l.locked[id] = true
+	}
+	return true
+}
+
+func (l *uploadLocks) unlock(ids ...int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, id := range ids {
+		delete(l.locked, id)
+	}
+}
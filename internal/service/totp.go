@@ -0,0 +1,248 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"fmt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"house-of-neural-networks/internal/models"
+	"house-of-neural-networks/pkg/totp"
+	"strings"
+	"time"
+)
+
+const (
+	totpIssuer = "House of Neural Networks"
+
+	loginChallengeTTL = 5 * time.Minute
+	// loginChallengeAttempts is the number of wrong codes after which the password has to be entered again.
+	loginChallengeAttempts = 5
+
+	recoveryCodeCount = 10
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// EnrollTOTP generates a new secret for the current user and returns it along with the otpauth URI for
+// authenticator apps. Two-factor authentication is enabled once ConfirmTOTP gets a code generated from it.
+func (s *AuthService) EnrollTOTP(ctx context.Context) (string, string, error) {
+	userID, err := sessionUser(ctx, "service.EnrollTOTP")
+	if err != nil {
+		return "", "", err
+	}
+	current, err := s.Repo.GetTOTP(ctx, userID)
+	if err != nil {
+		return "", "", err
+	}
+	if current.EnabledAt != nil {
+		return "", "", status.Error(codes.FailedPrecondition, "service.EnrollTOTP: two-factor authentication is already enabled")
+	}
+	user, err := s.Repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return "", "", err
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return "", "", status.Errorf(codes.Internal, "service.EnrollTOTP: %s", err)
+	}
+	if err = s.Repo.SetTOTPSecret(ctx, userID, secret); err != nil {
+		return "", "", err
+	}
+	return secret, totp.URI(totpIssuer, user.Username, secret), nil
+}
+
+// ConfirmTOTP enables two-factor authentication of the current user if the code matches the enrolled secret
+// and returns the recovery codes. They are shown only this once.
+func (s *AuthService) ConfirmTOTP(ctx context.Context, code string) (_ []string, err error) {
+	defer func() { s.audit(ctx, models.AuditEvent{Action: models.AuditTOTPEnable}, err) }()
+
+	userID, err := sessionUser(ctx, "service.ConfirmTOTP")
+	if err != nil {
+		return nil, err
+	}
+	current, err := s.Repo.GetTOTP(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if current.EnabledAt != nil {
+		return nil, status.Error(codes.FailedPrecondition, "service.ConfirmTOTP: two-factor authentication is already enabled")
+	}
+	if current.Secret == nil {
+		return nil, status.Error(codes.FailedPrecondition, "service.ConfirmTOTP: no enrolment to confirm")
+	}
+	step, ok := totp.Validate(*current.Secret, code, time.Now())
+	if !ok {
+		return nil, status.Error(codes.InvalidArgument, "service.ConfirmTOTP: invalid code")
+	}
+
+	recoveryCodes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err = s.Repo.EnableTOTP(ctx, userID, step, hashes); err != nil {
+		return nil, err
+	}
+	return recoveryCodes, nil
+}
+
+// DisableTOTP turns two-factor authentication of the current user off. It takes a code from the
+// authenticator app or a recovery code, so that a stolen access token alone is not enough.
+func (s *AuthService) DisableTOTP(ctx context.Context, code string) (err error) {
+	defer func() { s.audit(ctx, models.AuditEvent{Action: models.AuditTOTPDisable}, err) }()
+
+	userID, err := sessionUser(ctx, "service.DisableTOTP")
+	if err != nil {
+		return err
+	}
+	current, err := s.Repo.GetTOTP(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if current.EnabledAt == nil {
+		return status.Error(codes.FailedPrecondition, "service.DisableTOTP: two-factor authentication is not enabled")
+	}
+	ok, err := s.checkSecondFactor(ctx, current, code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return status.Error(codes.InvalidArgument, "service.DisableTOTP: invalid code")
+	}
+	return s.Repo.DisableTOTP(ctx, userID)
+}
+
+// VerifyLogIn completes a login started by LogIn or FinishOIDCLogin with a code from the authenticator app or a recovery
+// code. Wrong codes count as failed logins, and after loginChallengeAttempts of them the challenge is dropped.
+func (s *AuthService) VerifyLogIn(ctx context.Context, challengeToken, code, clientIP string) (_ *models.Session, err error) {
+	event := models.AuditEvent{Action: models.AuditLogInSecondFactor}
+	defer func() { s.audit(ctx, event, err) }()
+
+	if challengeToken == "" || code == "" {
+		return nil, status.Error(codes.InvalidArgument, "service.VerifyLogIn: challenge token or code is empty")
+	}
+
+	tokenHash := hashToken(challengeToken)
+	challenge, err := s.Repo.GetLoginChallenge(ctx, tokenHash)
+	if status.Code(err) == codes.NotFound {
+		return nil, status.Error(codes.Unauthenticated, "service.VerifyLogIn: invalid challenge token")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if time.Now().After(challenge.ExpiresAt) {
+		return nil, status.Error(codes.Unauthenticated, "service.VerifyLogIn: challenge token has expired")
+	}
+
+	user, err := s.Repo.GetUserByID(ctx, challenge.UserID)
+	if err != nil {
+		return nil, err
+	}
+	event.ActorID, event.Target = &user.ID, user.Username
+	failure := models.LoginFailure{Username: user.Username, UserID: &user.ID, ClientIP: clientIP}
+	retry, err := s.retryAfter(ctx, user, clientIP)
+	if err != nil {
+		return nil, err
+	}
+	if retry > 0 {
+		failure.Reason = models.LoginLocked
+		return nil, s.loginFailed(ctx, failure, status.Errorf(codes.ResourceExhausted,
+			"service.VerifyLogIn: too many failed attempts, retry in %s", retry.Round(time.Second)))
+	}
+
+	current, err := s.Repo.GetTOTP(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	ok, err := s.checkSecondFactor(ctx, current, code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		if err = s.Repo.FailLoginChallenge(ctx, tokenHash, loginChallengeAttempts); err != nil {
+			return nil, err
+		}
+		failure.Reason = models.LoginWrongCode
+		return nil, s.loginFailed(ctx, failure, status.Error(codes.Unauthenticated, "service.VerifyLogIn: invalid code"))
+	}
+
+	err = s.Repo.DeleteLoginChallenge(ctx, tokenHash)
+	if status.Code(err) == codes.Aborted {
+		return nil, status.Error(codes.Unauthenticated, "service.VerifyLogIn: invalid challenge token")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if user.DisabledAt != nil {
+		failure.Reason = models.LoginDisabled
+		return nil, s.loginFailed(ctx, failure, status.Error(codes.Unauthenticated, "service.VerifyLogIn: account is disabled"))
+	}
+	return s.startSession(ctx, user)
+}
+
+// loginChallenge starts the second step of a login.
+func (s *AuthService) loginChallenge(ctx context.Context, userID int64) (*models.Session, error) {
+	token, err := randomToken(32)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "service.LogIn: %s", err)
+	}
+	challenge := models.LoginChallenge{
+		TokenHash: hashToken(token),
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(loginChallengeTTL),
+	}
+	if err = s.Repo.CreateLoginChallenge(ctx, challenge); err != nil {
+		return nil, err
+	}
+	return &models.Session{UserID: userID, ChallengeToken: token, ChallengeExpiresAt: challenge.ExpiresAt}, nil
+}
+
+// checkSecondFactor reports whether the code is a valid code of the authenticator app or an unused recovery
+// code, and uses it up. Codes of the app are told apart by their length.
+func (s *AuthService) checkSecondFactor(ctx context.Context, current models.TOTP, code string) (bool, error) {
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if current.Secret == nil || current.EnabledAt == nil {
+		return false, nil
+	}
+
+	if len(code) == totp.Digits {
+		step, ok := totp.Validate(*current.Secret, code, time.Now())
+		if !ok {
+			return false, nil
+		}
+		// Each code works once, even within its time step
+		err := s.Repo.UseTOTPStep(ctx, current.UserID, step)
+		if status.Code(err) == codes.Aborted {
+			return false, nil
+		}
+		return err == nil, err
+	}
+
+	err := s.Repo.UseRecoveryCode(ctx, current.UserID, hashToken(normalizeRecoveryCode(code)))
+	if status.Code(err) == codes.NotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// generateRecoveryCodes returns new recovery codes, formatted like "abcde-fghij", and their hashes.
+func generateRecoveryCodes() ([]string, []string, error) {
+	recoveryCodes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for range recoveryCodeCount {
+		raw := make([]byte, 6)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, nil, status.Errorf(codes.Internal, "service.generateRecoveryCodes: %s", err)
+		}
+		code := strings.ToLower(recoveryCodeEncoding.EncodeToString(raw))
+		recoveryCodes = append(recoveryCodes, fmt.Sprintf("%s-%s", code[:5], code[5:]))
+		hashes = append(hashes, hashToken(code))
+	}
+	return recoveryCodes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(code, "-", ""))
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"house-of-neural-networks/internal/models"
+	"house-of-neural-networks/pkg/logger"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+)
+
+// UploadVersion stores a new version of the model with the files returned by next, one at a time until it
+// returns io.EOF, so that large files are copied to disk as they arrive rather than held in memory. The files
+// are staged in the upload directory and moved to the model repository once all of them are in and the version
+// is created. The version is deleted again if a file cannot be moved, an upload that fails halfway leaves
+// nothing behind.
+func (s *ModelService) UploadVersion(ctx context.Context, version models.Version, next func() (string, io.Reader, error)) (_ *models.Version, err error) {
+	defer func() { s.auditVersion(ctx, version, err) }()
+
+	model, err := s.versionModel(ctx, "service.UploadVersion", version)
+	if err != nil {
+		return nil, err
+	}
+
+	staging := ""
+	defer func() {
+		if staging != "" {
+			os.RemoveAll(staging)
+		}
+	}()
+	files := make([]stagedFile, 0)
+	seen := make(map[string]bool)
+	for {
+		filename, content, err := next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err = validateFilename("service.UploadVersion", filename); err != nil {
+			return nil, err
+		}
+		if seen[filename] {
+			return nil, status.Errorf(codes.InvalidArgument, "service.UploadVersion: file %s is uploaded twice", filename)
+		}
+		seen[filename] = true
+
+		if staging == "" {
+			if err = os.MkdirAll(s.UploadDir, os.ModePerm); err != nil {
+				return nil, status.Errorf(codes.Internal, "service.UploadVersion: %s", err)
+			}
+			if staging, err = os.MkdirTemp(s.UploadDir, versionStagingPrefix); err != nil {
+				return nil, status.Errorf(codes.Internal, "service.UploadVersion: %s", err)
+			}
+		}
+		file := stagedFile{filename: filename, path: filepath.Join(staging, filename)}
+		if err = saveFile(file.path, content); err != nil {
+			if _, ok := status.FromError(err); ok {
+				return nil, err
+			}
+			return nil, status.Errorf(codes.Internal, "service.UploadVersion: failed to save file %s: %v", filename, err)
+		}
+		files = append(files, file)
+	}
+
+	return s.publishVersion(ctx, "service.UploadVersion", model, version, files)
+}
+
+// versionStagingPrefix starts the names of the directories UploadVersion stages files in, resumable uploads
+// are named by their id.
+const versionStagingPrefix = "version-"
+
+// stagedFile is a file of a new version that has been received but is not in the version directory yet.
+type stagedFile struct {
+	filename string
+	path     string
+}
+
+// versionModel checks the new version and returns its model if the current user may manage it.
+func (s *ModelService) versionModel(ctx context.Context, method string, version models.Version) (*models.Model, error) {
+	if version.Number <= 0 || version.ModelID == 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "%s: number or model_id is empty", method)
+	}
+	model, err := s.Repo.GetModel(ctx, models.Model{ID: version.ModelID})
+	if err != nil {
+		return nil, err
+	}
+	if err = authorizeModel(ctx, method, model, models.PermissionManage, s.Repo.GetRole); err != nil {
+		return nil, err
+	}
+	return model, nil
+}
+
+// publishVersion creates the version and moves the staged files into its directory of the model repository.
+// The version is created first so that two uploads of the same number cannot mix their files, and deleted
+// with the files moved so far if a file cannot be moved, so that the upload can be retried.
+func (s *ModelService) publishVersion(ctx context.Context, method string, model *models.Model, version models.Version, files []stagedFile) (*models.Version, error) {
+	res, err := s.Repo.CreateVersion(ctx, version)
+	if err != nil {
+		return nil, err
+	}
+	versionDir := path.Join(model.Name, strconv.Itoa(int(version.Number)))
+	for _, file := range files {
+		if err = s.Store.MoveFile(ctx, path.Join(versionDir, file.filename), file.path); err != nil {
+			s.discardVersion(ctx, res.ID, versionDir)
+			return nil, status.Errorf(codes.Internal, "%s: failed to save file %s: %v", method, file.filename, err)
+		}
+	}
+	return res, nil
+}
+
+// discardVersion removes the directory and the row of a version that could not be published. The request may
+// have been cancelled, the cleanup runs all the same and its failures are logged.
+func (s *ModelService) discardVersion(ctx context.Context, versionID int64, versionDir string) {
+	ctx = context.WithoutCancel(ctx)
+	if err := s.Store.RemoveAll(ctx, versionDir); err != nil {
+		logger.GetLoggerFromCtx(ctx).Error(ctx, err.Error(), zap.String("Function", logger.GetFunctionName()))
+	}
+	if err := s.Repo.DeleteVersion(ctx, versionID); err != nil {
+		logger.GetLoggerFromCtx(ctx).Error(ctx, err.Error(), zap.String("Function", logger.GetFunctionName()))
+	}
+}
+
+func (s *ModelService) auditVersion(ctx context.Context, version models.Version, err error) {
+	target := fmt.Sprintf("%s/version:%d", auditTarget("model", version.ModelID), version.Number)
+	s.audit(ctx, models.AuditEvent{Action: models.AuditVersionCreate, Target: target}, err)
+}
+
+// validateFilename rejects names that are empty or would point outside the version directory.
+func validateFilename(method, filename string) error {
+	if filename == "" || filename != filepath.Base(filename) || filename == "." || filename == ".." {
+		return status.Errorf(codes.InvalidArgument, "%s: invalid filename %q", method, filename)
+	}
+	return nil
+}
+
+// saveFile copies content into a new file at path. Errors reading content are returned as they are, so that
+// a broken upload is told apart from a failing disk.
+func saveFile(path string, content io.Reader) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(file, content); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
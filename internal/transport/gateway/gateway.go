@@ -30,11 +30,18 @@ func New(ctx context.Context, cfg *config.Config) (*Gateway, error) {
 		return nil, fmt.Errorf("failed to create message client: %w", err)
 	}
 
-	r := NewRouter(ctx, messageClient, authClient, modelClient)
+	verifier := newTokenVerifier(authClient, cfg.JWKSRefreshInterval)
+	go verifier.run(ctx)
+
+	r := NewRouter(ctx, messageClient, authClient, modelClient, verifier)
+	var handler http.Handler = r.muxRouter
+	if cfg.TrustProxyHeaders {
+		handler = realIPMiddleware(handler)
+	}
 
 	httpServer := &http.Server{
 		Addr:    fmt.Sprintf(":%s", strconv.Itoa(cfg.HTTPServerPort)),
-		Handler: r.muxRouter,
+		Handler: handler,
 	}
 
 	return &Gateway{
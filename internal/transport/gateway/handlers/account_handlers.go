@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"encoding/json"
+	"go.uber.org/zap"
+	"house-of-neural-networks/internal/models"
+	"house-of-neural-networks/internal/transport/grpc_clients"
+	"house-of-neural-networks/pkg/logger"
+	"net/http"
+
+	authpb "house-of-neural-networks/pkg/api/auth"
+	modelpb "house-of-neural-networks/pkg/api/model"
+)
+
+// AccountHandlers serve the account of the current user. Deleting it involves both the auth and the model service.
+type AccountHandlers struct {
+	authClient  *grpc_clients.AuthClient
+	modelClient *grpc_clients.ModelClient
+}
+
+func NewAccountHandlers(authClient *grpc_clients.AuthClient, modelClient *grpc_clients.ModelClient) *AccountHandlers {
+	return &AccountHandlers{authClient: authClient, modelClient: modelClient}
+}
+
+// GetProfile
+// @Summary Get the profile
+// @Description Returns the account of the current user.
+// @Tags Account
+// @Produce json
+// @Security TokenAuth
+// @Success 200 {object} models.Profile
+// @Router /account [get]
+func (h *AccountHandlers) GetProfile(w http.ResponseWriter, r *http.Request) {
+	req := authpb.GetProfileRequest{RequestId: r.Context().Value(logger.RequestID).(string)}
+	resp, err := h.authClient.GetProfile(r.Context(), &req)
+	if err != nil {
+		writeServiceError(w, "Auth-Service", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(profile(resp))
+}
+
+// UpdateProfile
+// @Summary Update the profile
+// @Description Changes the username and email to the ones that are set. A new email has to be verified again, the link is sent right away.
+// @Tags Account
+// @Accept json
+// @Produce json
+// @Security TokenAuth
+// @Param request body models.UpdateProfileRequest true "Fields to change"
+// @Success 200 {object} models.Profile
+// @Failure 409 {string} string "Username is taken"
+// @Router /account [patch]
+func (h *AccountHandlers) UpdateProfile(w http.ResponseWriter, r *http.Request) {
+	var body models.UpdateProfileRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	req := authpb.UpdateProfileRequest{
+		Username:  body.Username,
+		Email:     body.Email,
+		RequestId: r.Context().Value(logger.RequestID).(string),
+	}
+	resp, err := h.authClient.UpdateProfile(r.Context(), &req)
+	if err != nil {
+		writeServiceError(w, "Auth-Service", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(profile(resp))
+}
+
+// ChangePassword
+// @Summary Change the password
+// @Description Sets a new password and ends every other session. Returns a new session for the caller.
+// @Tags Account
+// @Accept json
+// @Produce json
+// @Security TokenAuth
+// @Param request body models.ChangePasswordRequest true "Old and new password"
+// @Success 200 {object} models.LogInResponse
+// @Failure 403 {string} string "Wrong password"
+// @Failure 429 {string} string "Too many failed attempts"
+// @Router /account/password [post]
+func (h *AccountHandlers) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	var body models.ChangePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	req := authpb.ChangePasswordRequest{
+		OldPassword: body.OldPassword,
+		NewPassword: body.NewPassword,
+		RequestId:   r.Context().Value(logger.RequestID).(string),
+	}
+	resp, err := h.authClient.ChangePassword(r.Context(), &req)
+	if err != nil {
+		writeServiceError(w, "Auth-Service", err)
+		return
+	}
+
+	setSessionCookies(w, resp.GetJwt(), resp.GetExpiresAt().AsTime(), resp.GetRefreshToken(), resp.GetRefreshExpiresAt().AsTime())
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// DeleteAccount
+// @Summary Delete the account
+// @Description Deletes the account with its models, their files, the chat history and the organizations nobody else is a member of. Organizations left without an owner get a new one. Takes the password, and a code if two-factor authentication is enabled.
+// @Tags Account
+// @Accept json
+// @Security TokenAuth
+// @Param request body models.DeleteAccountRequest true "Password and code"
+// @Success 204 "Deleted"
+// @Success 202 "Account locked, the data is deleted shortly"
+// @Failure 403 {string} string "Wrong password or code"
+// @Failure 429 {string} string "Too many failed attempts"
+// @Router /account [delete]
+func (h *AccountHandlers) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	var body models.DeleteAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	requestID := r.Context().Value(logger.RequestID).(string)
+	req := authpb.DeleteAccountRequest{Password: body.Password, Code: body.Code, RequestId: requestID}
+	if _, err := h.authClient.DeleteAccount(r.Context(), &req); err != nil {
+		writeServiceError(w, "Auth-Service", err)
+		return
+	}
+	// The account is locked from here on, the principal of the request still identifies it
+	clearSessionCookies(w)
+	if _, err := h.modelClient.DeleteAccountData(r.Context(), &modelpb.DeleteAccountDataRequest{RequestId: requestID}); err != nil {
+		// The deletion is recorded, the model service retries it
+		logger.GetLoggerFromCtx(r.Context()).Error(
+			r.Context(),
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", http.StatusText(http.StatusAccepted)),
+		)
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func profile(p *authpb.Profile) models.Profile {
+	result := models.Profile{
+		ID:            p.GetId(),
+		Username:      p.GetUsername(),
+		Email:         p.GetEmail(),
+		EmailVerified: p.GetEmailVerified(),
+		Roles:         p.GetRoles(),
+		TOTPEnabled:   p.GetTotpEnabled(),
+	}
+	if p.GetLastLoginAt() != nil {
+		lastLoginAt := p.GetLastLoginAt().AsTime()
+		result.LastLoginAt = &lastLoginAt
+	}
+	return result
+}
@@ -0,0 +1,294 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"house-of-neural-networks/internal/models"
+	"house-of-neural-networks/internal/transport/grpc_clients"
+	"house-of-neural-networks/pkg/logger"
+	"net/http"
+	"strconv"
+	"time"
+
+	authpb "house-of-neural-networks/pkg/api/auth"
+	modelpb "house-of-neural-networks/pkg/api/model"
+)
+
+// AdminHandlers serve the administration endpoints. The services check that the caller has the admin role.
+type AdminHandlers struct {
+	authClient  *grpc_clients.AuthClient
+	modelClient *grpc_clients.ModelClient
+}
+
+func NewAdminHandlers(authClient *grpc_clients.AuthClient, modelClient *grpc_clients.ModelClient) *AdminHandlers {
+	return &AdminHandlers{authClient: authClient, modelClient: modelClient}
+}
+
+// ListUsers
+// @Summary List users
+// @Description Returns every user with their roles and whether the account is disabled. Requires the admin role.
+// @Tags Admin
+// @Produce json
+// @Security TokenAuth
+// @Success 200 {object} models.ListUsersResponse
+// @Failure 403 {string} string "Admin role required"
+// @Router /admin/users [get]
+func (h *AdminHandlers) ListUsers(w http.ResponseWriter, r *http.Request) {
+	req := authpb.ListUsersRequest{RequestId: r.Context().Value(logger.RequestID).(string)}
+	resp, err := h.authClient.ListUsers(r.Context(), &req)
+	if err != nil {
+		writeServiceError(w, "Auth-Service", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// DisableUser
+// @Summary Disable a user
+// @Description Blocks the account: the user can no longer log in, tokens and API keys already issued are rejected. Requires the admin role.
+// @Tags Admin
+// @Security TokenAuth
+// @Param id path int true "User ID"
+// @Success 204 "Disabled"
+// @Failure 400 {string} string "Administrators cannot disable themselves"
+// @Failure 403 {string} string "Admin role required"
+// @Failure 404 {string} string "User not found"
+// @Router /admin/users/{id}/disable [post]
+func (h *AdminHandlers) DisableUser(w http.ResponseWriter, r *http.Request) {
+	h.setUserDisabled(w, r, true)
+}
+
+// EnableUser
+// @Summary Enable a user
+// @Description Unblocks a disabled account. Requires the admin role.
+// @Tags Admin
+// @Security TokenAuth
+// @Param id path int true "User ID"
+// @Success 204 "Enabled"
+// @Failure 403 {string} string "Admin role required"
+// @Failure 404 {string} string "User not found"
+// @Router /admin/users/{id}/enable [post]
+func (h *AdminHandlers) EnableUser(w http.ResponseWriter, r *http.Request) {
+	h.setUserDisabled(w, r, false)
+}
+
+func (h *AdminHandlers) setUserDisabled(w http.ResponseWriter, r *http.Request, disabled bool) {
+	userId, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID format, must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	req := authpb.SetUserDisabledRequest{UserId: userId, Disabled: disabled, RequestId: r.Context().Value(logger.RequestID).(string)}
+	if _, err = h.authClient.SetUserDisabled(r.Context(), &req); err != nil {
+		writeServiceError(w, "Auth-Service", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SetUserRoles
+// @Summary Set the roles of a user
+// @Description Replaces the roles of the user, the user role is always kept. Takes effect on the next login or token refresh. Requires the admin role.
+// @Tags Admin
+// @Accept json
+// @Security TokenAuth
+// @Param id path int true "User ID"
+// @Param request body models.SetUserRolesRequest true "Roles"
+// @Success 204 "Roles set"
+// @Failure 400 {string} string "Unknown role"
+// @Failure 403 {string} string "Admin role required"
+// @Failure 404 {string} string "User not found"
+// @Router /admin/users/{id}/roles [put]
+func (h *AdminHandlers) SetUserRoles(w http.ResponseWriter, r *http.Request) {
+	userId, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID format, must be an integer", http.StatusBadRequest)
+		return
+	}
+	var body models.SetUserRolesRequest
+	if err = json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	req := authpb.SetUserRolesRequest{UserId: userId, Roles: body.Roles, RequestId: r.Context().Value(logger.RequestID).(string)}
+	if _, err = h.authClient.SetUserRoles(r.Context(), &req); err != nil {
+		writeServiceError(w, "Auth-Service", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListModels
+// @Summary List all models
+// @Description Returns the models of every user, or of one user. Requires the admin role.
+// @Tags Admin
+// @Produce json
+// @Security TokenAuth
+// @Param user_id query int false "Owner of the models"
+// @Success 200 {object} models.ListModelsResponse
+// @Failure 403 {string} string "Admin role required"
+// @Router /admin/models [get]
+func (h *AdminHandlers) ListModels(w http.ResponseWriter, r *http.Request) {
+	req := modelpb.ListAllModelsRequest{RequestId: r.Context().Value(logger.RequestID).(string)}
+	if value := r.URL.Query().Get("user_id"); value != "" {
+		userId, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid user_id", http.StatusBadRequest)
+			return
+		}
+		req.UserId = userId
+	}
+
+	resp, err := h.modelClient.ListAllModels(r.Context(), &req)
+	if err != nil {
+		writeServiceError(w, "Model-Service", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// GetModel
+// @Summary Get any model
+// @Description Returns a model regardless of its owner. Requires the admin role.
+// @Tags Admin
+// @Produce json
+// @Security TokenAuth
+// @Param id path int true "Model ID"
+// @Success 200 {object} models.GetModelResponse
+// @Failure 403 {string} string "Admin role required"
+// @Failure 404 {string} string "Model not found"
+// @Router /admin/models/{id} [get]
+func (h *AdminHandlers) GetModel(w http.ResponseWriter, r *http.Request) {
+	modelId, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID format, must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	req := modelpb.GetModelRequest{Id: modelId, RequestId: r.Context().Value(logger.RequestID).(string)}
+	resp, err := h.modelClient.GetModel(r.Context(), &req)
+	if err != nil {
+		writeServiceError(w, "Model-Service", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// DeleteModel
+// @Summary Delete any model
+// @Description Unloads and deletes a model regardless of its owner. Requires the admin role.
+// @Tags Admin
+// @Produce json
+// @Security TokenAuth
+// @Param id path int true "Model ID"
+// @Success 200 {object} models.UnloadModelResponse
+// @Failure 403 {string} string "Admin role required"
+// @Failure 404 {string} string "Model not found"
+// @Router /admin/models/{id} [delete]
+func (h *AdminHandlers) DeleteModel(w http.ResponseWriter, r *http.Request) {
+	modelId, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID format, must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	req := modelpb.UnloadModelRequest{Id: modelId, RequestId: r.Context().Value(logger.RequestID).(string)}
+	resp, err := h.modelClient.UnloadModel(r.Context(), &req)
+	if err != nil {
+		writeServiceError(w, "Model-Service", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// ListAuditEvents
+// @Summary List audit events
+// @Description Returns the security audit log of all services, newest first: logins, account and permission changes, uploads and deletions. Requires the admin role.
+// @Description Events are returned page by page; pass nextPageToken of the response as page_token to get the next page.
+// @Tags Admin
+// @Produce json
+// @Security TokenAuth
+// @Param actor_id query int false "Only events of this user"
+// @Param action query string false "Only events of this action, e.g. auth.login or model.delete"
+// @Param from query string false "Only events at or after this time (RFC 3339)"
+// @Param to query string false "Only events before this time (RFC 3339)"
+// @Param page_size query int false "Events per page, 50 by default, at most 500"
+// @Param page_token query string false "Token of the page returned as nextPageToken"
+// @Success 200 {object} models.ListAuditEventsResponse
+// @Failure 400 {string} string "Invalid filter or page token"
+// @Failure 403 {string} string "Admin role required"
+// @Router /admin/audit [get]
+func (h *AdminHandlers) ListAuditEvents(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	req := authpb.ListAuditEventsRequest{
+		Action:    query.Get("action"),
+		PageToken: query.Get("page_token"),
+		RequestId: r.Context().Value(logger.RequestID).(string),
+	}
+	if value := query.Get("actor_id"); value != "" {
+		actorId, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid actor_id", http.StatusBadRequest)
+			return
+		}
+		req.ActorId = actorId
+	}
+	for param, field := range map[string]**timestamppb.Timestamp{"from": &req.From, "to": &req.To} {
+		if value := query.Get(param); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Invalid %s, expected RFC 3339 time", param), http.StatusBadRequest)
+				return
+			}
+			*field = timestamppb.New(t)
+		}
+	}
+	if value := query.Get("page_size"); value != "" {
+		pageSize, err := strconv.ParseInt(value, 10, 32)
+		if err != nil || pageSize <= 0 {
+			http.Error(w, "Invalid page_size", http.StatusBadRequest)
+			return
+		}
+		req.PageSize = int32(pageSize)
+	}
+
+	resp, err := h.authClient.ListAuditEvents(r.Context(), &req)
+	if err != nil {
+		writeServiceError(w, "Auth-Service", err)
+		return
+	}
+
+	result := models.ListAuditEventsResponse{
+		Events:        make([]models.AuditEventResponse, 0, len(resp.GetEvents())),
+		NextPageToken: resp.GetNextPageToken(),
+	}
+	for _, event := range resp.GetEvents() {
+		item := models.AuditEventResponse{
+			ID:        event.GetId(),
+			Action:    event.GetAction(),
+			Target:    event.GetTarget(),
+			RequestID: event.GetRequestId(),
+			ClientIP:  event.GetClientIp(),
+			Outcome:   event.GetOutcome(),
+			CreatedAt: event.GetCreatedAt().AsTime(),
+		}
+		if event.GetActorId() != 0 {
+			item.ActorID = &event.ActorId
+		}
+		result.Events = append(result.Events, item)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"house-of-neural-networks/internal/models"
+	"house-of-neural-networks/pkg/logger"
+	"net/http"
+	"strconv"
+
+	pb "house-of-neural-networks/pkg/api/auth"
+)
+
+// CreateAPIKey
+// @Summary Create an API key
+// @Description Issues a key for scripts and CI. The key is sent as "Authorization: Bearer <key>" or "X-API-Key: <key>", is limited to the given scopes and, if model_ids is set, to those models. The key is returned only once. Keys cannot be managed with a key.
+// @Tags Auth service
+// @Accept json
+// @Produce json
+// @Security TokenAuth
+// @Param request body models.CreateAPIKeyRequest true "Key description"
+// @Success 200 {object} models.CreateAPIKeyResponse
+// @Failure 400 {string} string "Missing name, unknown scope or expiry in the past"
+// @Router /keys [post]
+func (h *AuthHandlers) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	var body models.CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	req := pb.CreateAPIKeyRequest{
+		Name:      body.Name,
+		Scopes:    body.Scopes,
+		ModelIds:  body.ModelIDs,
+		RequestId: r.Context().Value(logger.RequestID).(string),
+	}
+	if body.ExpiresAt != nil {
+		req.ExpiresAt = timestamppb.New(*body.ExpiresAt)
+	}
+	resp, err := h.client.CreateAPIKey(r.Context(), &req)
+	if err != nil {
+		writeServiceError(w, "Auth-Service", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// ListAPIKeys
+// @Summary List API keys
+// @Description Returns the keys of the user that have not been revoked, without the keys themselves
+// @Tags Auth service
+// @Produce json
+// @Security TokenAuth
+// @Success 200 {object} models.ListAPIKeysResponse
+// @Router /keys [get]
+func (h *AuthHandlers) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	req := pb.ListAPIKeysRequest{RequestId: r.Context().Value(logger.RequestID).(string)}
+	resp, err := h.client.ListAPIKeys(r.Context(), &req)
+	if err != nil {
+		writeServiceError(w, "Auth-Service", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// RevokeAPIKey
+// @Summary Revoke an API key
+// @Tags Auth service
+// @Security TokenAuth
+// @Param id path int true "Key ID"
+// @Success 204 "Key revoked"
+// @Failure 404 {string} string "Key not found"
+// @Router /keys/{id} [delete]
+func (h *AuthHandlers) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID format, must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	req := pb.RevokeAPIKeyRequest{Id: id, RequestId: r.Context().Value(logger.RequestID).(string)}
+	if _, err = h.client.RevokeAPIKey(r.Context(), &req); err != nil {
+		writeServiceError(w, "Auth-Service", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -2,10 +2,11 @@ package handlers
 
 import (
 	"encoding/json"
-	"fmt"
 	"go.uber.org/zap"
+	"house-of-neural-networks/internal/models"
 	"house-of-neural-networks/internal/transport/grpc_clients"
 	"house-of-neural-networks/pkg/logger"
+	"net"
 	"net/http"
 	"time"
 
@@ -54,12 +55,14 @@ func (h *AuthHandlers) SignUp(w http.ResponseWriter, r *http.Request) {
 
 // LogIn handles user logIn.
 // @Summary Авторизация пользователя
-// @Description Авторизует пользователей
+// @Description Авторизует пользователей. Если включена двухфакторная аутентификация, вместо токенов возвращается mfa_required и challenge_token, вход завершается через /login/totp.
 // @Tags Auth service
 // @Accept json
 // @Produce json
 // @Param register body models.LogInRequest true "LogIn data"
 // @Success 200 {object} models.LogInResponse
+// @Failure 401 {string} string "Invalid username or password"
+// @Failure 429 {string} string "Too many failed attempts"
 // @Router /login [post]
 func (h *AuthHandlers) LogIn(w http.ResponseWriter, r *http.Request) {
 	var req pb.LogInRequest
@@ -74,30 +77,121 @@ func (h *AuthHandlers) LogIn(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	req.RequestId = r.Context().Value(logger.RequestID).(string)
+	req.ClientIp = clientIP(r)
 	resp, err := h.client.LogIn(r.Context(), &req)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error calling Auth-Service, LogIn: %s", err.Error()), http.StatusInternalServerError)
+		writeServiceError(w, "Auth-Service", err)
 		return
 	}
 
+	// With two-factor authentication the session starts only after /login/totp
+	if !resp.GetMfaRequired() {
+		setSessionCookies(w, resp.GetJwt(), resp.GetExpiresAt().AsTime(), resp.GetRefreshToken(), resp.GetRefreshExpiresAt().AsTime())
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Refresh exchanges a refresh token for a new pair of tokens.
+// @Summary Обновление токена
+// @Description Выдает новый access-токен и новый refresh-токен взамен переданного. Refresh-токен берется из cookie refresh_token или из тела запроса и может быть использован только один раз.
+// @Tags Auth service
+// @Accept json
+// @Produce json
+// @Param request body models.RefreshRequest false "Refresh token, if not sent in the cookie"
+// @Success 200 {object} models.LogInResponse
+// @Failure 401 {string} string "Refresh token is invalid, expired or revoked"
+// @Router /refresh [post]
+func (h *AuthHandlers) Refresh(w http.ResponseWriter, r *http.Request) {
+	var body models.RefreshRequest
+	if cookie, err := r.Cookie(refreshCookie); err == nil {
+		body.RefreshToken = cookie.Value
+	} else if err = json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Missing refresh token", http.StatusBadRequest)
+		return
+	}
+
+	req := pb.RefreshRequest{RefreshToken: body.RefreshToken, RequestId: r.Context().Value(logger.RequestID).(string)}
+	resp, err := h.client.Refresh(r.Context(), &req)
+	if err != nil {
+		clearSessionCookies(w)
+		writeServiceError(w, "Auth-Service", err)
+		return
+	}
+
+	setSessionCookies(w, resp.GetJwt(), resp.GetExpiresAt().AsTime(), resp.GetRefreshToken(), resp.GetRefreshExpiresAt().AsTime())
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// LogOut ends the session.
+// @Summary Выход из системы
+// @Description Отзывает access-токен и refresh-токен из cookie и удаляет cookie
+// @Tags Auth service
+// @Success 204 "Logged out"
+// @Router /logout [post]
+func (h *AuthHandlers) LogOut(w http.ResponseWriter, r *http.Request) {
+	req := pb.LogOutRequest{RequestId: r.Context().Value(logger.RequestID).(string)}
+	if cookie, err := r.Cookie(tokenCookie); err == nil {
+		req.Jwt = cookie.Value
+	}
+	if cookie, err := r.Cookie(refreshCookie); err == nil {
+		req.RefreshToken = cookie.Value
+	}
+
+	clearSessionCookies(w)
+	if req.Jwt != "" || req.RefreshToken != "" {
+		if _, err := h.client.LogOut(r.Context(), &req); err != nil {
+			writeServiceError(w, "Auth-Service", err)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+const (
+	tokenCookie   = "token"
+	refreshCookie = "refresh_token"
+)
+
+func setSessionCookies(w http.ResponseWriter, token string, expiresAt time.Time, refreshToken string, refreshExpiresAt time.Time) {
 	http.SetCookie(w, &http.Cookie{
-		Name:     "token",
-		Value:    resp.Jwt,
+		Name:     tokenCookie,
+		Value:    token,
 		Path:     "/",
 		HttpOnly: true,
 		SameSite: http.SameSiteStrictMode,
-		Expires:  time.Now().Add(time.Hour),
+		Expires:  expiresAt,
 	})
-
 	http.SetCookie(w, &http.Cookie{
-		Name:     "user_id",
-		Value:    fmt.Sprint(resp.UserId),
+		Name:     refreshCookie,
+		Value:    refreshToken,
 		Path:     "/",
 		HttpOnly: true,
 		SameSite: http.SameSiteStrictMode,
-		Expires:  time.Now().Add(time.Hour),
+		Expires:  refreshExpiresAt,
 	})
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+func clearSessionCookies(w http.ResponseWriter) {
+	for _, name := range []string{tokenCookie, refreshCookie} {
+		http.SetCookie(w, &http.Cookie{
+			Name:     name,
+			Value:    "",
+			MaxAge:   -1,
+			HttpOnly: true,
+			Path:     "/",
+		})
+	}
+}
+
+// clientIP is the address the request came from.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
 }
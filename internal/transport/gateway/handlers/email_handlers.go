@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"encoding/json"
+	"house-of-neural-networks/internal/models"
+	"house-of-neural-networks/pkg/logger"
+	"net/http"
+
+	pb "house-of-neural-networks/pkg/api/auth"
+)
+
+// SendVerificationEmail
+// @Summary Send an email verification link
+// @Description Sends a new verification link to the email of the user. Earlier links stop working.
+// @Tags Auth service
+// @Security TokenAuth
+// @Success 202 "Email sent"
+// @Failure 400 {string} string "No email or email already verified"
+// @Router /email/verification [post]
+func (h *AuthHandlers) SendVerificationEmail(w http.ResponseWriter, r *http.Request) {
+	req := pb.SendVerificationEmailRequest{RequestId: r.Context().Value(logger.RequestID).(string)}
+	if _, err := h.client.SendVerificationEmail(r.Context(), &req); err != nil {
+		writeServiceError(w, "Auth-Service", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// VerifyEmail
+// @Summary Verify an email
+// @Description Target of the link sent by email. A link can be used once.
+// @Tags Auth service
+// @Produce json
+// @Param token query string true "Token from the link"
+// @Success 200 {object} models.VerifyEmailResponse
+// @Failure 400 {string} string "Invalid or expired token"
+// @Router /email/verify [get]
+func (h *AuthHandlers) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	req := pb.VerifyEmailRequest{
+		Token:     r.URL.Query().Get("token"),
+		RequestId: r.Context().Value(logger.RequestID).(string),
+	}
+	if _, err := h.client.VerifyEmail(r.Context(), &req); err != nil {
+		writeServiceError(w, "Auth-Service", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.VerifyEmailResponse{Verified: true})
+}
+
+// RequestPasswordReset
+// @Summary Request a password reset
+// @Description Emails a password reset token to every account registered with the email. The response does not reveal whether there is one.
+// @Tags Auth service
+// @Accept json
+// @Param request body models.PasswordResetRequest true "Email of the account"
+// @Success 202 "Request accepted"
+// @Router /password/forgot [post]
+func (h *AuthHandlers) RequestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	var body models.PasswordResetRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	req := pb.RequestPasswordResetRequest{Email: body.Email, RequestId: r.Context().Value(logger.RequestID).(string)}
+	if _, err := h.client.RequestPasswordReset(r.Context(), &req); err != nil {
+		writeServiceError(w, "Auth-Service", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// ResetPassword
+// @Summary Reset the password
+// @Description Sets a new password with the token from the password reset email and logs the user out everywhere. A token can be used once.
+// @Tags Auth service
+// @Accept json
+// @Param request body models.ResetPasswordRequest true "Token and new password"
+// @Success 204 "Password changed"
+// @Failure 400 {string} string "Invalid or expired token"
+// @Router /password/reset [post]
+func (h *AuthHandlers) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var body models.ResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	req := pb.ResetPasswordRequest{
+		Token:     body.Token,
+		Password:  body.Password,
+		RequestId: r.Context().Value(logger.RequestID).(string),
+	}
+	if _, err := h.client.ResetPassword(r.Context(), &req); err != nil {
+		writeServiceError(w, "Auth-Service", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
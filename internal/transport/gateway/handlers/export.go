@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"house-of-neural-networks/internal/models"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	exportJSONL = "jsonl"
+	exportCSV   = "csv"
+)
+
+var exportCSVHeader = []string{"id", "model_id", "version_id", "created_at", "inputs", "outputs"}
+
+// exportWriter writes exported messages to the response as they arrive.
+type exportWriter struct {
+	w       http.ResponseWriter
+	format  string
+	encoder *json.Encoder
+	csv     *csv.Writer
+	flusher http.Flusher
+}
+
+func newExportWriter(w http.ResponseWriter, format string, modelId int64) *exportWriter {
+	filename := fmt.Sprintf("chat-%d.%s", modelId, format)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	if format == exportCSV {
+		w.Header().Set("Content-Type", "text/csv")
+	} else {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	}
+	w.WriteHeader(http.StatusOK)
+
+	writer := &exportWriter{w: w, format: format}
+	writer.flusher, _ = w.(http.Flusher)
+	if format == exportCSV {
+		writer.csv = csv.NewWriter(w)
+		writer.csv.Write(exportCSVHeader)
+	} else {
+		writer.encoder = json.NewEncoder(w)
+	}
+	return writer
+}
+
+func (e *exportWriter) Write(msg models.ChatMessage) error {
+	if e.format == exportJSONL {
+		if err := e.encoder.Encode(msg); err != nil {
+			return err
+		}
+	} else {
+		inputs, err := json.Marshal(msg.Inputs)
+		if err != nil {
+			return err
+		}
+		outputs, err := json.Marshal(msg.Outputs)
+		if err != nil {
+			return err
+		}
+		err = e.csv.Write([]string{
+			strconv.FormatInt(msg.ID, 10),
+			strconv.FormatInt(msg.ModelID, 10),
+			strconv.FormatInt(msg.VersionID, 10),
+			msg.CreatedAt.Format(time.RFC3339Nano),
+			string(inputs),
+			string(outputs),
+		})
+		if err != nil {
+			return err
+		}
+		e.csv.Flush()
+		if err = e.csv.Error(); err != nil {
+			return err
+		}
+	}
+	if e.flusher != nil {
+		e.flusher.Flush()
+	}
+	return nil
+}
+
+func (e *exportWriter) Close() error {
+	if e.csv != nil {
+		e.csv.Flush()
+		return e.csv.Error()
+	}
+	return nil
+}
@@ -2,14 +2,19 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"github.com/gorilla/mux"
 	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/timestamppb"
 	"house-of-neural-networks/internal/models"
 	"house-of-neural-networks/internal/transport/grpc_clients"
 	pb "house-of-neural-networks/pkg/api/message"
 	"house-of-neural-networks/pkg/logger"
+	"io"
 	"net/http"
+	"net/url"
 	"strconv"
+	"time"
 )
 
 type MessageHandlers struct {
@@ -22,7 +27,8 @@ func NewMessageHandlers(client *grpc_clients.MessageClient) *MessageHandlers {
 
 // SendMessage sends a message to a specific model and retrieves the response.
 // @Summary Send a message to a model
-// @Description This endpoint allows a user to send a request to a specific model identified by its ID and receive a response.
+// @Description This endpoint allows a user to send named input tensors to a specific model version and receive its outputs keyed by output name.
+// @Description Datatype and shape of an input are taken from the model schema when omitted. Data is a (nested) array of numbers, booleans or strings; binary BYTES elements are passed as base64 strings with "encoding": "base64".
 // @Tags Message service
 // @Accept json
 // @Produce json
@@ -31,10 +37,17 @@ func NewMessageHandlers(client *grpc_clients.MessageClient) *MessageHandlers {
 // @Param version_id path int true "Version ID of model"
 // @Param request body models.SendMessageRequest true "Request to model"
 // @Success 200 {object} models.SendMessageResponse "Response from the model"
+// @Failure 400 {string} string "Inputs do not match the model schema"
+// @Failure 403 {string} string "Model belongs to another user"
+// @Failure 404 {string} string "Model or version not found"
+// @Failure 503 {string} string "Inference server is unavailable"
+// @Failure 504 {string} string "Inference timed out"
 // @Router /chat/{model_id}/{version_id} [post]
 func (h *MessageHandlers) SendMessage(w http.ResponseWriter, r *http.Request) {
 	var reqJson models.SendMessageRequest
-	if err := json.NewDecoder(r.Body).Decode(&reqJson); err != nil {
+	decoder := json.NewDecoder(r.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&reqJson); err != nil {
 		http.Error(w, "Invalid request", http.StatusBadRequest)
 		logger.GetLoggerFromCtx(r.Context()).Error(
 			r.Context(),
@@ -45,13 +58,18 @@ func (h *MessageHandlers) SendMessage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if len(reqJson.Input1) != 16 {
-		http.Error(w, "The number of values in Input1 is not equal to 16", http.StatusBadRequest)
+	if len(reqJson.Inputs) == 0 {
+		http.Error(w, "No inputs provided", http.StatusBadRequest)
 		return
 	}
-	if len(reqJson.Input2) != 16 {
-		http.Error(w, "The number of values in Input1 is not equal to 16", http.StatusBadRequest)
-		return
+	inputs := make([]*pb.Tensor, 0, len(reqJson.Inputs))
+	for i, input := range reqJson.Inputs {
+		tensor, err := tensorToProto(input)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid input %d: %s", i, err), http.StatusBadRequest)
+			return
+		}
+		inputs = append(inputs, tensor)
 	}
 
 	vars := mux.Vars(r)
@@ -76,20 +94,7 @@ func (h *MessageHandlers) SendMessage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userIdStr, _ := r.Cookie("user_id")
-	userId, _ := strconv.ParseInt(userIdStr.Value, 10, 32)
-
-	inputs := []*pb.Input{
-		{
-			Values: reqJson.Input1,
-		},
-		{
-			Values: reqJson.Input2,
-		},
-	}
-
 	req := pb.SendMessageRequest{
-		UserId:    userId,
 		VersionId: versionId,
 		ModelId:   modelId,
 		RequestId: r.Context().Value(logger.RequestID).(string),
@@ -98,25 +103,36 @@ func (h *MessageHandlers) SendMessage(w http.ResponseWriter, r *http.Request) {
 
 	respTriton, err := h.client.SendMessage(r.Context(), &req)
 	if err != nil {
-		http.Error(w, "Error calling Message-Service", http.StatusInternalServerError)
+		writeServiceError(w, "Message-Service", err)
 		return
 	}
 
 	resp := models.SendMessageResponse{
-		Results: respTriton.GetResults(),
+		Outputs: make(map[string]models.Tensor, len(respTriton.GetOutputs())),
+	}
+	for name, output := range respTriton.GetOutputs() {
+		resp.Outputs[name] = tensorFromProto(output)
 	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
 }
 
-// GetMessages retrieves all messages for a specific model and user.
+// GetMessages retrieves a page of messages for a specific model and user.
 // @Summary Get messages
-// @Description This endpoint retrieves all messages associated with a specific model ID and user ID.
+// @Description This endpoint retrieves messages associated with a specific model ID and user ID, with the input and output tensors of every message.
+// @Description Messages are ordered by creation time and returned page by page; pass nextPageToken of the response as page_token to get the next page.
 // @Tags Message service
 // @Produce json
 // @Security TokenAuth
 // @Param model_id path int true "Model ID"
-// @Success 200 {object} models.GetMessagesResponse "List of messages"
+// @Param version_id query int false "Only messages sent to this version"
+// @Param from query string false "Only messages created at or after this time (RFC 3339)"
+// @Param to query string false "Only messages created before this time (RFC 3339)"
+// @Param order query string false "Order by creation time" Enums(asc, desc) default(asc)
+// @Param page_size query int false "Messages per page, 50 by default, at most 500"
+// @Param page_token query string false "Token of the page returned as nextPageToken"
+// @Success 200 {object} models.GetMessagesResponse "Page of messages"
+// @Failure 400 {string} string "Invalid model ID, filter or page token"
 // @Router /chat/{model_id} [get]
 func (h *MessageHandlers) GetMessages(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -125,21 +141,262 @@ func (h *MessageHandlers) GetMessages(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Missing id parameter", http.StatusBadRequest)
 		return
 	}
-	modelId, err := strconv.ParseInt(idStr, 10, 32)
-	userIdStr, _ := r.Cookie("user_id")
-	userId, _ := strconv.ParseInt(userIdStr.Value, 10, 32)
+	modelId, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID format, must be an integer", http.StatusBadRequest)
+		return
+	}
 
 	req := pb.GetMessagesRequest{
 		ModelId:   modelId,
-		UserId:    userId,
 		RequestId: r.Context().Value(logger.RequestID).(string),
 	}
+	query := r.URL.Query()
+	req.PageToken = query.Get("page_token")
+	req.VersionId, req.From, req.To, err = historyFilter(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if value := query.Get("page_size"); value != "" {
+		pageSize, err := strconv.ParseInt(value, 10, 32)
+		if err != nil || pageSize <= 0 {
+			http.Error(w, "Invalid page_size", http.StatusBadRequest)
+			return
+		}
+		req.PageSize = int32(pageSize)
+	}
+	switch query.Get("order") {
+	case "", "asc":
+	case "desc":
+		req.Descending = true
+	default:
+		http.Error(w, "Invalid order, expected asc or desc", http.StatusBadRequest)
+		return
+	}
+
 	resp, err := h.client.GetMessages(r.Context(), &req)
 	if err != nil {
-		http.Error(w, "Error calling Message-Service", http.StatusInternalServerError)
+		writeServiceError(w, "Message-Service", err)
 		return
 	}
 
+	history := models.GetMessagesResponse{
+		Messages:      make([]models.ChatMessage, 0, len(resp.GetMessages())),
+		NextPageToken: resp.GetNextPageToken(),
+		TotalCount:    resp.GetTotalCount(),
+	}
+	for _, msg := range resp.GetMessages() {
+		history.Messages = append(history.Messages, chatMessageFromProto(msg))
+	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+	json.NewEncoder(w).Encode(history)
+}
+
+func chatMessageFromProto(msg *pb.Message) models.ChatMessage {
+	chatMessage := models.ChatMessage{
+		ID:        msg.GetId(),
+		ModelID:   msg.GetModelId(),
+		VersionID: msg.GetVersionId(),
+		Inputs:    make([]models.Tensor, 0, len(msg.GetInputs())),
+		Outputs:   make([]models.Tensor, 0, len(msg.GetOutputs())),
+		CreatedAt: msg.GetCreatedAt().AsTime(),
+	}
+	for _, input := range msg.GetInputs() {
+		chatMessage.Inputs = append(chatMessage.Inputs, tensorFromProto(input))
+	}
+	for _, output := range msg.GetOutputs() {
+		chatMessage.Outputs = append(chatMessage.Outputs, tensorFromProto(output))
+	}
+	return chatMessage
+}
+
+// ListChats retrieves the conversations of the current user.
+// @Summary List chats
+// @Description This endpoint lists every model version the user has talked to with the model name, the number of messages and the time of the last message, most recently active first.
+// @Tags Message service
+// @Produce json
+// @Security TokenAuth
+// @Success 200 {object} models.ListChatsResponse "List of chats"
+// @Router /chat [get]
+func (h *MessageHandlers) ListChats(w http.ResponseWriter, r *http.Request) {
+	req := pb.ListChatsRequest{
+		RequestId: r.Context().Value(logger.RequestID).(string),
+	}
+	resp, err := h.client.ListChats(r.Context(), &req)
+	if err != nil {
+		writeServiceError(w, "Message-Service", err)
+		return
+	}
+
+	list := models.ListChatsResponse{
+		Chats: make([]models.Chat, 0, len(resp.GetChats())),
+	}
+	for _, chat := range resp.GetChats() {
+		list.Chats = append(list.Chats, models.Chat{
+			ModelID:       chat.GetModelId(),
+			ModelName:     chat.GetModelName(),
+			VersionID:     chat.GetVersionId(),
+			VersionNumber: chat.GetVersionNumber(),
+			LastMessageAt: chat.GetLastMessageAt().AsTime(),
+			MessageCount:  chat.GetMessageCount(),
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// DeleteMessage deletes a single message of the current user.
+// @Summary Delete a message
+// @Description This endpoint deletes a message from the chat history of the user.
+// @Tags Message service
+// @Security TokenAuth
+// @Param message_id path int true "Message ID"
+// @Success 204 "Message deleted"
+// @Failure 404 {string} string "Message not found"
+// @Router /chat/messages/{message_id} [delete]
+func (h *MessageHandlers) DeleteMessage(w http.ResponseWriter, r *http.Request) {
+	messageId, err := strconv.ParseInt(mux.Vars(r)["message_id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid message_id", http.StatusBadRequest)
+		return
+	}
+	req := pb.DeleteMessageRequest{
+		MessageId: messageId,
+		RequestId: r.Context().Value(logger.RequestID).(string),
+	}
+	if _, err = h.client.DeleteMessage(r.Context(), &req); err != nil {
+		writeServiceError(w, "Message-Service", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeleteChat deletes the whole conversation of the current user with a model.
+// @Summary Delete a chat
+// @Description This endpoint deletes every message the user sent to the model, or only to one of its versions when version_id is given.
+// @Tags Message service
+// @Produce json
+// @Security TokenAuth
+// @Param model_id path int true "Model ID"
+// @Param version_id query int false "Only delete messages sent to this version"
+// @Success 200 {object} models.DeleteChatResponse "Number of deleted messages"
+// @Router /chat/{model_id} [delete]
+func (h *MessageHandlers) DeleteChat(w http.ResponseWriter, r *http.Request) {
+	modelId, err := strconv.ParseInt(mux.Vars(r)["model_id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid model_id", http.StatusBadRequest)
+		return
+	}
+	req := pb.DeleteChatRequest{
+		ModelId:   modelId,
+		RequestId: r.Context().Value(logger.RequestID).(string),
+	}
+	if value := r.URL.Query().Get("version_id"); value != "" {
+		if req.VersionId, err = strconv.ParseInt(value, 10, 64); err != nil {
+			http.Error(w, "Invalid version_id", http.StatusBadRequest)
+			return
+		}
+	}
+	resp, err := h.client.DeleteChat(r.Context(), &req)
+	if err != nil {
+		writeServiceError(w, "Message-Service", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.DeleteChatResponse{DeletedCount: resp.GetDeletedCount()})
+}
+
+// ExportMessages streams the chat history of the current user with a model.
+// @Summary Export messages
+// @Description This endpoint streams every message matching the filter in chronological order, either as JSON lines (one message per line) or as CSV with inputs and outputs encoded as JSON.
+// @Tags Message service
+// @Produce application/x-ndjson,text/csv
+// @Security TokenAuth
+// @Param model_id path int true "Model ID"
+// @Param format query string false "Export format" Enums(jsonl, csv) default(jsonl)
+// @Param version_id query int false "Only messages sent to this version"
+// @Param from query string false "Only messages created at or after this time (RFC 3339)"
+// @Param to query string false "Only messages created before this time (RFC 3339)"
+// @Success 200 {array} models.ChatMessage "Messages"
+// @Failure 400 {string} string "Invalid filter or format"
+// @Router /chat/{model_id}/export [get]
+func (h *MessageHandlers) ExportMessages(w http.ResponseWriter, r *http.Request) {
+	modelId, err := strconv.ParseInt(mux.Vars(r)["model_id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid model_id", http.StatusBadRequest)
+		return
+	}
+	query := r.URL.Query()
+	format := query.Get("format")
+	if format == "" {
+		format = exportJSONL
+	}
+	if format != exportJSONL && format != exportCSV {
+		http.Error(w, "Invalid format, expected jsonl or csv", http.StatusBadRequest)
+		return
+	}
+	req := pb.ExportMessagesRequest{
+		ModelId:   modelId,
+		RequestId: r.Context().Value(logger.RequestID).(string),
+	}
+	req.VersionId, req.From, req.To, err = historyFilter(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	stream, err := h.client.ExportMessages(r.Context(), &req)
+	if err != nil {
+		writeServiceError(w, "Message-Service", err)
+		return
+	}
+	// The first message is received before writing the headers so that a failed export gets a proper status
+	msg, err := stream.Recv()
+	if err != nil && err != io.EOF {
+		writeServiceError(w, "Message-Service", err)
+		return
+	}
+
+	writer := newExportWriter(w, format, modelId)
+	for err == nil {
+		if err = writer.Write(chatMessageFromProto(msg)); err != nil {
+			break
+		}
+		msg, err = stream.Recv()
+	}
+	if err == io.EOF {
+		err = writer.Close()
+	}
+	if err != nil {
+		// The status is already sent, the client sees a truncated export
+		logger.GetLoggerFromCtx(r.Context()).Error(
+			r.Context(),
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+		)
+	}
+}
+
+// historyFilter parses the version_id, from and to query parameters shared by the history endpoints.
+func historyFilter(query url.Values) (int64, *timestamppb.Timestamp, *timestamppb.Timestamp, error) {
+	var versionId int64
+	if value := query.Get("version_id"); value != "" {
+		var err error
+		if versionId, err = strconv.ParseInt(value, 10, 64); err != nil {
+			return 0, nil, nil, fmt.Errorf("Invalid version_id")
+		}
+	}
+	timestamps := make([]*timestamppb.Timestamp, 2)
+	for i, param := range []string{"from", "to"} {
+		if value := query.Get(param); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return 0, nil, nil, fmt.Errorf("Invalid %s, expected RFC 3339 time", param)
+			}
+			timestamps[i] = timestamppb.New(t)
+		}
+	}
+	return versionId, timestamps[0], timestamps[1], nil
 }
@@ -1,14 +1,19 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"github.com/gorilla/mux"
 	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"house-of-neural-networks/internal/models"
 	"house-of-neural-networks/internal/transport/grpc_clients"
 	"house-of-neural-networks/pkg/logger"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
 
 	pb "house-of-neural-networks/pkg/api/model"
 )
@@ -30,6 +35,8 @@ func NewModelHandlers(client *grpc_clients.ModelClient) *ModelHandlers {
 // @Security TokenAuth
 // @Param id path int true "Идентификатор модели"
 // @Success 200 {object} models.GetModelResponse
+// @Failure 403 {string} string "Model belongs to another user"
+// @Failure 404 {string} string "Model not found"
 // @Router /models/{id} [get]
 func (h *ModelHandlers) GetModel(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -55,7 +62,7 @@ func (h *ModelHandlers) GetModel(w http.ResponseWriter, r *http.Request) {
 
 	resp, err := h.client.GetModel(r.Context(), &req)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error calling Model-service, GetModel: %s", err.Error()), http.StatusInternalServerError)
+		writeServiceError(w, "Model-Service", err)
 		return
 	}
 
@@ -65,7 +72,7 @@ func (h *ModelHandlers) GetModel(w http.ResponseWriter, r *http.Request) {
 
 // ListModels
 // @Summary Получение списка моделей
-// @Description Возвращает список моделей, связанных с пользователем
+// @Description Возвращает список моделей пользователя и моделей, к которым ему открыт доступ, с его ролью в каждой
 // @Tags Model service
 // @Accept json
 // @Produce json
@@ -73,13 +80,10 @@ func (h *ModelHandlers) GetModel(w http.ResponseWriter, r *http.Request) {
 // @Success 200 {object} models.ListModelsResponse
 // @Router /models [get]
 func (h *ModelHandlers) ListModels(w http.ResponseWriter, r *http.Request) {
-	userIdStr, _ := r.Cookie("user_id")
-	userId, _ := strconv.ParseInt(userIdStr.Value, 10, 64)
-
-	req := pb.ListModelsRequest{UserId: userId, RequestId: r.Context().Value(logger.RequestID).(string)}
+	req := pb.ListModelsRequest{RequestId: r.Context().Value(logger.RequestID).(string)}
 	resp, err := h.client.ListModels(r.Context(), &req)
 	if err != nil {
-		http.Error(w, "Error calling Model-service", http.StatusInternalServerError)
+		writeServiceError(w, "Model-Service", err)
 		return
 	}
 
@@ -96,7 +100,10 @@ func (h *ModelHandlers) ListModels(w http.ResponseWriter, r *http.Request) {
 // @Security TokenAuth
 // @Param name formData string true "Name of the model"
 // @Param file formData file true "Config file"
+// @Param organization_id formData int false "Organization owning the model, requires the maintainer or owner role in it"
 // @Success 200 {object} models.UploadModelResponse "Model upload successful"
+// @Failure 403 {string} string "Not a maintainer of the organization"
+// @Failure 409 {string} string "Model name is taken"
 // @Router /models [post]
 func (h *ModelHandlers) UploadModel(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseMultipartForm(32 << 20); err != nil {
@@ -115,9 +122,14 @@ func (h *ModelHandlers) UploadModel(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Missing required fields", http.StatusBadRequest)
 		return
 	}
-
-	userIdStr, _ := r.Cookie("user_id")
-	userId, _ := strconv.ParseInt(userIdStr.Value, 10, 64)
+	var organizationId int64
+	if value := r.FormValue("organization_id"); value != "" {
+		var err error
+		if organizationId, err = strconv.ParseInt(value, 10, 64); err != nil {
+			http.Error(w, "Invalid organization_id", http.StatusBadRequest)
+			return
+		}
+	}
 
 	file, header, err := r.FormFile("file")
 	if err != nil {
@@ -158,13 +170,13 @@ func (h *ModelHandlers) UploadModel(w http.ResponseWriter, r *http.Request) {
 			Filename: header.Filename,
 			Content:  fileData,
 		},
-		UserId:    userId,
-		RequestId: r.Context().Value(logger.RequestID).(string),
+		OrganizationId: organizationId,
+		RequestId:      r.Context().Value(logger.RequestID).(string),
 	}
 
 	resp, err := h.client.UploadModel(r.Context(), &req)
 	if err != nil {
-		http.Error(w, "Error calling Model-service", http.StatusInternalServerError)
+		writeServiceError(w, "Model-Service", err)
 		return
 	}
 
@@ -174,9 +186,17 @@ func (h *ModelHandlers) UploadModel(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+const (
+	// maxUploadSize limits the size of a version upload.
+	maxUploadSize = 32 << 30 // 32 GB
+	// uploadChunkSize is the size of the parts files are streamed to the model service in.
+	uploadChunkSize = 1 << 20 // 1 MB
+)
+
 // UploadVersion uploads a new version of an existing model.
 // @Summary Upload a new version of a model
 // @Description This endpoint allows the user to upload a new version of an existing model. The request includes version number, model ID, and one or more files.
+// @Description Files are streamed to the model service as they arrive, so version and model_id have to come before the files in the form, or be passed in the query.
 // @Tags Model service
 // @Accept multipart/form-data
 // @Produce json
@@ -185,79 +205,182 @@ func (h *ModelHandlers) UploadModel(w http.ResponseWriter, r *http.Request) {
 // @Param model_id formData int true "ID of the model"
 // @Param files formData file true "Files for the new version model"
 // @Success 200 {object} models.UploadVersionResponse "Version upload successful"
+// @Failure 400 {string} string "Invalid form data"
+// @Failure 403 {string} string "Model belongs to another user"
+// @Failure 404 {string} string "Model not found"
+// @Failure 413 {string} string "Upload too large"
 // @Router /models/version [post]
 func (h *ModelHandlers) UploadVersion(w http.ResponseWriter, r *http.Request) {
-	versionStr := r.FormValue("version")
-	modelIdStr := r.FormValue("model_id")
-	if versionStr == "" || modelIdStr == "" {
-		http.Error(w, "Missing required fields", http.StatusBadRequest)
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	reader, err := r.MultipartReader()
+	if err != nil {
+		http.Error(w, "Error parsing form data", http.StatusBadRequest)
 		return
 	}
-	version, err := strconv.ParseInt(versionStr, 10, 32)
-	if err != nil {
-		http.Error(w, "Invalid version format, must be an integer", http.StatusBadRequest)
+
+	// Cancelling the stream makes the model service drop the files received so far
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	fields := map[string]string{
+		"version":  r.URL.Query().Get("version"),
+		"model_id": r.URL.Query().Get("model_id"),
+	}
+	var stream pb.ModelService_UploadVersionStreamClient
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			writeUploadError(w, err)
+			return
+		}
+
+		switch name := part.FormName(); name {
+		case "version", "model_id":
+			if stream != nil {
+				http.Error(w, "Fields must come before the files", http.StatusBadRequest)
+				return
+			}
+			value, err := io.ReadAll(io.LimitReader(part, 64))
+			if err != nil {
+				writeUploadError(w, err)
+				return
+			}
+			fields[name] = strings.TrimSpace(string(value))
+		case "files":
+			if stream == nil {
+				header, err := uploadVersionHeader(fields)
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				header.RequestId = r.Context().Value(logger.RequestID).(string)
+				if stream, err = h.client.UploadVersionStream(ctx); err != nil {
+					writeServiceError(w, "Model-Service", err)
+					return
+				}
+				if err = sendUploadFrame(stream, &pb.UploadVersionChunk{Frame: &pb.UploadVersionChunk_Header{Header: header}}); err != nil {
+					writeUploadError(w, err)
+					return
+				}
+			}
+
+			size, err := streamFile(stream, part.FileName(), part)
+			if err != nil {
+				writeUploadError(w, err)
+				return
+			}
+			logger.GetLoggerFromCtx(r.Context()).Info(
+				r.Context(),
+				"File uploaded",
+				zap.String("Filename", part.FileName()),
+				zap.Int64("Size", size),
+				zap.String("MIME-Type", part.Header.Get("Content-Type")),
+			)
+		}
+		part.Close()
+	}
+
+	if stream == nil {
+		if _, err = uploadVersionHeader(fields); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "No files uploaded", http.StatusBadRequest)
 		return
 	}
-	modelId, err := strconv.ParseInt(modelIdStr, 10, 64)
+	resp, err := stream.CloseAndRecv()
 	if err != nil {
-		http.Error(w, "Invalid model id format, must be an integer", http.StatusBadRequest)
+		writeServiceError(w, "Model-Service", err)
 		return
 	}
 
-	r.Body = http.MaxBytesReader(w, r.Body, 1<<30)       // 1 GB
-	if err = r.ParseMultipartForm(1 << 20); err != nil { // Ограничение в 1 MB на мета-данные
-		http.Error(w, "Error parsing form data", http.StatusBadRequest)
-		return
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func uploadVersionHeader(fields map[string]string) (*pb.UploadVersionHeader, error) {
+	if fields["version"] == "" || fields["model_id"] == "" {
+		return nil, errors.New("Missing required fields")
+	}
+	version, err := strconv.ParseInt(fields["version"], 10, 32)
+	if err != nil {
+		return nil, errors.New("Invalid version format, must be an integer")
 	}
+	modelId, err := strconv.ParseInt(fields["model_id"], 10, 64)
+	if err != nil {
+		return nil, errors.New("Invalid model id format, must be an integer")
+	}
+	return &pb.UploadVersionHeader{Number: int32(version), ModelId: modelId}, nil
+}
 
-	files := r.MultipartForm.File["files"]
-	if len(files) == 0 {
-		http.Error(w, "No files uploaded", http.StatusBadRequest)
-		return
+// streamFile sends the file as a filename frame followed by its content in chunks, and returns its size.
+func streamFile(stream pb.ModelService_UploadVersionStreamClient, filename string, content io.Reader) (int64, error) {
+	if err := sendUploadFrame(stream, &pb.UploadVersionChunk{Frame: &pb.UploadVersionChunk_Filename{Filename: filename}}); err != nil {
+		return 0, err
 	}
+	return streamChunks(content, func(chunk []byte) error {
+		return sendUploadFrame(stream, &pb.UploadVersionChunk{Frame: &pb.UploadVersionChunk_Data{Data: chunk}})
+	})
+}
 
-	filesData := make([]*pb.File, 0, len(files))
-	for _, fileHeader := range files {
-		file, err := fileHeader.Open()
-		if err != nil {
-			http.Error(w, "Unable to open file", http.StatusInternalServerError)
-			return
+// streamChunks passes the content to send in chunks and returns its size.
+func streamChunks(content io.Reader, send func(chunk []byte) error) (int64, error) {
+	var size int64
+	for {
+		// A sent message must not be modified, so every chunk gets its own buffer
+		chunk := make([]byte, uploadChunkSize)
+		n, err := io.ReadFull(content, chunk)
+		if n > 0 {
+			if err := send(chunk[:n]); err != nil {
+				return size, err
+			}
+			size += int64(n)
 		}
-		defer file.Close()
-		fileData := make([]byte, fileHeader.Size)
-		if _, err = file.Read(fileData); err != nil {
-			http.Error(w, "Error reading file content", http.StatusInternalServerError)
-			return
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return size, nil
+		}
+		if err != nil {
+			return size, err
 		}
-		filesData = append(filesData, &pb.File{
-			Filename: fileHeader.Filename,
-			Content:  fileData,
-		})
-
-		logger.GetLoggerFromCtx(r.Context()).Info(
-			r.Context(),
-			"File uploaded",
-			zap.String("Filename", fileHeader.Filename),
-			zap.Int64("Size", fileHeader.Size),
-			zap.String("MIME-Type", fileHeader.Header.Get("Content-Type")),
-		)
 	}
+}
 
-	req := pb.UploadVersionRequest{
-		Files:     filesData,
-		Number:    int32(version),
-		ModelId:   modelId,
-		RequestId: r.Context().Value(logger.RequestID).(string),
+// sendUploadFrame sends the frame. When the model service has already failed the upload, the error it
+// failed with is returned.
+func sendUploadFrame[Req, Res any](stream grpc.ClientStreamingClient[Req, Res], frame *Req) error {
+	err := stream.Send(frame)
+	if err == io.EOF {
+		_, err = stream.CloseAndRecv()
 	}
-
-	resp, err := h.client.UploadVersion(r.Context(), &req)
 	if err != nil {
-		http.Error(w, "Error calling Model-service", http.StatusInternalServerError)
-		return
+		return &uploadError{err}
 	}
+	return nil
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+// uploadError is an error of the model service during an upload, as opposed to an error reading the request.
+type uploadError struct {
+	err error
+}
+
+func (e *uploadError) Error() string {
+	return e.err.Error()
+}
+
+func writeUploadError(w http.ResponseWriter, err error) {
+	var serviceErr *uploadError
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &serviceErr):
+		writeServiceError(w, "Model-Service", serviceErr.err)
+	case errors.As(err, &maxBytesErr):
+		http.Error(w, "Upload too large", http.StatusRequestEntityTooLarge)
+	default:
+		http.Error(w, "Error reading file content", http.StatusBadRequest)
+	}
 }
 
 // UnloadModel unloads a model from the service.
@@ -269,6 +392,8 @@ func (h *ModelHandlers) UploadVersion(w http.ResponseWriter, r *http.Request) {
 // @Security TokenAuth
 // @Param request body models.UnloadModelRequest true "Unload model request body"
 // @Success 200 {object} models.UnloadModelResponse "Model unload successful"
+// @Failure 403 {string} string "Model belongs to another user"
+// @Failure 404 {string} string "Model not found"
 // @Router /models [delete]
 func (h *ModelHandlers) UnloadModel(w http.ResponseWriter, r *http.Request) {
 	var req pb.UnloadModelRequest
@@ -285,10 +410,92 @@ func (h *ModelHandlers) UnloadModel(w http.ResponseWriter, r *http.Request) {
 	req.RequestId = r.Context().Value(logger.RequestID).(string)
 	resp, err := h.client.UnloadModel(r.Context(), &req)
 	if err != nil {
-		http.Error(w, "Error calling Model-service", http.StatusInternalServerError)
+		writeServiceError(w, "Model-Service", err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
 }
+
+// GrantAccess shares a model.
+// @Summary Share a model
+// @Description Grants a user, or everyone when public is set, read, infer or manage access to the model. Requires manage access; only the owner may grant manage. Public access is limited to read and infer.
+// @Tags Model service
+// @Accept json
+// @Security TokenAuth
+// @Param id path int true "Model ID"
+// @Param request body models.GrantAccessRequest true "Grantee and permission"
+// @Success 204 "Access granted"
+// @Failure 400 {string} string "Invalid grantee or permission"
+// @Failure 403 {string} string "Not allowed to share the model"
+// @Failure 404 {string} string "Model or user not found"
+// @Router /models/{id}/access [post]
+func (h *ModelHandlers) GrantAccess(w http.ResponseWriter, r *http.Request) {
+	modelId, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID format, must be an integer", http.StatusBadRequest)
+		return
+	}
+	var body models.GrantAccessRequest
+	if err = json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	req := pb.GrantAccessRequest{
+		ModelId:    modelId,
+		UserId:     body.UserId,
+		Public:     body.Public,
+		Permission: body.Permission,
+		RequestId:  r.Context().Value(logger.RequestID).(string),
+	}
+	if _, err = h.client.GrantAccess(r.Context(), &req); err != nil {
+		writeServiceError(w, "Model-Service", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RevokeAccess stops sharing a model.
+// @Summary Revoke access to a model
+// @Description Takes away the access of a user, or public access when public is set. Requires manage access; only the owner may revoke manage.
+// @Tags Model service
+// @Security TokenAuth
+// @Param id path int true "Model ID"
+// @Param user_id query int false "User to revoke access from"
+// @Param public query bool false "Revoke public access"
+// @Success 204 "Access revoked"
+// @Failure 403 {string} string "Not allowed to manage access"
+// @Failure 404 {string} string "Model not found or user has no access"
+// @Router /models/{id}/access [delete]
+func (h *ModelHandlers) RevokeAccess(w http.ResponseWriter, r *http.Request) {
+	modelId, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID format, must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	req := pb.RevokeAccessRequest{
+		ModelId:   modelId,
+		RequestId: r.Context().Value(logger.RequestID).(string),
+	}
+	query := r.URL.Query()
+	if value := query.Get("user_id"); value != "" {
+		if req.UserId, err = strconv.ParseInt(value, 10, 64); err != nil {
+			http.Error(w, "Invalid user_id", http.StatusBadRequest)
+			return
+		}
+	}
+	if value := query.Get("public"); value != "" {
+		if req.Public, err = strconv.ParseBool(value); err != nil {
+			http.Error(w, "Invalid public", http.StatusBadRequest)
+			return
+		}
+	}
+	if _, err = h.client.RevokeAccess(r.Context(), &req); err != nil {
+		writeServiceError(w, "Model-Service", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"house-of-neural-networks/pkg/logger"
+	"net/http"
+
+	pb "house-of-neural-networks/pkg/api/auth"
+)
+
+// oidcStateCookie binds a login at the identity provider to the browser that started it.
+const oidcStateCookie = "oidc_state"
+
+// StartOIDCLogin redirects to the identity provider.
+// @Summary Вход через SSO
+// @Description Перенаправляет на страницу входа внешнего OpenID Connect провайдера. После входа провайдер возвращает пользователя на /login/oidc/callback.
+// @Tags Auth service
+// @Success 302 "Redirect to the identity provider"
+// @Failure 400 {string} string "OpenID Connect login is not configured"
+// @Router /login/oidc [get]
+func (h *AuthHandlers) StartOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	req := pb.StartOIDCLoginRequest{RequestId: r.Context().Value(logger.RequestID).(string)}
+	resp, err := h.client.StartOIDCLogin(r.Context(), &req)
+	if err != nil {
+		writeServiceError(w, "Auth-Service", err)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    resp.GetState(),
+		Path:     "/login/oidc",
+		MaxAge:   600,
+		HttpOnly: true,
+		// Strict would keep the cookie from the redirect back from the provider
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, resp.GetAuthorizationUrl(), http.StatusFound)
+}
+
+// FinishOIDCLogin completes the login at the identity provider.
+// @Summary Завершение входа через SSO
+// @Description Принимает перенаправление от OpenID Connect провайдера и выдает токены, как /login. При первом входе пользователь создается автоматически. Если включена двухфакторная аутентификация, вместо токенов возвращается mfa_required и challenge_token, вход завершается через /login/totp.
+// @Tags Auth service
+// @Produce json
+// @Param code query string true "Authorization code"
+// @Param state query string true "State of the login"
+// @Success 200 {object} models.LogInResponse
+// @Failure 401 {string} string "Login was denied, has expired or was started in another browser"
+// @Router /login/oidc/callback [get]
+func (h *AuthHandlers) FinishOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if providerErr := query.Get("error"); providerErr != "" {
+		http.Error(w, fmt.Sprintf("Identity provider denied the login: %s %s", providerErr, query.Get("error_description")), http.StatusUnauthorized)
+		return
+	}
+
+	state := query.Get("state")
+	cookie, err := r.Cookie(oidcStateCookie)
+	if err != nil || state == "" || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+		http.Error(w, "Login was started in another browser", http.StatusUnauthorized)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: oidcStateCookie, Value: "", Path: "/login/oidc", MaxAge: -1, HttpOnly: true})
+
+	req := pb.FinishOIDCLoginRequest{
+		Code:      query.Get("code"),
+		State:     state,
+		RequestId: r.Context().Value(logger.RequestID).(string),
+	}
+	resp, err := h.client.FinishOIDCLogin(r.Context(), &req)
+	if err != nil {
+		writeServiceError(w, "Auth-Service", err)
+		return
+	}
+
+	// With two-factor authentication the session starts only after /login/totp
+	if !resp.GetMfaRequired() {
+		setSessionCookies(w, resp.GetJwt(), resp.GetExpiresAt().AsTime(), resp.GetRefreshToken(), resp.GetRefreshExpiresAt().AsTime())
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
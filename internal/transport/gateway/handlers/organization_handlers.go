@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"house-of-neural-networks/internal/models"
+	"house-of-neural-networks/pkg/logger"
+	"net/http"
+	"strconv"
+
+	pb "house-of-neural-networks/pkg/api/model"
+)
+
+// CreateOrganization
+// @Summary Create an organization
+// @Description Creates an organization with the caller as its owner. Models uploaded to the organization are shared by all its members.
+// @Tags Organizations
+// @Accept json
+// @Produce json
+// @Security TokenAuth
+// @Param request body models.CreateOrganizationRequest true "Organization"
+// @Success 200 {object} models.CreateOrganizationResponse
+// @Failure 400 {string} string "Name is empty"
+// @Failure 409 {string} string "Organization already exists"
+// @Router /organizations [post]
+func (h *ModelHandlers) CreateOrganization(w http.ResponseWriter, r *http.Request) {
+	var body models.CreateOrganizationRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	req := pb.CreateOrganizationRequest{Name: body.Name, RequestId: r.Context().Value(logger.RequestID).(string)}
+	resp, err := h.client.CreateOrganization(r.Context(), &req)
+	if err != nil {
+		writeServiceError(w, "Model-Service", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// ListOrganizations
+// @Summary List organizations
+// @Description Returns the organizations the caller is a member of with their role in each
+// @Tags Organizations
+// @Produce json
+// @Security TokenAuth
+// @Success 200 {object} models.ListOrganizationsResponse
+// @Router /organizations [get]
+func (h *ModelHandlers) ListOrganizations(w http.ResponseWriter, r *http.Request) {
+	req := pb.ListOrganizationsRequest{RequestId: r.Context().Value(logger.RequestID).(string)}
+	resp, err := h.client.ListOrganizations(r.Context(), &req)
+	if err != nil {
+		writeServiceError(w, "Model-Service", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// ListMembers
+// @Summary List organization members
+// @Tags Organizations
+// @Produce json
+// @Security TokenAuth
+// @Param id path int true "Organization ID"
+// @Success 200 {object} models.ListMembersResponse
+// @Failure 403 {string} string "Not a member of the organization"
+// @Router /organizations/{id}/members [get]
+func (h *ModelHandlers) ListMembers(w http.ResponseWriter, r *http.Request) {
+	organizationId, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID format, must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	req := pb.ListMembersRequest{OrganizationId: organizationId, RequestId: r.Context().Value(logger.RequestID).(string)}
+	resp, err := h.client.ListMembers(r.Context(), &req)
+	if err != nil {
+		writeServiceError(w, "Model-Service", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// SetMember
+// @Summary Add a member or change their role
+// @Description Owners manage every membership, maintainers may only add members with the member role. The last owner cannot be demoted.
+// @Tags Organizations
+// @Accept json
+// @Security TokenAuth
+// @Param id path int true "Organization ID"
+// @Param user_id path int true "User ID"
+// @Param request body models.SetMemberRequest true "Role"
+// @Success 204 "Member saved"
+// @Failure 400 {string} string "Unknown role or the organization would be left without an owner"
+// @Failure 403 {string} string "Not allowed to manage members"
+// @Failure 404 {string} string "User not found"
+// @Router /organizations/{id}/members/{user_id} [put]
+func (h *ModelHandlers) SetMember(w http.ResponseWriter, r *http.Request) {
+	organizationId, userId, ok := memberVars(w, r)
+	if !ok {
+		return
+	}
+	var body models.SetMemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	req := pb.SetMemberRequest{
+		OrganizationId: organizationId,
+		UserId:         userId,
+		Role:           body.Role,
+		RequestId:      r.Context().Value(logger.RequestID).(string),
+	}
+	if _, err := h.client.SetMember(r.Context(), &req); err != nil {
+		writeServiceError(w, "Model-Service", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RemoveMember
+// @Summary Remove a member
+// @Description Removes the user from the organization. Any member may leave on their own, maintainers may remove members, owners anyone.
+// @Tags Organizations
+// @Security TokenAuth
+// @Param id path int true "Organization ID"
+// @Param user_id path int true "User ID"
+// @Success 204 "Member removed"
+// @Failure 400 {string} string "The organization would be left without an owner"
+// @Failure 403 {string} string "Not allowed to manage members"
+// @Failure 404 {string} string "User is not a member"
+// @Router /organizations/{id}/members/{user_id} [delete]
+func (h *ModelHandlers) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	organizationId, userId, ok := memberVars(w, r)
+	if !ok {
+		return
+	}
+
+	req := pb.RemoveMemberRequest{
+		OrganizationId: organizationId,
+		UserId:         userId,
+		RequestId:      r.Context().Value(logger.RequestID).(string),
+	}
+	if _, err := h.client.RemoveMember(r.Context(), &req); err != nil {
+		writeServiceError(w, "Model-Service", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func memberVars(w http.ResponseWriter, r *http.Request) (int64, int64, bool) {
+	vars := mux.Vars(r)
+	organizationId, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID format, must be an integer", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	userId, err := strconv.ParseInt(vars["user_id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid user_id", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	return organizationId, userId, true
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"house-of-neural-networks/internal/models"
+	pb "house-of-neural-networks/pkg/api/message"
+	"strconv"
+	"unicode/utf8"
+)
+
+const base64Encoding = "base64"
+
+// tensorToProto converts a tensor from a JSON request into its protobuf form.
+// The kind of contents follows the JSON values; conversion to the model datatype is done by the Message-service.
+func tensorToProto(tensor models.Tensor) (*pb.Tensor, error) {
+	values := make([]interface{}, 0)
+	flatten(tensor.Data, &values)
+
+	contents := &pb.TensorContents{}
+	var err error
+	if len(values) > 0 {
+		switch values[0].(type) {
+		case bool:
+			contents.BoolContents, err = boolValues(values)
+		case string:
+			contents.BytesContents, err = bytesValues(values, tensor.Encoding)
+		case json.Number:
+			contents.IntContents, contents.UintContents, contents.FpContents, err = numberValues(values)
+		default:
+			err = fmt.Errorf("unsupported value %v", values[0])
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.Tensor{
+		Name:     tensor.Name,
+		Datatype: tensor.Datatype,
+		Shape:    tensor.Shape,
+		Contents: contents,
+	}, nil
+}
+
+func tensorFromProto(tensor *pb.Tensor) models.Tensor {
+	result := models.Tensor{
+		Name:     tensor.GetName(),
+		Datatype: tensor.GetDatatype(),
+		Shape:    tensor.GetShape(),
+	}
+	contents := tensor.GetContents()
+	switch {
+	case contents.GetBoolContents() != nil:
+		result.Data = contents.GetBoolContents()
+	case contents.GetIntContents() != nil:
+		result.Data = contents.GetIntContents()
+	case contents.GetUintContents() != nil:
+		result.Data = contents.GetUintContents()
+	case contents.GetFpContents() != nil:
+		result.Data = contents.GetFpContents()
+	case contents.GetBytesContents() != nil:
+		result.Data, result.Encoding = bytesData(contents.GetBytesContents())
+	default:
+		result.Data = []interface{}{}
+	}
+	return result
+}
+
+// bytesData returns BYTES elements as strings, or as base64 encoded binary if any of them is not valid UTF-8.
+func bytesData(elements [][]byte) (interface{}, string) {
+	strs := make([]string, 0, len(elements))
+	for _, element := range elements {
+		if !utf8.Valid(element) {
+			return elements, base64Encoding
+		}
+		strs = append(strs, string(element))
+	}
+	return strs, ""
+}
+
+// flatten appends the elements of arbitrarily nested JSON arrays in row-major order.
+func flatten(data interface{}, values *[]interface{}) {
+	if items, ok := data.([]interface{}); ok {
+		for _, item := range items {
+			flatten(item, values)
+		}
+		return
+	}
+	if data != nil {
+		*values = append(*values, data)
+	}
+}
+
+func boolValues(values []interface{}) ([]bool, error) {
+	result := make([]bool, 0, len(values))
+	for _, value := range values {
+		v, ok := value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("data mixes booleans with %v", value)
+		}
+		result = append(result, v)
+	}
+	return result, nil
+}
+
+func bytesValues(values []interface{}, encoding string) ([][]byte, error) {
+	result := make([][]byte, 0, len(values))
+	for _, value := range values {
+		v, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("data mixes strings with %v", value)
+		}
+		if encoding != base64Encoding {
+			result = append(result, []byte(v))
+			continue
+		}
+		decoded, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64 data: %w", err)
+		}
+		result = append(result, decoded)
+	}
+	return result, nil
+}
+
+// numberValues returns the numbers as signed integers if possible, then as unsigned integers, then as floats.
+func numberValues(values []interface{}) ([]int64, []uint64, []float64, error) {
+	numbers := make([]json.Number, 0, len(values))
+	for _, value := range values {
+		v, ok := value.(json.Number)
+		if !ok {
+			return nil, nil, nil, fmt.Errorf("data mixes numbers with %v", value)
+		}
+		numbers = append(numbers, v)
+	}
+
+	ints := make([]int64, 0, len(numbers))
+	for _, n := range numbers {
+		i, err := n.Int64()
+		if err != nil {
+			break
+		}
+		ints = append(ints, i)
+	}
+	if len(ints) == len(numbers) {
+		return ints, nil, nil, nil
+	}
+
+	uints := make([]uint64, 0, len(numbers))
+	for _, n := range numbers {
+		u, err := strconv.ParseUint(n.String(), 10, 64)
+		if err != nil {
+			break
+		}
+		uints = append(uints, u)
+	}
+	if len(uints) == len(numbers) {
+		return nil, uints, nil, nil
+	}
+
+	floats := make([]float64, 0, len(numbers))
+	for _, n := range numbers {
+		f, err := n.Float64()
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("invalid number %s", n)
+		}
+		floats = append(floats, f)
+	}
+	return nil, nil, floats, nil
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"encoding/json"
+	"house-of-neural-networks/internal/models"
+	"house-of-neural-networks/pkg/logger"
+	"net/http"
+
+	pb "house-of-neural-networks/pkg/api/auth"
+)
+
+// VerifyLogIn completes a login that needs the second factor.
+// @Summary Второй шаг входа
+// @Description Завершает вход пользователя с двухфакторной аутентификацией кодом из приложения-аутентификатора или кодом восстановления. Токен challenge_token действует 5 минут и допускает 5 неверных кодов.
+// @Tags Auth service
+// @Accept json
+// @Produce json
+// @Param request body models.VerifyLogInRequest true "Challenge token from /login and the code"
+// @Success 200 {object} models.LogInResponse
+// @Failure 401 {string} string "Invalid code or challenge token"
+// @Failure 429 {string} string "Too many failed attempts"
+// @Router /login/totp [post]
+func (h *AuthHandlers) VerifyLogIn(w http.ResponseWriter, r *http.Request) {
+	var body models.VerifyLogInRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	req := pb.VerifyLogInRequest{
+		ChallengeToken: body.ChallengeToken,
+		Code:           body.Code,
+		RequestId:      r.Context().Value(logger.RequestID).(string),
+		ClientIp:       clientIP(r),
+	}
+	resp, err := h.client.VerifyLogIn(r.Context(), &req)
+	if err != nil {
+		writeServiceError(w, "Auth-Service", err)
+		return
+	}
+
+	setSessionCookies(w, resp.GetJwt(), resp.GetExpiresAt().AsTime(), resp.GetRefreshToken(), resp.GetRefreshExpiresAt().AsTime())
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// EnrollTOTP
+// @Summary Start enabling two-factor authentication
+// @Description Generates a new TOTP secret and returns it with the otpauth URI to add to an authenticator app. Two-factor authentication is enabled by /totp/confirm.
+// @Tags Auth service
+// @Produce json
+// @Security TokenAuth
+// @Success 200 {object} models.EnrollTOTPResponse
+// @Failure 400 {string} string "Already enabled"
+// @Router /totp [post]
+func (h *AuthHandlers) EnrollTOTP(w http.ResponseWriter, r *http.Request) {
+	req := pb.EnrollTOTPRequest{RequestId: r.Context().Value(logger.RequestID).(string)}
+	resp, err := h.client.EnrollTOTP(r.Context(), &req)
+	if err != nil {
+		writeServiceError(w, "Auth-Service", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.EnrollTOTPResponse{Secret: resp.GetSecret(), URI: resp.GetUri()})
+}
+
+// ConfirmTOTP
+// @Summary Enable two-factor authentication
+// @Description Enables two-factor authentication with a code from the authenticator app and returns the recovery codes. They are shown only once, each can replace a code once.
+// @Tags Auth service
+// @Accept json
+// @Produce json
+// @Security TokenAuth
+// @Param request body models.TOTPCodeRequest true "Code from the authenticator app"
+// @Success 200 {object} models.RecoveryCodesResponse
+// @Failure 400 {string} string "Invalid code or no enrolment"
+// @Router /totp/confirm [post]
+func (h *AuthHandlers) ConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	var body models.TOTPCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	req := pb.ConfirmTOTPRequest{Code: body.Code, RequestId: r.Context().Value(logger.RequestID).(string)}
+	resp, err := h.client.ConfirmTOTP(r.Context(), &req)
+	if err != nil {
+		writeServiceError(w, "Auth-Service", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.RecoveryCodesResponse{RecoveryCodes: resp.GetRecoveryCodes()})
+}
+
+// DisableTOTP
+// @Summary Disable two-factor authentication
+// @Description Disables two-factor authentication and drops the recovery codes. Takes a code from the authenticator app or a recovery code.
+// @Tags Auth service
+// @Accept json
+// @Security TokenAuth
+// @Param request body models.TOTPCodeRequest true "Code from the authenticator app or a recovery code"
+// @Success 204 "Disabled"
+// @Failure 400 {string} string "Invalid code or not enabled"
+// @Router /totp [delete]
+func (h *AuthHandlers) DisableTOTP(w http.ResponseWriter, r *http.Request) {
+	var body models.TOTPCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	req := pb.DisableTOTPRequest{Code: body.Code, RequestId: r.Context().Value(logger.RequestID).(string)}
+	if _, err := h.client.DisableTOTP(r.Context(), &req); err != nil {
+		writeServiceError(w, "Auth-Service", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
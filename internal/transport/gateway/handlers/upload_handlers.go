@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"house-of-neural-networks/internal/models"
+	"house-of-neural-networks/pkg/logger"
+	"net/http"
+	"strconv"
+
+	pb "house-of-neural-networks/pkg/api/model"
+)
+
+// CreateUpload starts a resumable upload.
+// @Summary Start a resumable upload
+// @Description Starts a resumable upload of a file for a new version of the model, for files too large to upload in one go. The content is sent with PATCH /uploads/{id}, and once all files of the version are uploaded the version is created with POST /uploads/finish. Uploads expire 24 hours after data last arrived. Requires manage access to the model.
+// @Tags Model service
+// @Accept json
+// @Produce json
+// @Security TokenAuth
+// @Param request body models.CreateUploadRequest true "Model, file name and size in bytes"
+// @Success 201 {object} models.Upload "Upload started"
+// @Header 201 {string} Location "Address of the upload"
+// @Failure 400 {string} string "Invalid file name or size"
+// @Failure 403 {string} string "Not allowed to manage the model"
+// @Failure 404 {string} string "Model not found"
+// @Router /uploads [post]
+func (h *ModelHandlers) CreateUpload(w http.ResponseWriter, r *http.Request) {
+	var body models.CreateUploadRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	req := pb.CreateUploadRequest{
+		ModelId:   body.ModelID,
+		Filename:  body.Filename,
+		Size:      body.Size,
+		RequestId: r.Context().Value(logger.RequestID).(string),
+	}
+	resp, err := h.client.CreateUpload(r.Context(), &req)
+	if err != nil {
+		writeServiceError(w, "Model-Service", err)
+		return
+	}
+
+	setUploadHeaders(w, resp)
+	w.Header().Set("Location", fmt.Sprintf("/uploads/%d", resp.GetId()))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(uploadFromProto(resp))
+}
+
+// GetUpload reports the progress of an upload.
+// @Summary Get the offset of an upload
+// @Description Returns the number of bytes of the upload received so far in Upload-Offset, an interrupted upload carries on from there.
+// @Tags Model service
+// @Security TokenAuth
+// @Param id path int true "Upload ID"
+// @Success 200 "Upload found"
+// @Header 200 {integer} Upload-Offset "Bytes received so far"
+// @Header 200 {integer} Upload-Length "Size of the file"
+// @Header 200 {string} Upload-Expires "Time the upload expires at"
+// @Failure 404 {string} string "Upload not found or expired"
+// @Router /uploads/{id} [head]
+func (h *ModelHandlers) GetUpload(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID format, must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	req := pb.GetUploadRequest{Id: id, RequestId: r.Context().Value(logger.RequestID).(string)}
+	resp, err := h.client.GetUpload(r.Context(), &req)
+	if err != nil {
+		writeServiceError(w, "Model-Service", err)
+		return
+	}
+
+	setUploadHeaders(w, resp)
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+}
+
+// WriteUpload sends a part of an upload.
+// @Summary Send a part of an upload
+// @Description Appends the request body to the upload. Upload-Offset has to be the number of bytes received so far, otherwise 409 is returned. If the transfer breaks, the data that arrived is kept: ask for the offset with HEAD /uploads/{id} and send the rest from there.
+// @Tags Model service
+// @Accept application/offset+octet-stream
+// @Security TokenAuth
+// @Param id path int true "Upload ID"
+// @Param Upload-Offset header int true "Offset to append at"
+// @Success 204 "Part received"
+// @Header 204 {integer} Upload-Offset "Bytes received so far"
+// @Failure 400 {string} string "Missing offset or content beyond the size of the file"
+// @Failure 404 {string} string "Upload not found or expired"
+// @Failure 409 {string} string "Offset does not match or the upload is being written"
+// @Router /uploads/{id} [patch]
+func (h *ModelHandlers) WriteUpload(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID format, must be an integer", http.StatusBadRequest)
+		return
+	}
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		http.Error(w, "Invalid Upload-Offset header", http.StatusBadRequest)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+
+	stream, err := h.client.WriteUpload(r.Context())
+	if err != nil {
+		writeServiceError(w, "Model-Service", err)
+		return
+	}
+	header := &pb.WriteUploadHeader{Id: id, Offset: offset, RequestId: r.Context().Value(logger.RequestID).(string)}
+	if err = sendUploadFrame(stream, &pb.WriteUploadChunk{Frame: &pb.WriteUploadChunk_Header{Header: header}}); err != nil {
+		writeUploadError(w, err)
+		return
+	}
+	_, readErr := streamChunks(r.Body, func(chunk []byte) error {
+		return sendUploadFrame(stream, &pb.WriteUploadChunk{Frame: &pb.WriteUploadChunk_Data{Data: chunk}})
+	})
+	if _, ok := readErr.(*uploadError); ok {
+		writeUploadError(w, readErr)
+		return
+	}
+
+	// Even if the body broke off, the data sent so far is committed
+	resp, err := stream.CloseAndRecv()
+	if err != nil {
+		writeServiceError(w, "Model-Service", err)
+		return
+	}
+	setUploadHeaders(w, resp)
+	if readErr != nil {
+		writeUploadError(w, readErr)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeleteUpload cancels an upload.
+// @Summary Cancel an upload
+// @Description Cancels the upload and drops the data received.
+// @Tags Model service
+// @Security TokenAuth
+// @Param id path int true "Upload ID"
+// @Success 204 "Upload cancelled"
+// @Failure 404 {string} string "Upload not found or expired"
+// @Failure 409 {string} string "The upload is being written"
+// @Router /uploads/{id} [delete]
+func (h *ModelHandlers) DeleteUpload(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID format, must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	req := pb.DeleteUploadRequest{Id: id, RequestId: r.Context().Value(logger.RequestID).(string)}
+	if _, err = h.client.DeleteUpload(r.Context(), &req); err != nil {
+		writeServiceError(w, "Model-Service", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// FinishUpload creates a version from uploads.
+// @Summary Create a version from uploads
+// @Description Creates a new version of the model with the files of the uploads, which have to be complete. The uploads are consumed.
+// @Tags Model service
+// @Accept json
+// @Produce json
+// @Security TokenAuth
+// @Param request body models.FinishUploadRequest true "Model, version number and uploads"
+// @Success 200 {object} models.UploadVersionResponse "Version created"
+// @Failure 400 {string} string "Incomplete upload, upload of another model or duplicate file name"
+// @Failure 403 {string} string "Not allowed to manage the model"
+// @Failure 404 {string} string "Model or upload not found"
+// @Router /uploads/finish [post]
+func (h *ModelHandlers) FinishUpload(w http.ResponseWriter, r *http.Request) {
+	var body models.FinishUploadRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	req := pb.FinishUploadRequest{
+		ModelId:   body.ModelID,
+		Number:    body.Version,
+		UploadIds: body.UploadIDs,
+		RequestId: r.Context().Value(logger.RequestID).(string),
+	}
+	resp, err := h.client.FinishUpload(r.Context(), &req)
+	if err != nil {
+		writeServiceError(w, "Model-Service", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.UploadVersionResponse{Id: resp.GetId()})
+}
+
+func setUploadHeaders(w http.ResponseWriter, upload *pb.Upload) {
+	w.Header().Set("Upload-Offset", strconv.FormatInt(upload.GetOffset(), 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(upload.GetSize(), 10))
+	w.Header().Set("Upload-Expires", upload.GetExpiresAt().AsTime().UTC().Format(http.TimeFormat))
+}
+
+func uploadFromProto(upload *pb.Upload) models.Upload {
+	return models.Upload{
+		ID:        upload.GetId(),
+		ModelID:   upload.GetModelId(),
+		Filename:  upload.GetFilename(),
+		Size:      upload.GetSize(),
+		Offset:    upload.GetOffset(),
+		ExpiresAt: upload.GetExpiresAt().AsTime(),
+	}
+}
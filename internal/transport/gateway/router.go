@@ -5,10 +5,14 @@ import (
 	"fmt"
 	"github.com/google/uuid"
 	httpSwagger "github.com/swaggo/http-swagger"
+	"house-of-neural-networks/internal/models"
+	"house-of-neural-networks/internal/origin"
+	"house-of-neural-networks/internal/principal"
 	"house-of-neural-networks/internal/transport/gateway/handlers"
 	"house-of-neural-networks/internal/transport/grpc_clients"
 	pb "house-of-neural-networks/pkg/api/auth"
 	"house-of-neural-networks/pkg/logger"
+	"net"
 	"net/http"
 	"strings"
 
@@ -19,34 +23,84 @@ type Router struct {
 	muxRouter   *mux.Router
 	authClient  *grpc_clients.AuthClient
 	modelClient *grpc_clients.ModelClient
+	verifier    *tokenVerifier
 	ctx         context.Context
 }
 
-func NewRouter(ctx context.Context, messageClient *grpc_clients.MessageClient, authClient *grpc_clients.AuthClient, modelClient *grpc_clients.ModelClient) *Router {
+func NewRouter(ctx context.Context, messageClient *grpc_clients.MessageClient, authClient *grpc_clients.AuthClient, modelClient *grpc_clients.ModelClient, verifier *tokenVerifier) *Router {
 	muxRouter := mux.NewRouter()
-	r := &Router{muxRouter: muxRouter, ctx: ctx, authClient: authClient, modelClient: modelClient}
+	r := &Router{muxRouter: muxRouter, ctx: ctx, authClient: authClient, modelClient: modelClient, verifier: verifier}
 	r.muxRouter.Use(r.RequestIDMiddleware, r.loggingMiddleware, r.authMiddleware)
 
 	r.muxRouter.PathPrefix("/docs/").Handler(httpSwagger.WrapHandler)
+	r.muxRouter.HandleFunc("/.well-known/jwks.json", r.JWKS).Methods(http.MethodGet)
 
 	// Auth-service routes
 	authHandlers := handlers.NewAuthHandlers(authClient)
 	r.muxRouter.HandleFunc("/signup", authHandlers.SignUp).Methods(http.MethodPost)
 	r.muxRouter.HandleFunc("/login", authHandlers.LogIn).Methods(http.MethodPost)
+	r.muxRouter.HandleFunc("/login/totp", authHandlers.VerifyLogIn).Methods(http.MethodPost)
+	r.muxRouter.HandleFunc("/login/oidc", authHandlers.StartOIDCLogin).Methods(http.MethodGet)
+	r.muxRouter.HandleFunc("/login/oidc/callback", authHandlers.FinishOIDCLogin).Methods(http.MethodGet)
+	r.muxRouter.HandleFunc("/refresh", authHandlers.Refresh).Methods(http.MethodPost)
+	r.muxRouter.HandleFunc("/logout", authHandlers.LogOut).Methods(http.MethodPost)
+	r.muxRouter.HandleFunc("/email/verification", authHandlers.SendVerificationEmail).Methods(http.MethodPost)
+	r.muxRouter.HandleFunc("/email/verify", authHandlers.VerifyEmail).Methods(http.MethodGet)
+	r.muxRouter.HandleFunc("/password/forgot", authHandlers.RequestPasswordReset).Methods(http.MethodPost)
+	r.muxRouter.HandleFunc("/password/reset", authHandlers.ResetPassword).Methods(http.MethodPost)
+	r.muxRouter.HandleFunc("/totp", authHandlers.EnrollTOTP).Methods(http.MethodPost)
+	r.muxRouter.HandleFunc("/totp/confirm", authHandlers.ConfirmTOTP).Methods(http.MethodPost)
+	r.muxRouter.HandleFunc("/totp", authHandlers.DisableTOTP).Methods(http.MethodDelete)
+	r.muxRouter.HandleFunc("/keys", authHandlers.CreateAPIKey).Methods(http.MethodPost)
+	r.muxRouter.HandleFunc("/keys", authHandlers.ListAPIKeys).Methods(http.MethodGet)
+	r.muxRouter.HandleFunc("/keys/{id:[0-9]+}", authHandlers.RevokeAPIKey).Methods(http.MethodDelete)
+
+	// Account routes
+	accountHandlers := handlers.NewAccountHandlers(authClient, modelClient)
+	r.muxRouter.HandleFunc("/account", accountHandlers.GetProfile).Methods(http.MethodGet)
+	r.muxRouter.HandleFunc("/account", accountHandlers.UpdateProfile).Methods(http.MethodPatch)
+	r.muxRouter.HandleFunc("/account/password", accountHandlers.ChangePassword).Methods(http.MethodPost)
+	r.muxRouter.HandleFunc("/account", accountHandlers.DeleteAccount).Methods(http.MethodDelete)
 
 	// Model-service routes
 	modelHandlers := handlers.NewModelHandlers(modelClient)
-	r.muxRouter.HandleFunc("/models/{id:[0-9]+}", modelHandlers.GetModel).Methods(http.MethodGet)
-	r.muxRouter.HandleFunc("/models", modelHandlers.ListModels).Methods(http.MethodGet)
-	r.muxRouter.HandleFunc("/models", modelHandlers.UploadModel).Methods(http.MethodPost)
-	r.muxRouter.HandleFunc("/models/version", modelHandlers.UploadVersion).Methods(http.MethodPost)
-	r.muxRouter.HandleFunc("/models", modelHandlers.UnloadModel).Methods(http.MethodDelete)
+	r.muxRouter.HandleFunc("/models/{id:[0-9]+}", scoped(models.ScopeModelsRead, modelHandlers.GetModel)).Methods(http.MethodGet)
+	r.muxRouter.HandleFunc("/models", scoped(models.ScopeModelsRead, modelHandlers.ListModels)).Methods(http.MethodGet)
+	r.muxRouter.HandleFunc("/models", scoped(models.ScopeModelsWrite, modelHandlers.UploadModel)).Methods(http.MethodPost)
+	r.muxRouter.HandleFunc("/models/version", scoped(models.ScopeModelsWrite, modelHandlers.UploadVersion)).Methods(http.MethodPost)
+	r.muxRouter.HandleFunc("/uploads", scoped(models.ScopeModelsWrite, modelHandlers.CreateUpload)).Methods(http.MethodPost)
+	r.muxRouter.HandleFunc("/uploads/finish", scoped(models.ScopeModelsWrite, modelHandlers.FinishUpload)).Methods(http.MethodPost)
+	r.muxRouter.HandleFunc("/uploads/{id:[0-9]+}", scoped(models.ScopeModelsWrite, modelHandlers.GetUpload)).Methods(http.MethodHead)
+	r.muxRouter.HandleFunc("/uploads/{id:[0-9]+}", scoped(models.ScopeModelsWrite, modelHandlers.WriteUpload)).Methods(http.MethodPatch)
+	r.muxRouter.HandleFunc("/uploads/{id:[0-9]+}", scoped(models.ScopeModelsWrite, modelHandlers.DeleteUpload)).Methods(http.MethodDelete)
+	r.muxRouter.HandleFunc("/models", scoped(models.ScopeModelsWrite, modelHandlers.UnloadModel)).Methods(http.MethodDelete)
+	r.muxRouter.HandleFunc("/models/{id:[0-9]+}/access", scoped(models.ScopeModelsWrite, modelHandlers.GrantAccess)).Methods(http.MethodPost)
+	r.muxRouter.HandleFunc("/models/{id:[0-9]+}/access", scoped(models.ScopeModelsWrite, modelHandlers.RevokeAccess)).Methods(http.MethodDelete)
+	r.muxRouter.HandleFunc("/organizations", scoped(models.ScopeModelsWrite, modelHandlers.CreateOrganization)).Methods(http.MethodPost)
+	r.muxRouter.HandleFunc("/organizations", scoped(models.ScopeModelsRead, modelHandlers.ListOrganizations)).Methods(http.MethodGet)
+	r.muxRouter.HandleFunc("/organizations/{id:[0-9]+}/members", scoped(models.ScopeModelsRead, modelHandlers.ListMembers)).Methods(http.MethodGet)
+	r.muxRouter.HandleFunc("/organizations/{id:[0-9]+}/members/{user_id:[0-9]+}", scoped(models.ScopeModelsWrite, modelHandlers.SetMember)).Methods(http.MethodPut)
+	r.muxRouter.HandleFunc("/organizations/{id:[0-9]+}/members/{user_id:[0-9]+}", scoped(models.ScopeModelsWrite, modelHandlers.RemoveMember)).Methods(http.MethodDelete)
+
+	// Administration routes, the services enforce the admin role as well
+	adminHandlers := handlers.NewAdminHandlers(authClient, modelClient)
+	r.muxRouter.HandleFunc("/admin/users", adminOnly(adminHandlers.ListUsers)).Methods(http.MethodGet)
+	r.muxRouter.HandleFunc("/admin/users/{id:[0-9]+}/disable", adminOnly(adminHandlers.DisableUser)).Methods(http.MethodPost)
+	r.muxRouter.HandleFunc("/admin/users/{id:[0-9]+}/enable", adminOnly(adminHandlers.EnableUser)).Methods(http.MethodPost)
+	r.muxRouter.HandleFunc("/admin/users/{id:[0-9]+}/roles", adminOnly(adminHandlers.SetUserRoles)).Methods(http.MethodPut)
+	r.muxRouter.HandleFunc("/admin/models", adminOnly(adminHandlers.ListModels)).Methods(http.MethodGet)
+	r.muxRouter.HandleFunc("/admin/models/{id:[0-9]+}", adminOnly(adminHandlers.GetModel)).Methods(http.MethodGet)
+	r.muxRouter.HandleFunc("/admin/models/{id:[0-9]+}", adminOnly(adminHandlers.DeleteModel)).Methods(http.MethodDelete)
+	r.muxRouter.HandleFunc("/admin/audit", adminOnly(adminHandlers.ListAuditEvents)).Methods(http.MethodGet)
 
 	// Message-service routes
 	messageHandlers := handlers.NewMessageHandlers(messageClient)
-	r.muxRouter.HandleFunc("/chat/{model_id:[0-9]+}", messageHandlers.GetMessages).Methods("GET")
-	r.muxRouter.HandleFunc("/chat/{model_id:[0-9]+}/{version_id:[0-9]+}", messageHandlers.SendMessage).Methods("POST")
-	//muxRouter.HandleFunc("/chat", messageHandlers.ListChats).Methods("GET")
+	r.muxRouter.HandleFunc("/chat/{model_id:[0-9]+}", scoped(models.ScopeChatRead, messageHandlers.GetMessages)).Methods("GET")
+	r.muxRouter.HandleFunc("/chat/{model_id:[0-9]+}/{version_id:[0-9]+}", scoped(models.ScopeChatWrite, messageHandlers.SendMessage)).Methods("POST")
+	r.muxRouter.HandleFunc("/chat", scoped(models.ScopeChatRead, messageHandlers.ListChats)).Methods("GET")
+	r.muxRouter.HandleFunc("/chat/{model_id:[0-9]+}", scoped(models.ScopeChatWrite, messageHandlers.DeleteChat)).Methods(http.MethodDelete)
+	r.muxRouter.HandleFunc("/chat/{model_id:[0-9]+}/export", scoped(models.ScopeChatRead, messageHandlers.ExportMessages)).Methods(http.MethodGet)
+	r.muxRouter.HandleFunc("/chat/messages/{message_id:[0-9]+}", scoped(models.ScopeChatWrite, messageHandlers.DeleteMessage)).Methods(http.MethodDelete)
 
 	return r
 }
@@ -58,32 +112,134 @@ func (s *Router) loggingMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// realIPMiddleware takes the client address from the X-Real-IP header set by the reverse proxy, so that
+// failed logins are counted and audit events recorded per client rather than per proxy. Only used when the
+// gateway is behind one, it has to run before the other middleware.
+func realIPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ip := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); ip != nil {
+			r.RemoteAddr = net.JoinHostPort(ip.String(), "0")
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 func (s *Router) authMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		parts := strings.Split(r.URL.Path, "/")
-		if parts[1] == "signup" || parts[1] == "login" || parts[1] == "docs" {
+		// The access token may have expired by the time the client refreshes or logs out. Links sent by email
+		// are opened without logging in
+		if parts[1] == "signup" || parts[1] == "login" || parts[1] == "refresh" || parts[1] == "logout" || parts[1] == "docs" || parts[1] == ".well-known" ||
+			parts[1] == "password" || r.URL.Path == "/email/verify" {
 			next.ServeHTTP(w, r)
 			return
 		}
-		cookie, err := r.Cookie("token")
-		if err != nil {
+
+		apiKey, token := credentials(r)
+		var p principal.Principal
+		switch {
+		case apiKey != "":
+			result, err := s.authClient.ValidateAPIKey(s.ctx, &pb.ValidateAPIKeyRequest{Key: apiKey})
+			if err != nil || !result.GetValid() {
+				http.Error(w, "Invalid API key", http.StatusUnauthorized)
+				return
+			}
+			p = principalFromResponse(result)
+		case token != "":
+			var err error
+			p, err = s.validateToken(token)
+			if err != nil {
+				http.SetCookie(w, &http.Cookie{
+					Name:     "token",
+					Value:    "",
+					MaxAge:   -1,
+					HttpOnly: true,
+					Path:     "/",
+				})
+				http.Redirect(w, r, "/login", http.StatusUnauthorized)
+				return
+			}
+		default:
 			http.Redirect(w, r, "/login", http.StatusUnauthorized)
 			return
 		}
-		result, err := s.authClient.ValidateToken(s.ctx, &pb.ValidateTokenRequest{Jwt: cookie.Value})
-		if !result.GetValid() {
-			http.SetCookie(w, &http.Cookie{
-				Name:     "token",
-				Value:    "",
-				MaxAge:   -1,
-				HttpOnly: true,
-				Path:     "/",
-			})
-			http.Redirect(w, r, "/login", http.StatusUnauthorized)
+
+		next.ServeHTTP(w, r.WithContext(principal.NewContext(r.Context(), p)))
+	})
+}
+
+// validateToken verifies the access token locally when it is signed with a published key and asks the auth
+// service otherwise.
+func (s *Router) validateToken(token string) (principal.Principal, error) {
+	p, ok, err := s.verifier.verify(token)
+	if ok {
+		return p, err
+	}
+	result, err := s.authClient.ValidateToken(s.ctx, &pb.ValidateTokenRequest{Jwt: token})
+	if err != nil {
+		return principal.Principal{}, err
+	}
+	if !result.GetValid() {
+		return principal.Principal{}, fmt.Errorf("invalid token")
+	}
+	return principalFromResponse(result), nil
+}
+
+func principalFromResponse(result *pb.ValidateTokenResponse) principal.Principal {
+	p := principal.Principal{
+		UserID: result.GetUserId(),
+		Roles:  result.GetRoles(),
+	}
+	if result.GetExpiresAt() != nil {
+		p.ExpiresAt = result.GetExpiresAt().AsTime()
+	}
+	if result.GetApiKey() {
+		p.Scopes = append(make([]string, 0), result.GetScopes()...)
+		if len(result.GetModelIds()) > 0 {
+			p.ModelIDs = result.GetModelIds()
+		}
+	}
+	return p
+}
+
+// credentials returns the API key or the access token the request is made with. API keys come in the X-API-Key
+// header or as a bearer token, access tokens as a bearer token or in the token cookie.
+func credentials(r *http.Request) (apiKey string, token string) {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key, ""
+	}
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		if strings.HasPrefix(bearer, models.APIKeyPrefix) {
+			return bearer, ""
+		}
+		return "", bearer
+	}
+	if cookie, err := r.Cookie("token"); err == nil {
+		return "", cookie.Value
+	}
+	return "", ""
+}
+
+// scoped rejects requests made with an API key that lacks the scope.
+func scoped(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if p, ok := principal.FromContext(r.Context()); ok && !p.AllowsScope(scope) {
+			http.Error(w, fmt.Sprintf("API key lacks the %s scope", scope), http.StatusForbidden)
 			return
 		}
-		next.ServeHTTP(w, r)
-	})
+		next(w, r)
+	}
+}
+
+// adminOnly rejects requests of users without the admin role. API keys never carry it.
+func adminOnly(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if p, _ := principal.FromContext(r.Context()); !p.HasRole(models.RoleAdmin) {
+			http.Error(w, "Admin role required", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
 }
 
 func (s *Router) RequestIDMiddleware(next http.Handler) http.Handler {
@@ -93,7 +249,13 @@ func (s *Router) RequestIDMiddleware(next http.Handler) http.Handler {
 			requestID = uuid.New().String()
 		}
 
+		clientIP, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			clientIP = r.RemoteAddr
+		}
+
 		ctx := context.WithValue(r.Context(), logger.RequestID, requestID)
+		ctx = origin.NewContext(ctx, origin.Origin{RequestID: requestID, ClientIP: clientIP})
 		ctx = context.WithValue(ctx, logger.LoggerKey, s.ctx.Value(logger.LoggerKey))
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
@@ -0,0 +1,201 @@
+package gateway
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"house-of-neural-networks/internal/models"
+	"house-of-neural-networks/internal/principal"
+	"house-of-neural-networks/internal/transport/grpc_clients"
+	pb "house-of-neural-networks/pkg/api/auth"
+	"house-of-neural-networks/pkg/jwks"
+	"house-of-neural-networks/pkg/logger"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// minEarlyRefresh is the least time between a refresh and one triggered by an unknown key.
+const minEarlyRefresh = 5 * time.Second
+
+// tokenVerifier checks access tokens against the keys published by the auth service, sparing a ValidateToken
+// call per request. Keys, revoked tokens, disabled users and password changes are reloaded periodically, so a
+// logout, a disabled account or a new password takes effect at the gateway within one refresh interval.
+type tokenVerifier struct {
+	authClient *grpc_clients.AuthClient
+	interval   time.Duration
+	refreshCh  chan struct{}
+
+	mu            sync.RWMutex
+	set           jwks.Set
+	keys          map[string]verificationKey
+	revoked       map[string]struct{}
+	disabledUsers map[int64]struct{}
+	validAfter    map[int64]time.Time
+}
+
+type verificationKey struct {
+	algorithm string
+	public    crypto.PublicKey
+}
+
+// accessClaims mirrors the claims the auth service issues.
+type accessClaims struct {
+	UserID int64    `json:"user_id"`
+	Roles  []string `json:"roles,omitempty"`
+	jwt.RegisteredClaims
+}
+
+func newTokenVerifier(authClient *grpc_clients.AuthClient, interval time.Duration) *tokenVerifier {
+	return &tokenVerifier{
+		authClient: authClient,
+		interval:   interval,
+		refreshCh:  make(chan struct{}, 1),
+		set:        jwks.Set{Keys: make([]jwks.Key, 0)},
+	}
+}
+
+// run refreshes the keys until ctx is done. A token signed with an unknown key triggers an early refresh,
+// which picks up a rotated key before the next tick.
+func (v *tokenVerifier) run(ctx context.Context) {
+	ticker := time.NewTicker(v.interval)
+	defer ticker.Stop()
+	for {
+		if err := v.refresh(ctx); err != nil {
+			logger.GetLoggerFromCtx(ctx).Error(ctx, fmt.Sprintf("failed to refresh signing keys: %s", err.Error()))
+		}
+		refreshed := time.Now()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-v.refreshCh:
+			// Tokens with made-up key ids must not keep the gateway refreshing
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Until(refreshed.Add(minEarlyRefresh))):
+			}
+		}
+	}
+}
+
+func (v *tokenVerifier) refresh(ctx context.Context) error {
+	set, err := v.authClient.GetJWKS(ctx, &pb.GetJWKSRequest{})
+	if err != nil {
+		return err
+	}
+	revokedTokens, err := v.authClient.ListRevokedTokens(ctx, &pb.ListRevokedTokensRequest{})
+	if err != nil {
+		return err
+	}
+
+	published := jwks.Set{Keys: make([]jwks.Key, 0, len(set.GetKeys()))}
+	keys := make(map[string]verificationKey, len(set.GetKeys()))
+	for _, k := range set.GetKeys() {
+		key := jwks.Key{Kty: k.GetKty(), Kid: k.GetKid(), Alg: k.GetAlg(), Use: k.GetUse(), N: k.GetN(), E: k.GetE(), Crv: k.GetCrv(), X: k.GetX()}
+		public, err := key.PublicKey()
+		if err != nil {
+			return err
+		}
+		published.Keys = append(published.Keys, key)
+		keys[key.Kid] = verificationKey{algorithm: key.Alg, public: public}
+	}
+	revoked := make(map[string]struct{}, len(revokedTokens.GetIds()))
+	for _, id := range revokedTokens.GetIds() {
+		revoked[id] = struct{}{}
+	}
+	disabledUsers := make(map[int64]struct{}, len(revokedTokens.GetDisabledUserIds()))
+	for _, id := range revokedTokens.GetDisabledUserIds() {
+		disabledUsers[id] = struct{}{}
+	}
+	validAfter := make(map[int64]time.Time, len(revokedTokens.GetTokensValidAfter()))
+	for id, after := range revokedTokens.GetTokensValidAfter() {
+		validAfter[id] = after.AsTime()
+	}
+
+	v.mu.Lock()
+	v.set, v.keys, v.revoked, v.disabledUsers, v.validAfter = published, keys, revoked, disabledUsers, validAfter
+	v.mu.Unlock()
+	return nil
+}
+
+// verify checks the signature, expiry and revocation of the token. ok is false when the token is not signed
+// with a published key, e.g. with the shared secret or a key issued after the last refresh, and the auth service
+// has to validate it instead.
+func (v *tokenVerifier) verify(tokenString string) (p principal.Principal, ok bool, err error) {
+	parser := jwt.NewParser(jwt.WithIssuer(models.TokenIssuer), jwt.WithExpirationRequired())
+	claims := &accessClaims{}
+	unverified, _, err := parser.ParseUnverified(tokenString, claims)
+	if err != nil {
+		return principal.Principal{}, true, err
+	}
+	kid, _ := unverified.Header["kid"].(string)
+
+	v.mu.RLock()
+	key, known := v.keys[kid]
+	v.mu.RUnlock()
+	if !known {
+		if kid != "" {
+			v.requestRefresh()
+		}
+		return principal.Principal{}, false, nil
+	}
+
+	claims = &accessClaims{}
+	parser = jwt.NewParser(jwt.WithIssuer(models.TokenIssuer), jwt.WithExpirationRequired(), jwt.WithValidMethods([]string{key.algorithm}))
+	if _, err = parser.ParseWithClaims(tokenString, claims, func(*jwt.Token) (interface{}, error) {
+		return key.public, nil
+	}); err != nil {
+		return principal.Principal{}, true, err
+	}
+	if claims.UserID == 0 {
+		return principal.Principal{}, true, fmt.Errorf("invalid token claims")
+	}
+
+	v.mu.RLock()
+	_, revoked := v.revoked[claims.ID]
+	_, disabled := v.disabledUsers[claims.UserID]
+	validAfter, passwordChanged := v.validAfter[claims.UserID]
+	v.mu.RUnlock()
+	if passwordChanged && (claims.IssuedAt == nil || claims.IssuedAt.Time.Before(validAfter)) {
+		revoked = true
+	}
+	if revoked || disabled {
+		return principal.Principal{}, true, fmt.Errorf("token has been revoked")
+	}
+
+	return principal.Principal{
+		UserID:    claims.UserID,
+		Roles:     claims.Roles,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}, true, nil
+}
+
+func (v *tokenVerifier) requestRefresh() {
+	select {
+	case v.refreshCh <- struct{}{}:
+	default:
+	}
+}
+
+func (v *tokenVerifier) jwks() jwks.Set {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.set
+}
+
+// JWKS serves the public keys access tokens are signed with.
+// @Summary Ключи проверки токенов
+// @Description Возвращает публичные ключи (JWKS), которыми подписываются access-токены, чтобы проверять их без обращения к сервису авторизации. Пустой список означает, что токены подписываются общим секретом.
+// @Tags Auth service
+// @Produce json
+// @Success 200 {object} jwks.Set
+// @Router /.well-known/jwks.json [get]
+func (s *Router) JWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(s.verifier.interval.Seconds())))
+	json.NewEncoder(w).Encode(s.verifier.jwks())
+}
@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"house-of-neural-networks/internal/models"
+	client "house-of-neural-networks/pkg/api/auth"
+	"house-of-neural-networks/pkg/logger"
+)
+
+func (s *AuthService) GetProfile(ctx context.Context, req *client.GetProfileRequest) (*client.Profile, error) {
+	user, err := s.service.GetProfile(ctx)
+	if err != nil {
+		logger.GetLoggerFromCtx(s.ctx).Error(
+			s.ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", status.Code(err).String()),
+		)
+		return nil, err
+	}
+
+	return profile(user), nil
+}
+
+func (s *AuthService) UpdateProfile(ctx context.Context, req *client.UpdateProfileRequest) (*client.Profile, error) {
+	user, err := s.service.UpdateProfile(ctx, models.User{
+		Username: req.GetUsername(),
+		Email:    req.GetEmail(),
+	})
+	if err != nil {
+		logger.GetLoggerFromCtx(s.ctx).Error(
+			s.ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", status.Code(err).String()),
+		)
+		return nil, err
+	}
+
+	return profile(user), nil
+}
+
+func (s *AuthService) ChangePassword(ctx context.Context, req *client.ChangePasswordRequest) (*client.LogInResponse, error) {
+	session, err := s.service.ChangePassword(ctx, req.GetOldPassword(), req.GetNewPassword())
+	if err != nil {
+		logger.GetLoggerFromCtx(s.ctx).Error(
+			s.ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", status.Code(err).String()),
+		)
+		return nil, err
+	}
+
+	return logInResponse(session), nil
+}
+
+func (s *AuthService) DeleteAccount(ctx context.Context, req *client.DeleteAccountRequest) (*client.DeleteAccountResponse, error) {
+	if err := s.service.DeleteAccount(ctx, req.GetPassword(), req.GetCode()); err != nil {
+		logger.GetLoggerFromCtx(s.ctx).Error(
+			s.ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", status.Code(err).String()),
+		)
+		return nil, err
+	}
+
+	return &client.DeleteAccountResponse{}, nil
+}
+
+func profile(user models.User) *client.Profile {
+	result := &client.Profile{
+		Id:            user.ID,
+		Username:      user.Username,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerifiedAt != nil,
+		Roles:         user.Roles,
+		TotpEnabled:   user.TOTPEnabledAt != nil,
+	}
+	if user.LastLoginAt != nil {
+		result.LastLoginAt = timestamppb.New(*user.LastLoginAt)
+	}
+	return result
+}
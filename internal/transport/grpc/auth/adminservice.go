@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"house-of-neural-networks/internal/models"
+	client "house-of-neural-networks/pkg/api/auth"
+	"house-of-neural-networks/pkg/logger"
+)
+
+func (s *AuthService) ListUsers(ctx context.Context, req *client.ListUsersRequest) (*client.ListUsersResponse, error) {
+	users, err := s.service.ListUsers(ctx)
+	if err != nil {
+		logger.GetLoggerFromCtx(s.ctx).Error(
+			s.ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", status.Code(err).String()),
+		)
+		return nil, err
+	}
+
+	resp := &client.ListUsersResponse{Users: make([]*client.User, 0, len(users))}
+	for _, user := range users {
+		resp.Users = append(resp.Users, &client.User{
+			Id:            user.ID,
+			Username:      user.Username,
+			Email:         user.Email,
+			EmailVerified: user.EmailVerifiedAt != nil,
+			Roles:         user.Roles,
+			Disabled:      user.DisabledAt != nil,
+		})
+	}
+	return resp, nil
+}
+
+func (s *AuthService) SetUserDisabled(ctx context.Context, req *client.SetUserDisabledRequest) (*client.SetUserDisabledResponse, error) {
+	if err := s.service.SetUserDisabled(ctx, req.GetUserId(), req.GetDisabled()); err != nil {
+		logger.GetLoggerFromCtx(s.ctx).Error(
+			s.ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", status.Code(err).String()),
+		)
+		return nil, err
+	}
+
+	return &client.SetUserDisabledResponse{}, nil
+}
+
+func (s *AuthService) SetUserRoles(ctx context.Context, req *client.SetUserRolesRequest) (*client.SetUserRolesResponse, error) {
+	if err := s.service.SetUserRoles(ctx, req.GetUserId(), req.GetRoles()); err != nil {
+		logger.GetLoggerFromCtx(s.ctx).Error(
+			s.ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", status.Code(err).String()),
+		)
+		return nil, err
+	}
+
+	return &client.SetUserRolesResponse{}, nil
+}
+
+func (s *AuthService) ListAuditEvents(ctx context.Context, req *client.ListAuditEventsRequest) (*client.ListAuditEventsResponse, error) {
+	filter := models.AuditFilter{ActorID: req.GetActorId(), Action: req.GetAction()}
+	if req.GetFrom() != nil {
+		filter.From = req.GetFrom().AsTime()
+	}
+	if req.GetTo() != nil {
+		filter.To = req.GetTo().AsTime()
+	}
+	events, nextPageToken, err := s.service.ListAuditEvents(ctx, filter, req.GetPageSize(), req.GetPageToken())
+	if err != nil {
+		logger.GetLoggerFromCtx(s.ctx).Error(
+			s.ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", status.Code(err).String()),
+		)
+		return nil, err
+	}
+
+	resp := &client.ListAuditEventsResponse{Events: make([]*client.AuditEvent, 0, len(events)), NextPageToken: nextPageToken}
+	for _, event := range events {
+		item := &client.AuditEvent{
+			Id:        event.ID,
+			Action:    event.Action,
+			Target:    event.Target,
+			RequestId: event.RequestID,
+			ClientIp:  event.ClientIP,
+			Outcome:   event.Outcome,
+			CreatedAt: timestamppb.New(event.CreatedAt),
+		}
+		if event.ActorID != nil {
+			item.ActorId = *event.ActorID
+		}
+		resp.Events = append(resp.Events, item)
+	}
+	return resp, nil
+}
@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"house-of-neural-networks/internal/models"
+	client "house-of-neural-networks/pkg/api/auth"
+	"house-of-neural-networks/pkg/logger"
+	"time"
+)
+
+func (s *AuthService) CreateAPIKey(ctx context.Context, req *client.CreateAPIKeyRequest) (*client.CreateAPIKeyResponse, error) {
+	key := models.APIKey{
+		Name:     req.GetName(),
+		Scopes:   req.GetScopes(),
+		ModelIDs: req.GetModelIds(),
+	}
+	if req.GetExpiresAt() != nil {
+		expiresAt := req.GetExpiresAt().AsTime()
+		key.ExpiresAt = &expiresAt
+	}
+
+	plain, created, err := s.service.CreateAPIKey(ctx, key)
+	if err != nil {
+		logger.GetLoggerFromCtx(s.ctx).Error(
+			s.ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", status.Code(err).String()),
+		)
+		return nil, err
+	}
+
+	return &client.CreateAPIKeyResponse{
+		Key:    plain,
+		ApiKey: apiKeyToProto(*created),
+	}, nil
+}
+
+func (s *AuthService) ListAPIKeys(ctx context.Context, req *client.ListAPIKeysRequest) (*client.ListAPIKeysResponse, error) {
+	keys, err := s.service.ListAPIKeys(ctx)
+	if err != nil {
+		logger.GetLoggerFromCtx(s.ctx).Error(
+			s.ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", status.Code(err).String()),
+		)
+		return nil, err
+	}
+
+	result := make([]*client.APIKey, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, apiKeyToProto(key))
+	}
+	return &client.ListAPIKeysResponse{
+		Keys: result,
+	}, nil
+}
+
+func (s *AuthService) RevokeAPIKey(ctx context.Context, req *client.RevokeAPIKeyRequest) (*client.RevokeAPIKeyResponse, error) {
+	if err := s.service.RevokeAPIKey(ctx, req.GetId()); err != nil {
+		logger.GetLoggerFromCtx(s.ctx).Error(
+			s.ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", status.Code(err).String()),
+		)
+		return nil, err
+	}
+
+	return &client.RevokeAPIKeyResponse{}, nil
+}
+
+func (s *AuthService) ValidateAPIKey(ctx context.Context, req *client.ValidateAPIKeyRequest) (*client.ValidateTokenResponse, error) {
+	p, err := s.service.ValidateAPIKey(ctx, req.GetKey())
+	if err != nil {
+		logger.GetLoggerFromCtx(s.ctx).Error(
+			s.ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", status.Code(err).String()),
+		)
+		return nil, err
+	}
+
+	return validateTokenResponse(p), nil
+}
+
+func apiKeyToProto(key models.APIKey) *client.APIKey {
+	return &client.APIKey{
+		Id:         key.ID,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Scopes:     key.Scopes,
+		ModelIds:   key.ModelIDs,
+		ExpiresAt:  optionalTimestamp(key.ExpiresAt),
+		CreatedAt:  timestamppb.New(key.CreatedAt),
+		LastUsedAt: optionalTimestamp(key.LastUsedAt),
+	}
+}
+
+func optionalTimestamp(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
@@ -4,16 +4,47 @@ import (
 	"context"
 	"fmt"
 	"go.uber.org/zap"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
 	"house-of-neural-networks/internal/models"
+	"house-of-neural-networks/internal/principal"
 	client "house-of-neural-networks/pkg/api/auth"
+	"house-of-neural-networks/pkg/jwks"
 	"house-of-neural-networks/pkg/logger"
 	"net/http"
+	"time"
 )
 
 type Service interface {
 	SignUp(ctx context.Context, user models.User) (bool, error)
-	LogIn(ctx context.Context, user models.User) (string, int64, error)
-	ValidateToken(jwt string) (bool, error)
+	LogIn(ctx context.Context, user models.User, clientIP string) (*models.Session, error)
+	ValidateToken(ctx context.Context, jwt string) (principal.Principal, error)
+	Refresh(ctx context.Context, refreshToken string) (*models.Session, error)
+	LogOut(ctx context.Context, jwt, refreshToken string) error
+	CreateAPIKey(ctx context.Context, key models.APIKey) (string, *models.APIKey, error)
+	ListAPIKeys(ctx context.Context) ([]models.APIKey, error)
+	RevokeAPIKey(ctx context.Context, keyID int64) error
+	ValidateAPIKey(ctx context.Context, key string) (principal.Principal, error)
+	JWKS() (jwks.Set, error)
+	RevokedTokens(ctx context.Context) ([]string, []int64, map[int64]time.Time, error)
+	StartOIDCLogin(ctx context.Context) (authURL string, state string, err error)
+	FinishOIDCLogin(ctx context.Context, code, state string) (*models.Session, error)
+	ListUsers(ctx context.Context) ([]models.User, error)
+	SetUserDisabled(ctx context.Context, userID int64, disabled bool) error
+	SetUserRoles(ctx context.Context, userID int64, roles []string) error
+	ListAuditEvents(ctx context.Context, filter models.AuditFilter, pageSize int32, pageToken string) ([]models.AuditEvent, string, error)
+	SendVerificationEmail(ctx context.Context) error
+	VerifyEmail(ctx context.Context, token string) error
+	RequestPasswordReset(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, token, password string) error
+	VerifyLogIn(ctx context.Context, challengeToken, code, clientIP string) (*models.Session, error)
+	EnrollTOTP(ctx context.Context) (secret string, uri string, err error)
+	ConfirmTOTP(ctx context.Context, code string) ([]string, error)
+	DisableTOTP(ctx context.Context, code string) error
+	GetProfile(ctx context.Context) (models.User, error)
+	UpdateProfile(ctx context.Context, update models.User) (models.User, error)
+	ChangePassword(ctx context.Context, oldPassword, newPassword string) (*models.Session, error)
+	DeleteAccount(ctx context.Context, password, code string) error
 }
 
 type AuthService struct {
@@ -48,27 +79,42 @@ func (s *AuthService) SignUp(ctx context.Context, req *client.SignUpRequest) (*c
 }
 
 func (s *AuthService) LogIn(ctx context.Context, req *client.LogInRequest) (*client.LogInResponse, error) {
-	token, userId, err := s.service.LogIn(ctx, models.User{
+	session, err := s.service.LogIn(ctx, models.User{
 		Username: req.GetUsername(),
 		Password: req.GetPassword(),
-	})
+	}, req.GetClientIp())
 	if err != nil {
 		logger.GetLoggerFromCtx(s.ctx).Error(
 			s.ctx,
 			err.Error(),
 			zap.String("Function", logger.GetFunctionName()),
-			zap.String("Status", http.StatusText(http.StatusInternalServerError)),
+			zap.String("Status", status.Code(err).String()),
 		)
-		return nil, fmt.Errorf("ValidateUser: %w", err)
+		return nil, err
+	}
+	return logInResponse(session), nil
+}
+
+func logInResponse(session *models.Session) *client.LogInResponse {
+	if session.ChallengeToken != "" {
+		return &client.LogInResponse{
+			UserId:             session.UserID,
+			MfaRequired:        true,
+			ChallengeToken:     session.ChallengeToken,
+			ChallengeExpiresAt: timestamppb.New(session.ChallengeExpiresAt),
+		}
 	}
 	return &client.LogInResponse{
-		Jwt:    token,
-		UserId: userId,
-	}, nil
+		Jwt:              session.AccessToken,
+		UserId:           session.UserID,
+		RefreshToken:     session.RefreshToken,
+		ExpiresAt:        timestamppb.New(session.AccessExpiresAt),
+		RefreshExpiresAt: timestamppb.New(session.RefreshExpiresAt),
+	}
 }
 
 func (s *AuthService) ValidateToken(ctx context.Context, req *client.ValidateTokenRequest) (*client.ValidateTokenResponse, error) {
-	valid, err := s.service.ValidateToken(req.GetJwt())
+	user, err := s.service.ValidateToken(ctx, req.GetJwt())
 	if err != nil {
 		logger.GetLoggerFromCtx(s.ctx).Error(
 			s.ctx,
@@ -79,7 +125,55 @@ func (s *AuthService) ValidateToken(ctx context.Context, req *client.ValidateTok
 		return nil, fmt.Errorf("ValidateToken: %w", err)
 	}
 
-	return &client.ValidateTokenResponse{
-		Valid: valid,
+	return validateTokenResponse(user), nil
+}
+
+func validateTokenResponse(p principal.Principal) *client.ValidateTokenResponse {
+	resp := &client.ValidateTokenResponse{
+		Valid:    true,
+		UserId:   p.UserID,
+		Roles:    p.Roles,
+		ApiKey:   p.Scopes != nil,
+		Scopes:   p.Scopes,
+		ModelIds: p.ModelIDs,
+	}
+	if !p.ExpiresAt.IsZero() {
+		resp.ExpiresAt = timestamppb.New(p.ExpiresAt)
+	}
+	return resp
+}
+
+func (s *AuthService) Refresh(ctx context.Context, req *client.RefreshRequest) (*client.RefreshResponse, error) {
+	session, err := s.service.Refresh(ctx, req.GetRefreshToken())
+	if err != nil {
+		logger.GetLoggerFromCtx(s.ctx).Error(
+			s.ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", status.Code(err).String()),
+		)
+		return nil, err
+	}
+
+	return &client.RefreshResponse{
+		Jwt:              session.AccessToken,
+		UserId:           session.UserID,
+		RefreshToken:     session.RefreshToken,
+		ExpiresAt:        timestamppb.New(session.AccessExpiresAt),
+		RefreshExpiresAt: timestamppb.New(session.RefreshExpiresAt),
 	}, nil
 }
+
+func (s *AuthService) LogOut(ctx context.Context, req *client.LogOutRequest) (*client.LogOutResponse, error) {
+	if err := s.service.LogOut(ctx, req.GetJwt(), req.GetRefreshToken()); err != nil {
+		logger.GetLoggerFromCtx(s.ctx).Error(
+			s.ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", status.Code(err).String()),
+		)
+		return nil, err
+	}
+
+	return &client.LogOutResponse{}, nil
+}
@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/status"
+	client "house-of-neural-networks/pkg/api/auth"
+	"house-of-neural-networks/pkg/logger"
+)
+
+func (s *AuthService) SendVerificationEmail(ctx context.Context, req *client.SendVerificationEmailRequest) (*client.SendVerificationEmailResponse, error) {
+	if err := s.service.SendVerificationEmail(ctx); err != nil {
+		logger.GetLoggerFromCtx(s.ctx).Error(
+			s.ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", status.Code(err).String()),
+		)
+		return nil, err
+	}
+
+	return &client.SendVerificationEmailResponse{}, nil
+}
+
+func (s *AuthService) VerifyEmail(ctx context.Context, req *client.VerifyEmailRequest) (*client.VerifyEmailResponse, error) {
+	if err := s.service.VerifyEmail(ctx, req.GetToken()); err != nil {
+		logger.GetLoggerFromCtx(s.ctx).Error(
+			s.ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", status.Code(err).String()),
+		)
+		return nil, err
+	}
+
+	return &client.VerifyEmailResponse{}, nil
+}
+
+func (s *AuthService) RequestPasswordReset(ctx context.Context, req *client.RequestPasswordResetRequest) (*client.RequestPasswordResetResponse, error) {
+	if err := s.service.RequestPasswordReset(ctx, req.GetEmail()); err != nil {
+		logger.GetLoggerFromCtx(s.ctx).Error(
+			s.ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", status.Code(err).String()),
+		)
+		return nil, err
+	}
+
+	return &client.RequestPasswordResetResponse{}, nil
+}
+
+func (s *AuthService) ResetPassword(ctx context.Context, req *client.ResetPasswordRequest) (*client.ResetPasswordResponse, error) {
+	if err := s.service.ResetPassword(ctx, req.GetToken(), req.GetPassword()); err != nil {
+		logger.GetLoggerFromCtx(s.ctx).Error(
+			s.ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", status.Code(err).String()),
+		)
+		return nil, err
+	}
+
+	return &client.ResetPasswordResponse{}, nil
+}
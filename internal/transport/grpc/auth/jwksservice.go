@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	client "house-of-neural-networks/pkg/api/auth"
+	"house-of-neural-networks/pkg/logger"
+)
+
+func (s *AuthService) GetJWKS(ctx context.Context, req *client.GetJWKSRequest) (*client.GetJWKSResponse, error) {
+	set, err := s.service.JWKS()
+	if err != nil {
+		logger.GetLoggerFromCtx(s.ctx).Error(
+			s.ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", status.Code(err).String()),
+		)
+		return nil, err
+	}
+
+	resp := &client.GetJWKSResponse{Keys: make([]*client.JSONWebKey, 0, len(set.Keys))}
+	for _, key := range set.Keys {
+		resp.Keys = append(resp.Keys, &client.JSONWebKey{
+			Kty: key.Kty,
+			Kid: key.Kid,
+			Alg: key.Alg,
+			Use: key.Use,
+			N:   key.N,
+			E:   key.E,
+			Crv: key.Crv,
+			X:   key.X,
+		})
+	}
+	return resp, nil
+}
+
+func (s *AuthService) ListRevokedTokens(ctx context.Context, req *client.ListRevokedTokensRequest) (*client.ListRevokedTokensResponse, error) {
+	ids, disabledUsers, validAfter, err := s.service.RevokedTokens(ctx)
+	if err != nil {
+		logger.GetLoggerFromCtx(s.ctx).Error(
+			s.ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", status.Code(err).String()),
+		)
+		return nil, err
+	}
+
+	tokensValidAfter := make(map[int64]*timestamppb.Timestamp, len(validAfter))
+	for userID, after := range validAfter {
+		tokensValidAfter[userID] = timestamppb.New(after)
+	}
+	return &client.ListRevokedTokensResponse{Ids: ids, DisabledUserIds: disabledUsers, TokensValidAfter: tokensValidAfter}, nil
+}
@@ -0,0 +1,39 @@
+package auth
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/status"
+	client "house-of-neural-networks/pkg/api/auth"
+	"house-of-neural-networks/pkg/logger"
+)
+
+func (s *AuthService) StartOIDCLogin(ctx context.Context, req *client.StartOIDCLoginRequest) (*client.StartOIDCLoginResponse, error) {
+	authURL, state, err := s.service.StartOIDCLogin(ctx)
+	if err != nil {
+		logger.GetLoggerFromCtx(s.ctx).Error(
+			s.ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", status.Code(err).String()),
+		)
+		return nil, err
+	}
+
+	return &client.StartOIDCLoginResponse{AuthorizationUrl: authURL, State: state}, nil
+}
+
+func (s *AuthService) FinishOIDCLogin(ctx context.Context, req *client.FinishOIDCLoginRequest) (*client.LogInResponse, error) {
+	session, err := s.service.FinishOIDCLogin(ctx, req.GetCode(), req.GetState())
+	if err != nil {
+		logger.GetLoggerFromCtx(s.ctx).Error(
+			s.ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", status.Code(err).String()),
+		)
+		return nil, err
+	}
+
+	return logInResponse(session), nil
+}
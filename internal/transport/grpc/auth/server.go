@@ -6,6 +6,7 @@ import (
 	"go.uber.org/zap"
 	"golang.org/x/sync/errgroup"
 	"google.golang.org/grpc"
+	"house-of-neural-networks/internal/models"
 	interceptor "house-of-neural-networks/internal/transport/grpc"
 	client "house-of-neural-networks/pkg/api/auth"
 	"house-of-neural-networks/pkg/logger"
@@ -13,6 +14,14 @@ import (
 	"net"
 )
 
+// methodRoles are the roles required to call the methods of the service.
+var methodRoles = map[string]string{
+	client.AuthService_ListUsers_FullMethodName:       models.RoleAdmin,
+	client.AuthService_SetUserDisabled_FullMethodName: models.RoleAdmin,
+	client.AuthService_SetUserRoles_FullMethodName:    models.RoleAdmin,
+	client.AuthService_ListAuditEvents_FullMethodName: models.RoleAdmin,
+}
+
 type Server struct {
 	grpcServer *grpc.Server
 	listener   net.Listener
@@ -25,7 +34,7 @@ func New(ctx context.Context, port int, service Service) (*Server, error) {
 	}
 
 	opts := []grpc.ServerOption{
-		grpc.UnaryInterceptor(interceptor.ContextWithLogger(logger.GetLoggerFromCtx(ctx))),
+		grpc.ChainUnaryInterceptor(interceptor.WithOrigin(), interceptor.ContextWithLogger(logger.GetLoggerFromCtx(ctx)), interceptor.Identify(), interceptor.Authorize(methodRoles)),
 	}
 	grpcServer := grpc.NewServer(opts...)
 	client.RegisterAuthServiceServer(grpcServer, NewAuthService(ctx, service))
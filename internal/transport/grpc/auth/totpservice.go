@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/status"
+	client "house-of-neural-networks/pkg/api/auth"
+	"house-of-neural-networks/pkg/logger"
+)
+
+func (s *AuthService) VerifyLogIn(ctx context.Context, req *client.VerifyLogInRequest) (*client.LogInResponse, error) {
+	session, err := s.service.VerifyLogIn(ctx, req.GetChallengeToken(), req.GetCode(), req.GetClientIp())
+	if err != nil {
+		logger.GetLoggerFromCtx(s.ctx).Error(
+			s.ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", status.Code(err).String()),
+		)
+		return nil, err
+	}
+	return logInResponse(session), nil
+}
+
+func (s *AuthService) EnrollTOTP(ctx context.Context, req *client.EnrollTOTPRequest) (*client.EnrollTOTPResponse, error) {
+	secret, uri, err := s.service.EnrollTOTP(ctx)
+	if err != nil {
+		logger.GetLoggerFromCtx(s.ctx).Error(
+			s.ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", status.Code(err).String()),
+		)
+		return nil, err
+	}
+
+	return &client.EnrollTOTPResponse{Secret: secret, Uri: uri}, nil
+}
+
+func (s *AuthService) ConfirmTOTP(ctx context.Context, req *client.ConfirmTOTPRequest) (*client.ConfirmTOTPResponse, error) {
+	recoveryCodes, err := s.service.ConfirmTOTP(ctx, req.GetCode())
+	if err != nil {
+		logger.GetLoggerFromCtx(s.ctx).Error(
+			s.ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", status.Code(err).String()),
+		)
+		return nil, err
+	}
+
+	return &client.ConfirmTOTPResponse{RecoveryCodes: recoveryCodes}, nil
+}
+
+func (s *AuthService) DisableTOTP(ctx context.Context, req *client.DisableTOTPRequest) (*client.DisableTOTPResponse, error) {
+	if err := s.service.DisableTOTP(ctx, req.GetCode()); err != nil {
+		logger.GetLoggerFromCtx(s.ctx).Error(
+			s.ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", status.Code(err).String()),
+		)
+		return nil, err
+	}
+
+	return &client.DisableTOTPResponse{}, nil
+}
@@ -4,6 +4,10 @@ import (
 	"context"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"house-of-neural-networks/internal/origin"
+	"house-of-neural-networks/internal/principal"
 	"house-of-neural-networks/pkg/logger"
 )
 
@@ -13,3 +17,121 @@ func ContextWithLogger(l logger.Logger) grpc.UnaryServerInterceptor {
 		return handler(ctx, req)
 	}
 }
+
+// WithOrigin puts the origin of the request passed by the gateway into the request context, for the audit log
+// and so that the request id shows up in the logs of the service.
+func WithOrigin() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		return handler(originContext(ctx), req)
+	}
+}
+
+// WithOriginStream is WithOrigin for streaming calls.
+func WithOriginStream() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &serverStream{ServerStream: ss, ctx: originContext(ss.Context())})
+	}
+}
+
+func originContext(ctx context.Context) context.Context {
+	o, ok := origin.FromIncomingContext(ctx)
+	if !ok {
+		return ctx
+	}
+	ctx = origin.NewContext(ctx, o)
+	return context.WithValue(ctx, logger.RequestID, o.RequestID)
+}
+
+// Authenticate puts the principal passed by the gateway in the call metadata into the request context.
+// Calls without a principal are rejected. The services trust the metadata, so they must only be reachable through the gateway.
+func Authenticate() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		p, ok := principal.FromIncomingContext(ctx)
+		if !ok {
+			return nil, status.Errorf(codes.Unauthenticated, "%s: no authenticated user", info.FullMethod)
+		}
+		return handler(principal.NewContext(ctx, p), req)
+	}
+}
+
+// Identify is Authenticate for services with public methods: calls without a principal are passed through
+// and the service decides whether it needs one.
+func Identify() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		if p, ok := principal.FromIncomingContext(ctx); ok {
+			ctx = principal.NewContext(ctx, p)
+		}
+		return handler(ctx, req)
+	}
+}
+
+// Authorize enforces per-method roles: the methods listed in roles may only be called by principals with the
+// role of the method, other methods are left to the service. It has to be chained after Authenticate or Identify.
+func Authorize(roles map[string]string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		if role, ok := roles[info.FullMethod]; ok {
+			p, ok := principal.FromContext(ctx)
+			if !ok {
+				return nil, status.Errorf(codes.Unauthenticated, "%s: no authenticated user", info.FullMethod)
+			}
+			if !p.HasRole(role) {
+				return nil, status.Errorf(codes.PermissionDenied, "%s: %s role required", info.FullMethod, role)
+			}
+		}
+		return handler(ctx, req)
+	}
+}
+
+// AuthenticateStream is Authenticate for streaming calls.
+func AuthenticateStream() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		p, ok := principal.FromIncomingContext(ss.Context())
+		if !ok {
+			return status.Errorf(codes.Unauthenticated, "%s: no authenticated user", info.FullMethod)
+		}
+		return handler(srv, &serverStream{ServerStream: ss, ctx: principal.NewContext(ss.Context(), p)})
+	}
+}
+
+// PropagatePrincipal passes the principal of the request context to the called service.
+func PropagatePrincipal() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if p, ok := principal.FromContext(ctx); ok {
+			ctx = principal.AppendToOutgoingContext(ctx, p)
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// PropagatePrincipalStream is PropagatePrincipal for streaming calls.
+func PropagatePrincipalStream() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		if p, ok := principal.FromContext(ctx); ok {
+			ctx = principal.AppendToOutgoingContext(ctx, p)
+		}
+		return streamer(ctx, desc, cc, method, opts...)
+	}
+}
+
+// PropagateOrigin passes the origin of the request context to the called service.
+func PropagateOrigin() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(origin.AppendToOutgoingContext(ctx, origin.FromContext(ctx)), method, req, reply, cc, opts...)
+	}
+}
+
+// PropagateOriginStream is PropagateOrigin for streaming calls.
+func PropagateOriginStream() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(origin.AppendToOutgoingContext(ctx, origin.FromContext(ctx)), desc, cc, method, opts...)
+	}
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
@@ -3,16 +3,20 @@ package message
 import (
 	"context"
 	"go.uber.org/zap"
-	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"house-of-neural-networks/internal/models"
 	client "house-of-neural-networks/pkg/api/message"
 	"house-of-neural-networks/pkg/logger"
-	"net/http"
 )
 
 type Service interface {
-	ProcessMessage(ctx context.Context, userID, modelID, versionID int64, inputs []*client.Input) ([]string, error)
-	GetMessages(ctx context.Context, userID, modelID int64) ([]*client.Message, error)
+	ProcessMessage(ctx context.Context, modelID, versionID int64, inputs []*client.Tensor) (map[string]*client.Tensor, error)
+	ListChats(ctx context.Context) ([]*client.Chat, error)
+	DeleteMessage(ctx context.Context, messageID int64) error
+	DeleteChat(ctx context.Context, modelID, versionID int64) (int64, error)
+	ExportMessages(ctx context.Context, filter models.MessageFilter, send func(*client.Message) error) error
+	GetMessages(ctx context.Context, filter models.MessageFilter, pageSize int32, pageToken string, descending bool) ([]*client.Message, string, int64, error)
 }
 
 type MessageService struct {
@@ -26,35 +30,116 @@ func NewMessageService(ctx context.Context, srv Service) *MessageService {
 }
 
 func (s *MessageService) SendMessage(ctx context.Context, req *client.SendMessageRequest) (*client.SendMessageResponse, error) {
-	results, err := s.service.ProcessMessage(ctx, req.GetUserId(), req.GetModelId(), req.GetVersionId(), req.GetInputs())
+	outputs, err := s.service.ProcessMessage(ctx, req.GetModelId(), req.GetVersionId(), req.GetInputs())
 	if err != nil {
 		logger.GetLoggerFromCtx(s.ctx).Error(
 			s.ctx,
 			err.Error(),
 			zap.String("Function", logger.GetFunctionName()),
-			zap.String("Status", http.StatusText(http.StatusInternalServerError)),
+			zap.String("Status", status.Code(err).String()),
 		)
-		return nil, status.Errorf(codes.Unknown, "SendMessage: %s", err)
+		return nil, err
 	}
 
 	return &client.SendMessageResponse{
-		Results: results,
+		Outputs: outputs,
 	}, nil
 }
 
 func (s *MessageService) GetMessages(ctx context.Context, req *client.GetMessagesRequest) (*client.GetMessagesResponse, error) {
-	messages, err := s.service.GetMessages(ctx, req.GetUserId(), req.GetModelId())
+	filter := messageFilter(req.GetModelId(), req.GetVersionId(), req.GetFrom(), req.GetTo())
+	messages, nextPageToken, total, err := s.service.GetMessages(ctx, filter, req.GetPageSize(), req.GetPageToken(), req.GetDescending())
 	if err != nil {
 		logger.GetLoggerFromCtx(s.ctx).Error(
 			s.ctx,
 			err.Error(),
 			zap.String("Function", logger.GetFunctionName()),
-			zap.String("Status", http.StatusText(http.StatusInternalServerError)),
+			zap.String("Status", status.Code(err).String()),
 		)
-		return nil, status.Errorf(codes.Unknown, "GetMessages: %s", err)
+		return nil, err
 	}
 
 	return &client.GetMessagesResponse{
-		Messages: messages,
+		Messages:      messages,
+		NextPageToken: nextPageToken,
+		TotalCount:    total,
 	}, nil
 }
+
+func (s *MessageService) ListChats(ctx context.Context, req *client.ListChatsRequest) (*client.ListChatsResponse, error) {
+	chats, err := s.service.ListChats(ctx)
+	if err != nil {
+		logger.GetLoggerFromCtx(s.ctx).Error(
+			s.ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", status.Code(err).String()),
+		)
+		return nil, err
+	}
+
+	return &client.ListChatsResponse{
+		Chats: chats,
+	}, nil
+}
+
+func (s *MessageService) DeleteMessage(ctx context.Context, req *client.DeleteMessageRequest) (*client.DeleteMessageResponse, error) {
+	err := s.service.DeleteMessage(ctx, req.GetMessageId())
+	if err != nil {
+		logger.GetLoggerFromCtx(s.ctx).Error(
+			s.ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", status.Code(err).String()),
+		)
+		return nil, err
+	}
+
+	return &client.DeleteMessageResponse{}, nil
+}
+
+func (s *MessageService) DeleteChat(ctx context.Context, req *client.DeleteChatRequest) (*client.DeleteChatResponse, error) {
+	deleted, err := s.service.DeleteChat(ctx, req.GetModelId(), req.GetVersionId())
+	if err != nil {
+		logger.GetLoggerFromCtx(s.ctx).Error(
+			s.ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", status.Code(err).String()),
+		)
+		return nil, err
+	}
+
+	return &client.DeleteChatResponse{
+		DeletedCount: deleted,
+	}, nil
+}
+
+func (s *MessageService) ExportMessages(req *client.ExportMessagesRequest, stream client.MessageService_ExportMessagesServer) error {
+	filter := messageFilter(req.GetModelId(), req.GetVersionId(), req.GetFrom(), req.GetTo())
+	err := s.service.ExportMessages(stream.Context(), filter, stream.Send)
+	if err != nil {
+		logger.GetLoggerFromCtx(s.ctx).Error(
+			s.ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", status.Code(err).String()),
+		)
+		return err
+	}
+	return nil
+}
+
+func messageFilter(modelID, versionID int64, from, to *timestamppb.Timestamp) models.MessageFilter {
+	filter := models.MessageFilter{
+		ModelID:   modelID,
+		VersionID: versionID,
+	}
+	if from != nil {
+		filter.From = from.AsTime()
+	}
+	if to != nil {
+		filter.To = to.AsTime()
+	}
+	return filter
+}
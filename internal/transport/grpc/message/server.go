@@ -25,7 +25,8 @@ func New(ctx context.Context, port int, service Service) (*Server, error) {
 	}
 
 	opts := []grpc.ServerOption{
-		grpc.UnaryInterceptor(interceptor.ContextWithLogger(logger.GetLoggerFromCtx(ctx))),
+		grpc.ChainUnaryInterceptor(interceptor.WithOrigin(), interceptor.ContextWithLogger(logger.GetLoggerFromCtx(ctx)), interceptor.Authenticate()),
+		grpc.ChainStreamInterceptor(interceptor.WithOriginStream(), interceptor.AuthenticateStream()),
 	}
 	grpcServer := grpc.NewServer(opts...)
 	client.RegisterMessageServiceServer(grpcServer, NewMessageService(ctx, service))
@@ -0,0 +1,24 @@
+package model
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/status"
+	client "house-of-neural-networks/pkg/api/model"
+	"house-of-neural-networks/pkg/logger"
+)
+
+func (s *ModelService) DeleteAccountData(ctx context.Context, req *client.DeleteAccountDataRequest) (*client.DeleteAccountDataResponse, error) {
+	deleted, err := s.service.DeleteAccountData(ctx)
+	if err != nil {
+		logger.GetLoggerFromCtx(s.ctx).Error(
+			s.ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", status.Code(err).String()),
+		)
+		return nil, err
+	}
+
+	return &client.DeleteAccountDataResponse{DeletedModels: int32(deleted)}, nil
+}
@@ -0,0 +1,37 @@
+package model
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/status"
+	client "house-of-neural-networks/pkg/api/model"
+	"house-of-neural-networks/pkg/logger"
+)
+
+func (s *ModelService) ListAllModels(ctx context.Context, req *client.ListAllModelsRequest) (*client.ListModelsResponse, error) {
+	resp, err := s.service.ListAllModels(ctx, req.GetUserId())
+	if err != nil {
+		logger.GetLoggerFromCtx(s.ctx).Error(
+			s.ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", status.Code(err).String()),
+		)
+		return nil, err
+	}
+
+	result := make([]*client.Model, 0, len(resp))
+	for _, model := range resp {
+		result = append(result, &client.Model{
+			Id:               model.ID,
+			Name:             model.Name,
+			UserId:           model.UserID,
+			PublicPermission: model.PublicPermission,
+			OrganizationId:   model.OrganizationID,
+		})
+	}
+
+	return &client.ListModelsResponse{
+		Models: result,
+	}, nil
+}
@@ -4,20 +4,34 @@ import (
 	"context"
 	"github.com/AlekSi/pointer"
 	"go.uber.org/zap"
-	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"house-of-neural-networks/internal/models"
 	client "house-of-neural-networks/pkg/api/model"
 	"house-of-neural-networks/pkg/logger"
-	"net/http"
+	"io"
 )
 
 type Service interface {
 	CreateModel(ctx context.Context, model models.Model, filename string, content []byte) (*models.Model, error)
 	GetModel(ctx context.Context, model models.Model) (*models.Model, error)
 	CreateVersion(ctx context.Context, version models.Version, files []models.File) (*models.Version, error)
+	UploadVersion(ctx context.Context, version models.Version, next func() (string, io.Reader, error)) (*models.Version, error)
+	CreateUpload(ctx context.Context, upload models.Upload) (*models.Upload, error)
+	GetUpload(ctx context.Context, id int64) (*models.Upload, error)
+	WriteUpload(ctx context.Context, id, offset int64, content io.Reader) (*models.Upload, error)
+	DeleteUpload(ctx context.Context, id int64) error
+	FinishUpload(ctx context.Context, version models.Version, uploadIDs []int64) (*models.Version, error)
 	DeleteModel(ctx context.Context, model models.Model) (bool, error)
-	ListModels(ctx context.Context, userID int64) ([]*models.Model, error)
+	ListModels(ctx context.Context) ([]*models.Model, error)
+	GrantAccess(ctx context.Context, modelID, userID int64, public bool, permission string) error
+	RevokeAccess(ctx context.Context, modelID, userID int64, public bool) error
+	CreateOrganization(ctx context.Context, name string) (*models.Organization, error)
+	ListOrganizations(ctx context.Context) ([]models.Organization, error)
+	ListMembers(ctx context.Context, organizationID int64) ([]models.Member, error)
+	SetMember(ctx context.Context, organizationID, userID int64, role string) error
+	RemoveMember(ctx context.Context, organizationID, userID int64) error
+	ListAllModels(ctx context.Context, userID int64) ([]*models.Model, error)
+	DeleteAccountData(ctx context.Context) (int, error)
 }
 
 type ModelService struct {
@@ -32,17 +46,17 @@ func NewModelService(ctx context.Context, srv Service) *ModelService {
 
 func (s *ModelService) UploadModel(ctx context.Context, req *client.UploadModelRequest) (*client.UploadModelResponse, error) {
 	resp, err := s.service.CreateModel(ctx, models.Model{
-		Name:   req.GetName(),
-		UserID: req.GetUserId(),
+		Name:           req.GetName(),
+		OrganizationID: req.GetOrganizationId(),
 	}, req.GetConfig().GetFilename(), req.GetConfig().GetContent())
 	if err != nil {
 		logger.GetLoggerFromCtx(s.ctx).Error(
 			s.ctx,
 			err.Error(),
 			zap.String("Function", logger.GetFunctionName()),
-			zap.String("Status", http.StatusText(http.StatusInternalServerError)),
+			zap.String("Status", status.Code(err).String()),
 		)
-		return nil, status.Errorf(codes.Internal, "UploadModel: %s", err)
+		return nil, err
 	}
 	r := pointer.Get(resp)
 	return &client.UploadModelResponse{
@@ -68,9 +82,9 @@ func (s *ModelService) UploadVersion(ctx context.Context, req *client.UploadVers
 			s.ctx,
 			err.Error(),
 			zap.String("Function", logger.GetFunctionName()),
-			zap.String("Status", http.StatusText(http.StatusInternalServerError)),
+			zap.String("Status", status.Code(err).String()),
 		)
-		return nil, status.Errorf(codes.Internal, "UploadVersion: %s", err)
+		return nil, err
 	}
 	r := pointer.Get(resp)
 	return &client.UploadVersionResponse{
@@ -87,9 +101,9 @@ func (s *ModelService) GetModel(ctx context.Context, req *client.GetModelRequest
 			s.ctx,
 			err.Error(),
 			zap.String("Function", logger.GetFunctionName()),
-			zap.String("Status", http.StatusText(http.StatusInternalServerError)),
+			zap.String("Status", status.Code(err).String()),
 		)
-		return nil, status.Errorf(codes.NotFound, "GetModel: %s", err)
+		return nil, err
 	}
 	r := pointer.Get(resp)
 
@@ -105,10 +119,13 @@ func (s *ModelService) GetModel(ctx context.Context, req *client.GetModelRequest
 
 	return &client.GetModelResponse{
 		Model: &client.Model{
-			Id:       r.ID,
-			Name:     r.Name,
-			UserId:   r.UserID,
-			Versions: versions,
+			Id:               r.ID,
+			Name:             r.Name,
+			UserId:           r.UserID,
+			Versions:         versions,
+			Role:             r.Role,
+			PublicPermission: r.PublicPermission,
+			OrganizationId:   r.OrganizationID,
 		},
 	}, nil
 }
@@ -122,9 +139,9 @@ func (s *ModelService) UnloadModel(ctx context.Context, req *client.UnloadModelR
 			s.ctx,
 			err.Error(),
 			zap.String("Function", logger.GetFunctionName()),
-			zap.String("Status", http.StatusText(http.StatusInternalServerError)),
+			zap.String("Status", status.Code(err).String()),
 		)
-		return nil, status.Errorf(codes.NotFound, "UnloadModel: %s", err)
+		return nil, err
 	}
 
 	return &client.UnloadModelResponse{
@@ -133,24 +150,27 @@ func (s *ModelService) UnloadModel(ctx context.Context, req *client.UnloadModelR
 }
 
 func (s *ModelService) ListModels(ctx context.Context, req *client.ListModelsRequest) (*client.ListModelsResponse, error) {
-	resp, err := s.service.ListModels(ctx, req.GetUserId())
+	resp, err := s.service.ListModels(ctx)
 	if err != nil {
 		logger.GetLoggerFromCtx(s.ctx).Error(
 			s.ctx,
 			err.Error(),
 			zap.String("Function", logger.GetFunctionName()),
-			zap.String("Status", http.StatusText(http.StatusInternalServerError)),
+			zap.String("Status", status.Code(err).String()),
 		)
-		return nil, status.Errorf(codes.Internal, "ListModels: %s", err)
+		return nil, err
 	}
 
 	result := make([]*client.Model, 0)
 	for _, model := range resp {
 		r := pointer.Get(model)
 		result = append(result, &client.Model{
-			Id:     r.ID,
-			Name:   r.Name,
-			UserId: r.UserID,
+			Id:               r.ID,
+			Name:             r.Name,
+			UserId:           r.UserID,
+			Role:             r.Role,
+			PublicPermission: r.PublicPermission,
+			OrganizationId:   r.OrganizationID,
 		})
 	}
 
@@ -158,3 +178,33 @@ func (s *ModelService) ListModels(ctx context.Context, req *client.ListModelsReq
 		Models: result,
 	}, nil
 }
+
+func (s *ModelService) GrantAccess(ctx context.Context, req *client.GrantAccessRequest) (*client.GrantAccessResponse, error) {
+	err := s.service.GrantAccess(ctx, req.GetModelId(), req.GetUserId(), req.GetPublic(), req.GetPermission())
+	if err != nil {
+		logger.GetLoggerFromCtx(s.ctx).Error(
+			s.ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", status.Code(err).String()),
+		)
+		return nil, err
+	}
+
+	return &client.GrantAccessResponse{}, nil
+}
+
+func (s *ModelService) RevokeAccess(ctx context.Context, req *client.RevokeAccessRequest) (*client.RevokeAccessResponse, error) {
+	err := s.service.RevokeAccess(ctx, req.GetModelId(), req.GetUserId(), req.GetPublic())
+	if err != nil {
+		logger.GetLoggerFromCtx(s.ctx).Error(
+			s.ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", status.Code(err).String()),
+		)
+		return nil, err
+	}
+
+	return &client.RevokeAccessResponse{}, nil
+}
@@ -0,0 +1,110 @@
+package model
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	client "house-of-neural-networks/pkg/api/model"
+	"house-of-neural-networks/pkg/logger"
+)
+
+func (s *ModelService) CreateOrganization(ctx context.Context, req *client.CreateOrganizationRequest) (*client.CreateOrganizationResponse, error) {
+	resp, err := s.service.CreateOrganization(ctx, req.GetName())
+	if err != nil {
+		logger.GetLoggerFromCtx(s.ctx).Error(
+			s.ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", status.Code(err).String()),
+		)
+		return nil, err
+	}
+
+	return &client.CreateOrganizationResponse{
+		Id: resp.ID,
+	}, nil
+}
+
+func (s *ModelService) ListOrganizations(ctx context.Context, req *client.ListOrganizationsRequest) (*client.ListOrganizationsResponse, error) {
+	resp, err := s.service.ListOrganizations(ctx)
+	if err != nil {
+		logger.GetLoggerFromCtx(s.ctx).Error(
+			s.ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", status.Code(err).String()),
+		)
+		return nil, err
+	}
+
+	result := make([]*client.Organization, 0, len(resp))
+	for _, organization := range resp {
+		result = append(result, &client.Organization{
+			Id:        organization.ID,
+			Name:      organization.Name,
+			CreatedAt: timestamppb.New(organization.CreatedAt),
+			Role:      organization.Role,
+		})
+	}
+
+	return &client.ListOrganizationsResponse{
+		Organizations: result,
+	}, nil
+}
+
+func (s *ModelService) ListMembers(ctx context.Context, req *client.ListMembersRequest) (*client.ListMembersResponse, error) {
+	resp, err := s.service.ListMembers(ctx, req.GetOrganizationId())
+	if err != nil {
+		logger.GetLoggerFromCtx(s.ctx).Error(
+			s.ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", status.Code(err).String()),
+		)
+		return nil, err
+	}
+
+	result := make([]*client.Member, 0, len(resp))
+	for _, member := range resp {
+		result = append(result, &client.Member{
+			UserId:   member.UserID,
+			Username: member.Username,
+			Role:     member.Role,
+		})
+	}
+
+	return &client.ListMembersResponse{
+		Members: result,
+	}, nil
+}
+
+func (s *ModelService) SetMember(ctx context.Context, req *client.SetMemberRequest) (*client.SetMemberResponse, error) {
+	err := s.service.SetMember(ctx, req.GetOrganizationId(), req.GetUserId(), req.GetRole())
+	if err != nil {
+		logger.GetLoggerFromCtx(s.ctx).Error(
+			s.ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", status.Code(err).String()),
+		)
+		return nil, err
+	}
+
+	return &client.SetMemberResponse{}, nil
+}
+
+func (s *ModelService) RemoveMember(ctx context.Context, req *client.RemoveMemberRequest) (*client.RemoveMemberResponse, error) {
+	err := s.service.RemoveMember(ctx, req.GetOrganizationId(), req.GetUserId())
+	if err != nil {
+		logger.GetLoggerFromCtx(s.ctx).Error(
+			s.ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", status.Code(err).String()),
+		)
+		return nil, err
+	}
+
+	return &client.RemoveMemberResponse{}, nil
+}
@@ -6,6 +6,7 @@ import (
 	"go.uber.org/zap"
 	"golang.org/x/sync/errgroup"
 	"google.golang.org/grpc"
+	"house-of-neural-networks/internal/models"
 	interceptor "house-of-neural-networks/internal/transport/grpc"
 	client "house-of-neural-networks/pkg/api/model"
 	"house-of-neural-networks/pkg/logger"
@@ -13,6 +14,11 @@ import (
 	"net"
 )
 
+// methodRoles are the roles required to call the methods of the service.
+var methodRoles = map[string]string{
+	client.ModelService_ListAllModels_FullMethodName: models.RoleAdmin,
+}
+
 type Server struct {
 	grpcServer *grpc.Server
 	listener   net.Listener
@@ -25,7 +31,8 @@ func New(ctx context.Context, port int, service Service) (*Server, error) {
 	}
 
 	opts := []grpc.ServerOption{
-		grpc.UnaryInterceptor(interceptor.ContextWithLogger(logger.GetLoggerFromCtx(ctx))),
+		grpc.ChainUnaryInterceptor(interceptor.WithOrigin(), interceptor.ContextWithLogger(logger.GetLoggerFromCtx(ctx)), interceptor.Authenticate(), interceptor.Authorize(methodRoles)),
+		grpc.ChainStreamInterceptor(interceptor.WithOriginStream(), interceptor.AuthenticateStream()),
 	}
 	grpcServer := grpc.NewServer(opts...)
 	client.RegisterModelServiceServer(grpcServer, NewModelService(ctx, service))
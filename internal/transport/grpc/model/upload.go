@@ -0,0 +1,228 @@
+package model
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"house-of-neural-networks/internal/models"
+	client "house-of-neural-networks/pkg/api/model"
+	"house-of-neural-networks/pkg/logger"
+	"io"
+)
+
+func (s *ModelService) UploadVersionStream(stream client.ModelService_UploadVersionStreamServer) error {
+	ctx := stream.Context()
+	err := func() error {
+		first, err := stream.Recv()
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if first.GetHeader() == nil {
+			return status.Error(codes.InvalidArgument, "transport.UploadVersionStream: the stream has to start with a header")
+		}
+		header := first.GetHeader()
+		resp, err := s.service.UploadVersion(ctx, models.Version{
+			Number:  header.GetNumber(),
+			ModelID: header.GetModelId(),
+		}, (&frameReader{stream: stream, done: true}).next)
+		if err != nil {
+			return err
+		}
+		return stream.SendAndClose(&client.UploadVersionResponse{Id: resp.ID})
+	}()
+	if err != nil {
+		logger.GetLoggerFromCtx(s.ctx).Error(
+			s.ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", status.Code(err).String()),
+		)
+		return err
+	}
+	return nil
+}
+
+// frameReader splits the frames of UploadVersionStream into files. The content of a file is read straight off
+// the stream, so it has to be consumed before the next file is requested.
+type frameReader struct {
+	stream  client.ModelService_UploadVersionStreamServer
+	pending *client.UploadVersionChunk
+	data    []byte
+	done    bool
+}
+
+// next returns the following file of the stream, or io.EOF after the last one.
+func (r *frameReader) next() (string, io.Reader, error) {
+	// Skip whatever is left of the previous file
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		return "", nil, err
+	}
+	frame, err := r.recv()
+	if err != nil {
+		return "", nil, err
+	}
+	if frame.GetFilename() == "" {
+		return "", nil, status.Error(codes.InvalidArgument, "transport.UploadVersionStream: expected a filename frame")
+	}
+	r.data, r.done = nil, false
+	return frame.GetFilename(), r, nil
+}
+
+// Read reads the content of the current file until the next filename frame.
+func (r *frameReader) Read(p []byte) (int, error) {
+	for len(r.data) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		frame, err := r.recv()
+		if err == io.EOF {
+			r.done = true
+			return 0, io.EOF
+		}
+		if err != nil {
+			return 0, err
+		}
+		if _, ok := frame.GetFrame().(*client.UploadVersionChunk_Data); !ok {
+			r.pending, r.done = frame, true
+			return 0, io.EOF
+		}
+		r.data = frame.GetData()
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func (r *frameReader) recv() (*client.UploadVersionChunk, error) {
+	if r.pending != nil {
+		frame := r.pending
+		r.pending = nil
+		return frame, nil
+	}
+	return r.stream.Recv()
+}
+
+func (s *ModelService) CreateUpload(ctx context.Context, req *client.CreateUploadRequest) (*client.Upload, error) {
+	upload, err := s.service.CreateUpload(ctx, models.Upload{
+		ModelID:  req.GetModelId(),
+		Filename: req.GetFilename(),
+		Size:     req.GetSize(),
+	})
+	if err != nil {
+		logger.GetLoggerFromCtx(s.ctx).Error(
+			s.ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", status.Code(err).String()),
+		)
+		return nil, err
+	}
+	return uploadToProto(upload), nil
+}
+
+func (s *ModelService) GetUpload(ctx context.Context, req *client.GetUploadRequest) (*client.Upload, error) {
+	upload, err := s.service.GetUpload(ctx, req.GetId())
+	if err != nil {
+		logger.GetLoggerFromCtx(s.ctx).Error(
+			s.ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", status.Code(err).String()),
+		)
+		return nil, err
+	}
+	return uploadToProto(upload), nil
+}
+
+func (s *ModelService) WriteUpload(stream client.ModelService_WriteUploadServer) error {
+	err := func() error {
+		first, err := stream.Recv()
+		if err != nil && err != io.EOF {
+			return err
+		}
+		header := first.GetHeader()
+		if header == nil {
+			return status.Error(codes.InvalidArgument, "transport.WriteUpload: the stream has to start with a header")
+		}
+		upload, err := s.service.WriteUpload(stream.Context(), header.GetId(), header.GetOffset(), &dataReader{stream: stream})
+		if err != nil {
+			return err
+		}
+		return stream.SendAndClose(uploadToProto(upload))
+	}()
+	if err != nil {
+		logger.GetLoggerFromCtx(s.ctx).Error(
+			s.ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", status.Code(err).String()),
+		)
+		return err
+	}
+	return nil
+}
+
+func (s *ModelService) DeleteUpload(ctx context.Context, req *client.DeleteUploadRequest) (*client.DeleteUploadResponse, error) {
+	if err := s.service.DeleteUpload(ctx, req.GetId()); err != nil {
+		logger.GetLoggerFromCtx(s.ctx).Error(
+			s.ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", status.Code(err).String()),
+		)
+		return nil, err
+	}
+	return &client.DeleteUploadResponse{}, nil
+}
+
+func (s *ModelService) FinishUpload(ctx context.Context, req *client.FinishUploadRequest) (*client.UploadVersionResponse, error) {
+	resp, err := s.service.FinishUpload(ctx, models.Version{
+		Number:  req.GetNumber(),
+		ModelID: req.GetModelId(),
+	}, req.GetUploadIds())
+	if err != nil {
+		logger.GetLoggerFromCtx(s.ctx).Error(
+			s.ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", status.Code(err).String()),
+		)
+		return nil, err
+	}
+	return &client.UploadVersionResponse{Id: resp.ID}, nil
+}
+
+func uploadToProto(upload *models.Upload) *client.Upload {
+	return &client.Upload{
+		Id:        upload.ID,
+		ModelId:   upload.ModelID,
+		Filename:  upload.Filename,
+		Size:      upload.Size,
+		Offset:    upload.Offset,
+		ExpiresAt: timestamppb.New(upload.ExpiresAt),
+	}
+}
+
+// dataReader reads the data frames of WriteUpload.
+type dataReader struct {
+	stream client.ModelService_WriteUploadServer
+	data   []byte
+}
+
+func (r *dataReader) Read(p []byte) (int, error) {
+	for len(r.data) == 0 {
+		frame, err := r.stream.Recv()
+		if err != nil {
+			return 0, err
+		}
+		if _, ok := frame.GetFrame().(*client.WriteUploadChunk_Data); !ok {
+			return 0, status.Error(codes.InvalidArgument, "transport.WriteUpload: expected a data frame")
+		}
+		r.data = frame.GetData()
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"go.uber.org/zap"
+	interceptor "house-of-neural-networks/internal/transport/grpc"
 	"house-of-neural-networks/pkg/logger"
 	"net/http"
 
@@ -17,7 +18,10 @@ type AuthClient struct {
 }
 
 func NewAuthClient(addr string) (*AuthClient, error) {
-	conn, err := grpc.Dial(addr, grpc.WithInsecure())
+	conn, err := grpc.Dial(addr,
+		grpc.WithInsecure(),
+		grpc.WithChainUnaryInterceptor(interceptor.PropagateOrigin(), interceptor.PropagatePrincipal()),
+	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to AuthService: %w", err)
 	}
@@ -64,3 +68,341 @@ func (c *AuthClient) ValidateToken(ctx context.Context, req *pb.ValidateTokenReq
 	}
 	return response, err
 }
+
+func (c *AuthClient) Refresh(ctx context.Context, req *pb.RefreshRequest) (*pb.RefreshResponse, error) {
+	response, err := c.client.Refresh(ctx, req)
+	if err != nil {
+		logger.GetLoggerFromCtx(ctx).Error(
+			ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", http.StatusText(http.StatusInternalServerError)),
+		)
+	}
+	return response, err
+}
+
+func (c *AuthClient) LogOut(ctx context.Context, req *pb.LogOutRequest) (*pb.LogOutResponse, error) {
+	response, err := c.client.LogOut(ctx, req)
+	if err != nil {
+		logger.GetLoggerFromCtx(ctx).Error(
+			ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", http.StatusText(http.StatusInternalServerError)),
+		)
+	}
+	return response, err
+}
+
+func (c *AuthClient) CreateAPIKey(ctx context.Context, req *pb.CreateAPIKeyRequest) (*pb.CreateAPIKeyResponse, error) {
+	response, err := c.client.CreateAPIKey(ctx, req)
+	if err != nil {
+		logger.GetLoggerFromCtx(ctx).Error(
+			ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", http.StatusText(http.StatusInternalServerError)),
+		)
+	}
+	return response, err
+}
+
+func (c *AuthClient) ListAPIKeys(ctx context.Context, req *pb.ListAPIKeysRequest) (*pb.ListAPIKeysResponse, error) {
+	response, err := c.client.ListAPIKeys(ctx, req)
+	if err != nil {
+		logger.GetLoggerFromCtx(ctx).Error(
+			ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", http.StatusText(http.StatusInternalServerError)),
+		)
+	}
+	return response, err
+}
+
+func (c *AuthClient) RevokeAPIKey(ctx context.Context, req *pb.RevokeAPIKeyRequest) (*pb.RevokeAPIKeyResponse, error) {
+	response, err := c.client.RevokeAPIKey(ctx, req)
+	if err != nil {
+		logger.GetLoggerFromCtx(ctx).Error(
+			ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", http.StatusText(http.StatusInternalServerError)),
+		)
+	}
+	return response, err
+}
+
+func (c *AuthClient) ValidateAPIKey(ctx context.Context, req *pb.ValidateAPIKeyRequest) (*pb.ValidateTokenResponse, error) {
+	response, err := c.client.ValidateAPIKey(ctx, req)
+	if err != nil {
+		logger.GetLoggerFromCtx(ctx).Error(
+			ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", http.StatusText(http.StatusInternalServerError)),
+		)
+	}
+	return response, err
+}
+
+func (c *AuthClient) GetJWKS(ctx context.Context, req *pb.GetJWKSRequest) (*pb.GetJWKSResponse, error) {
+	response, err := c.client.GetJWKS(ctx, req)
+	if err != nil {
+		logger.GetLoggerFromCtx(ctx).Error(
+			ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", http.StatusText(http.StatusInternalServerError)),
+		)
+	}
+	return response, err
+}
+
+func (c *AuthClient) ListRevokedTokens(ctx context.Context, req *pb.ListRevokedTokensRequest) (*pb.ListRevokedTokensResponse, error) {
+	response, err := c.client.ListRevokedTokens(ctx, req)
+	if err != nil {
+		logger.GetLoggerFromCtx(ctx).Error(
+			ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", http.StatusText(http.StatusInternalServerError)),
+		)
+	}
+	return response, err
+}
+
+func (c *AuthClient) StartOIDCLogin(ctx context.Context, req *pb.StartOIDCLoginRequest) (*pb.StartOIDCLoginResponse, error) {
+	response, err := c.client.StartOIDCLogin(ctx, req)
+	if err != nil {
+		logger.GetLoggerFromCtx(ctx).Error(
+			ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", http.StatusText(http.StatusInternalServerError)),
+		)
+	}
+	return response, err
+}
+
+func (c *AuthClient) FinishOIDCLogin(ctx context.Context, req *pb.FinishOIDCLoginRequest) (*pb.LogInResponse, error) {
+	response, err := c.client.FinishOIDCLogin(ctx, req)
+	if err != nil {
+		logger.GetLoggerFromCtx(ctx).Error(
+			ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", http.StatusText(http.StatusInternalServerError)),
+		)
+	}
+	return response, err
+}
+
+func (c *AuthClient) ListUsers(ctx context.Context, req *pb.ListUsersRequest) (*pb.ListUsersResponse, error) {
+	response, err := c.client.ListUsers(ctx, req)
+	if err != nil {
+		logger.GetLoggerFromCtx(ctx).Error(
+			ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", http.StatusText(http.StatusInternalServerError)),
+		)
+	}
+	return response, err
+}
+
+func (c *AuthClient) SetUserDisabled(ctx context.Context, req *pb.SetUserDisabledRequest) (*pb.SetUserDisabledResponse, error) {
+	response, err := c.client.SetUserDisabled(ctx, req)
+	if err != nil {
+		logger.GetLoggerFromCtx(ctx).Error(
+			ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", http.StatusText(http.StatusInternalServerError)),
+		)
+	}
+	return response, err
+}
+
+func (c *AuthClient) SetUserRoles(ctx context.Context, req *pb.SetUserRolesRequest) (*pb.SetUserRolesResponse, error) {
+	response, err := c.client.SetUserRoles(ctx, req)
+	if err != nil {
+		logger.GetLoggerFromCtx(ctx).Error(
+			ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", http.StatusText(http.StatusInternalServerError)),
+		)
+	}
+	return response, err
+}
+
+func (c *AuthClient) SendVerificationEmail(ctx context.Context, req *pb.SendVerificationEmailRequest) (*pb.SendVerificationEmailResponse, error) {
+	response, err := c.client.SendVerificationEmail(ctx, req)
+	if err != nil {
+		logger.GetLoggerFromCtx(ctx).Error(
+			ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", http.StatusText(http.StatusInternalServerError)),
+		)
+	}
+	return response, err
+}
+
+func (c *AuthClient) VerifyEmail(ctx context.Context, req *pb.VerifyEmailRequest) (*pb.VerifyEmailResponse, error) {
+	response, err := c.client.VerifyEmail(ctx, req)
+	if err != nil {
+		logger.GetLoggerFromCtx(ctx).Error(
+			ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", http.StatusText(http.StatusInternalServerError)),
+		)
+	}
+	return response, err
+}
+
+func (c *AuthClient) RequestPasswordReset(ctx context.Context, req *pb.RequestPasswordResetRequest) (*pb.RequestPasswordResetResponse, error) {
+	response, err := c.client.RequestPasswordReset(ctx, req)
+	if err != nil {
+		logger.GetLoggerFromCtx(ctx).Error(
+			ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", http.StatusText(http.StatusInternalServerError)),
+		)
+	}
+	return response, err
+}
+
+func (c *AuthClient) ResetPassword(ctx context.Context, req *pb.ResetPasswordRequest) (*pb.ResetPasswordResponse, error) {
+	response, err := c.client.ResetPassword(ctx, req)
+	if err != nil {
+		logger.GetLoggerFromCtx(ctx).Error(
+			ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", http.StatusText(http.StatusInternalServerError)),
+		)
+	}
+	return response, err
+}
+
+func (c *AuthClient) VerifyLogIn(ctx context.Context, req *pb.VerifyLogInRequest) (*pb.LogInResponse, error) {
+	response, err := c.client.VerifyLogIn(ctx, req)
+	if err != nil {
+		logger.GetLoggerFromCtx(ctx).Error(
+			ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", http.StatusText(http.StatusInternalServerError)),
+		)
+	}
+	return response, err
+}
+
+func (c *AuthClient) EnrollTOTP(ctx context.Context, req *pb.EnrollTOTPRequest) (*pb.EnrollTOTPResponse, error) {
+	response, err := c.client.EnrollTOTP(ctx, req)
+	if err != nil {
+		logger.GetLoggerFromCtx(ctx).Error(
+			ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", http.StatusText(http.StatusInternalServerError)),
+		)
+	}
+	return response, err
+}
+
+func (c *AuthClient) ConfirmTOTP(ctx context.Context, req *pb.ConfirmTOTPRequest) (*pb.ConfirmTOTPResponse, error) {
+	response, err := c.client.ConfirmTOTP(ctx, req)
+	if err != nil {
+		logger.GetLoggerFromCtx(ctx).Error(
+			ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", http.StatusText(http.StatusInternalServerError)),
+		)
+	}
+	return response, err
+}
+
+func (c *AuthClient) DisableTOTP(ctx context.Context, req *pb.DisableTOTPRequest) (*pb.DisableTOTPResponse, error) {
+	response, err := c.client.DisableTOTP(ctx, req)
+	if err != nil {
+		logger.GetLoggerFromCtx(ctx).Error(
+			ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", http.StatusText(http.StatusInternalServerError)),
+		)
+	}
+	return response, err
+}
+
+func (c *AuthClient) GetProfile(ctx context.Context, req *pb.GetProfileRequest) (*pb.Profile, error) {
+	response, err := c.client.GetProfile(ctx, req)
+	if err != nil {
+		logger.GetLoggerFromCtx(ctx).Error(
+			ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", http.StatusText(http.StatusInternalServerError)),
+		)
+	}
+	return response, err
+}
+
+func (c *AuthClient) UpdateProfile(ctx context.Context, req *pb.UpdateProfileRequest) (*pb.Profile, error) {
+	response, err := c.client.UpdateProfile(ctx, req)
+	if err != nil {
+		logger.GetLoggerFromCtx(ctx).Error(
+			ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", http.StatusText(http.StatusInternalServerError)),
+		)
+	}
+	return response, err
+}
+
+func (c *AuthClient) ChangePassword(ctx context.Context, req *pb.ChangePasswordRequest) (*pb.LogInResponse, error) {
+	response, err := c.client.ChangePassword(ctx, req)
+	if err != nil {
+		logger.GetLoggerFromCtx(ctx).Error(
+			ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", http.StatusText(http.StatusInternalServerError)),
+		)
+	}
+	return response, err
+}
+
+func (c *AuthClient) DeleteAccount(ctx context.Context, req *pb.DeleteAccountRequest) (*pb.DeleteAccountResponse, error) {
+	response, err := c.client.DeleteAccount(ctx, req)
+	if err != nil {
+		logger.GetLoggerFromCtx(ctx).Error(
+			ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", http.StatusText(http.StatusInternalServerError)),
+		)
+	}
+	return response, err
+}
+
+func (c *AuthClient) ListAuditEvents(ctx context.Context, req *pb.ListAuditEventsRequest) (*pb.ListAuditEventsResponse, error) {
+	response, err := c.client.ListAuditEvents(ctx, req)
+	if err != nil {
+		logger.GetLoggerFromCtx(ctx).Error(
+			ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", http.StatusText(http.StatusInternalServerError)),
+		)
+	}
+	return response, err
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"go.uber.org/zap"
+	interceptor "house-of-neural-networks/internal/transport/grpc"
 	"house-of-neural-networks/pkg/logger"
 	"net/http"
 
@@ -17,7 +18,11 @@ type MessageClient struct {
 }
 
 func NewMessageClient(addr string) (*MessageClient, error) {
-	conn, err := grpc.Dial(addr, grpc.WithInsecure())
+	conn, err := grpc.Dial(addr,
+		grpc.WithInsecure(),
+		grpc.WithChainUnaryInterceptor(interceptor.PropagateOrigin(), interceptor.PropagatePrincipal()),
+		grpc.WithChainStreamInterceptor(interceptor.PropagateOriginStream(), interceptor.PropagatePrincipalStream()),
+	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to Message-service: %w", err)
 	}
@@ -55,3 +60,55 @@ func (c *MessageClient) GetMessages(ctx context.Context, req *pb.GetMessagesRequ
 	}
 	return resp, err
 }
+
+func (c *MessageClient) ListChats(ctx context.Context, req *pb.ListChatsRequest) (*pb.ListChatsResponse, error) {
+	resp, err := c.client.ListChats(ctx, req)
+	if err != nil {
+		logger.GetLoggerFromCtx(ctx).Error(
+			ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", http.StatusText(http.StatusInternalServerError)),
+		)
+	}
+	return resp, err
+}
+
+func (c *MessageClient) DeleteMessage(ctx context.Context, req *pb.DeleteMessageRequest) (*pb.DeleteMessageResponse, error) {
+	resp, err := c.client.DeleteMessage(ctx, req)
+	if err != nil {
+		logger.GetLoggerFromCtx(ctx).Error(
+			ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", http.StatusText(http.StatusInternalServerError)),
+		)
+	}
+	return resp, err
+}
+
+func (c *MessageClient) DeleteChat(ctx context.Context, req *pb.DeleteChatRequest) (*pb.DeleteChatResponse, error) {
+	resp, err := c.client.DeleteChat(ctx, req)
+	if err != nil {
+		logger.GetLoggerFromCtx(ctx).Error(
+			ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", http.StatusText(http.StatusInternalServerError)),
+		)
+	}
+	return resp, err
+}
+
+func (c *MessageClient) ExportMessages(ctx context.Context, req *pb.ExportMessagesRequest) (pb.MessageService_ExportMessagesClient, error) {
+	resp, err := c.client.ExportMessages(ctx, req)
+	if err != nil {
+		logger.GetLoggerFromCtx(ctx).Error(
+			ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", http.StatusText(http.StatusInternalServerError)),
+		)
+	}
+	return resp, err
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"go.uber.org/zap"
+	interceptor "house-of-neural-networks/internal/transport/grpc"
 	"house-of-neural-networks/pkg/logger"
 	"net/http"
 
@@ -17,7 +18,11 @@ type ModelClient struct {
 }
 
 func NewModelClient(addr string) (*ModelClient, error) {
-	conn, err := grpc.Dial(addr, grpc.WithInsecure())
+	conn, err := grpc.Dial(addr,
+		grpc.WithInsecure(),
+		grpc.WithChainUnaryInterceptor(interceptor.PropagateOrigin(), interceptor.PropagatePrincipal()),
+		grpc.WithChainStreamInterceptor(interceptor.PropagateOriginStream(), interceptor.PropagatePrincipalStream()),
+	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to ModelService: %w", err)
 	}
@@ -78,6 +83,86 @@ func (c *ModelClient) UploadVersion(ctx context.Context, req *pb.UploadVersionRe
 	return response, err
 }
 
+// UploadVersionStream opens a stream to upload a version in chunks, the caller sends the frames and closes it.
+func (c *ModelClient) UploadVersionStream(ctx context.Context) (pb.ModelService_UploadVersionStreamClient, error) {
+	stream, err := c.client.UploadVersionStream(ctx)
+	if err != nil {
+		logger.GetLoggerFromCtx(ctx).Error(
+			ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", http.StatusText(http.StatusInternalServerError)),
+		)
+	}
+	return stream, err
+}
+
+func (c *ModelClient) CreateUpload(ctx context.Context, req *pb.CreateUploadRequest) (*pb.Upload, error) {
+	response, err := c.client.CreateUpload(ctx, req)
+	if err != nil {
+		logger.GetLoggerFromCtx(ctx).Error(
+			ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", http.StatusText(http.StatusInternalServerError)),
+		)
+	}
+	return response, err
+}
+
+func (c *ModelClient) GetUpload(ctx context.Context, req *pb.GetUploadRequest) (*pb.Upload, error) {
+	response, err := c.client.GetUpload(ctx, req)
+	if err != nil {
+		logger.GetLoggerFromCtx(ctx).Error(
+			ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", http.StatusText(http.StatusInternalServerError)),
+		)
+	}
+	return response, err
+}
+
+// WriteUpload opens a stream to append to an upload, the caller sends the frames and closes it.
+func (c *ModelClient) WriteUpload(ctx context.Context) (pb.ModelService_WriteUploadClient, error) {
+	stream, err := c.client.WriteUpload(ctx)
+	if err != nil {
+		logger.GetLoggerFromCtx(ctx).Error(
+			ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", http.StatusText(http.StatusInternalServerError)),
+		)
+	}
+	return stream, err
+}
+
+func (c *ModelClient) DeleteUpload(ctx context.Context, req *pb.DeleteUploadRequest) (*pb.DeleteUploadResponse, error) {
+	response, err := c.client.DeleteUpload(ctx, req)
+	if err != nil {
+		logger.GetLoggerFromCtx(ctx).Error(
+			ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", http.StatusText(http.StatusInternalServerError)),
+		)
+	}
+	return response, err
+}
+
+func (c *ModelClient) FinishUpload(ctx context.Context, req *pb.FinishUploadRequest) (*pb.UploadVersionResponse, error) {
+	response, err := c.client.FinishUpload(ctx, req)
+	if err != nil {
+		logger.GetLoggerFromCtx(ctx).Error(
+			ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", http.StatusText(http.StatusInternalServerError)),
+		)
+	}
+	return response, err
+}
+
 func (c *ModelClient) UnloadModel(ctx context.Context, req *pb.UnloadModelRequest) (*pb.UnloadModelResponse, error) {
 	response, err := c.client.UnloadModel(ctx, req)
 	if err != nil {
@@ -90,3 +175,120 @@ func (c *ModelClient) UnloadModel(ctx context.Context, req *pb.UnloadModelReques
 	}
 	return response, err
 }
+
+func (c *ModelClient) GrantAccess(ctx context.Context, req *pb.GrantAccessRequest) (*pb.GrantAccessResponse, error) {
+	response, err := c.client.GrantAccess(ctx, req)
+	if err != nil {
+		logger.GetLoggerFromCtx(ctx).Error(
+			ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", http.StatusText(http.StatusInternalServerError)),
+		)
+	}
+	return response, err
+}
+
+func (c *ModelClient) RevokeAccess(ctx context.Context, req *pb.RevokeAccessRequest) (*pb.RevokeAccessResponse, error) {
+	response, err := c.client.RevokeAccess(ctx, req)
+	if err != nil {
+		logger.GetLoggerFromCtx(ctx).Error(
+			ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", http.StatusText(http.StatusInternalServerError)),
+		)
+	}
+	return response, err
+}
+
+func (c *ModelClient) CreateOrganization(ctx context.Context, req *pb.CreateOrganizationRequest) (*pb.CreateOrganizationResponse, error) {
+	response, err := c.client.CreateOrganization(ctx, req)
+	if err != nil {
+		logger.GetLoggerFromCtx(ctx).Error(
+			ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", http.StatusText(http.StatusInternalServerError)),
+		)
+	}
+	return response, err
+}
+
+func (c *ModelClient) ListOrganizations(ctx context.Context, req *pb.ListOrganizationsRequest) (*pb.ListOrganizationsResponse, error) {
+	response, err := c.client.ListOrganizations(ctx, req)
+	if err != nil {
+		logger.GetLoggerFromCtx(ctx).Error(
+			ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", http.StatusText(http.StatusInternalServerError)),
+		)
+	}
+	return response, err
+}
+
+func (c *ModelClient) ListMembers(ctx context.Context, req *pb.ListMembersRequest) (*pb.ListMembersResponse, error) {
+	response, err := c.client.ListMembers(ctx, req)
+	if err != nil {
+		logger.GetLoggerFromCtx(ctx).Error(
+			ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", http.StatusText(http.StatusInternalServerError)),
+		)
+	}
+	return response, err
+}
+
+func (c *ModelClient) SetMember(ctx context.Context, req *pb.SetMemberRequest) (*pb.SetMemberResponse, error) {
+	response, err := c.client.SetMember(ctx, req)
+	if err != nil {
+		logger.GetLoggerFromCtx(ctx).Error(
+			ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", http.StatusText(http.StatusInternalServerError)),
+		)
+	}
+	return response, err
+}
+
+func (c *ModelClient) RemoveMember(ctx context.Context, req *pb.RemoveMemberRequest) (*pb.RemoveMemberResponse, error) {
+	response, err := c.client.RemoveMember(ctx, req)
+	if err != nil {
+		logger.GetLoggerFromCtx(ctx).Error(
+			ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", http.StatusText(http.StatusInternalServerError)),
+		)
+	}
+	return response, err
+}
+
+func (c *ModelClient) ListAllModels(ctx context.Context, req *pb.ListAllModelsRequest) (*pb.ListModelsResponse, error) {
+	response, err := c.client.ListAllModels(ctx, req)
+	if err != nil {
+		logger.GetLoggerFromCtx(ctx).Error(
+			ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", http.StatusText(http.StatusInternalServerError)),
+		)
+	}
+	return response, err
+}
+
+func (c *ModelClient) DeleteAccountData(ctx context.Context, req *pb.DeleteAccountDataRequest) (*pb.DeleteAccountDataResponse, error) {
+	response, err := c.client.DeleteAccountData(ctx, req)
+	if err != nil {
+		logger.GetLoggerFromCtx(ctx).Error(
+			ctx,
+			err.Error(),
+			zap.String("Function", logger.GetFunctionName()),
+			zap.String("Status", http.StatusText(http.StatusInternalServerError)),
+		)
+	}
+	return response, err
+}
@@ -0,0 +1,46 @@
+package triton
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// classifyError converts an error returned by Triton into a gRPC status error
+// whose code can be passed on to the caller unchanged.
+func classifyError(msg string, err error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		switch {
+		case errors.Is(err, context.DeadlineExceeded):
+			return status.Errorf(codes.DeadlineExceeded, "triton: %s: %s", msg, err)
+		case errors.Is(err, context.Canceled):
+			return status.Errorf(codes.Canceled, "triton: %s: %s", msg, err)
+		}
+		return status.Errorf(codes.Internal, "triton: %s: %s", msg, err)
+	}
+
+	code := st.Code()
+	switch code {
+	case codes.NotFound, codes.InvalidArgument, codes.Unavailable, codes.DeadlineExceeded,
+		codes.ResourceExhausted, codes.FailedPrecondition, codes.Canceled, codes.Unimplemented:
+	default:
+		code = codes.Internal
+	}
+
+	// Triton reports missing models and bad inputs with generic codes in some versions,
+	// so the message is used as a fallback.
+	message := strings.ToLower(st.Message())
+	switch {
+	case strings.Contains(message, "unknown model"), strings.Contains(message, "is not found"),
+		strings.Contains(message, "no version is available"):
+		code = codes.NotFound
+	case code == codes.Internal && (strings.Contains(message, "unexpected") || strings.Contains(message, "but got")):
+		code = codes.InvalidArgument
+	}
+
+	return status.Errorf(code, "triton: %s: %s", msg, st.Message())
+}
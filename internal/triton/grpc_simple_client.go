@@ -1,9 +1,7 @@
 package triton
 
 import (
-	"bytes"
 	"context"
-	"encoding/binary"
 	"fmt"
 	"log"
 	"time"
@@ -13,11 +11,6 @@ import (
 	"google.golang.org/grpc"
 )
 
-const (
-	inputSize  = 16
-	outputSize = 16
-)
-
 type Flags struct {
 	ModelName    string
 	ModelVersion string
@@ -34,7 +27,7 @@ type TritonClient struct {
 	Client triton.GRPCInferenceServiceClient
 }
 
-func ServerLiveRequest(client triton.GRPCInferenceServiceClient) *triton.ServerLiveResponse {
+func ServerLiveRequest(client triton.GRPCInferenceServiceClient) (*triton.ServerLiveResponse, error) {
 	// Create context for our request with 10 second timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -43,12 +36,12 @@ func ServerLiveRequest(client triton.GRPCInferenceServiceClient) *triton.ServerL
 	// Submit ServerLive request to server
 	serverLiveResponse, err := client.ServerLive(ctx, &serverLiveRequest)
 	if err != nil {
-		log.Fatalf("Couldn't get server live: %v", err)
+		return nil, classifyError("couldn't get server live", err)
 	}
-	return serverLiveResponse
+	return serverLiveResponse, nil
 }
 
-func ServerReadyRequest(client triton.GRPCInferenceServiceClient) *triton.ServerReadyResponse {
+func ServerReadyRequest(client triton.GRPCInferenceServiceClient) (*triton.ServerReadyResponse, error) {
 	// Create context for our request with 10 second timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -57,12 +50,12 @@ func ServerReadyRequest(client triton.GRPCInferenceServiceClient) *triton.Server
 	// Submit ServerReady request to server
 	serverReadyResponse, err := client.ServerReady(ctx, &serverReadyRequest)
 	if err != nil {
-		log.Fatalf("Couldn't get server ready: %v", err)
+		return nil, classifyError("couldn't get server ready", err)
 	}
-	return serverReadyResponse
+	return serverReadyResponse, nil
 }
 
-func ModelMetadataRequest(client triton.GRPCInferenceServiceClient, modelName string, modelVersion string) *triton.ModelMetadataResponse {
+func ModelMetadataRequest(client triton.GRPCInferenceServiceClient, modelName string, modelVersion string) (*triton.ModelMetadataResponse, error) {
 	// Create context for our request with 10 second timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -75,57 +68,36 @@ func ModelMetadataRequest(client triton.GRPCInferenceServiceClient, modelName st
 	// Submit modelMetadata request to server
 	modelMetadataResponse, err := client.ModelMetadata(ctx, &modelMetadataRequest)
 	if err != nil {
-		log.Fatalf("Couldn't get server model metadata: %v", err)
+		return nil, classifyError(fmt.Sprintf("couldn't get metadata of model %s", modelName), err)
 	}
-	return modelMetadataResponse
+	return modelMetadataResponse, nil
 }
 
-func ModelInferRequest(client triton.GRPCInferenceServiceClient, rawInput [][]byte, modelName string, modelVersion string) *triton.ModelInferResponse {
-	// Create context for our request with 10 second timeout
+func ModelConfigRequest(client triton.GRPCInferenceServiceClient, modelName string, modelVersion string) (*triton.ModelConfigResponse, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	// Create request input tensors
-	inferInputs := []*triton.ModelInferRequest_InferInputTensor{
-		&triton.ModelInferRequest_InferInputTensor{
-			Name:     "INPUT0",
-			Datatype: "INT32",
-			Shape:    []int64{1, 16},
-		},
-		&triton.ModelInferRequest_InferInputTensor{
-			Name:     "INPUT1",
-			Datatype: "INT32",
-			Shape:    []int64{1, 16},
-		},
-	}
-
-	// Create request input output tensors
-	inferOutputs := []*triton.ModelInferRequest_InferRequestedOutputTensor{
-		&triton.ModelInferRequest_InferRequestedOutputTensor{
-			Name: "OUTPUT0",
-		},
-		&triton.ModelInferRequest_InferRequestedOutputTensor{
-			Name: "OUTPUT1",
-		},
-	}
-
-	// Create inference request for specific model/version
-	modelInferRequest := triton.ModelInferRequest{
-		ModelName:    modelName,
-		ModelVersion: modelVersion,
-		Inputs:       inferInputs,
-		Outputs:      inferOutputs,
+	modelConfigResponse, err := client.ModelConfig(ctx, &triton.ModelConfigRequest{
+		Name:    modelName,
+		Version: modelVersion,
+	})
+	if err != nil {
+		return nil, classifyError(fmt.Sprintf("couldn't get config of model %s", modelName), err)
 	}
+	return modelConfigResponse, nil
+}
 
-	modelInferRequest.RawInputContents = append(modelInferRequest.RawInputContents, rawInput[0])
-	modelInferRequest.RawInputContents = append(modelInferRequest.RawInputContents, rawInput[1])
+func ModelInferRequest(client triton.GRPCInferenceServiceClient, modelInferRequest *triton.ModelInferRequest) (*triton.ModelInferResponse, error) {
+	// Create context for our request with 10 second timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 
 	// Submit inference request to server
-	modelInferResponse, err := client.ModelInfer(ctx, &modelInferRequest)
+	modelInferResponse, err := client.ModelInfer(ctx, modelInferRequest)
 	if err != nil {
-		log.Fatalf("Error processing InferRequest: %v", err)
+		return nil, classifyError(fmt.Sprintf("error processing infer request for model %s", modelInferRequest.GetModelName()), err)
 	}
-	return modelInferResponse
+	return modelInferResponse, nil
 }
 
 func ModelReadyRequest(client triton.GRPCInferenceServiceClient, modelName string, modelVersion string) (bool, error) {
@@ -137,7 +109,7 @@ func ModelReadyRequest(client triton.GRPCInferenceServiceClient, modelName strin
 	}
 	modelReadyResponse, err := client.ModelReady(ctx, &modelReadyRequest)
 	if err != nil {
-		return false, err
+		return false, classifyError(fmt.Sprintf("couldn't get readiness of model %s", modelName), err)
 	}
 	return modelReadyResponse.GetReady(), nil
 }
@@ -149,7 +121,7 @@ func LoadModelRequest(client triton.GRPCInferenceServiceClient, modelName string
 		ModelName: modelName,
 	})
 	if err != nil {
-		return err
+		return classifyError(fmt.Sprintf("couldn't load model %s", modelName), err)
 	}
 	return nil
 }
@@ -161,65 +133,11 @@ func UnloadModelRequest(client triton.GRPCInferenceServiceClient, modelName stri
 		ModelName: modelName,
 	})
 	if err != nil {
-		return err
+		return classifyError(fmt.Sprintf("couldn't unload model %s", modelName), err)
 	}
 	return nil
 }
 
-// Convert int32 input data into raw bytes (assumes Little Endian)
-func Preprocess(inputs [][]int32) [][]byte {
-	inputData0 := inputs[0]
-	inputData1 := inputs[1]
-
-	var inputBytes0 []byte
-	var inputBytes1 []byte
-	// Temp variable to hold our converted int32 -> []byte
-	bs := make([]byte, 4)
-	for i := 0; i < inputSize; i++ {
-		binary.LittleEndian.PutUint32(bs, uint32(inputData0[i]))
-		inputBytes0 = append(inputBytes0, bs...)
-		binary.LittleEndian.PutUint32(bs, uint32(inputData1[i]))
-		inputBytes1 = append(inputBytes1, bs...)
-	}
-
-	return [][]byte{inputBytes0, inputBytes1}
-}
-
-func BytesToInt32(rawInputs [][]byte) [][]int32 {
-	inputBytes0 := rawInputs[0]
-	inputBytes1 := rawInputs[1]
-
-	outputData0 := make([]int32, outputSize)
-	outputData1 := make([]int32, outputSize)
-	for i := 0; i < outputSize; i++ {
-		outputData0[i] = readInt32(inputBytes0[i*4 : i*4+4])
-		outputData1[i] = readInt32(inputBytes1[i*4 : i*4+4])
-	}
-	return [][]int32{outputData0, outputData1}
-}
-
-// Convert slice of 4 bytes to int32 (assumes Little Endian)
-func readInt32(fourBytes []byte) int32 {
-	buf := bytes.NewBuffer(fourBytes)
-	var retval int32
-	binary.Read(buf, binary.LittleEndian, &retval)
-	return retval
-}
-
-// Convert output's raw bytes into int32 data (assumes Little Endian)
-func Postprocess(inferResponse *triton.ModelInferResponse) [][]int32 {
-	outputBytes0 := inferResponse.RawOutputContents[0]
-	outputBytes1 := inferResponse.RawOutputContents[1]
-
-	outputData0 := make([]int32, outputSize)
-	outputData1 := make([]int32, outputSize)
-	for i := 0; i < outputSize; i++ {
-		outputData0[i] = readInt32(outputBytes0[i*4 : i*4+4])
-		outputData1[i] = readInt32(outputBytes1[i*4 : i*4+4])
-	}
-	return [][]int32{outputData0, outputData1}
-}
-
 func NewTritonClient(host, port string) *TritonClient {
 	conn, err := grpc.Dial(fmt.Sprintf("%s:%s", host, port), grpc.WithInsecure())
 	if err != nil {
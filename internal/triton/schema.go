@@ -0,0 +1,210 @@
+package triton
+
+import (
+	"fmt"
+
+	triton "house-of-neural-networks/pkg/api/triton2"
+	"house-of-neural-networks/pkg/tensor"
+)
+
+// TensorSpec describes a single model input or output as reported by Triton.
+type TensorSpec struct {
+	Name     string
+	Datatype string
+	Shape    []int64
+	Optional bool
+}
+
+// ModelSchema is the signature of a concrete model version.
+type ModelSchema struct {
+	Name         string
+	Version      string
+	MaxBatchSize int32
+	Inputs       []TensorSpec
+	Outputs      []TensorSpec
+}
+
+// Tensor is a named tensor exchanged with Triton.
+type Tensor struct {
+	Name     string
+	Datatype string
+	Shape    []int64
+	Contents tensor.Contents
+}
+
+// GetModelSchema fetches model metadata and config and merges them into a single schema.
+func GetModelSchema(client triton.GRPCInferenceServiceClient, modelName string, modelVersion string) (*ModelSchema, error) {
+	metadata, err := ModelMetadataRequest(client, modelName, modelVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get metadata of model %s: %w", modelName, err)
+	}
+	config, err := ModelConfigRequest(client, modelName, modelVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get config of model %s: %w", modelName, err)
+	}
+
+	optional := make(map[string]bool)
+	for _, input := range config.GetConfig().GetInput() {
+		optional[input.GetName()] = input.GetOptional()
+	}
+
+	schema := &ModelSchema{
+		Name:         metadata.GetName(),
+		Version:      modelVersion,
+		MaxBatchSize: config.GetConfig().GetMaxBatchSize(),
+		Inputs:       make([]TensorSpec, 0, len(metadata.GetInputs())),
+		Outputs:      make([]TensorSpec, 0, len(metadata.GetOutputs())),
+	}
+	for _, input := range metadata.GetInputs() {
+		schema.Inputs = append(schema.Inputs, TensorSpec{
+			Name:     input.GetName(),
+			Datatype: input.GetDatatype(),
+			Shape:    input.GetShape(),
+			Optional: optional[input.GetName()],
+		})
+	}
+	for _, output := range metadata.GetOutputs() {
+		schema.Outputs = append(schema.Outputs, TensorSpec{
+			Name:     output.GetName(),
+			Datatype: output.GetDatatype(),
+			Shape:    output.GetShape(),
+		})
+	}
+	return schema, nil
+}
+
+// BuildInferRequest matches inputs against the model schema and requests every output declared by the model.
+// Inputs are matched by name; an input without a name takes the schema input at the same position.
+// A missing datatype or shape is taken from the schema.
+func BuildInferRequest(schema *ModelSchema, inputs []Tensor) (*triton.ModelInferRequest, error) {
+	if len(inputs) > len(schema.Inputs) {
+		return nil, fmt.Errorf("model %s accepts %d inputs, got %d", schema.Name, len(schema.Inputs), len(inputs))
+	}
+
+	byName := make(map[string]Tensor, len(inputs))
+	for i, input := range inputs {
+		if input.Name == "" {
+			input.Name = schema.Inputs[i].Name
+		}
+		if _, ok := byName[input.Name]; ok {
+			return nil, fmt.Errorf("duplicate input %s", input.Name)
+		}
+		byName[input.Name] = input
+	}
+
+	request := &triton.ModelInferRequest{
+		ModelName:    schema.Name,
+		ModelVersion: schema.Version,
+	}
+	for _, spec := range schema.Inputs {
+		input, ok := byName[spec.Name]
+		if !ok {
+			if spec.Optional {
+				continue
+			}
+			return nil, fmt.Errorf("missing required input %s", spec.Name)
+		}
+		delete(byName, spec.Name)
+
+		if input.Datatype != "" && input.Datatype != spec.Datatype {
+			return nil, fmt.Errorf("input %s: expected datatype %s, got %s", spec.Name, spec.Datatype, input.Datatype)
+		}
+		shape := input.Shape
+		if len(shape) == 0 {
+			resolved, err := ResolveShape(spec.Shape, input.Contents.Len())
+			if err != nil {
+				return nil, fmt.Errorf("input %s: %w", spec.Name, err)
+			}
+			shape = resolved
+		} else if !matchShape(spec.Shape, shape) {
+			return nil, fmt.Errorf("input %s: shape %v does not match model shape %v", spec.Name, shape, spec.Shape)
+		}
+		raw, err := tensor.EncodeContents(spec.Datatype, shape, input.Contents)
+		if err != nil {
+			return nil, fmt.Errorf("input %s: %w", spec.Name, err)
+		}
+
+		request.Inputs = append(request.Inputs, &triton.ModelInferRequest_InferInputTensor{
+			Name:     spec.Name,
+			Datatype: spec.Datatype,
+			Shape:    shape,
+		})
+		request.RawInputContents = append(request.RawInputContents, raw)
+	}
+	for name := range byName {
+		return nil, fmt.Errorf("model %s has no input %s", schema.Name, name)
+	}
+
+	for _, spec := range schema.Outputs {
+		request.Outputs = append(request.Outputs, &triton.ModelInferRequest_InferRequestedOutputTensor{
+			Name: spec.Name,
+		})
+	}
+	return request, nil
+}
+
+// ParseInferResponse decodes raw output contents according to the datatype of each output.
+func ParseInferResponse(response *triton.ModelInferResponse) ([]Tensor, error) {
+	if len(response.GetRawOutputContents()) != len(response.GetOutputs()) {
+		return nil, fmt.Errorf("got %d raw outputs for %d output tensors", len(response.GetRawOutputContents()), len(response.GetOutputs()))
+	}
+
+	tensors := make([]Tensor, 0, len(response.GetOutputs()))
+	for i, output := range response.GetOutputs() {
+		contents, err := tensor.Decode(output.GetDatatype(), output.GetShape(), response.GetRawOutputContents()[i])
+		if err != nil {
+			return nil, fmt.Errorf("output %s: %w", output.GetName(), err)
+		}
+		tensors = append(tensors, Tensor{
+			Name:     output.GetName(),
+			Datatype: output.GetDatatype(),
+			Shape:    output.GetShape(),
+			Contents: contents,
+		})
+	}
+	return tensors, nil
+}
+
+// ResolveShape replaces variable-size (-1) dimensions so that the shape holds exactly count elements.
+// Every variable dimension except the last one is set to 1 (e.g. the batch dimension).
+func ResolveShape(shape []int64, count int) ([]int64, error) {
+	resolved := make([]int64, len(shape))
+	copy(resolved, shape)
+
+	last := -1
+	known := int64(1)
+	for i, dim := range resolved {
+		if dim < 0 {
+			if last >= 0 {
+				resolved[last] = 1
+			}
+			last = i
+			continue
+		}
+		known *= dim
+	}
+
+	if last >= 0 {
+		if known == 0 || int64(count)%known != 0 {
+			return nil, fmt.Errorf("%d elements do not fit shape %v", count, shape)
+		}
+		resolved[last] = int64(count) / known
+		return resolved, nil
+	}
+	if known != int64(count) {
+		return nil, fmt.Errorf("shape %v expects %d elements, got %d", shape, known, count)
+	}
+	return resolved, nil
+}
+
+func matchShape(spec []int64, shape []int64) bool {
+	if len(spec) != len(shape) {
+		return false
+	}
+	for i, dim := range spec {
+		if dim >= 0 && dim != shape[i] {
+			return false
+		}
+	}
+	return true
+}
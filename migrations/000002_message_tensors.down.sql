@@ -0,0 +1,20 @@
+alter table public.messages
+    add column if not exists input1  bytea  not null default ''::bytea,
+    add column if not exists input2  bytea  not null default ''::bytea,
+    add column if not exists results TEXT[] not null default '{}';
+
+-- Only inline INT32 inputs fit the old columns
+update public.messages
+set input1 = coalesce((select decode(t.val ->> 'data', 'base64')
+                       from jsonb_array_elements(inputs) with ordinality as t(val, ord)
+                       where t.ord = 1 and t.val ->> 'datatype' = 'INT32' and t.val ? 'data'), ''::bytea),
+    input2 = coalesce((select decode(t.val ->> 'data', 'base64')
+                       from jsonb_array_elements(inputs) with ordinality as t(val, ord)
+                       where t.ord = 2 and t.val ->> 'datatype' = 'INT32' and t.val ? 'data'), ''::bytea),
+    results = array(select o.val ->> 'name' from jsonb_array_elements(outputs) as o(val));
+
+drop table if exists public.message_blobs;
+
+alter table public.messages
+    drop column if exists inputs,
+    drop column if exists outputs;
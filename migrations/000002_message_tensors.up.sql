@@ -0,0 +1,46 @@
+alter table public.messages
+    add column if not exists inputs  jsonb not null default '[]'::jsonb,
+    add column if not exists outputs jsonb not null default '[]'::jsonb;
+
+-- Tensors that are too large to be stored inline in the descriptors
+create table if not exists public.message_blobs
+(
+    message_id int         not null
+        constraint fk_message
+            references public.messages (id) on delete cascade,
+    direction  varchar(6)  not null,
+    name       text        not null,
+    data       bytea       not null,
+    constraint message_blobs_pk
+        primary key (message_id, direction, name)
+);
+
+-- input1/input2 hold raw little-endian INT32 contents of the "simple" model inputs
+update public.messages
+set inputs = (select coalesce(jsonb_agg(jsonb_build_object(
+                                            'name', 'INPUT' || (t.ord - 1),
+                                            'datatype', 'INT32',
+                                            'shape', jsonb_build_array(1, length(t.raw) / 4),
+                                            'data', encode(t.raw, 'base64')) order by t.ord), '[]'::jsonb)
+              from unnest(array [input1, input2]) with ordinality as t(raw, ord)
+              where length(t.raw) > 0);
+
+-- results become a single BYTES tensor, elements are prefixed with their little-endian length
+update public.messages
+set outputs = jsonb_build_array(jsonb_build_object(
+        'name', 'results',
+        'datatype', 'BYTES',
+        'shape', jsonb_build_array(cardinality(results)),
+        'data', encode(coalesce((select string_agg(
+                                            set_byte(set_byte(set_byte(set_byte('\x00000000'::bytea,
+                                                                                0, octet_length(r.val) & 255),
+                                                                       1, (octet_length(r.val) >> 8) & 255),
+                                                              2, (octet_length(r.val) >> 16) & 255),
+                                                     3, (octet_length(r.val) >> 24) & 255)
+                                                || convert_to(r.val, 'UTF8'), ''::bytea order by r.ord)
+                                 from unnest(results) with ordinality as r(val, ord)), ''::bytea), 'base64')));
+
+alter table public.messages
+    drop column if exists input1,
+    drop column if exists input2,
+    drop column if exists results;
@@ -0,0 +1 @@
+drop index if exists public.messages_history_idx;
@@ -0,0 +1,3 @@
+-- Chat history is read page by page in (created_at, id) order
+create index if not exists messages_history_idx
+    on public.messages (user_id, model_id, created_at, id);
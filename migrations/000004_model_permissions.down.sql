@@ -0,0 +1,4 @@
+drop table if exists public.model_permissions;
+
+alter table public.models
+    drop column if exists public_permission;
@@ -0,0 +1,23 @@
+-- Everyone may read or infer a public model
+alter table public.models
+    add column if not exists public_permission varchar(10)
+        constraint models_public_permission_check
+            check (public_permission in ('read', 'infer'));
+
+create table if not exists public.model_permissions
+(
+    model_id   int         not null
+        constraint fk_model
+            references public.models (id) on delete cascade,
+    user_id    int         not null
+        constraint fk_user
+            references public.users (id) on delete cascade,
+    permission varchar(10) not null
+        constraint model_permissions_permission_check
+            check (permission in ('read', 'infer', 'manage')),
+    constraint model_permissions_pk
+        primary key (model_id, user_id)
+);
+
+create index if not exists model_permissions_user_idx
+    on public.model_permissions (user_id);
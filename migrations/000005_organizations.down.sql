@@ -0,0 +1,8 @@
+drop index if exists public.models_organization_idx;
+
+alter table public.models
+    drop column if exists organization_id;
+
+drop table if exists public.organization_members;
+
+drop table if exists public.organizations;
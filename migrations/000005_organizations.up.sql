@@ -0,0 +1,37 @@
+create table if not exists public.organizations
+(
+    id         serial
+        constraint organizations_pk
+            primary key,
+    name       varchar(50)               not null
+        constraint organizations_name_key
+            unique,
+    created_at timestamptz default now() not null
+);
+
+create table if not exists public.organization_members
+(
+    organization_id int         not null
+        constraint fk_organization
+            references public.organizations (id) on delete cascade,
+    user_id         int         not null
+        constraint fk_user
+            references public.users (id) on delete cascade,
+    role            varchar(10) not null
+        constraint organization_members_role_check
+            check (role in ('owner', 'maintainer', 'member')),
+    constraint organization_members_pk
+        primary key (organization_id, user_id)
+);
+
+create index if not exists organization_members_user_idx
+    on public.organization_members (user_id);
+
+-- Models of an organization belong to it rather than to the member who uploaded them
+alter table public.models
+    add column if not exists organization_id int
+        constraint fk_organization
+            references public.organizations (id) on delete cascade;
+
+create index if not exists models_organization_idx
+    on public.models (organization_id);
@@ -0,0 +1,3 @@
+drop table if exists public.revoked_tokens;
+
+drop table if exists public.refresh_tokens;
@@ -0,0 +1,30 @@
+-- Refresh tokens are stored as sha256 hashes. Every refresh replaces the token with a new one of the same family,
+-- presenting an already replaced token revokes the whole family.
+create table if not exists public.refresh_tokens
+(
+    id         serial
+        constraint refresh_tokens_pk
+            primary key,
+    user_id    int                       not null
+        constraint fk_user
+            references public.users (id) on delete cascade,
+    token_hash char(64)                  not null
+        constraint refresh_tokens_token_hash_key
+            unique,
+    family_id  uuid                      not null,
+    expires_at timestamptz               not null,
+    created_at timestamptz default now() not null,
+    revoked_at timestamptz
+);
+
+create index if not exists refresh_tokens_family_idx
+    on public.refresh_tokens (family_id);
+
+-- Access tokens revoked before their expiry, by jti
+create table if not exists public.revoked_tokens
+(
+    jti        uuid        not null
+        constraint revoked_tokens_pk
+            primary key,
+    expires_at timestamptz not null
+);
@@ -0,0 +1,25 @@
+-- Keys are stored as sha256 hashes, prefix is the beginning of the key shown to tell keys apart
+create table if not exists public.api_keys
+(
+    id           serial
+        constraint api_keys_pk
+            primary key,
+    user_id      int                       not null
+        constraint fk_user
+            references public.users (id) on delete cascade,
+    name         varchar(50)               not null,
+    prefix       varchar(16)               not null,
+    key_hash     char(64)                  not null
+        constraint api_keys_key_hash_key
+            unique,
+    scopes       text[]                    not null,
+    -- NULL gives access to every model of the user
+    model_ids    int[],
+    expires_at   timestamptz,
+    created_at   timestamptz default now() not null,
+    last_used_at timestamptz,
+    revoked_at   timestamptz
+);
+
+create index if not exists api_keys_user_idx
+    on public.api_keys (user_id);
@@ -0,0 +1,11 @@
+-- Private keys are PKCS #8 DER, a key is published until every token signed with it has expired
+create table if not exists public.signing_keys
+(
+    kid         varchar(64)               not null
+        constraint signing_keys_pk
+            primary key,
+    algorithm   varchar(16)               not null,
+    private_key bytea                     not null,
+    created_at  timestamptz default now() not null,
+    expires_at  timestamptz               not null
+);
@@ -0,0 +1,2 @@
+drop table if exists public.oidc_states;
+drop table if exists public.user_identities;
@@ -0,0 +1,27 @@
+-- Accounts at external OpenID providers, a user signing in with one for the first time is provisioned
+create table if not exists public.user_identities
+(
+    id         serial
+        constraint user_identities_pk
+            primary key,
+    user_id    int                       not null
+        constraint fk_user
+            references public.users (id) on delete cascade,
+    issuer     varchar(255)              not null,
+    subject    varchar(255)              not null,
+    email      text                      not null,
+    created_at timestamptz default now() not null,
+    constraint user_identities_issuer_subject_key
+        unique (issuer, subject)
+);
+
+-- Pending authorization requests, removed when the provider redirects back
+create table if not exists public.oidc_states
+(
+    state         varchar(64) not null
+        constraint oidc_states_pk
+            primary key,
+    nonce         varchar(64) not null,
+    code_verifier varchar(128) not null,
+    expires_at    timestamptz not null
+);
@@ -0,0 +1,3 @@
+alter table public.users
+    drop column if exists disabled_at,
+    drop column if exists roles;
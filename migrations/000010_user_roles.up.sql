@@ -0,0 +1,4 @@
+-- Roles are embedded in access tokens, disabled users can neither log in nor use their tokens and keys
+alter table public.users
+    add column if not exists roles       text[] default '{user}' not null,
+    add column if not exists disabled_at timestamptz;
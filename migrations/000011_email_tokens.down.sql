@@ -0,0 +1,4 @@
+drop table if exists public.email_tokens;
+
+alter table public.users
+    drop column if exists email_verified_at;
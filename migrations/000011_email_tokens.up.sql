@@ -0,0 +1,20 @@
+-- Verified when the user follows the link sent to the email
+alter table public.users
+    add column if not exists email_verified_at timestamptz;
+
+-- Single-use tokens sent by email to verify the address or reset the password, only their hashes are stored
+create table if not exists public.email_tokens
+(
+    token_hash varchar(64)               not null
+        constraint email_tokens_pk
+            primary key,
+    user_id    int                       not null
+        constraint fk_user
+            references public.users (id) on delete cascade,
+    purpose    varchar(32)               not null,
+    email      text                      not null,
+    created_at timestamptz default now() not null,
+    expires_at timestamptz               not null
+);
+
+create index if not exists email_tokens_user_id_idx on public.email_tokens (user_id, purpose);
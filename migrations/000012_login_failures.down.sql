@@ -0,0 +1,4 @@
+drop table if exists public.login_failures;
+
+alter table public.users
+    drop column if exists last_login_at;
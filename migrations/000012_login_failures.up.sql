@@ -0,0 +1,21 @@
+-- Failed logins, kept as an audit record and counted to slow down password guessing. Failures of an account
+-- made before its last successful login are not counted.
+alter table public.users
+    add column if not exists last_login_at timestamptz;
+
+create table if not exists public.login_failures
+(
+    id         bigserial
+        constraint login_failures_pk
+            primary key,
+    username   text                      not null,
+    user_id    int
+        constraint fk_user
+            references public.users (id) on delete set null,
+    client_ip  varchar(64)               not null default '',
+    reason     varchar(32)               not null,
+    created_at timestamptz default now() not null
+);
+
+create index if not exists login_failures_username_idx on public.login_failures (username, created_at);
+create index if not exists login_failures_client_ip_idx on public.login_failures (client_ip, created_at);
@@ -0,0 +1,7 @@
+drop table if exists public.login_challenges;
+drop table if exists public.recovery_codes;
+
+alter table public.users
+    drop column if exists totp_secret,
+    drop column if exists totp_enabled_at,
+    drop column if exists totp_last_step;
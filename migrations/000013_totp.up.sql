@@ -0,0 +1,35 @@
+-- TOTP two-factor authentication. The secret is stored on enrolment and takes effect once the user confirms
+-- it with a code, totp_last_step keeps a code from being used twice.
+alter table public.users
+    add column if not exists totp_secret     text,
+    add column if not exists totp_enabled_at timestamptz,
+    add column if not exists totp_last_step  bigint;
+
+-- Single-use codes to log in without the authenticator, only their hashes are stored
+create table if not exists public.recovery_codes
+(
+    id        serial
+        constraint recovery_codes_pk
+            primary key,
+    user_id   int      not null
+        constraint fk_user
+            references public.users (id) on delete cascade,
+    code_hash char(64) not null,
+    used_at   timestamptz
+);
+
+create index if not exists recovery_codes_user_id_idx on public.recovery_codes (user_id);
+
+-- Logins waiting for the second factor after the password has been checked
+create table if not exists public.login_challenges
+(
+    token_hash char(64)                  not null
+        constraint login_challenges_pk
+            primary key,
+    user_id    int                       not null
+        constraint fk_user
+            references public.users (id) on delete cascade,
+    attempts   int         default 0     not null,
+    created_at timestamptz default now() not null,
+    expires_at timestamptz               not null
+);
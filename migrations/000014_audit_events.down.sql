@@ -0,0 +1,3 @@
+drop table if exists public.audit_events;
+
+drop function if exists public.audit_events_append_only();
@@ -0,0 +1,38 @@
+-- Security audit log written by all services. Events outlive the users they name, so actor_id is not a
+-- foreign key, and the table is append-only: updates, deletes and truncation are rejected.
+create table if not exists public.audit_events
+(
+    id         bigserial
+        constraint audit_events_pk
+            primary key,
+    actor_id   int,
+    action     varchar(64)               not null,
+    target     text                      not null default '',
+    request_id varchar(64)               not null default '',
+    client_ip  varchar(64)               not null default '',
+    outcome    varchar(16)               not null,
+    created_at timestamptz default now() not null
+);
+
+create index if not exists audit_events_actor_idx on public.audit_events (actor_id, id);
+create index if not exists audit_events_action_idx on public.audit_events (action, id);
+create index if not exists audit_events_created_at_idx on public.audit_events (created_at);
+
+create or replace function public.audit_events_append_only() returns trigger as
+$$
+begin
+    raise exception 'audit_events is append-only';
+end;
+$$ language plpgsql;
+
+create trigger audit_events_no_change
+    before update or delete
+    on public.audit_events
+    for each row
+execute function public.audit_events_append_only();
+
+create trigger audit_events_no_truncate
+    before truncate
+    on public.audit_events
+    for each statement
+execute function public.audit_events_append_only();
@@ -0,0 +1,21 @@
+-- Resumable uploads of model files. The content is staged on disk by the model service, the number of bytes
+-- received is the size of the staged file.
+create table if not exists public.uploads
+(
+    id         serial
+        constraint uploads_pk
+            primary key,
+    user_id    int                       not null
+        constraint fk_user
+            references public.users (id) on delete cascade,
+    model_id   int                       not null
+        constraint fk_model
+            references public.models (id) on delete cascade,
+    filename   text                      not null,
+    size       bigint                    not null,
+    created_at timestamptz default now() not null,
+    expires_at timestamptz               not null
+);
+
+create index if not exists uploads_expires_at_idx
+    on public.uploads (expires_at);
@@ -0,0 +1,2 @@
+alter table public.models
+    drop constraint if exists models_name_key;
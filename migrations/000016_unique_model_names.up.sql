@@ -0,0 +1,12 @@
+-- A model is stored in the model repository and loaded in Triton under its name, so names have to be unique.
+-- Models sharing a name with an older one get their id appended, their files have to be uploaded again.
+update public.models
+set name = models.name || '-' || models.id
+where exists (select 1
+              from public.models older
+              where older.name = models.name
+                and older.id < models.id);
+
+alter table public.models
+    add constraint models_name_key
+        unique (name);
@@ -0,0 +1,3 @@
+-- Access tokens issued before tokens_valid_after are rejected, a password change ends every session at once
+alter table public.users
+    add column if not exists tokens_valid_after timestamptz;
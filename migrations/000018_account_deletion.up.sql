@@ -0,0 +1,4 @@
+-- Accounts locked for deletion by the auth service, the model service deletes them with their data and retries
+-- until it succeeds
+alter table public.users
+    add column if not exists deletion_requested_at timestamptz;
@@ -0,0 +1,428 @@
+package tensor
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// Triton datatypes, see https://github.com/triton-inference-server/server/blob/main/docs/user_guide/model_configuration.md#datatypes
+const (
+	Bool   = "BOOL"
+	Uint8  = "UINT8"
+	Uint16 = "UINT16"
+	Uint32 = "UINT32"
+	Uint64 = "UINT64"
+	Int8   = "INT8"
+	Int16  = "INT16"
+	Int32  = "INT32"
+	Int64  = "INT64"
+	Fp16   = "FP16"
+	Bf16   = "BF16"
+	Fp32   = "FP32"
+	Fp64   = "FP64"
+	Bytes  = "BYTES"
+)
+
+// Contents holds tensor elements widened to the largest Go type of their kind.
+// Only the slice matching the datatype is populated.
+type Contents struct {
+	Bools  []bool
+	Ints   []int64
+	Uints  []uint64
+	Floats []float64
+	Bytes  [][]byte
+}
+
+// Len returns the number of elements.
+func (c Contents) Len() int {
+	switch {
+	case c.Bools != nil:
+		return len(c.Bools)
+	case c.Ints != nil:
+		return len(c.Ints)
+	case c.Uints != nil:
+		return len(c.Uints)
+	case c.Floats != nil:
+		return len(c.Floats)
+	case c.Bytes != nil:
+		return len(c.Bytes)
+	}
+	return 0
+}
+
+// Values returns the populated slice of elements.
+func (c Contents) Values() interface{} {
+	switch {
+	case c.Bools != nil:
+		return c.Bools
+	case c.Ints != nil:
+		return c.Ints
+	case c.Uints != nil:
+		return c.Uints
+	case c.Floats != nil:
+		return c.Floats
+	case c.Bytes != nil:
+		strs := make([]string, len(c.Bytes))
+		for i, b := range c.Bytes {
+			strs[i] = string(b)
+		}
+		return strs
+	}
+	return []interface{}{}
+}
+
+// IsValid reports whether datatype is a known Triton datatype.
+func IsValid(datatype string) bool {
+	_, err := ElementSize(datatype)
+	return err == nil
+}
+
+// ElementSize returns the size in bytes of one element, 0 for variable-size BYTES.
+func ElementSize(datatype string) (int, error) {
+	switch datatype {
+	case Bool, Uint8, Int8:
+		return 1, nil
+	case Uint16, Int16, Fp16, Bf16:
+		return 2, nil
+	case Uint32, Int32, Fp32:
+		return 4, nil
+	case Uint64, Int64, Fp64:
+		return 8, nil
+	case Bytes:
+		return 0, nil
+	}
+	return 0, fmt.Errorf("unsupported datatype %s", datatype)
+}
+
+// ElementCount returns the number of elements a tensor of the given shape holds.
+func ElementCount(shape []int64) (int64, error) {
+	count := int64(1)
+	for _, dim := range shape {
+		if dim < 0 {
+			return 0, fmt.Errorf("shape %v has variable-size dimension", shape)
+		}
+		count *= dim
+	}
+	return count, nil
+}
+
+// ValidateShape checks that the shape holds exactly count elements.
+func ValidateShape(shape []int64, count int) error {
+	expected, err := ElementCount(shape)
+	if err != nil {
+		return err
+	}
+	if expected != int64(count) {
+		return fmt.Errorf("shape %v expects %d elements, got %d", shape, expected, count)
+	}
+	return nil
+}
+
+// ContentsOf widens a slice of Go values ([]bool, []intN, []uintN, []floatN, []string or [][]byte).
+func ContentsOf(values interface{}) (Contents, error) {
+	var c Contents
+	switch v := values.(type) {
+	case []bool:
+		c.Bools = v
+	case []int8:
+		c.Ints = widen(v, func(x int8) int64 { return int64(x) })
+	case []int16:
+		c.Ints = widen(v, func(x int16) int64 { return int64(x) })
+	case []int32:
+		c.Ints = widen(v, func(x int32) int64 { return int64(x) })
+	case []int64:
+		c.Ints = v
+	case []int:
+		c.Ints = widen(v, func(x int) int64 { return int64(x) })
+	case []uint8:
+		c.Uints = widen(v, func(x uint8) uint64 { return uint64(x) })
+	case []uint16:
+		c.Uints = widen(v, func(x uint16) uint64 { return uint64(x) })
+	case []uint32:
+		c.Uints = widen(v, func(x uint32) uint64 { return uint64(x) })
+	case []uint64:
+		c.Uints = v
+	case []float32:
+		c.Floats = widen(v, func(x float32) float64 { return float64(x) })
+	case []float64:
+		c.Floats = v
+	case []string:
+		c.Bytes = widen(v, func(x string) []byte { return []byte(x) })
+	case [][]byte:
+		c.Bytes = v
+	default:
+		return Contents{}, fmt.Errorf("unsupported values type %T", values)
+	}
+	return c, nil
+}
+
+// Encode converts Go values into raw contents of the given datatype (Little Endian)
+// after checking them against the shape.
+func Encode(datatype string, shape []int64, values interface{}) ([]byte, error) {
+	c, err := ContentsOf(values)
+	if err != nil {
+		return nil, err
+	}
+	return EncodeContents(datatype, shape, c)
+}
+
+// EncodeContents converts widened contents into raw contents of the given datatype (Little Endian).
+// Integer values are range-checked against the target datatype; BYTES elements are length-prefixed.
+func EncodeContents(datatype string, shape []int64, c Contents) ([]byte, error) {
+	size, err := ElementSize(datatype)
+	if err != nil {
+		return nil, err
+	}
+	n := c.Len()
+	if err = ValidateShape(shape, n); err != nil {
+		return nil, err
+	}
+
+	if datatype == Bytes {
+		if n > 0 && c.Bytes == nil {
+			return nil, fmt.Errorf("%s tensor requires string or binary values", datatype)
+		}
+		total := 0
+		for _, b := range c.Bytes {
+			total += 4 + len(b)
+		}
+		raw := make([]byte, 0, total)
+		for _, b := range c.Bytes {
+			raw = binary.LittleEndian.AppendUint32(raw, uint32(len(b)))
+			raw = append(raw, b...)
+		}
+		return raw, nil
+	}
+
+	raw := make([]byte, size*n)
+	for i := 0; i < n; i++ {
+		if err = putElement(datatype, raw[i*size:(i+1)*size], c, i); err != nil {
+			return nil, fmt.Errorf("element %d: %w", i, err)
+		}
+	}
+	return raw, nil
+}
+
+// Decode converts raw contents of the given datatype (Little Endian) into widened contents
+// after checking their size against the shape.
+func Decode(datatype string, shape []int64, raw []byte) (Contents, error) {
+	size, err := ElementSize(datatype)
+	if err != nil {
+		return Contents{}, err
+	}
+
+	var c Contents
+	if datatype == Bytes {
+		c.Bytes = make([][]byte, 0)
+		for offset := 0; offset < len(raw); {
+			if offset+4 > len(raw) {
+				return Contents{}, fmt.Errorf("truncated length prefix at byte %d", offset)
+			}
+			length := int(binary.LittleEndian.Uint32(raw[offset:]))
+			offset += 4
+			if offset+length > len(raw) {
+				return Contents{}, fmt.Errorf("element of %d bytes exceeds contents at byte %d", length, offset)
+			}
+			c.Bytes = append(c.Bytes, raw[offset:offset+length])
+			offset += length
+		}
+		if err = ValidateShape(shape, len(c.Bytes)); err != nil {
+			return Contents{}, err
+		}
+		return c, nil
+	}
+
+	if len(raw)%size != 0 {
+		return Contents{}, fmt.Errorf("%d bytes is not a multiple of %s element size %d", len(raw), datatype, size)
+	}
+	n := len(raw) / size
+	if err = ValidateShape(shape, n); err != nil {
+		return Contents{}, err
+	}
+
+	switch datatype {
+	case Bool:
+		c.Bools = make([]bool, n)
+	case Uint8, Uint16, Uint32, Uint64:
+		c.Uints = make([]uint64, n)
+	case Int8, Int16, Int32, Int64:
+		c.Ints = make([]int64, n)
+	case Fp16, Bf16, Fp32, Fp64:
+		c.Floats = make([]float64, n)
+	}
+
+	for i := 0; i < n; i++ {
+		b := raw[i*size : (i+1)*size]
+		switch datatype {
+		case Bool:
+			c.Bools[i] = b[0] != 0
+		case Uint8:
+			c.Uints[i] = uint64(b[0])
+		case Uint16:
+			c.Uints[i] = uint64(binary.LittleEndian.Uint16(b))
+		case Uint32:
+			c.Uints[i] = uint64(binary.LittleEndian.Uint32(b))
+		case Uint64:
+			c.Uints[i] = binary.LittleEndian.Uint64(b)
+		case Int8:
+			c.Ints[i] = int64(int8(b[0]))
+		case Int16:
+			c.Ints[i] = int64(int16(binary.LittleEndian.Uint16(b)))
+		case Int32:
+			c.Ints[i] = int64(int32(binary.LittleEndian.Uint32(b)))
+		case Int64:
+			c.Ints[i] = int64(binary.LittleEndian.Uint64(b))
+		case Fp16:
+			c.Floats[i] = float64(Float16ToFloat32(binary.LittleEndian.Uint16(b)))
+		case Bf16:
+			c.Floats[i] = float64(BFloat16ToFloat32(binary.LittleEndian.Uint16(b)))
+		case Fp32:
+			c.Floats[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+		case Fp64:
+			c.Floats[i] = math.Float64frombits(binary.LittleEndian.Uint64(b))
+		}
+	}
+	return c, nil
+}
+
+func putElement(datatype string, b []byte, c Contents, i int) error {
+	switch datatype {
+	case Bool:
+		v, err := c.boolAt(i)
+		if err != nil {
+			return err
+		}
+		if v {
+			b[0] = 1
+		}
+	case Uint8, Uint16, Uint32, Uint64:
+		v, err := c.uintAt(i, datatype)
+		if err != nil {
+			return err
+		}
+		switch datatype {
+		case Uint8:
+			b[0] = byte(v)
+		case Uint16:
+			binary.LittleEndian.PutUint16(b, uint16(v))
+		case Uint32:
+			binary.LittleEndian.PutUint32(b, uint32(v))
+		case Uint64:
+			binary.LittleEndian.PutUint64(b, v)
+		}
+	case Int8, Int16, Int32, Int64:
+		v, err := c.intAt(i, datatype)
+		if err != nil {
+			return err
+		}
+		switch datatype {
+		case Int8:
+			b[0] = byte(int8(v))
+		case Int16:
+			binary.LittleEndian.PutUint16(b, uint16(int16(v)))
+		case Int32:
+			binary.LittleEndian.PutUint32(b, uint32(int32(v)))
+		case Int64:
+			binary.LittleEndian.PutUint64(b, uint64(v))
+		}
+	case Fp16, Bf16, Fp32, Fp64:
+		v, err := c.floatAt(i)
+		if err != nil {
+			return err
+		}
+		switch datatype {
+		case Fp16:
+			binary.LittleEndian.PutUint16(b, Float32ToFloat16(float32(v)))
+		case Bf16:
+			binary.LittleEndian.PutUint16(b, Float32ToBFloat16(float32(v)))
+		case Fp32:
+			binary.LittleEndian.PutUint32(b, math.Float32bits(float32(v)))
+		case Fp64:
+			binary.LittleEndian.PutUint64(b, math.Float64bits(v))
+		}
+	}
+	return nil
+}
+
+func (c Contents) boolAt(i int) (bool, error) {
+	switch {
+	case c.Bools != nil:
+		return c.Bools[i], nil
+	case c.Ints != nil:
+		return c.Ints[i] != 0, nil
+	case c.Uints != nil:
+		return c.Uints[i] != 0, nil
+	}
+	return false, fmt.Errorf("%s requires boolean or integer values", Bool)
+}
+
+func (c Contents) intAt(i int, datatype string) (int64, error) {
+	var v int64
+	switch {
+	case c.Ints != nil:
+		v = c.Ints[i]
+	case c.Uints != nil:
+		if c.Uints[i] > math.MaxInt64 {
+			return 0, fmt.Errorf("value %d overflows %s", c.Uints[i], datatype)
+		}
+		v = int64(c.Uints[i])
+	case c.Bools != nil:
+		if c.Bools[i] {
+			v = 1
+		}
+	default:
+		return 0, fmt.Errorf("%s requires integer values", datatype)
+	}
+
+	bits := map[string]uint{Int8: 8, Int16: 16, Int32: 32, Int64: 64}[datatype]
+	if bits < 64 && (v < -(1<<(bits-1)) || v > 1<<(bits-1)-1) {
+		return 0, fmt.Errorf("value %d overflows %s", v, datatype)
+	}
+	return v, nil
+}
+
+func (c Contents) uintAt(i int, datatype string) (uint64, error) {
+	var v uint64
+	switch {
+	case c.Uints != nil:
+		v = c.Uints[i]
+	case c.Ints != nil:
+		if c.Ints[i] < 0 {
+			return 0, fmt.Errorf("value %d overflows %s", c.Ints[i], datatype)
+		}
+		v = uint64(c.Ints[i])
+	case c.Bools != nil:
+		if c.Bools[i] {
+			v = 1
+		}
+	default:
+		return 0, fmt.Errorf("%s requires integer values", datatype)
+	}
+
+	bits := map[string]uint{Uint8: 8, Uint16: 16, Uint32: 32, Uint64: 64}[datatype]
+	if bits < 64 && v > 1<<bits-1 {
+		return 0, fmt.Errorf("value %d overflows %s", v, datatype)
+	}
+	return v, nil
+}
+
+func (c Contents) floatAt(i int) (float64, error) {
+	switch {
+	case c.Floats != nil:
+		return c.Floats[i], nil
+	case c.Ints != nil:
+		return float64(c.Ints[i]), nil
+	case c.Uints != nil:
+		return float64(c.Uints[i]), nil
+	}
+	return 0, fmt.Errorf("floating point datatype requires numeric values")
+}
+
+func widen[T, R any](values []T, convert func(T) R) []R {
+	result := make([]R, len(values))
+	for i, v := range values {
+		result[i] = convert(v)
+	}
+	return result
+}
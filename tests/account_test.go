@@ -0,0 +1,302 @@
+package tests
+
+import (
+	"context"
+	"database/sql/driver"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"house-of-neural-networks/internal/models"
+	"house-of-neural-networks/internal/modelstore"
+	"house-of-neural-networks/internal/principal"
+	"house-of-neural-networks/internal/repository"
+	"house-of-neural-networks/internal/service"
+	"house-of-neural-networks/internal/transport/grpc/model"
+	"house-of-neural-networks/internal/triton"
+	client "house-of-neural-networks/pkg/api/auth"
+	modelpb "house-of-neural-networks/pkg/api/model"
+	tritonpb "house-of-neural-networks/pkg/api/triton2"
+	"house-of-neural-networks/pkg/db/postgres"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+)
+
+// expectPasswordCheck expects the lookups of checkPassword for user 42 with the password.
+func expectPasswordCheck(mock sqlmock.Sqlmock, password []byte) {
+	mock.ExpectQuery(regexp.QuoteMeta(getUserByIDQuery)).
+		WithArgs(42).
+		WillReturnRows(userRows(nil))
+	mock.ExpectQuery(regexp.QuoteMeta(getUserQuery)).
+		WithArgs("test user").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "password", "roles", "disabled_at", "last_login_at", "totp_enabled_at"}).
+			AddRow(42, "test user", password, "{user}", nil, nil, nil))
+	mock.ExpectQuery(regexp.QuoteMeta(countLoginFailuresQuery)).
+		WillReturnRows(loginFailureRows(0, nil, 0, nil))
+}
+
+func TestGetProfile(t *testing.T) {
+	authService, mock := newAuthService(t)
+	lastLoginAt := time.Now().Add(-time.Hour)
+
+	mock.ExpectQuery(regexp.QuoteMeta(getUserByIDQuery)).
+		WithArgs(42).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "email", "roles", "disabled_at", "email_verified_at", "last_login_at", "totp_enabled_at"}).
+			AddRow(42, "test user", "test@example.com", "{user,admin}", nil, time.Now(), lastLoginAt, nil))
+
+	t.Run("Success", func(t *testing.T) {
+		resp, err := authService.GetProfile(userContext(42), &client.GetProfileRequest{})
+		require.NoError(t, err)
+		assert.Equal(t, "test user", resp.GetUsername())
+		assert.True(t, resp.GetEmailVerified())
+		assert.False(t, resp.GetTotpEnabled())
+		assert.Equal(t, []string{"user", "admin"}, resp.GetRoles())
+		assert.WithinDuration(t, lastLoginAt, resp.GetLastLoginAt().AsTime(), time.Millisecond)
+	})
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		_, err := authService.GetProfile(context.Background(), &client.GetProfileRequest{})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateProfile(t *testing.T) {
+	authService, mock := newAuthService(t)
+	const updateQuery = "UPDATE users SET username = $1, email = $2, email_verified_at = CASE WHEN email = $3 THEN email_verified_at END WHERE id = $4"
+
+	t.Run("New email", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(getUserByIDQuery)).
+			WithArgs(42).
+			WillReturnRows(userRows(nil))
+		mock.ExpectExec(regexp.QuoteMeta(updateQuery)).
+			WithArgs("test user", "new@example.com", "new@example.com", 42).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		resp, err := authService.UpdateProfile(userContext(42), &client.UpdateProfileRequest{Email: " new@example.com "})
+		require.NoError(t, err)
+		assert.Equal(t, "new@example.com", resp.GetEmail())
+		assert.False(t, resp.GetEmailVerified())
+	})
+
+	t.Run("Username taken", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(getUserByIDQuery)).
+			WithArgs(42).
+			WillReturnRows(userRows(nil))
+		mock.ExpectExec(regexp.QuoteMeta(updateQuery)).
+			WithArgs("john", "test@example.com", "test@example.com", 42).
+			WillReturnError(&pq.Error{Code: "23505"})
+
+		_, err := authService.UpdateProfile(userContext(42), &client.UpdateProfileRequest{Username: "john"})
+		assert.Equal(t, codes.AlreadyExists, status.Code(err))
+	})
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestChangePassword(t *testing.T) {
+	authService, mock := newAuthService(t)
+	password, _ := bcrypt.GenerateFromPassword([]byte("old password"), bcrypt.DefaultCost)
+
+	t.Run("Wrong old password", func(t *testing.T) {
+		expectPasswordCheck(mock, password)
+		mock.ExpectExec(regexp.QuoteMeta(recordLoginFailureQuery)).
+			WithArgs("test user", 42, "", models.LoginWrongPassword).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		_, err := authService.ChangePassword(userContext(42), &client.ChangePasswordRequest{OldPassword: "wrong", NewPassword: "new password"})
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		var validAfter time.Time
+		expectPasswordCheck(mock, password)
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta("UPDATE users SET password = $1, tokens_valid_after = $2 WHERE id = $3")).
+			WithArgs(sqlmock.AnyArg(), capturedTime{&validAfter}, 42).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta("UPDATE refresh_tokens SET revoked_at = now() WHERE revoked_at IS NULL AND user_id = $1")).
+			WithArgs(42).
+			WillReturnResult(sqlmock.NewResult(0, 3))
+		mock.ExpectCommit()
+		mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO refresh_tokens (user_id,token_hash,family_id,expires_at)`)).
+			WithArgs(42, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec(regexp.QuoteMeta(setLastLoginQuery)).
+			WithArgs(42).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		issuedBefore := time.Now().Add(-time.Second)
+		resp, err := authService.ChangePassword(userContext(42), &client.ChangePasswordRequest{OldPassword: "old password", NewPassword: "new password"})
+		require.NoError(t, err)
+		assert.NotEmpty(t, resp.GetRefreshToken())
+
+		// Access tokens issued before the change are rejected from now on, the new one is not
+		claims := &jwt.RegisteredClaims{}
+		_, _, err = jwt.NewParser().ParseUnverified(resp.GetJwt(), claims)
+		require.NoError(t, err)
+		assert.False(t, claims.IssuedAt.Time.Before(validAfter))
+		assert.True(t, issuedBefore.Truncate(time.Second).Before(validAfter))
+	})
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteAccount(t *testing.T) {
+	authService, mock := newAuthService(t)
+	password, _ := bcrypt.GenerateFromPassword([]byte("123"), bcrypt.DefaultCost)
+
+	expectPasswordCheck(mock, password)
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE users SET disabled_at = COALESCE(disabled_at, now()), deletion_requested_at = COALESCE(deletion_requested_at, now()) WHERE id = $1")).
+		WithArgs(42).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE refresh_tokens SET revoked_at = now()")).
+		WithArgs(42).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	t.Run("Locks the account for deletion", func(t *testing.T) {
+		_, err := authService.DeleteAccount(userContext(42), &client.DeleteAccountRequest{Password: "123"})
+		require.NoError(t, err)
+	})
+
+	t.Run("API key", func(t *testing.T) {
+		_, err := authService.DeleteAccount(principal.NewContext(context.Background(), principal.Principal{UserID: 42, Scopes: []string{"models:read"}}), &client.DeleteAccountRequest{Password: "123"})
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteAccountData(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	db := sqlx.NewDb(mockDB, "sqlmock")
+	repo := repository.NewModelRepository(&postgres.DB{Db: db})
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "simple", "1"), os.ModePerm))
+	serv := service.NewModelService(repo, &triton.TritonClient{Client: unloadedTriton{}})
+	serv.Store = modelstore.NewLocalStore(root)
+	modelService := model.NewModelService(context.Background(), serv)
+
+	expectDeletion := func() {
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT id, name, user_id, organization_id FROM models WHERE (organization_id IS NULL AND user_id = $1 OR organization_id IN (SELECT organization_id FROM organization_members WHERE user_id = $2")).
+			WithArgs(42, 42, 42).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "user_id", "organization_id"}).AddRow(7, "simple", 42, nil))
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta("DELETE FROM messages WHERE (user_id = $1 OR model_id IN ($2))")).
+			WithArgs(42, 7).
+			WillReturnResult(sqlmock.NewResult(0, 12))
+		mock.ExpectExec(regexp.QuoteMeta("DELETE FROM models WHERE id IN ($1)")).
+			WithArgs(7).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta("DELETE FROM organizations WHERE id IN (SELECT organization_id FROM organization_members WHERE user_id = $1")).
+			WithArgs(42, 42).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(regexp.QuoteMeta("UPDATE organization_members SET role = $1 WHERE (organization_id, user_id) IN (SELECT DISTINCT ON")).
+			WithArgs(models.OrgRoleOwner, 42, 42, 42).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta("UPDATE models SET user_id = (SELECT owners.user_id FROM organization_members owners")).
+			WithArgs(42, 42).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		expectDeletion()
+		mock.ExpectExec(regexp.QuoteMeta("DELETE FROM users WHERE id = $1 AND deletion_requested_at IS NOT NULL")).
+			WithArgs(42).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		resp, err := modelService.DeleteAccountData(userContext(42), &modelpb.DeleteAccountDataRequest{})
+		require.NoError(t, err)
+		assert.Equal(t, int32(1), resp.GetDeletedModels())
+		assert.NoDirExists(t, filepath.Join(root, "simple"))
+	})
+
+	t.Run("Deletion not requested", func(t *testing.T) {
+		expectDeletion()
+		mock.ExpectExec(regexp.QuoteMeta("DELETE FROM users WHERE id = $1 AND deletion_requested_at IS NOT NULL")).
+			WithArgs(42).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		_, err := modelService.DeleteAccountData(userContext(42), &modelpb.DeleteAccountDataRequest{})
+		assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	})
+
+	t.Run("Retry", func(t *testing.T) {
+		require.NoError(t, os.MkdirAll(filepath.Join(root, "simple", "1"), os.ModePerm))
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT id FROM users WHERE deletion_requested_at IS NOT NULL ORDER BY deletion_requested_at")).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(42))
+		expectDeletion()
+		mock.ExpectExec(regexp.QuoteMeta("DELETE FROM users WHERE id = $1 AND deletion_requested_at IS NOT NULL")).
+			WithArgs(42).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		// Nobody is signed in, the accounts locked for deletion are deleted in the background
+		require.NoError(t, serv.DeleteRequestedAccounts(context.Background()))
+		assert.NoDirExists(t, filepath.Join(root, "simple"))
+	})
+
+	t.Run("Triton unavailable", func(t *testing.T) {
+		serv.TritonClient = &triton.TritonClient{Client: unavailableTriton{}}
+		defer func() { serv.TritonClient = &triton.TritonClient{Client: unloadedTriton{}} }()
+		require.NoError(t, os.MkdirAll(filepath.Join(root, "simple", "1"), os.ModePerm))
+		expectDeletion()
+		mock.ExpectExec(regexp.QuoteMeta("DELETE FROM users WHERE id = $1 AND deletion_requested_at IS NOT NULL")).
+			WithArgs(42).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		// The rows are gone, so the files cannot wait for Triton to come back
+		_, err := modelService.DeleteAccountData(userContext(42), &modelpb.DeleteAccountDataRequest{})
+		require.NoError(t, err)
+		assert.NoDirExists(t, filepath.Join(root, "simple"))
+	})
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+// capturedTime matches any time and stores it.
+type capturedTime struct {
+	value *time.Time
+}
+
+func (c capturedTime) Match(v driver.Value) bool {
+	t, ok := v.(time.Time)
+	*c.value = t
+	return ok
+}
+
+// unloadedTriton is a Triton server none of the models are loaded in.
+type unloadedTriton struct {
+	tritonpb.GRPCInferenceServiceClient
+}
+
+func (unloadedTriton) ModelReady(context.Context, *tritonpb.ModelReadyRequest, ...grpc.CallOption) (*tritonpb.ModelReadyResponse, error) {
+	return &tritonpb.ModelReadyResponse{Ready: false}, nil
+}
+
+// unavailableTriton is a Triton server that cannot be reached.
+type unavailableTriton struct {
+	tritonpb.GRPCInferenceServiceClient
+}
+
+func (unavailableTriton) ModelReady(context.Context, *tritonpb.ModelReadyRequest, ...grpc.CallOption) (*tritonpb.ModelReadyResponse, error) {
+	return nil, status.Error(codes.Unavailable, "connection refused")
+}
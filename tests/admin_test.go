@@ -0,0 +1,141 @@
+package tests
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"house-of-neural-networks/internal/models"
+	"house-of-neural-networks/internal/principal"
+	"house-of-neural-networks/internal/repository"
+	"house-of-neural-networks/internal/service"
+	interceptor "house-of-neural-networks/internal/transport/grpc"
+	"house-of-neural-networks/internal/transport/grpc/auth"
+	"house-of-neural-networks/internal/transport/grpc/model"
+	authpb "house-of-neural-networks/pkg/api/auth"
+	modelpb "house-of-neural-networks/pkg/api/model"
+	"house-of-neural-networks/pkg/db/postgres"
+	"regexp"
+	"testing"
+)
+
+func adminContext(userID int64) context.Context {
+	return principal.NewContext(context.Background(), principal.Principal{UserID: userID, Roles: []string{models.RoleUser, models.RoleAdmin}})
+}
+
+func TestAuthorize(t *testing.T) {
+	authorize := interceptor.Authorize(map[string]string{authpb.AuthService_ListUsers_FullMethodName: models.RoleAdmin})
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) { return "ok", nil }
+	call := func(ctx context.Context, method string) error {
+		_, err := authorize(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+		return err
+	}
+
+	t.Run("Admin", func(t *testing.T) {
+		require.NoError(t, call(adminContext(1), authpb.AuthService_ListUsers_FullMethodName))
+	})
+
+	t.Run("Not an admin", func(t *testing.T) {
+		err := call(userContext(1), authpb.AuthService_ListUsers_FullMethodName)
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+
+	t.Run("API key", func(t *testing.T) {
+		ctx := principal.NewContext(context.Background(), principal.Principal{UserID: 1, Roles: []string{models.RoleAdmin}, Scopes: []string{"models:read"}})
+		err := call(ctx, authpb.AuthService_ListUsers_FullMethodName)
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+
+	t.Run("No user", func(t *testing.T) {
+		err := call(context.Background(), authpb.AuthService_ListUsers_FullMethodName)
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("Unlisted method", func(t *testing.T) {
+		require.NoError(t, call(context.Background(), authpb.AuthService_LogIn_FullMethodName))
+	})
+}
+
+func TestAdminUsers_IncorrectData(t *testing.T) {
+	mockDB, _, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	db := sqlx.NewDb(mockDB, "sqlmock")
+	ctx := context.Background()
+	repo := repository.NewAuthRepository(&postgres.DB{Db: db})
+	serv := service.NewAuthService(repo, "very-secret-key")
+	authService := auth.NewAuthService(ctx, serv)
+
+	t.Run("Disable self", func(t *testing.T) {
+		resp, err := authService.SetUserDisabled(adminContext(1), &authpb.SetUserDisabledRequest{UserId: 1, Disabled: true})
+		require.Error(t, err)
+		assert.Nil(t, resp)
+		assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	})
+
+	t.Run("Unknown role", func(t *testing.T) {
+		resp, err := authService.SetUserRoles(adminContext(1), &authpb.SetUserRolesRequest{UserId: 2, Roles: []string{"root"}})
+		require.Error(t, err)
+		assert.Nil(t, resp)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("Revoke own admin role", func(t *testing.T) {
+		resp, err := authService.SetUserRoles(adminContext(1), &authpb.SetUserRolesRequest{UserId: 1, Roles: []string{models.RoleUser}})
+		require.Error(t, err)
+		assert.Nil(t, resp)
+		assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	})
+}
+
+func TestSetUserRoles_KeepsUserRole(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE users SET roles = $1 WHERE id = $2`)).
+		WithArgs(`{"user","admin"}`, 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	db := sqlx.NewDb(mockDB, "sqlmock")
+	ctx := context.Background()
+	repo := repository.NewAuthRepository(&postgres.DB{Db: db})
+	serv := service.NewAuthService(repo, "very-secret-key")
+	authService := auth.NewAuthService(ctx, serv)
+
+	t.Run("Success", func(t *testing.T) {
+		_, err := authService.SetUserRoles(adminContext(1), &authpb.SetUserRolesRequest{UserId: 2, Roles: []string{models.RoleAdmin, models.RoleAdmin}})
+		require.NoError(t, err)
+	})
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetModel_Admin(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	mock.ExpectQuery(getModelQuery).
+		WithArgs(1).
+		WillReturnRows(modelRows())
+
+	db := sqlx.NewDb(mockDB, "sqlmock")
+	ctx := context.Background()
+	repo := repository.NewModelRepository(&postgres.DB{Db: db})
+	serv := service.NewModelService(repo, nil)
+	modelService := model.NewModelService(ctx, serv)
+
+	t.Run("Model of another user", func(t *testing.T) {
+		resp, err := modelService.GetModel(adminContext(2), &modelpb.GetModelRequest{Id: 1})
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), resp.GetModel().GetUserId())
+	})
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
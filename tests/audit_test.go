@@ -0,0 +1,192 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"house-of-neural-networks/internal/models"
+	"house-of-neural-networks/internal/origin"
+	"house-of-neural-networks/internal/repository"
+	"house-of-neural-networks/internal/service"
+	"house-of-neural-networks/internal/transport/grpc/auth"
+	"house-of-neural-networks/internal/transport/grpc/model"
+	client "house-of-neural-networks/pkg/api/auth"
+	modelpb "house-of-neural-networks/pkg/api/model"
+	"house-of-neural-networks/pkg/db/postgres"
+	"regexp"
+	"testing"
+	"time"
+)
+
+const recordAuditEventQuery = "INSERT INTO audit_events (actor_id,action,target,request_id,client_ip,outcome) VALUES ($1,$2,$3,$4,$5,$6)"
+
+func withOrigin(ctx context.Context) context.Context {
+	return origin.NewContext(ctx, origin.Origin{RequestID: "request-1", ClientIP: "203.0.113.7"})
+}
+
+func newAuditedAuthService(t *testing.T) (*auth.AuthService, sqlmock.Sqlmock) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { mockDB.Close() })
+
+	db := &postgres.DB{Db: sqlx.NewDb(mockDB, "sqlmock")}
+	serv := service.NewAuthService(repository.NewAuthRepository(db), "very-secret-key")
+	serv.Audit = repository.NewAuditRepository(db)
+	return auth.NewAuthService(context.Background(), serv), mock
+}
+
+func TestAudit_LogIn(t *testing.T) {
+	authService, mock := newAuditedAuthService(t)
+	password, _ := bcrypt.GenerateFromPassword([]byte("123"), bcrypt.DefaultCost)
+
+	t.Run("Wrong password", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(getUserQuery)).
+			WithArgs("test user").
+			WillReturnRows(loginUserRows(1, password))
+		mock.ExpectQuery(regexp.QuoteMeta(countLoginFailuresQuery)).
+			WillReturnRows(loginFailureRows(0, nil, 0, nil))
+		mock.ExpectExec(regexp.QuoteMeta(recordLoginFailureQuery)).
+			WithArgs("test user", 1, "203.0.113.7", models.LoginWrongPassword).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec(regexp.QuoteMeta(recordAuditEventQuery)).
+			WithArgs(1, models.AuditLogIn, "test user", "request-1", "203.0.113.7", models.AuditDenied).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		req := &client.LogInRequest{Username: "test user", Password: "wrong", ClientIp: "203.0.113.7"}
+		_, err := authService.LogIn(withOrigin(context.Background()), req)
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("Unknown user", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(getUserQuery)).
+			WithArgs("nobody").
+			WillReturnError(sql.ErrNoRows)
+		mock.ExpectQuery(regexp.QuoteMeta(countLoginFailuresQuery)).
+			WillReturnRows(loginFailureRows(0, nil, 0, nil))
+		mock.ExpectExec(regexp.QuoteMeta(recordLoginFailureQuery)).
+			WithArgs("nobody", nil, "203.0.113.7", models.LoginUnknownUser).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec(regexp.QuoteMeta(recordAuditEventQuery)).
+			WithArgs(nil, models.AuditLogIn, "nobody", "request-1", "203.0.113.7", models.AuditDenied).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		req := &client.LogInRequest{Username: "nobody", Password: "123", ClientIp: "203.0.113.7"}
+		_, err := authService.LogIn(withOrigin(context.Background()), req)
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(getUserQuery)).
+			WithArgs("test user").
+			WillReturnRows(loginUserRows(1, password))
+		mock.ExpectQuery(regexp.QuoteMeta(countLoginFailuresQuery)).
+			WillReturnRows(loginFailureRows(0, nil, 0, nil))
+		mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO refresh_tokens (user_id,token_hash,family_id,expires_at)`)).
+			WithArgs(1, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec(regexp.QuoteMeta(setLastLoginQuery)).
+			WithArgs(1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		// Failing to record the event does not fail the login
+		mock.ExpectExec(regexp.QuoteMeta(recordAuditEventQuery)).
+			WithArgs(1, models.AuditLogIn, "test user", "request-1", "203.0.113.7", models.AuditSuccess).
+			WillReturnError(sql.ErrConnDone)
+
+		req := &client.LogInRequest{Username: "test user", Password: "123", ClientIp: "203.0.113.7"}
+		resp, err := authService.LogIn(withOrigin(context.Background()), req)
+		require.NoError(t, err)
+		assert.NotEmpty(t, resp.GetJwt())
+	})
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAudit_RemoveMemberDenied(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	mock.ExpectQuery(getMemberRoleQuery).
+		WithArgs(7, 2).
+		WillReturnRows(memberRoleRows("maintainer"))
+	mock.ExpectQuery(getMemberRoleQuery).
+		WithArgs(7, 1).
+		WillReturnRows(memberRoleRows("owner"))
+	mock.ExpectExec(regexp.QuoteMeta(recordAuditEventQuery)).
+		WithArgs(2, models.AuditMemberRemove, "organization:7/user:1", "request-1", "203.0.113.7", models.AuditDenied).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	db := &postgres.DB{Db: sqlx.NewDb(mockDB, "sqlmock")}
+	serv := service.NewModelService(repository.NewModelRepository(db), nil)
+	serv.Audit = repository.NewAuditRepository(db)
+	modelService := model.NewModelService(context.Background(), serv)
+
+	_, err = modelService.RemoveMember(withOrigin(userContext(2)), &modelpb.RemoveMemberRequest{OrganizationId: 7, UserId: 1})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListAuditEvents(t *testing.T) {
+	authService, mock := newAuditedAuthService(t)
+	const listQuery = "SELECT id, actor_id, action, target, request_id, client_ip, outcome, created_at FROM audit_events"
+	columns := []string{"id", "actor_id", "action", "target", "request_id", "client_ip", "outcome", "created_at"}
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var nextPageToken string
+	t.Run("First page", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(listQuery+" WHERE actor_id = $1 AND action = $2 AND created_at >= $3 ORDER BY id DESC LIMIT 3")).
+			WithArgs(5, models.AuditModelDelete, from).
+			WillReturnRows(sqlmock.NewRows(columns).
+				AddRow(30, 5, models.AuditModelDelete, "model:3", "r3", "203.0.113.7", models.AuditSuccess, from.Add(3*time.Hour)).
+				AddRow(20, 5, models.AuditModelDelete, "model:2", "r2", "203.0.113.7", models.AuditDenied, from.Add(2*time.Hour)).
+				AddRow(10, 5, models.AuditModelDelete, "model:1", "r1", "203.0.113.7", models.AuditSuccess, from.Add(time.Hour)))
+
+		resp, err := authService.ListAuditEvents(adminContext(1), &client.ListAuditEventsRequest{
+			ActorId:  5,
+			Action:   models.AuditModelDelete,
+			From:     timestamppb.New(from),
+			PageSize: 2,
+		})
+		require.NoError(t, err)
+		require.Len(t, resp.GetEvents(), 2)
+		assert.Equal(t, int64(30), resp.GetEvents()[0].GetId())
+		assert.Equal(t, "model:2", resp.GetEvents()[1].GetTarget())
+		assert.Equal(t, models.AuditDenied, resp.GetEvents()[1].GetOutcome())
+		nextPageToken = resp.GetNextPageToken()
+		assert.NotEmpty(t, nextPageToken)
+	})
+
+	t.Run("Last page", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(listQuery+" WHERE actor_id = $1 AND action = $2 AND created_at >= $3 AND id < $4 ORDER BY id DESC LIMIT 3")).
+			WithArgs(5, models.AuditModelDelete, from, 20).
+			WillReturnRows(sqlmock.NewRows(columns).
+				AddRow(10, nil, models.AuditModelDelete, "model:1", "r1", "203.0.113.7", models.AuditSuccess, from.Add(time.Hour)))
+
+		resp, err := authService.ListAuditEvents(adminContext(1), &client.ListAuditEventsRequest{
+			ActorId:   5,
+			Action:    models.AuditModelDelete,
+			From:      timestamppb.New(from),
+			PageSize:  2,
+			PageToken: nextPageToken,
+		})
+		require.NoError(t, err)
+		require.Len(t, resp.GetEvents(), 1)
+		assert.Zero(t, resp.GetEvents()[0].GetActorId())
+		assert.Empty(t, resp.GetNextPageToken())
+	})
+
+	t.Run("Invalid page token", func(t *testing.T) {
+		_, err := authService.ListAuditEvents(adminContext(1), &client.ListAuditEventsRequest{PageToken: "not a token"})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -4,31 +4,74 @@ import (
 	"context"
 	"database/sql"
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/jmoiron/sqlx"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"house-of-neural-networks/internal/models"
+	"house-of-neural-networks/internal/principal"
 	"house-of-neural-networks/internal/repository"
 	"house-of-neural-networks/internal/service"
 	"house-of-neural-networks/internal/transport/grpc/auth"
 	client "house-of-neural-networks/pkg/api/auth"
 	"house-of-neural-networks/pkg/db/postgres"
+	"house-of-neural-networks/pkg/jwks"
 	"log"
 	"regexp"
 	"testing"
+	"time"
 )
 
+const getUserQuery = "SELECT id, username, password, roles, disabled_at, last_login_at, totp_enabled_at FROM users WHERE username = $1"
+
+const countLoginFailuresQuery = "SELECT count(*) FILTER (WHERE username = $1 AND created_at > $2), max(created_at) FILTER (WHERE username = $3 AND created_at > $4), count(*) FILTER (WHERE client_ip = $5), max(created_at) FILTER (WHERE client_ip = $6) FROM login_failures WHERE created_at > $7 AND reason <> $8"
+
+const recordLoginFailureQuery = "INSERT INTO login_failures (username,user_id,client_ip,reason) VALUES ($1,$2,$3,$4)"
+
+const setLastLoginQuery = "UPDATE users SET last_login_at = now() WHERE id = $1"
+
+// newAuthService returns the auth service over a mocked database, closed at the end of the test.
+func newAuthService(t *testing.T) (*auth.AuthService, sqlmock.Sqlmock) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { mockDB.Close() })
+
+	db := sqlx.NewDb(mockDB, "sqlmock")
+	repo := repository.NewAuthRepository(&postgres.DB{Db: db})
+	serv := service.NewAuthService(repo, "very-secret-key")
+	return auth.NewAuthService(context.Background(), serv), mock
+}
+
+func loginUserRows(id int64, password []byte) *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "username", "password", "roles", "disabled_at", "last_login_at", "totp_enabled_at"}).
+		AddRow(id, "test user", password, "{user}", nil, nil, nil)
+}
+
+func loginFailureRows(accountCount int, accountLast interface{}, clientCount int, clientLast interface{}) *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"account_count", "account_last", "client_count", "client_last"}).
+		AddRow(accountCount, accountLast, clientCount, clientLast)
+}
+
 func TestLogIn_Success(t *testing.T) {
 	mockDB, mock, err := sqlmock.New()
 	require.NoError(t, err)
 	defer mockDB.Close()
 
 	password, _ := bcrypt.GenerateFromPassword([]byte("123"), bcrypt.DefaultCost)
-	rows := sqlmock.NewRows([]string{"id", "username", "password"}).
-		AddRow(1, "test user", password)
-	mock.ExpectQuery("SELECT id, username, password FROM users WHERE username = \\$1").
+	mock.ExpectQuery(regexp.QuoteMeta(getUserQuery)).
 		WithArgs("test user").
-		WillReturnRows(rows)
+		WillReturnRows(loginUserRows(1, password))
+	mock.ExpectQuery(regexp.QuoteMeta(countLoginFailuresQuery)).
+		WillReturnRows(loginFailureRows(0, nil, 0, nil))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO refresh_tokens (user_id,token_hash,family_id,expires_at)`)).
+		WithArgs(1, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(regexp.QuoteMeta(setLastLoginQuery)).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
 
 	db := sqlx.NewDb(mockDB, "sqlmock")
 	if err != nil {
@@ -43,6 +86,8 @@ func TestLogIn_Success(t *testing.T) {
 		resp, err := authService.LogIn(context.Background(), &client.LogInRequest{Username: "test user", Password: "123"})
 		require.NoError(t, err)
 		assert.NotNil(t, resp)
+		assert.NotEmpty(t, resp.GetRefreshToken())
+		assert.True(t, resp.GetRefreshExpiresAt().AsTime().After(resp.GetExpiresAt().AsTime()))
 	})
 
 	require.NoError(t, mock.ExpectationsWereMet())
@@ -53,9 +98,14 @@ func TestLogIn_UserNotFound(t *testing.T) {
 	require.NoError(t, err)
 	defer mockDB.Close()
 
-	mock.ExpectQuery("SELECT id, username, password FROM users WHERE username = \\$1").
+	mock.ExpectQuery(regexp.QuoteMeta(getUserQuery)).
 		WithArgs("test user").
 		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(regexp.QuoteMeta(countLoginFailuresQuery)).
+		WillReturnRows(loginFailureRows(0, nil, 0, nil))
+	mock.ExpectExec(regexp.QuoteMeta(recordLoginFailureQuery)).
+		WithArgs("test user", nil, "", models.LoginUnknownUser).
+		WillReturnResult(sqlmock.NewResult(1, 1))
 
 	db := sqlx.NewDb(mockDB, "sqlmock")
 	if err != nil {
@@ -70,7 +120,8 @@ func TestLogIn_UserNotFound(t *testing.T) {
 		resp, err := authService.LogIn(context.Background(), &client.LogInRequest{Username: "test user", Password: "123"})
 		require.Error(t, err)
 		assert.Nil(t, resp)
-		assert.Contains(t, err.Error(), "no rows in result set")
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+		assert.Equal(t, "service.LogIn: invalid username or password", status.Convert(err).Message())
 	})
 
 	require.NoError(t, mock.ExpectationsWereMet())
@@ -82,11 +133,14 @@ func TestLogIn_PasswordDoesNotMatch(t *testing.T) {
 	defer mockDB.Close()
 
 	password, _ := bcrypt.GenerateFromPassword([]byte("123"), bcrypt.DefaultCost)
-	rows := sqlmock.NewRows([]string{"id", "username", "password"}).
-		AddRow(1, "test user", password)
-	mock.ExpectQuery("SELECT id, username, password FROM users WHERE username = \\$1").
+	mock.ExpectQuery(regexp.QuoteMeta(getUserQuery)).
 		WithArgs("test user").
-		WillReturnRows(rows)
+		WillReturnRows(loginUserRows(1, password))
+	mock.ExpectQuery(regexp.QuoteMeta(countLoginFailuresQuery)).
+		WillReturnRows(loginFailureRows(0, nil, 0, nil))
+	mock.ExpectExec(regexp.QuoteMeta(recordLoginFailureQuery)).
+		WithArgs("test user", 1, "", models.LoginWrongPassword).
+		WillReturnResult(sqlmock.NewResult(1, 1))
 
 	db := sqlx.NewDb(mockDB, "sqlmock")
 	if err != nil {
@@ -101,7 +155,89 @@ func TestLogIn_PasswordDoesNotMatch(t *testing.T) {
 		resp, err := authService.LogIn(context.Background(), &client.LogInRequest{Username: "test user", Password: "wrong_password"})
 		require.Error(t, err)
 		assert.Nil(t, resp)
-		assert.Contains(t, err.Error(), "password does not match")
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+		assert.Equal(t, "service.LogIn: invalid username or password", status.Convert(err).Message())
+	})
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLogIn_Throttled(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	db := sqlx.NewDb(mockDB, "sqlmock")
+	ctx := context.Background()
+	repo := repository.NewAuthRepository(&postgres.DB{Db: db})
+	serv := service.NewAuthService(repo, "very-secret-key")
+	authService := auth.NewAuthService(ctx, serv)
+	password, _ := bcrypt.GenerateFromPassword([]byte("123"), bcrypt.DefaultCost)
+
+	t.Run("Account locked", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(getUserQuery)).
+			WithArgs("test user").
+			WillReturnRows(loginUserRows(1, password))
+		mock.ExpectQuery(regexp.QuoteMeta(countLoginFailuresQuery)).
+			WillReturnRows(loginFailureRows(6, time.Now(), 6, time.Now()))
+		mock.ExpectExec(regexp.QuoteMeta(recordLoginFailureQuery)).
+			WithArgs("test user", 1, "203.0.113.7", models.LoginLocked).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		// Locked even with the right password
+		resp, err := authService.LogIn(ctx, &client.LogInRequest{Username: "test user", Password: "123", ClientIp: "203.0.113.7"})
+		require.Error(t, err)
+		assert.Nil(t, resp)
+		assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	})
+
+	t.Run("Unknown user locked alike", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(getUserQuery)).
+			WithArgs("nobody").
+			WillReturnError(sql.ErrNoRows)
+		mock.ExpectQuery(regexp.QuoteMeta(countLoginFailuresQuery)).
+			WillReturnRows(loginFailureRows(6, time.Now(), 6, time.Now()))
+		mock.ExpectExec(regexp.QuoteMeta(recordLoginFailureQuery)).
+			WithArgs("nobody", nil, "203.0.113.7", models.LoginLocked).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		resp, err := authService.LogIn(ctx, &client.LogInRequest{Username: "nobody", Password: "123", ClientIp: "203.0.113.7"})
+		require.Error(t, err)
+		assert.Nil(t, resp)
+		assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	})
+
+	t.Run("Client address locked", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(getUserQuery)).
+			WithArgs("test user").
+			WillReturnRows(loginUserRows(1, password))
+		mock.ExpectQuery(regexp.QuoteMeta(countLoginFailuresQuery)).
+			WillReturnRows(loginFailureRows(0, nil, 20, time.Now()))
+		mock.ExpectExec(regexp.QuoteMeta(recordLoginFailureQuery)).
+			WithArgs("test user", 1, "203.0.113.7", models.LoginLocked).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		resp, err := authService.LogIn(ctx, &client.LogInRequest{Username: "test user", Password: "123", ClientIp: "203.0.113.7"})
+		require.Error(t, err)
+		assert.Nil(t, resp)
+		assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	})
+
+	t.Run("Delay elapsed", func(t *testing.T) {
+		// 6 failures wait 2 seconds after the last one
+		mock.ExpectQuery(regexp.QuoteMeta(getUserQuery)).
+			WithArgs("test user").
+			WillReturnRows(loginUserRows(1, password))
+		mock.ExpectQuery(regexp.QuoteMeta(countLoginFailuresQuery)).
+			WillReturnRows(loginFailureRows(6, time.Now().Add(-3*time.Second), 6, time.Now().Add(-3*time.Second)))
+		mock.ExpectExec(regexp.QuoteMeta(recordLoginFailureQuery)).
+			WithArgs("test user", 1, "203.0.113.7", models.LoginWrongPassword).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		resp, err := authService.LogIn(ctx, &client.LogInRequest{Username: "test user", Password: "wrong_password", ClientIp: "203.0.113.7"})
+		require.Error(t, err)
+		assert.Nil(t, resp)
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
 	})
 
 	require.NoError(t, mock.ExpectationsWereMet())
@@ -136,9 +272,9 @@ func TestSignUp_Success(t *testing.T) {
 	require.NoError(t, err)
 	defer mockDB.Close()
 
-	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO users`)).
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO users`)).
 		WithArgs("test user", sqlmock.AnyArg(), "test@test.com").
-		WillReturnResult(sqlmock.NewResult(1, 1))
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 
 	db := sqlx.NewDb(mockDB, "sqlmock")
 	if err != nil {
@@ -181,3 +317,359 @@ func TestSignUp_IncorrectData(t *testing.T) {
 
 	require.NoError(t, mock.ExpectationsWereMet())
 }
+
+func TestValidateToken_Claims(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	password, _ := bcrypt.GenerateFromPassword([]byte("123"), bcrypt.DefaultCost)
+	mock.ExpectQuery(regexp.QuoteMeta(getUserQuery)).
+		WithArgs("test user").
+		WillReturnRows(loginUserRows(42, password))
+	mock.ExpectQuery(regexp.QuoteMeta(countLoginFailuresQuery)).
+		WillReturnRows(loginFailureRows(0, nil, 0, nil))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO refresh_tokens`)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(regexp.QuoteMeta(setLastLoginQuery)).
+		WithArgs(42).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(revokedQuery)).
+		WithArgs(sqlmock.AnyArg(), 42, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+	db := sqlx.NewDb(mockDB, "sqlmock")
+	ctx := context.Background()
+	repo := repository.NewAuthRepository(&postgres.DB{Db: db})
+	serv := service.NewAuthService(repo, "very-secret-key")
+	authService := auth.NewAuthService(ctx, serv)
+
+	login, err := authService.LogIn(context.Background(), &client.LogInRequest{Username: "test user", Password: "123"})
+	require.NoError(t, err)
+
+	t.Run("Valid token", func(t *testing.T) {
+		resp, err := authService.ValidateToken(context.Background(), &client.ValidateTokenRequest{Jwt: login.GetJwt()})
+		require.NoError(t, err)
+		assert.True(t, resp.GetValid())
+		assert.Equal(t, int64(42), resp.GetUserId())
+		assert.Equal(t, []string{"user"}, resp.GetRoles())
+		assert.True(t, resp.GetExpiresAt().AsTime().After(time.Now()))
+	})
+
+	t.Run("Foreign signature", func(t *testing.T) {
+		other := service.NewAuthService(repo, "another-secret-key")
+		resp, err := auth.NewAuthService(ctx, other).ValidateToken(context.Background(), &client.ValidateTokenRequest{Jwt: login.GetJwt()})
+		require.Error(t, err)
+		assert.Nil(t, resp)
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+const revokedQuery = "SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1) OR EXISTS (SELECT 1 FROM users WHERE id = $2 AND (disabled_at IS NOT NULL OR tokens_valid_after > $3))"
+
+const getRefreshTokenQuery = "SELECT id, user_id, token_hash, family_id, expires_at, revoked_at FROM refresh_tokens WHERE token_hash = $1"
+
+const getUserByIDQuery = "SELECT id, username, email, roles, disabled_at, email_verified_at, last_login_at, totp_enabled_at FROM users WHERE id = $1"
+
+func userRows(disabledAt interface{}) *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "username", "email", "roles", "disabled_at", "email_verified_at", "last_login_at", "totp_enabled_at"}).
+		AddRow(42, "test user", "test@example.com", "{user}", disabledAt, nil, nil, nil)
+}
+
+func refreshTokenRows(revokedAt interface{}, expiresAt time.Time) *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "user_id", "token_hash", "family_id", "expires_at", "revoked_at"}).
+		AddRow(5, 42, "hash", "3f1c9a4e-6b1d-4a8e-9c63-0d1f2b7e8a55", expiresAt, revokedAt)
+}
+
+func TestRefresh_Rotation(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta(getRefreshTokenQuery)).
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnRows(refreshTokenRows(nil, time.Now().Add(time.Hour)))
+	mock.ExpectQuery(regexp.QuoteMeta(getUserByIDQuery)).
+		WithArgs(42).
+		WillReturnRows(userRows(nil))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE refresh_tokens SET revoked_at = now() WHERE id = $1 AND revoked_at IS NULL`)).
+		WithArgs(5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO refresh_tokens`)).
+		WithArgs(42, sqlmock.AnyArg(), "3f1c9a4e-6b1d-4a8e-9c63-0d1f2b7e8a55", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(6, 1))
+	mock.ExpectCommit()
+
+	db := sqlx.NewDb(mockDB, "sqlmock")
+	ctx := context.Background()
+	repo := repository.NewAuthRepository(&postgres.DB{Db: db})
+	serv := service.NewAuthService(repo, "very-secret-key")
+	authService := auth.NewAuthService(ctx, serv)
+
+	t.Run("Success", func(t *testing.T) {
+		resp, err := authService.Refresh(context.Background(), &client.RefreshRequest{RefreshToken: "old-token"})
+		require.NoError(t, err)
+		assert.Equal(t, int64(42), resp.GetUserId())
+		assert.NotEmpty(t, resp.GetJwt())
+		assert.NotEqual(t, "old-token", resp.GetRefreshToken())
+	})
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRefresh_Reuse(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta(getRefreshTokenQuery)).
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnRows(refreshTokenRows(time.Now().Add(-time.Minute), time.Now().Add(time.Hour)))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE refresh_tokens SET revoked_at = now() WHERE family_id = $1 AND revoked_at IS NULL`)).
+		WithArgs("3f1c9a4e-6b1d-4a8e-9c63-0d1f2b7e8a55").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(getRefreshTokenQuery)).
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnError(sql.ErrNoRows)
+
+	db := sqlx.NewDb(mockDB, "sqlmock")
+	ctx := context.Background()
+	repo := repository.NewAuthRepository(&postgres.DB{Db: db})
+	serv := service.NewAuthService(repo, "very-secret-key")
+	authService := auth.NewAuthService(ctx, serv)
+
+	t.Run("Revoked token revokes the family", func(t *testing.T) {
+		resp, err := authService.Refresh(context.Background(), &client.RefreshRequest{RefreshToken: "used-token"})
+		require.Error(t, err)
+		assert.Nil(t, resp)
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("Unknown token", func(t *testing.T) {
+		resp, err := authService.Refresh(context.Background(), &client.RefreshRequest{RefreshToken: "unknown-token"})
+		require.Error(t, err)
+		assert.Nil(t, resp)
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLogOut_RevokesAccessToken(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM revoked_tokens WHERE expires_at < $1`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO revoked_tokens (jti,expires_at) VALUES ($1,$2) ON CONFLICT (jti) DO NOTHING`)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(revokedQuery)).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+	db := sqlx.NewDb(mockDB, "sqlmock")
+	ctx := context.Background()
+	repo := repository.NewAuthRepository(&postgres.DB{Db: db})
+	serv := service.NewAuthService(repo, "very-secret-key")
+	authService := auth.NewAuthService(ctx, serv)
+
+	token, _, err := serv.GenerateToken(42, []string{models.RoleUser})
+	require.NoError(t, err)
+
+	t.Run("Log out", func(t *testing.T) {
+		_, err := authService.LogOut(context.Background(), &client.LogOutRequest{Jwt: token})
+		require.NoError(t, err)
+	})
+
+	t.Run("Revoked token is rejected", func(t *testing.T) {
+		resp, err := authService.ValidateToken(context.Background(), &client.ValidateTokenRequest{Jwt: token})
+		require.Error(t, err)
+		assert.Nil(t, resp)
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateAPIKey_IncorrectData(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	db := sqlx.NewDb(mockDB, "sqlmock")
+	ctx := context.Background()
+	repo := repository.NewAuthRepository(&postgres.DB{Db: db})
+	serv := service.NewAuthService(repo, "very-secret-key")
+	authService := auth.NewAuthService(ctx, serv)
+
+	t.Run("Unknown scope", func(t *testing.T) {
+		resp, err := authService.CreateAPIKey(userContext(1), &client.CreateAPIKeyRequest{Name: "ci", Scopes: []string{"admin"}})
+		require.Error(t, err)
+		assert.Nil(t, resp)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("Created with an api key", func(t *testing.T) {
+		keyContext := principal.NewContext(context.Background(), principal.Principal{UserID: 1, Scopes: []string{"chat:write"}})
+		resp, err := authService.CreateAPIKey(keyContext, &client.CreateAPIKeyRequest{Name: "ci", Scopes: []string{"chat:write"}})
+		require.Error(t, err)
+		assert.Nil(t, resp)
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestValidateAPIKey(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	const getAPIKeyQuery = "SELECT id, user_id, name, prefix, key_hash, scopes, model_ids, expires_at, created_at, last_used_at FROM api_keys WHERE key_hash = $1 AND revoked_at IS NULL AND NOT EXISTS (SELECT 1 FROM users WHERE users.id = api_keys.user_id AND users.disabled_at IS NOT NULL)"
+	keyRows := func(expiresAt time.Time) *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "user_id", "name", "prefix", "key_hash", "scopes", "model_ids", "expires_at", "created_at", "last_used_at"}).
+			AddRow(3, 42, "ci", "hnn_abcdefgh", "hash", "{chat:write}", "{1,2}", expiresAt, time.Now(), nil)
+	}
+	mock.ExpectQuery(regexp.QuoteMeta(getAPIKeyQuery)).
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnRows(keyRows(time.Now().Add(time.Hour)))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE api_keys SET last_used_at = now() WHERE id = $1")).
+		WithArgs(3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(getAPIKeyQuery)).
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnRows(keyRows(time.Now().Add(-time.Hour)))
+
+	db := sqlx.NewDb(mockDB, "sqlmock")
+	ctx := context.Background()
+	repo := repository.NewAuthRepository(&postgres.DB{Db: db})
+	serv := service.NewAuthService(repo, "very-secret-key")
+	authService := auth.NewAuthService(ctx, serv)
+
+	t.Run("Valid key", func(t *testing.T) {
+		resp, err := authService.ValidateAPIKey(context.Background(), &client.ValidateAPIKeyRequest{Key: "hnn_key"})
+		require.NoError(t, err)
+		assert.True(t, resp.GetApiKey())
+		assert.Equal(t, int64(42), resp.GetUserId())
+		assert.Equal(t, []string{"chat:write"}, resp.GetScopes())
+		assert.Equal(t, []int64{1, 2}, resp.GetModelIds())
+	})
+
+	t.Run("Expired key", func(t *testing.T) {
+		resp, err := authService.ValidateAPIKey(context.Background(), &client.ValidateAPIKeyRequest{Key: "hnn_key"})
+		require.Error(t, err)
+		assert.Nil(t, resp)
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSigningKeys(t *testing.T) {
+	for _, algorithm := range []string{jwks.RS256, jwks.EdDSA} {
+		t.Run(algorithm, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer mockDB.Close()
+
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT kid, algorithm, private_key, created_at, expires_at FROM signing_keys WHERE expires_at > $1 ORDER BY created_at DESC")).
+				WillReturnRows(sqlmock.NewRows([]string{"kid", "algorithm", "private_key", "created_at", "expires_at"}))
+			mock.ExpectExec(regexp.QuoteMeta("DELETE FROM signing_keys WHERE expires_at < $1")).
+				WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectExec(regexp.QuoteMeta("INSERT INTO signing_keys (kid,algorithm,private_key,created_at,expires_at)")).
+				WithArgs(sqlmock.AnyArg(), algorithm, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectQuery(regexp.QuoteMeta(revokedQuery)).
+				WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+			db := sqlx.NewDb(mockDB, "sqlmock")
+			ctx := context.Background()
+			repo := repository.NewAuthRepository(&postgres.DB{Db: db})
+			serv := service.NewAuthService(repo, "very-secret-key")
+			serv.Keys, err = service.NewKeyring(repo, algorithm, 24*time.Hour)
+			require.NoError(t, err)
+			require.NoError(t, serv.Keys.Rotate(ctx))
+			authService := auth.NewAuthService(ctx, serv)
+
+			token, _, err := serv.GenerateToken(42, []string{models.RoleUser})
+			require.NoError(t, err)
+
+			resp, err := authService.ValidateToken(context.Background(), &client.ValidateTokenRequest{Jwt: token})
+			require.NoError(t, err)
+			assert.Equal(t, int64(42), resp.GetUserId())
+
+			set, err := authService.GetJWKS(context.Background(), &client.GetJWKSRequest{})
+			require.NoError(t, err)
+			require.Len(t, set.GetKeys(), 1)
+			published := set.GetKeys()[0]
+			assert.Equal(t, algorithm, published.GetAlg())
+
+			// The published key alone is enough to verify the token
+			key := jwks.Key{Kty: published.GetKty(), Kid: published.GetKid(), Alg: published.GetAlg(), N: published.GetN(), E: published.GetE(), Crv: published.GetCrv(), X: published.GetX()}
+			public, err := key.PublicKey()
+			require.NoError(t, err)
+			parsed, err := jwt.Parse(token, func(*jwt.Token) (interface{}, error) { return public, nil }, jwt.WithValidMethods([]string{algorithm}))
+			require.NoError(t, err)
+			assert.Equal(t, published.GetKid(), parsed.Header["kid"])
+
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestSigningKeys_SharedSecretRejected(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	db := sqlx.NewDb(mockDB, "sqlmock")
+	ctx := context.Background()
+	repo := repository.NewAuthRepository(&postgres.DB{Db: db})
+	serv := service.NewAuthService(repo, "very-secret-key")
+	token, _, err := serv.GenerateToken(42, []string{models.RoleUser})
+	require.NoError(t, err)
+
+	serv.Keys, err = service.NewKeyring(repo, jwks.EdDSA, 24*time.Hour)
+	require.NoError(t, err)
+	authService := auth.NewAuthService(ctx, serv)
+
+	t.Run("HS256 token", func(t *testing.T) {
+		resp, err := authService.ValidateToken(context.Background(), &client.ValidateTokenRequest{Jwt: token})
+		require.Error(t, err)
+		assert.Nil(t, resp)
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListRevokedTokens(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	changedAt := time.Now().Add(-time.Minute).Truncate(time.Second)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT jti FROM revoked_tokens WHERE expires_at > $1")).
+		WillReturnRows(sqlmock.NewRows([]string{"jti"}).AddRow("3f1c9a4e-6b1d-4a8e-9c63-0d1f2b7e8a55"))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id FROM users WHERE disabled_at > $1")).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, tokens_valid_after FROM users WHERE tokens_valid_after > $1")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "tokens_valid_after"}).AddRow(42, changedAt))
+
+	db := sqlx.NewDb(mockDB, "sqlmock")
+	ctx := context.Background()
+	repo := repository.NewAuthRepository(&postgres.DB{Db: db})
+	authService := auth.NewAuthService(ctx, service.NewAuthService(repo, "very-secret-key"))
+
+	resp, err := authService.ListRevokedTokens(context.Background(), &client.ListRevokedTokensRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"3f1c9a4e-6b1d-4a8e-9c63-0d1f2b7e8a55"}, resp.GetIds())
+	assert.Equal(t, []int64{7}, resp.GetDisabledUserIds())
+	require.Contains(t, resp.GetTokensValidAfter(), int64(42))
+	assert.True(t, changedAt.Equal(resp.GetTokensValidAfter()[42].AsTime()))
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,245 @@
+package tests
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"house-of-neural-networks/internal/mailer"
+	"house-of-neural-networks/internal/repository"
+	"house-of-neural-networks/internal/service"
+	"house-of-neural-networks/internal/transport/grpc/auth"
+	client "house-of-neural-networks/pkg/api/auth"
+	"house-of-neural-networks/pkg/db/postgres"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+)
+
+const takeEmailTokenQuery = "DELETE FROM email_tokens WHERE purpose = $1 AND token_hash = $2 RETURNING user_id, email, expires_at"
+
+// sentToken reads the only email written to dir and returns the token in it.
+func sentToken(t *testing.T, dir string) string {
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	raw, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+	token := regexp.MustCompile(`(?m)^([A-Za-z0-9_-]{43})\r$`).FindSubmatch(raw)
+	require.NotNil(t, token, string(raw))
+	return string(token[1])
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := t.TempDir()
+	m := mailer.NewFileMailer("House of Neural Networks <no-reply@localhost>", dir)
+
+	t.Run("Success", func(t *testing.T) {
+		require.NoError(t, m.Send(context.Background(), mailer.Message{To: "john@example.com", Subject: "Hello", Body: "line 1\nline 2"}))
+		files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+		require.NoError(t, err)
+		require.Len(t, files, 1)
+		raw, err := os.ReadFile(files[0])
+		require.NoError(t, err)
+		assert.Contains(t, string(raw), "To: john@example.com\r\n")
+		assert.Contains(t, string(raw), "Subject: Hello\r\n")
+		assert.Contains(t, string(raw), "\r\n\r\nline 1\r\nline 2")
+	})
+
+	t.Run("Header injection", func(t *testing.T) {
+		err := m.Send(context.Background(), mailer.Message{To: "john@example.com\r\nBcc: eve@example.com", Subject: "Hello"})
+		require.Error(t, err)
+	})
+}
+
+func TestPasswordReset_Success(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	db := sqlx.NewDb(mockDB, "sqlmock")
+	ctx := context.Background()
+	repo := repository.NewAuthRepository(&postgres.DB{Db: db})
+	serv := service.NewAuthService(repo, "very-secret-key")
+	dir := t.TempDir()
+	serv.Mailer = mailer.NewFileMailer("no-reply@localhost", dir)
+	serv.PublicURL = "https://example.com"
+	authService := auth.NewAuthService(ctx, serv)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, username, email, roles, disabled_at, email_verified_at FROM users WHERE lower(email) = lower($1) AND disabled_at IS NULL")).
+		WithArgs("john@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "email", "roles", "disabled_at", "email_verified_at"}).
+			AddRow(42, "john", "John@example.com", "{user}", nil, nil))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM email_tokens")).
+		WithArgs(sqlmock.AnyArg(), "reset_password", 42).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO email_tokens (token_hash,user_id,purpose,email,expires_at)")).
+		WithArgs(sqlmock.AnyArg(), 42, "reset_password", "John@example.com", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	_, err = authService.RequestPasswordReset(ctx, &client.RequestPasswordResetRequest{Email: " john@example.com "})
+	require.NoError(t, err)
+	token := sentToken(t, dir)
+
+	mock.ExpectQuery(regexp.QuoteMeta(takeEmailTokenQuery)).
+		WithArgs("reset_password", sha256Hex(token)).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "email", "expires_at"}).AddRow(42, "John@example.com", time.Now().Add(time.Hour)))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE users SET password = $1, tokens_valid_after = $2 WHERE id = $3")).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 42).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE refresh_tokens SET revoked_at = now() WHERE revoked_at IS NULL AND user_id = $1")).
+		WithArgs(42).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE users SET email_verified_at = COALESCE(email_verified_at, now()) WHERE email = $1 AND id = $2")).
+		WithArgs("John@example.com", 42).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	t.Run("Success", func(t *testing.T) {
+		_, err := authService.ResetPassword(ctx, &client.ResetPasswordRequest{Token: token, Password: "newpassword123"})
+		require.NoError(t, err)
+	})
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRequestPasswordReset_UnknownEmail(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, username, email, roles, disabled_at, email_verified_at FROM users")).
+		WithArgs("nobody@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "email", "roles", "disabled_at", "email_verified_at"}))
+
+	db := sqlx.NewDb(mockDB, "sqlmock")
+	ctx := context.Background()
+	repo := repository.NewAuthRepository(&postgres.DB{Db: db})
+	serv := service.NewAuthService(repo, "very-secret-key")
+	dir := t.TempDir()
+	serv.Mailer = mailer.NewFileMailer("no-reply@localhost", dir)
+	authService := auth.NewAuthService(ctx, serv)
+
+	t.Run("Not revealed", func(t *testing.T) {
+		_, err := authService.RequestPasswordReset(ctx, &client.RequestPasswordResetRequest{Email: "nobody@example.com"})
+		require.NoError(t, err)
+		files, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+		assert.Empty(t, files)
+	})
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+// failingMailer is a mailer that cannot deliver to the address failing.
+type failingMailer struct {
+	mailer.Mailer
+	failing string
+}
+
+func (m failingMailer) Send(ctx context.Context, msg mailer.Message) error {
+	if msg.To == m.failing {
+		return errors.New("mailbox unavailable")
+	}
+	return m.Mailer.Send(ctx, msg)
+}
+
+func TestRequestPasswordReset_MailFailure(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, username, email, roles, disabled_at, email_verified_at FROM users")).
+		WithArgs("john@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "email", "roles", "disabled_at", "email_verified_at"}).
+			AddRow(42, "john", "John@example.com", "{user}", nil, nil).
+			AddRow(43, "johnny", "john@example.com", "{user}", nil, nil))
+	for _, user := range []struct {
+		id    int
+		email string
+	}{{42, "John@example.com"}, {43, "john@example.com"}} {
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta("DELETE FROM email_tokens")).
+			WithArgs(sqlmock.AnyArg(), "reset_password", user.id).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO email_tokens (token_hash,user_id,purpose,email,expires_at)")).
+			WithArgs(sqlmock.AnyArg(), user.id, "reset_password", user.email, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+	}
+
+	db := sqlx.NewDb(mockDB, "sqlmock")
+	ctx := context.Background()
+	repo := repository.NewAuthRepository(&postgres.DB{Db: db})
+	serv := service.NewAuthService(repo, "very-secret-key")
+	dir := t.TempDir()
+	serv.Mailer = failingMailer{Mailer: mailer.NewFileMailer("no-reply@localhost", dir), failing: "John@example.com"}
+	authService := auth.NewAuthService(ctx, serv)
+
+	t.Run("Not revealed", func(t *testing.T) {
+		_, err := authService.RequestPasswordReset(ctx, &client.RequestPasswordResetRequest{Email: "john@example.com"})
+		require.NoError(t, err)
+		// The other account still gets its link
+		assert.NotEmpty(t, sentToken(t, dir))
+	})
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestVerifyEmail_InvalidToken(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	db := sqlx.NewDb(mockDB, "sqlmock")
+	ctx := context.Background()
+	repo := repository.NewAuthRepository(&postgres.DB{Db: db})
+	serv := service.NewAuthService(repo, "very-secret-key")
+	authService := auth.NewAuthService(ctx, serv)
+
+	t.Run("Used or unknown", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(takeEmailTokenQuery)).
+			WithArgs("verify_email", sha256Hex("used")).
+			WillReturnRows(sqlmock.NewRows([]string{"user_id", "email", "expires_at"}))
+
+		resp, err := authService.VerifyEmail(ctx, &client.VerifyEmailRequest{Token: "used"})
+		require.Error(t, err)
+		assert.Nil(t, resp)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(takeEmailTokenQuery)).
+			WithArgs("verify_email", sha256Hex("expired")).
+			WillReturnRows(sqlmock.NewRows([]string{"user_id", "email", "expires_at"}).AddRow(42, "john@example.com", time.Now().Add(-time.Minute)))
+
+		resp, err := authService.VerifyEmail(ctx, &client.VerifyEmailRequest{Token: "expired"})
+		require.Error(t, err)
+		assert.Nil(t, resp)
+		assert.Contains(t, err.Error(), "token has expired")
+	})
+
+	t.Run("Empty token", func(t *testing.T) {
+		resp, err := authService.VerifyEmail(ctx, &client.VerifyEmailRequest{})
+		require.Error(t, err)
+		assert.Nil(t, resp)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,124 @@
+package tests
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"house-of-neural-networks/internal/models"
+	"house-of-neural-networks/internal/transport/gateway/handlers"
+	"house-of-neural-networks/internal/transport/grpc_clients"
+	client "house-of-neural-networks/pkg/api/message"
+	"house-of-neural-networks/pkg/logger"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// exportServer is a message service that exports the given messages, or fails with err.
+type exportServer struct {
+	client.UnimplementedMessageServiceServer
+	messages []*client.Message
+	err      error
+}
+
+func (s *exportServer) ExportMessages(_ *client.ExportMessagesRequest, stream client.MessageService_ExportMessagesServer) error {
+	if s.err != nil {
+		return s.err
+	}
+	for _, msg := range s.messages {
+		if err := stream.Send(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newExportHandlers(t *testing.T, server *exportServer) *handlers.MessageHandlers {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	grpcServer := grpc.NewServer()
+	client.RegisterMessageServiceServer(grpcServer, server)
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	messageClient, err := grpc_clients.NewMessageClient(listener.Addr().String())
+	require.NoError(t, err)
+	return handlers.NewMessageHandlers(messageClient)
+}
+
+func exportRequest(query string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/chat/1/export?"+query, nil)
+	r = r.WithContext(context.WithValue(r.Context(), logger.RequestID, "export-test"))
+	return mux.SetURLVars(r, map[string]string{"model_id": "1"})
+}
+
+func TestExportMessagesHandler(t *testing.T) {
+	messages := []*client.Message{
+		{
+			Id: 5, ModelId: 1, VersionId: 3, CreatedAt: timestamppb.New(firstMessageAt),
+			Inputs:  []*client.Tensor{{Name: "INPUT1", Datatype: "INT32", Shape: []int64{1, 2}, Contents: &client.TensorContents{IntContents: []int64{1, 2}}}},
+			Outputs: []*client.Tensor{{Name: "OUTPUT0", Datatype: "BYTES", Shape: []int64{1}, Contents: &client.TensorContents{BytesContents: [][]byte{[]byte("a, \"b\"")}}}},
+		},
+		{Id: 6, ModelId: 1, VersionId: 3, CreatedAt: timestamppb.New(firstMessageAt.Add(1))},
+	}
+	h := newExportHandlers(t, &exportServer{messages: messages})
+
+	t.Run("JSON lines", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		h.ExportMessages(w, exportRequest(""))
+
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+		assert.Equal(t, `attachment; filename="chat-1.jsonl"`, w.Header().Get("Content-Disposition"))
+		lines := strings.Split(strings.TrimSuffix(w.Body.String(), "\n"), "\n")
+		require.Len(t, lines, 2)
+		var first models.ChatMessage
+		require.NoError(t, json.Unmarshal([]byte(lines[0]), &first))
+		assert.Equal(t, int64(5), first.ID)
+		assert.True(t, firstMessageAt.Equal(first.CreatedAt))
+		assert.Equal(t, "INPUT1", first.Inputs[0].Name)
+		assert.Equal(t, []interface{}{float64(1), float64(2)}, first.Inputs[0].Data)
+		assert.Equal(t, []interface{}{`a, "b"`}, first.Outputs[0].Data)
+	})
+
+	t.Run("CSV", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		h.ExportMessages(w, exportRequest("format=csv"))
+
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "text/csv", w.Header().Get("Content-Type"))
+		records, err := csv.NewReader(w.Body).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, records, 3)
+		assert.Equal(t, []string{"id", "model_id", "version_id", "created_at", "inputs", "outputs"}, records[0])
+		assert.Equal(t, []string{"5", "1", "3", "2026-03-01T12:00:00.0000005Z"}, records[1][:4])
+		var outputs []models.Tensor
+		require.NoError(t, json.Unmarshal([]byte(records[1][5]), &outputs), "tensors are encoded as JSON")
+		assert.Equal(t, []interface{}{`a, "b"`}, outputs[0].Data)
+		assert.Equal(t, "[]", records[2][4])
+	})
+
+	t.Run("Invalid format", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		h.ExportMessages(w, exportRequest("format=xml"))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Failed export", func(t *testing.T) {
+		h := newExportHandlers(t, &exportServer{err: status.Error(codes.PermissionDenied, "read access to model (id 1) required")})
+		w := httptest.NewRecorder()
+		h.ExportMessages(w, exportRequest("format=csv"))
+
+		assert.Equal(t, http.StatusForbidden, w.Code, "the error is sent instead of an empty export")
+		assert.NotContains(t, w.Body.String(), "model_id")
+	})
+}
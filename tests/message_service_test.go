@@ -0,0 +1,384 @@
+package tests
+
+import (
+	"context"
+	"encoding/base64"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"house-of-neural-networks/internal/repository"
+	"house-of-neural-networks/internal/service"
+	"house-of-neural-networks/internal/transport/grpc/message"
+	"house-of-neural-networks/internal/triton"
+	client "house-of-neural-networks/pkg/api/message"
+	tritonpb "house-of-neural-networks/pkg/api/triton2"
+	"house-of-neural-networks/pkg/db/postgres"
+	"house-of-neural-networks/pkg/tensor"
+	"regexp"
+	"testing"
+	"time"
+)
+
+const (
+	getChatModelQuery   = "SELECT id, name, user_id, organization_id, public_permission FROM models WHERE id = $1"
+	getChatVersionQuery = "SELECT id, number, model_id FROM versions WHERE id = $1"
+	getMessagesQuery    = "SELECT id, user_id, model_id, version_id, inputs, outputs, created_at FROM messages WHERE (user_id = $1 AND model_id = $2)"
+	countMessagesQuery  = "SELECT COUNT(*) FROM messages WHERE (user_id = $1 AND model_id = $2)"
+)
+
+var firstMessageAt = time.Date(2026, 3, 1, 12, 0, 0, 500, time.UTC)
+
+// messageRows returns messages of user 1 to version 3 of model 1 sent a minute apart, starting at firstMessageAt.
+func messageRows(ids ...int64) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"id", "user_id", "model_id", "version_id", "inputs", "outputs", "created_at"})
+	for i, id := range ids {
+		rows.AddRow(id, 1, 1, 3,
+			[]byte(`[{"name":"INPUT1","datatype":"INT32","shape":[1,2],"data":"AQAAAAIAAAA="}]`),
+			[]byte(`[{"name":"OUTPUT1","datatype":"INT32","shape":[1],"data":"AwAAAA=="}]`),
+			firstMessageAt.Add(time.Duration(i)*time.Minute))
+	}
+	return rows
+}
+
+func chatModelRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "name", "user_id", "organization_id", "public_permission"}).
+		AddRow(1, "simple model", 1, nil, nil)
+}
+
+// exportStream collects the messages sent by ExportMessages.
+type exportStream struct {
+	client.MessageService_ExportMessagesServer
+	ctx      context.Context
+	messages []*client.Message
+}
+
+func (s *exportStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *exportStream) Send(msg *client.Message) error {
+	s.messages = append(s.messages, msg)
+	return nil
+}
+
+// inferenceTriton is a Triton server with a loaded model of simpleSchema that returns its first input as OUTPUT0.
+type inferenceTriton struct {
+	tritonpb.GRPCInferenceServiceClient
+	requests []*tritonpb.ModelInferRequest
+}
+
+func (*inferenceTriton) ModelReady(context.Context, *tritonpb.ModelReadyRequest, ...grpc.CallOption) (*tritonpb.ModelReadyResponse, error) {
+	return &tritonpb.ModelReadyResponse{Ready: true}, nil
+}
+
+func (*inferenceTriton) ModelMetadata(_ context.Context, req *tritonpb.ModelMetadataRequest, _ ...grpc.CallOption) (*tritonpb.ModelMetadataResponse, error) {
+	schema := simpleSchema()
+	metadata := &tritonpb.ModelMetadataResponse{Name: req.GetName()}
+	for _, input := range schema.Inputs {
+		metadata.Inputs = append(metadata.Inputs, &tritonpb.ModelMetadataResponse_TensorMetadata{Name: input.Name, Datatype: input.Datatype, Shape: input.Shape})
+	}
+	for _, output := range schema.Outputs {
+		metadata.Outputs = append(metadata.Outputs, &tritonpb.ModelMetadataResponse_TensorMetadata{Name: output.Name, Datatype: output.Datatype, Shape: output.Shape})
+	}
+	return metadata, nil
+}
+
+func (*inferenceTriton) ModelConfig(context.Context, *tritonpb.ModelConfigRequest, ...grpc.CallOption) (*tritonpb.ModelConfigResponse, error) {
+	return &tritonpb.ModelConfigResponse{Config: &tritonpb.ModelConfig{Input: []*tritonpb.ModelInput{{Name: "MASK", Optional: true}}}}, nil
+}
+
+func (s *inferenceTriton) ModelInfer(_ context.Context, req *tritonpb.ModelInferRequest, _ ...grpc.CallOption) (*tritonpb.ModelInferResponse, error) {
+	s.requests = append(s.requests, req)
+	return &tritonpb.ModelInferResponse{
+		ModelName: req.GetModelName(),
+		Outputs: []*tritonpb.ModelInferResponse_InferOutputTensor{
+			{Name: "OUTPUT0", Datatype: req.GetInputs()[0].GetDatatype(), Shape: req.GetInputs()[0].GetShape()},
+		},
+		RawOutputContents: [][]byte{req.GetRawInputContents()[0]},
+	}, nil
+}
+
+func TestSendMessage(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	db := sqlx.NewDb(mockDB, "sqlmock")
+	ctx := context.Background()
+	repo := repository.NewMessageRepository(&postgres.DB{Db: db})
+	tritonServer := &inferenceTriton{}
+	serv := service.NewMessageService(repo, &triton.TritonClient{Client: tritonServer})
+	messageService := message.NewMessageService(ctx, serv)
+
+	expectModel := func() {
+		mock.ExpectQuery(regexp.QuoteMeta(getChatModelQuery)).
+			WithArgs(1).
+			WillReturnRows(chatModelRows())
+		mock.ExpectQuery(regexp.QuoteMeta(getChatVersionQuery)).
+			WithArgs(3).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "number", "model_id"}).AddRow(3, 2, 1))
+	}
+	floats := &client.TensorContents{FpContents: []float64{1, 2, 3, 4, 5, 6, 7, 8}}
+	ints := &client.TensorContents{IntContents: []int64{1, 2}}
+
+	t.Run("Inputs by position", func(t *testing.T) {
+		expectModel()
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO messages (user_id,model_id,version_id,inputs,outputs,created_at) VALUES ($1,$2,$3,$4,$5,$6) RETURNING id")).
+			WithArgs(1, 1, 3, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(10))
+		mock.ExpectCommit()
+
+		resp, err := messageService.SendMessage(userContext(1), &client.SendMessageRequest{
+			ModelId:   1,
+			VersionId: 3,
+			Inputs:    []*client.Tensor{{Contents: floats}, {Contents: ints}},
+		})
+		require.NoError(t, err)
+		request := tritonServer.requests[len(tritonServer.requests)-1]
+		assert.Equal(t, "simple model", request.GetModelName())
+		assert.Equal(t, "2", request.GetModelVersion())
+		assert.Equal(t, []int64{2, 4}, request.GetInputs()[0].GetShape(), "the batch dimension is resolved")
+		output := resp.GetOutputs()["OUTPUT0"]
+		require.NotNil(t, output)
+		assert.Equal(t, tensor.Fp32, output.GetDatatype())
+		assert.Equal(t, []float64{1, 2, 3, 4, 5, 6, 7, 8}, output.GetContents().GetFpContents())
+	})
+
+	t.Run("Inputs by name", func(t *testing.T) {
+		expectModel()
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO messages")).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(11))
+		mock.ExpectCommit()
+
+		_, err := messageService.SendMessage(userContext(1), &client.SendMessageRequest{
+			ModelId:   1,
+			VersionId: 3,
+			Inputs: []*client.Tensor{
+				{Name: "INPUT1", Contents: ints},
+				{Name: "INPUT0", Shape: []int64{2, 4}, Contents: floats},
+			},
+		})
+		require.NoError(t, err)
+		request := tritonServer.requests[len(tritonServer.requests)-1]
+		assert.Equal(t, "INPUT0", request.GetInputs()[0].GetName())
+		assert.Equal(t, "INPUT1", request.GetInputs()[1].GetName())
+	})
+
+	t.Run("Datatype mismatch", func(t *testing.T) {
+		expectModel()
+		sent := len(tritonServer.requests)
+
+		_, err := messageService.SendMessage(userContext(1), &client.SendMessageRequest{
+			ModelId:   1,
+			VersionId: 3,
+			Inputs:    []*client.Tensor{{Datatype: tensor.Fp64, Contents: floats}, {Contents: ints}},
+		})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		assert.Len(t, tritonServer.requests, sent, "nothing is sent to Triton")
+	})
+
+	t.Run("Shape mismatch", func(t *testing.T) {
+		expectModel()
+
+		_, err := messageService.SendMessage(userContext(1), &client.SendMessageRequest{
+			ModelId:   1,
+			VersionId: 3,
+			Inputs:    []*client.Tensor{{Shape: []int64{4, 2}, Contents: floats}, {Contents: ints}},
+		})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetMessages(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	db := sqlx.NewDb(mockDB, "sqlmock")
+	ctx := context.Background()
+	repo := repository.NewMessageRepository(&postgres.DB{Db: db})
+	messageService := message.NewMessageService(ctx, service.NewMessageService(repo, nil))
+
+	t.Run("Page token round trip", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(getChatModelQuery)).
+			WithArgs(1).
+			WillReturnRows(chatModelRows())
+		// One extra message is read to tell whether there is a next page
+		mock.ExpectQuery(regexp.QuoteMeta(getMessagesQuery + " ORDER BY created_at ASC, id ASC LIMIT 2")).
+			WithArgs(1, 1).
+			WillReturnRows(messageRows(5, 6))
+		mock.ExpectQuery(regexp.QuoteMeta(countMessagesQuery)).
+			WithArgs(1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+
+		resp, err := messageService.GetMessages(userContext(1), &client.GetMessagesRequest{ModelId: 1, PageSize: 1})
+		require.NoError(t, err)
+		require.Len(t, resp.GetMessages(), 1)
+		assert.Equal(t, int64(5), resp.GetMessages()[0].GetId())
+		assert.Equal(t, []int64{1, 2}, resp.GetMessages()[0].GetInputs()[0].GetContents().GetIntContents())
+		assert.Equal(t, int64(2), resp.GetTotalCount())
+		require.NotEmpty(t, resp.GetNextPageToken())
+
+		var after time.Time
+		mock.ExpectQuery(regexp.QuoteMeta(getChatModelQuery)).
+			WithArgs(1).
+			WillReturnRows(chatModelRows())
+		mock.ExpectQuery(regexp.QuoteMeta(getMessagesQuery + " AND (created_at, id) > ($3, $4) ORDER BY created_at ASC, id ASC LIMIT 2")).
+			WithArgs(1, 1, capturedTime{&after}, 5).
+			WillReturnRows(messageRows(6))
+		mock.ExpectQuery(regexp.QuoteMeta(countMessagesQuery)).
+			WithArgs(1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+
+		resp, err = messageService.GetMessages(userContext(1), &client.GetMessagesRequest{ModelId: 1, PageSize: 1, PageToken: resp.GetNextPageToken()})
+		require.NoError(t, err)
+		require.Len(t, resp.GetMessages(), 1)
+		assert.Equal(t, int64(6), resp.GetMessages()[0].GetId())
+		assert.True(t, firstMessageAt.Equal(after), "the cursor keeps nanoseconds, got %s", after)
+		assert.Empty(t, resp.GetNextPageToken(), "the last page has no next page")
+	})
+
+	t.Run("Malformed page token", func(t *testing.T) {
+		for _, token := range []string{"not base64!", base64.RawURLEncoding.EncodeToString([]byte("5")), base64.RawURLEncoding.EncodeToString([]byte("now:5"))} {
+			mock.ExpectQuery(regexp.QuoteMeta(getChatModelQuery)).
+				WithArgs(1).
+				WillReturnRows(chatModelRows())
+
+			_, err := messageService.GetMessages(userContext(1), &client.GetMessagesRequest{ModelId: 1, PageToken: token})
+			assert.Equal(t, codes.InvalidArgument, status.Code(err), token)
+		}
+	})
+
+	t.Run("No access to the model", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(getChatModelQuery)).
+			WithArgs(1).
+			WillReturnRows(chatModelRows())
+		mock.ExpectQuery(getRoleQuery).
+			WillReturnRows(roleRows(nil))
+
+		_, err := messageService.GetMessages(userContext(2), &client.GetMessagesRequest{ModelId: 1})
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListChats(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	db := sqlx.NewDb(mockDB, "sqlmock")
+	ctx := context.Background()
+	repo := repository.NewMessageRepository(&postgres.DB{Db: db})
+	messageService := message.NewMessageService(ctx, service.NewMessageService(repo, nil))
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT m.model_id, models.name AS model_name, m.version_id, v.number AS version_number")).
+		WithArgs(1, 1, 1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"model_id", "model_name", "version_id", "version_number", "last_message_at", "message_count"}).
+			AddRow(1, "simple model", 3, 2, firstMessageAt, 4))
+
+	resp, err := messageService.ListChats(userContext(1), &client.ListChatsRequest{})
+	require.NoError(t, err)
+	require.Len(t, resp.GetChats(), 1)
+	chat := resp.GetChats()[0]
+	assert.Equal(t, "simple model", chat.GetModelName())
+	assert.Equal(t, int64(3), chat.GetVersionId())
+	assert.Equal(t, int64(4), chat.GetMessageCount())
+	assert.True(t, firstMessageAt.Equal(chat.GetLastMessageAt().AsTime()))
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteMessages(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	db := sqlx.NewDb(mockDB, "sqlmock")
+	ctx := context.Background()
+	repo := repository.NewMessageRepository(&postgres.DB{Db: db})
+	messageService := message.NewMessageService(ctx, service.NewMessageService(repo, nil))
+
+	t.Run("Message of the user", func(t *testing.T) {
+		mock.ExpectExec(regexp.QuoteMeta("DELETE FROM messages WHERE (id = $1 AND user_id = $2)")).
+			WithArgs(5, 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		_, err := messageService.DeleteMessage(userContext(1), &client.DeleteMessageRequest{MessageId: 5})
+		assert.NoError(t, err)
+	})
+
+	t.Run("Message of another user", func(t *testing.T) {
+		mock.ExpectExec(regexp.QuoteMeta("DELETE FROM messages WHERE (id = $1 AND user_id = $2)")).
+			WithArgs(5, 2).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		_, err := messageService.DeleteMessage(userContext(2), &client.DeleteMessageRequest{MessageId: 5})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+
+	t.Run("Chat with a version", func(t *testing.T) {
+		mock.ExpectExec(regexp.QuoteMeta("DELETE FROM messages WHERE (user_id = $1 AND model_id = $2 AND version_id = $3)")).
+			WithArgs(1, 1, 3).
+			WillReturnResult(sqlmock.NewResult(0, 4))
+
+		resp, err := messageService.DeleteChat(userContext(1), &client.DeleteChatRequest{ModelId: 1, VersionId: 3})
+		require.NoError(t, err)
+		assert.Equal(t, int64(4), resp.GetDeletedCount())
+	})
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestExportMessages(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	db := sqlx.NewDb(mockDB, "sqlmock")
+	ctx := context.Background()
+	repo := repository.NewMessageRepository(&postgres.DB{Db: db})
+	messageService := message.NewMessageService(ctx, service.NewMessageService(repo, nil))
+
+	t.Run("Whole history", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(getChatModelQuery)).
+			WithArgs(1).
+			WillReturnRows(chatModelRows())
+		mock.ExpectQuery(regexp.QuoteMeta(getMessagesQuery + " ORDER BY created_at ASC, id ASC LIMIT 500")).
+			WithArgs(1, 1).
+			WillReturnRows(messageRows(5, 6, 7))
+
+		stream := &exportStream{ctx: userContext(1)}
+		err := messageService.ExportMessages(&client.ExportMessagesRequest{ModelId: 1}, stream)
+		require.NoError(t, err)
+		require.Len(t, stream.messages, 3)
+		for i, msg := range stream.messages {
+			assert.Equal(t, int64(5+i), msg.GetId())
+			assert.Equal(t, []int64{3}, msg.GetOutputs()[0].GetContents().GetIntContents())
+		}
+	})
+
+	t.Run("No access to the model", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(getChatModelQuery)).
+			WithArgs(1).
+			WillReturnRows(chatModelRows())
+		mock.ExpectQuery(getRoleQuery).
+			WillReturnRows(roleRows(nil))
+
+		stream := &exportStream{ctx: userContext(2)}
+		err := messageService.ExportMessages(&client.ExportMessagesRequest{ModelId: 1}, stream)
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+		assert.Empty(t, stream.messages)
+	})
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -5,44 +5,64 @@ import (
 	"database/sql"
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"house-of-neural-networks/internal/modelstore"
+	"house-of-neural-networks/internal/principal"
 	"house-of-neural-networks/internal/repository"
 	"house-of-neural-networks/internal/service"
 	"house-of-neural-networks/internal/transport/grpc/model"
+	"house-of-neural-networks/internal/triton"
 	client "house-of-neural-networks/pkg/api/model"
 	"house-of-neural-networks/pkg/db/postgres"
-	"log"
+	"os"
+	"path/filepath"
 	"regexp"
 	"testing"
 )
 
+const getModelQuery = "SELECT models.id, models.name, models.user_id, models.organization_id, models.public_permission, versions.id as version_id, versions.number as version_number, versions.model_id as version_model_id FROM models LEFT JOIN versions ON models.id = versions.model_id WHERE models.id = \\$1"
+
+func userContext(userID int64) context.Context {
+	return principal.NewContext(context.Background(), principal.Principal{UserID: userID, Roles: []string{"user"}})
+}
+
+const getRoleQuery = "SELECT CASE WHEN models.organization_id IS NULL AND models.user_id = \\$1 THEN 'owner' .* END AS role FROM models LEFT JOIN model_permissions .* LEFT JOIN organization_members .* WHERE models.id = \\$4"
+
+func roleRows(role interface{}) *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"role"}).AddRow(role)
+}
+
+func modelRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"models.id", "models.name", "models.user_id", "models.organization_id", "models.public_permission", "versions.id as version_id", "versions.number as version_number", "versions.model_id as version_model_id"}).
+		AddRow(1, "simple model", 1, nil, nil, 1, 1, 1)
+}
+
 func TestGetModel_Success(t *testing.T) {
 	mockDB, mock, err := sqlmock.New()
 	require.NoError(t, err)
 	defer mockDB.Close()
 
-	rows := sqlmock.NewRows([]string{"models.id", "models.name", "models.user_id", "versions.id as version_id", "versions.number as version_number", "versions.model_id as version_model_id"}).
-		AddRow(1, "simple model", 1, 0, 1, 1)
-	mock.ExpectQuery("SELECT models.id, models.name, models.user_id, versions.id as version_id, versions.number as version_number, versions.model_id as version_model_id FROM models LEFT JOIN versions ON models.id = versions.model_id WHERE models.id = \\$1").
+	mock.ExpectQuery(getModelQuery).
 		WithArgs(1).
-		WillReturnRows(rows)
+		WillReturnRows(modelRows())
 
 	db := sqlx.NewDb(mockDB, "sqlmock")
-	if err != nil {
-		log.Fatalln(err)
-	}
 	ctx := context.Background()
 	repo := repository.NewModelRepository(&postgres.DB{Db: db})
-	serv := service.NewModelService(repo, true)
+	serv := service.NewModelService(repo, nil)
 	modelService := model.NewModelService(ctx, serv)
 
 	t.Run("Success", func(t *testing.T) {
-		resp, err := modelService.GetModel(context.Background(), &client.GetModelRequest{Id: 1})
+		resp, err := modelService.GetModel(userContext(1), &client.GetModelRequest{Id: 1})
 		require.NoError(t, err)
 		assert.Equal(t, int64(1), resp.GetModel().GetId())
 		assert.Equal(t, "simple model", resp.GetModel().GetName())
 		assert.Equal(t, int64(1), resp.GetModel().GetUserId())
+		assert.Len(t, resp.GetModel().GetVersions(), 1)
 	})
 
 	require.NoError(t, mock.ExpectationsWereMet())
@@ -53,52 +73,115 @@ func TestGetModel_NotFound(t *testing.T) {
 	require.NoError(t, err)
 	defer mockDB.Close()
 
-	mock.ExpectQuery("SELECT models.id, models.name, models.user_id, versions.id as version_id, versions.number as version_number, versions.model_id as version_model_id FROM models LEFT JOIN versions ON models.id = versions.model_id WHERE models.id = \\$1").
+	mock.ExpectQuery(getModelQuery).
 		WithArgs(1).
-		WillReturnError(sql.ErrNoRows)
+		WillReturnRows(sqlmock.NewRows([]string{"models.id", "models.name", "models.user_id", "models.organization_id", "models.public_permission", "versions.id as version_id", "versions.number as version_number", "versions.model_id as version_model_id"}))
 
 	db := sqlx.NewDb(mockDB, "sqlmock")
-	if err != nil {
-		log.Fatalln(err)
-	}
 	ctx := context.Background()
 	repo := repository.NewModelRepository(&postgres.DB{Db: db})
-	serv := service.NewModelService(repo, true)
+	serv := service.NewModelService(repo, nil)
 	modelService := model.NewModelService(ctx, serv)
 
 	t.Run("Not Found", func(t *testing.T) {
-		resp, err := modelService.GetModel(context.Background(), &client.GetModelRequest{Id: 1})
+		resp, err := modelService.GetModel(userContext(1), &client.GetModelRequest{Id: 1})
 		require.Error(t, err)
 		assert.Nil(t, resp)
-		assert.Contains(t, err.Error(), "no rows in result set")
+		assert.Equal(t, codes.NotFound, status.Code(err))
 	})
 
 	require.NoError(t, mock.ExpectationsWereMet())
 }
 
-func TestUploadModel_Success(t *testing.T) {
+func TestGetModel_PermissionDenied(t *testing.T) {
 	mockDB, mock, err := sqlmock.New()
 	require.NoError(t, err)
 	defer mockDB.Close()
 
-	mock.ExpectQuery("INSERT INTO models").
-		WithArgs("test model", int64(1)).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "user_id"}).
-			AddRow(1, "test model", 1))
+	mock.ExpectQuery(getModelQuery).
+		WithArgs(1).
+		WillReturnRows(modelRows())
+	mock.ExpectQuery(getRoleQuery).
+		WithArgs(2, 2, 2, 1).
+		WillReturnRows(roleRows(nil))
 
 	db := sqlx.NewDb(mockDB, "sqlmock")
-	if err != nil {
-		log.Fatalln(err)
-	}
 	ctx := context.Background()
 	repo := repository.NewModelRepository(&postgres.DB{Db: db})
-	serv := service.NewModelService(repo, true)
+	serv := service.NewModelService(repo, nil)
 	modelService := model.NewModelService(ctx, serv)
 
-	t.Run("Success", func(t *testing.T) {
-		resp, err := modelService.UploadModel(context.Background(), &client.UploadModelRequest{Name: "test model", UserId: 1})
+	t.Run("Foreign model", func(t *testing.T) {
+		resp, err := modelService.GetModel(userContext(2), &client.GetModelRequest{Id: 1})
+		require.Error(t, err)
+		assert.Nil(t, resp)
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetModel_Shared(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	mock.ExpectQuery(getModelQuery).
+		WithArgs(1).
+		WillReturnRows(modelRows())
+	mock.ExpectQuery(getRoleQuery).
+		WithArgs(2, 2, 2, 1).
+		WillReturnRows(roleRows("read"))
+	mock.ExpectQuery(getModelQuery).
+		WithArgs(1).
+		WillReturnRows(modelRows())
+	mock.ExpectQuery(getRoleQuery).
+		WithArgs(2, 2, 2, 1).
+		WillReturnRows(roleRows("read"))
+
+	db := sqlx.NewDb(mockDB, "sqlmock")
+	ctx := context.Background()
+	repo := repository.NewModelRepository(&postgres.DB{Db: db})
+	serv := service.NewModelService(repo, nil)
+	modelService := model.NewModelService(ctx, serv)
+
+	t.Run("Read access", func(t *testing.T) {
+		resp, err := modelService.GetModel(userContext(2), &client.GetModelRequest{Id: 1})
 		require.NoError(t, err)
-		assert.Equal(t, int64(1), resp.GetId())
+		assert.Equal(t, "read", resp.GetModel().GetRole())
+	})
+
+	t.Run("Read access does not allow new versions", func(t *testing.T) {
+		resp, err := modelService.UploadVersion(userContext(2), &client.UploadVersionRequest{Number: 2, ModelId: 1})
+		require.Error(t, err)
+		assert.Nil(t, resp)
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGrantAccess_IncorrectData(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	db := sqlx.NewDb(mockDB, "sqlmock")
+	ctx := context.Background()
+	repo := repository.NewModelRepository(&postgres.DB{Db: db})
+	serv := service.NewModelService(repo, nil)
+	modelService := model.NewModelService(ctx, serv)
+
+	t.Run("Public manage", func(t *testing.T) {
+		_, err := modelService.GrantAccess(userContext(1), &client.GrantAccessRequest{ModelId: 1, Public: true, Permission: "manage"})
+		require.Error(t, err)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("No grantee", func(t *testing.T) {
+		_, err := modelService.GrantAccess(userContext(1), &client.GrantAccessRequest{ModelId: 1, Permission: "read"})
+		require.Error(t, err)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
 	})
 
 	require.NoError(t, mock.ExpectationsWereMet())
@@ -110,19 +193,75 @@ func TestUploadModel_IncorrectData(t *testing.T) {
 	defer mockDB.Close()
 
 	db := sqlx.NewDb(mockDB, "sqlmock")
-	if err != nil {
-		log.Fatalln(err)
-	}
 	ctx := context.Background()
 	repo := repository.NewModelRepository(&postgres.DB{Db: db})
-	serv := service.NewModelService(repo, true)
+	serv := service.NewModelService(repo, nil)
 	modelService := model.NewModelService(ctx, serv)
 
 	t.Run("Incorrect data", func(t *testing.T) {
-		resp, err := modelService.UploadModel(context.Background(), &client.UploadModelRequest{})
+		resp, err := modelService.UploadModel(userContext(1), &client.UploadModelRequest{})
+		require.Error(t, err)
+		assert.Nil(t, resp)
+		assert.Contains(t, err.Error(), "name is empty")
+	})
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		resp, err := modelService.UploadModel(context.Background(), &client.UploadModelRequest{Name: "test model"})
 		require.Error(t, err)
 		assert.Nil(t, resp)
-		assert.Contains(t, err.Error(), "name or user_id is empty")
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("Slash in name", func(t *testing.T) {
+		_, err := modelService.UploadModel(userContext(1), &client.UploadModelRequest{Name: "simple/1"})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("Config outside the model", func(t *testing.T) {
+		_, err := modelService.UploadModel(userContext(1), &client.UploadModelRequest{
+			Name:   "simple",
+			Config: &client.File{Filename: "../victim/config.pbtxt", Content: []byte("name: \"victim\"")},
+		})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("Name taken", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO models (name,user_id,organization_id) VALUES ($1,$2,$3) returning id, name, user_id, organization_id")).
+			WithArgs("simple", 1, nil).
+			WillReturnError(&pq.Error{Code: "23505"})
+
+		_, err := modelService.UploadModel(userContext(1), &client.UploadModelRequest{Name: "simple", Config: &client.File{Filename: "config.pbtxt"}})
+		assert.Equal(t, codes.AlreadyExists, status.Code(err))
+	})
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUploadModel_StoreFailure(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO models (name,user_id,organization_id) VALUES ($1,$2,$3) returning id, name, user_id, organization_id")).
+		WithArgs("simple", 1, nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "user_id", "organization_id"}).AddRow(7, "simple", 1, nil))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM messages WHERE model_id = $1")).
+		WithArgs(7).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM models WHERE id = $1 returning *")).
+		WithArgs(7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	db := sqlx.NewDb(mockDB, "sqlmock")
+	ctx := context.Background()
+	repo := repository.NewModelRepository(&postgres.DB{Db: db})
+	serv := service.NewModelService(repo, nil)
+	serv.Store = failingStore{ModelStore: modelstore.NewLocalStore(t.TempDir()), failing: "config.pbtxt"}
+	modelService := model.NewModelService(ctx, serv)
+
+	t.Run("Model is deleted again", func(t *testing.T) {
+		_, err := modelService.UploadModel(userContext(1), &client.UploadModelRequest{Name: "simple", Config: &client.File{Filename: "config.pbtxt"}})
+		assert.Equal(t, codes.Internal, status.Code(err))
 	})
 
 	require.NoError(t, mock.ExpectationsWereMet())
@@ -133,53 +272,54 @@ func TestListModels_Success(t *testing.T) {
 	require.NoError(t, err)
 	defer mockDB.Close()
 
-	rows := sqlmock.NewRows([]string{"id", "name", "user_id"}).
-		AddRow(1, "test model 1", 1).
-		AddRow(2, "test model 2", 1).
-		AddRow(3, "test model 3", 1)
-	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM models WHERE user_id = $1`)).
-		WithArgs(1).
+	rows := sqlmock.NewRows([]string{"id", "name", "user_id", "organization_id", "public_permission", "role"}).
+		AddRow(1, "test model 1", 1, nil, nil, "owner").
+		AddRow(2, "test model 2", 1, nil, "read", "owner").
+		AddRow(3, "test model 3", 2, nil, "infer", "read").
+		AddRow(4, "team model", 2, 7, nil, "manage")
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT models.id, models.name, models.user_id, models.organization_id, models.public_permission, CASE WHEN models.organization_id IS NULL AND models.user_id = $1 THEN 'owner'`)).
+		WithArgs(1, 1, 1, 1).
 		WillReturnRows(rows)
 
 	db := sqlx.NewDb(mockDB, "sqlmock")
-	if err != nil {
-		log.Fatalln(err)
-	}
 	ctx := context.Background()
 	repo := repository.NewModelRepository(&postgres.DB{Db: db})
-	serv := service.NewModelService(repo, true)
+	serv := service.NewModelService(repo, nil)
 	modelService := model.NewModelService(ctx, serv)
 
 	t.Run("Success", func(t *testing.T) {
-		resp, err := modelService.ListModels(context.Background(), &client.ListModelsRequest{UserId: 1})
+		resp, err := modelService.ListModels(userContext(1), &client.ListModelsRequest{})
 		require.NoError(t, err)
+		assert.Len(t, resp.GetModels(), 4)
 		assert.Equal(t, int64(1), resp.GetModels()[0].GetId())
 		assert.Equal(t, "test model 1", resp.GetModels()[0].GetName())
 		assert.Equal(t, int64(1), resp.GetModels()[0].GetUserId())
+		assert.Equal(t, "owner", resp.GetModels()[0].GetRole())
+		assert.Equal(t, "owner", resp.GetModels()[1].GetRole())
+		assert.Equal(t, "infer", resp.GetModels()[2].GetRole())
+		assert.Equal(t, "manage", resp.GetModels()[3].GetRole())
+		assert.Equal(t, int64(7), resp.GetModels()[3].GetOrganizationId())
 	})
 
 	require.NoError(t, mock.ExpectationsWereMet())
 }
 
-func TestListModels_IncorrectData(t *testing.T) {
+func TestListModels_Unauthenticated(t *testing.T) {
 	mockDB, mock, err := sqlmock.New()
 	require.NoError(t, err)
 	defer mockDB.Close()
 
 	db := sqlx.NewDb(mockDB, "sqlmock")
-	if err != nil {
-		log.Fatalln(err)
-	}
 	ctx := context.Background()
 	repo := repository.NewModelRepository(&postgres.DB{Db: db})
-	serv := service.NewModelService(repo, true)
+	serv := service.NewModelService(repo, nil)
 	modelService := model.NewModelService(ctx, serv)
 
-	t.Run("Success", func(t *testing.T) {
+	t.Run("No user", func(t *testing.T) {
 		resp, err := modelService.ListModels(context.Background(), &client.ListModelsRequest{})
 		require.Error(t, err)
 		assert.Nil(t, resp)
-		assert.Contains(t, err.Error(), "user_id is empty")
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
 	})
 
 	require.NoError(t, mock.ExpectationsWereMet())
@@ -190,23 +330,30 @@ func TestDeleteModel_Success(t *testing.T) {
 	require.NoError(t, err)
 	defer mockDB.Close()
 
-	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM models WHERE id = $1`)).
+	mock.ExpectQuery(getModelQuery).
+		WithArgs(1).
+		WillReturnRows(modelRows())
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM messages WHERE model_id = $1")).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM models WHERE id = $1 returning *")).
 		WithArgs(1).
-		WillReturnResult(sqlmock.NewResult(1, 1))
+		WillReturnResult(sqlmock.NewResult(0, 1))
 
 	db := sqlx.NewDb(mockDB, "sqlmock")
-	if err != nil {
-		log.Fatalln(err)
-	}
 	ctx := context.Background()
 	repo := repository.NewModelRepository(&postgres.DB{Db: db})
-	serv := service.NewModelService(repo, true)
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "simple model", "1"), os.ModePerm))
+	serv := service.NewModelService(repo, &triton.TritonClient{Client: unloadedTriton{}})
+	serv.Store = modelstore.NewLocalStore(root)
 	modelService := model.NewModelService(ctx, serv)
 
-	t.Run("Success", func(t *testing.T) {
-		resp, err := modelService.UnloadModel(context.Background(), &client.UnloadModelRequest{Id: 1})
+	t.Run("Files are removed", func(t *testing.T) {
+		resp, err := modelService.UnloadModel(userContext(1), &client.UnloadModelRequest{Id: 1})
 		require.NoError(t, err)
-		assert.Equal(t, true, resp.GetSuccess())
+		assert.True(t, resp.GetSuccess())
+		assert.NoDirExists(t, filepath.Join(root, "simple model"), "a new model of the same name must not get the old versions")
 	})
 
 	require.NoError(t, mock.ExpectationsWereMet())
@@ -217,23 +364,49 @@ func TestDeleteModel_NotFound(t *testing.T) {
 	require.NoError(t, err)
 	defer mockDB.Close()
 
-	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM models WHERE id = $1`)).
+	mock.ExpectQuery(getModelQuery).
 		WithArgs(1).
 		WillReturnError(sql.ErrNoRows)
 
 	db := sqlx.NewDb(mockDB, "sqlmock")
-	if err != nil {
-		log.Fatalln(err)
-	}
 	ctx := context.Background()
 	repo := repository.NewModelRepository(&postgres.DB{Db: db})
-	serv := service.NewModelService(repo, true)
+	serv := service.NewModelService(repo, nil)
 	modelService := model.NewModelService(ctx, serv)
 
 	t.Run("Not found", func(t *testing.T) {
-		resp, err := modelService.UnloadModel(context.Background(), &client.UnloadModelRequest{Id: 1})
+		resp, err := modelService.UnloadModel(userContext(1), &client.UnloadModelRequest{Id: 1})
 		require.Error(t, err)
 		assert.Equal(t, false, resp.GetSuccess())
+		assert.Contains(t, err.Error(), "no rows in result set")
+	})
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteModel_PermissionDenied(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	mock.ExpectQuery(getModelQuery).
+		WithArgs(1).
+		WillReturnRows(modelRows())
+	mock.ExpectQuery(getRoleQuery).
+		WithArgs(2, 2, 2, 1).
+		WillReturnRows(roleRows(nil))
+
+	db := sqlx.NewDb(mockDB, "sqlmock")
+	ctx := context.Background()
+	repo := repository.NewModelRepository(&postgres.DB{Db: db})
+	serv := service.NewModelService(repo, nil)
+	modelService := model.NewModelService(ctx, serv)
+
+	t.Run("Foreign model", func(t *testing.T) {
+		resp, err := modelService.UnloadModel(userContext(2), &client.UnloadModelRequest{Id: 1})
+		require.Error(t, err)
+		assert.Nil(t, resp)
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
 	})
 
 	require.NoError(t, mock.ExpectationsWereMet())
@@ -245,16 +418,13 @@ func TestDeleteModel_IncorrectData(t *testing.T) {
 	defer mockDB.Close()
 
 	db := sqlx.NewDb(mockDB, "sqlmock")
-	if err != nil {
-		log.Fatalln(err)
-	}
 	ctx := context.Background()
 	repo := repository.NewModelRepository(&postgres.DB{Db: db})
-	serv := service.NewModelService(repo, true)
+	serv := service.NewModelService(repo, nil)
 	modelService := model.NewModelService(ctx, serv)
 
 	t.Run("Incorrect data", func(t *testing.T) {
-		resp, err := modelService.UnloadModel(context.Background(), &client.UnloadModelRequest{})
+		resp, err := modelService.UnloadModel(userContext(1), &client.UnloadModelRequest{})
 		require.Error(t, err)
 		assert.Nil(t, resp)
 		assert.Contains(t, err.Error(), "id is empty")
@@ -263,29 +433,29 @@ func TestDeleteModel_IncorrectData(t *testing.T) {
 	require.NoError(t, mock.ExpectationsWereMet())
 }
 
-func TestUploadVersion_Success(t *testing.T) {
+func TestUploadVersion_PermissionDenied(t *testing.T) {
 	mockDB, mock, err := sqlmock.New()
 	require.NoError(t, err)
 	defer mockDB.Close()
 
-	mock.ExpectQuery("INSERT INTO versions").
-		WithArgs(1, int64(1)).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "number", "model_id"}).
-			AddRow(1, 1, 1))
+	mock.ExpectQuery(getModelQuery).
+		WithArgs(1).
+		WillReturnRows(modelRows())
+	mock.ExpectQuery(getRoleQuery).
+		WithArgs(2, 2, 2, 1).
+		WillReturnRows(roleRows(nil))
 
 	db := sqlx.NewDb(mockDB, "sqlmock")
-	if err != nil {
-		log.Fatalln(err)
-	}
 	ctx := context.Background()
 	repo := repository.NewModelRepository(&postgres.DB{Db: db})
-	serv := service.NewModelService(repo, true)
+	serv := service.NewModelService(repo, nil)
 	modelService := model.NewModelService(ctx, serv)
 
-	t.Run("Success", func(t *testing.T) {
-		resp, err := modelService.UploadVersion(context.Background(), &client.UploadVersionRequest{Number: 1, ModelId: 1})
-		require.NoError(t, err)
-		assert.Equal(t, int64(1), resp.GetId())
+	t.Run("Foreign model", func(t *testing.T) {
+		resp, err := modelService.UploadVersion(userContext(2), &client.UploadVersionRequest{Number: 2, ModelId: 1})
+		require.Error(t, err)
+		assert.Nil(t, resp)
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
 	})
 
 	require.NoError(t, mock.ExpectationsWereMet())
@@ -297,16 +467,13 @@ func TestUploadVersion_IncorrectData(t *testing.T) {
 	defer mockDB.Close()
 
 	db := sqlx.NewDb(mockDB, "sqlmock")
-	if err != nil {
-		log.Fatalln(err)
-	}
 	ctx := context.Background()
 	repo := repository.NewModelRepository(&postgres.DB{Db: db})
-	serv := service.NewModelService(repo, true)
+	serv := service.NewModelService(repo, nil)
 	modelService := model.NewModelService(ctx, serv)
 
 	t.Run("Incorrect data", func(t *testing.T) {
-		resp, err := modelService.UploadVersion(context.Background(), &client.UploadVersionRequest{})
+		resp, err := modelService.UploadVersion(userContext(1), &client.UploadVersionRequest{})
 		require.Error(t, err)
 		assert.Nil(t, resp)
 		assert.Contains(t, err.Error(), "number or model_id is empty")
@@ -314,3 +481,29 @@ func TestUploadVersion_IncorrectData(t *testing.T) {
 
 	require.NoError(t, mock.ExpectationsWereMet())
 }
+
+func TestGetModel_APIKeyRestricted(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	mock.ExpectQuery(getModelQuery).
+		WithArgs(1).
+		WillReturnRows(modelRows())
+
+	db := sqlx.NewDb(mockDB, "sqlmock")
+	ctx := context.Background()
+	repo := repository.NewModelRepository(&postgres.DB{Db: db})
+	serv := service.NewModelService(repo, nil)
+	modelService := model.NewModelService(ctx, serv)
+
+	t.Run("Key for another model", func(t *testing.T) {
+		keyContext := principal.NewContext(context.Background(), principal.Principal{UserID: 1, Scopes: []string{"models:read"}, ModelIDs: []int64{2}})
+		resp, err := modelService.GetModel(keyContext, &client.GetModelRequest{Id: 1})
+		require.Error(t, err)
+		assert.Nil(t, resp)
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
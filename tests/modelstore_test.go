@@ -0,0 +1,295 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"house-of-neural-networks/internal/modelstore"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// s3StandIn is an in-memory stand-in for an S3-compatible storage such as MinIO, with the requests the model
+// store makes.
+type s3StandIn struct {
+	t       *testing.T
+	bucket  string
+	mu      sync.Mutex
+	objects map[string][]byte
+	parts   map[string]map[int][]byte
+	uploads int
+}
+
+func newS3StandIn(t *testing.T, bucket string) (*s3StandIn, *httptest.Server) {
+	s := &s3StandIn{t: t, bucket: bucket, objects: make(map[string][]byte), parts: make(map[string]map[int][]byte)}
+	server := httptest.NewServer(s)
+	t.Cleanup(server.Close)
+	return s, server
+}
+
+func (s *s3StandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=access/") || !strings.Contains(auth, "/us-east-1/s3/aws4_request") {
+		writeS3Error(w, http.StatusForbidden, "AccessDenied", "Access Denied.")
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeS3Error(w, http.StatusBadRequest, "IncompleteBody", err.Error())
+		return
+	}
+	switch hash := r.Header.Get("X-Amz-Content-Sha256"); hash {
+	case "UNSIGNED-PAYLOAD":
+	case "STREAMING-AWS4-HMAC-SHA256-PAYLOAD":
+		if body, err = decodeAWSChunked(body); !assert.NoError(s.t, err, "chunked body of %s %s", r.Method, r.URL) {
+			writeS3Error(w, http.StatusBadRequest, "IncompleteBody", err.Error())
+			return
+		}
+		assert.Equal(s.t, r.Header.Get("X-Amz-Decoded-Content-Length"), strconv.Itoa(len(body)), "decoded length of %s %s", r.Method, r.URL)
+	default:
+		sum := sha256.Sum256(body)
+		if !assert.Equal(s.t, hex.EncodeToString(sum[:]), hash, "payload hash of %s %s", r.Method, r.URL) {
+			writeS3Error(w, http.StatusBadRequest, "XAmzContentSHA256Mismatch", "The provided 'x-amz-content-sha256' header does not match what was computed.")
+			return
+		}
+	}
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != s.bucket {
+		writeS3Error(w, http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist")
+		return
+	}
+	query := r.URL.Query()
+	switch {
+	case r.Method == http.MethodGet && key == "" && query.Get("list-type") == "2":
+		s.list(w, query.Get("prefix"), query.Get("continuation-token"))
+	case r.Method == http.MethodPost && key == "" && query.Has("delete"):
+		var request struct {
+			Objects []struct {
+				Key string
+			} `xml:"Object"`
+		}
+		if !assert.NoError(s.t, xml.Unmarshal(body, &request)) {
+			writeS3Error(w, http.StatusBadRequest, "MalformedXML", "The XML you provided was not well-formed.")
+			return
+		}
+		fmt.Fprint(w, "<DeleteResult>")
+		for _, object := range request.Objects {
+			delete(s.objects, object.Key)
+			fmt.Fprintf(w, "<Deleted><Key>%s</Key></Deleted>", object.Key)
+		}
+		fmt.Fprint(w, "</DeleteResult>")
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		s.uploads++
+		uploadID := strconv.Itoa(s.uploads)
+		s.parts[uploadID] = make(map[int][]byte)
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><Bucket>%s</Bucket><Key>%s</Key><UploadId>%s</UploadId></InitiateMultipartUploadResult>", bucket, key, uploadID)
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		number, _ := strconv.Atoi(query.Get("partNumber"))
+		s.parts[query.Get("uploadId")][number] = body
+		w.Header().Set("ETag", fmt.Sprintf("%q", "etag-"+strconv.Itoa(number)))
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		var complete struct {
+			Parts []struct {
+				PartNumber int
+				ETag       string
+			} `xml:"Part"`
+		}
+		if !assert.NoError(s.t, xml.Unmarshal(body, &complete)) {
+			writeS3Error(w, http.StatusBadRequest, "MalformedXML", "The XML you provided was not well-formed.")
+			return
+		}
+		var content []byte
+		for i, part := range complete.Parts {
+			if !assert.Equal(s.t, i+1, part.PartNumber) || !assert.Equal(s.t, "etag-"+strconv.Itoa(i+1), strings.Trim(part.ETag, `"`)) {
+				writeS3Error(w, http.StatusBadRequest, "InvalidPart", "One or more of the specified parts could not be found.")
+				return
+			}
+			content = append(content, s.parts[query.Get("uploadId")][part.PartNumber]...)
+		}
+		delete(s.parts, query.Get("uploadId"))
+		s.objects[key] = content
+		fmt.Fprintf(w, "<CompleteMultipartUploadResult><Bucket>%s</Bucket><Key>%s</Key><ETag>\"etag\"</ETag></CompleteMultipartUploadResult>", bucket, key)
+	case r.Method == http.MethodPut:
+		s.objects[key] = body
+		w.Header().Set("ETag", `"etag"`)
+	case r.Method == http.MethodDelete:
+		delete(s.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeS3Error(w, http.StatusMethodNotAllowed, "MethodNotAllowed", "The specified method is not allowed against this resource.")
+	}
+}
+
+// decodeAWSChunked decodes a body sent with the streaming signature, the chunk signatures are not checked.
+func decodeAWSChunked(body []byte) ([]byte, error) {
+	var decoded []byte
+	for {
+		header, rest, ok := bytes.Cut(body, []byte("\r\n"))
+		if !ok {
+			return nil, fmt.Errorf("missing chunk header")
+		}
+		sizeHex, _, _ := bytes.Cut(header, []byte(";"))
+		size, err := strconv.ParseInt(string(sizeHex), 16, 64)
+		if err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			return decoded, nil
+		}
+		if int64(len(rest)) < size {
+			return nil, fmt.Errorf("chunk is shorter than %d bytes", size)
+		}
+		decoded = append(decoded, rest[:size]...)
+		body = bytes.TrimPrefix(rest[size:], []byte("\r\n"))
+	}
+}
+
+// list returns two keys at a time, so that the store has to follow continuation tokens.
+func (s *s3StandIn) list(w http.ResponseWriter, prefix, token string) {
+	keys := make([]string, 0)
+	for key := range s.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	start, _ := strconv.Atoi(token)
+	end := min(start+2, len(keys))
+	fmt.Fprint(w, "<ListBucketResult>")
+	for _, key := range keys[start:end] {
+		fmt.Fprintf(w, "<Contents><Key>%s</Key></Contents>", key)
+	}
+	if end < len(keys) {
+		fmt.Fprintf(w, "<IsTruncated>true</IsTruncated><NextContinuationToken>%d</NextContinuationToken>", end)
+	}
+	fmt.Fprint(w, "</ListBucketResult>")
+}
+
+func (s *s3StandIn) keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]string, 0, len(s.objects))
+	for key := range s.objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func writeS3Error(w http.ResponseWriter, statusCode int, code, message string) {
+	w.WriteHeader(statusCode)
+	fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, message)
+}
+
+func stagedFile(t *testing.T, content string) string {
+	name := filepath.Join(t.TempDir(), "upload")
+	require.NoError(t, os.WriteFile(name, []byte(content), 0644))
+	return name
+}
+
+func TestS3Store(t *testing.T) {
+	standIn, server := newS3StandIn(t, "triton")
+	store, err := modelstore.NewS3Store(modelstore.ModelStoreConfig{
+		S3Endpoint:  server.URL,
+		S3Region:    "us-east-1",
+		S3Bucket:    "triton",
+		S3Prefix:    "models",
+		S3AccessKey: "access",
+		S3SecretKey: "secret",
+		S3PartSize:  5 << 20,
+	})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	t.Run("Put", func(t *testing.T) {
+		require.NoError(t, store.Put(ctx, "simple model/config.pbtxt", []byte("name: \"simple\"")))
+		assert.Equal(t, []byte("name: \"simple\""), standIn.objects["models/simple model/config.pbtxt"])
+	})
+
+	t.Run("Move small file", func(t *testing.T) {
+		src := stagedFile(t, "abc")
+		require.NoError(t, store.MoveFile(ctx, "simple model/1/model.onnx", src))
+		assert.Equal(t, []byte("abc"), standIn.objects["models/simple model/1/model.onnx"])
+		assert.NoFileExists(t, src)
+	})
+
+	t.Run("Move large file in parts", func(t *testing.T) {
+		uploads := standIn.uploads
+		content := strings.Repeat("0123456789", 1<<20)
+		src := stagedFile(t, content)
+		require.NoError(t, store.MoveFile(ctx, "simple model/2/model.onnx", src))
+		assert.Equal(t, uploads+1, standIn.uploads, "multipart upload")
+		assert.Equal(t, []byte(content), standIn.objects["models/simple model/2/model.onnx"])
+		assert.Empty(t, standIn.parts)
+		assert.NoFileExists(t, src)
+	})
+
+	t.Run("Remove model", func(t *testing.T) {
+		require.NoError(t, store.Put(ctx, "simple model 2/config.pbtxt", nil))
+		require.NoError(t, store.Put(ctx, "simple model/2/labels.txt", nil))
+
+		require.NoError(t, store.RemoveAll(ctx, "simple model"))
+		assert.Equal(t, []string{"models/simple model 2/config.pbtxt"}, standIn.keys())
+	})
+
+	t.Run("Invalid path", func(t *testing.T) {
+		assert.Error(t, store.Put(ctx, "../config.pbtxt", nil))
+		assert.Error(t, store.RemoveAll(ctx, "/"))
+	})
+}
+
+func TestS3Store_Error(t *testing.T) {
+	_, server := newS3StandIn(t, "triton")
+	store, err := modelstore.NewS3Store(modelstore.ModelStoreConfig{
+		S3Endpoint:  server.URL,
+		S3Region:    "us-east-1",
+		S3Bucket:    "missing",
+		S3AccessKey: "access",
+		S3SecretKey: "secret",
+	})
+	require.NoError(t, err)
+
+	err = store.Put(context.Background(), "simple/config.pbtxt", nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "The specified bucket does not exist")
+
+	src := stagedFile(t, "abc")
+	assert.Error(t, store.MoveFile(context.Background(), "simple/1/model.onnx", src))
+	assert.FileExists(t, src, "the file is kept if it could not be moved")
+}
+
+func TestLocalStore(t *testing.T) {
+	root := t.TempDir()
+	store := modelstore.NewLocalStore(root)
+	ctx := context.Background()
+
+	require.NoError(t, store.Put(ctx, "simple/config.pbtxt", []byte("name: \"simple\"")))
+	src := stagedFile(t, "abc")
+	require.NoError(t, store.MoveFile(ctx, "simple/1/model.onnx", src))
+	assert.NoFileExists(t, src)
+
+	content, err := os.ReadFile(filepath.Join(root, "simple", "1", "model.onnx"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("abc"), content)
+
+	assert.Error(t, store.Put(ctx, "../config.pbtxt", nil))
+	require.NoError(t, store.RemoveAll(ctx, "simple"))
+	assert.NoDirExists(t, filepath.Join(root, "simple"))
+}
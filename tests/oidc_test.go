@@ -0,0 +1,297 @@
+package tests
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"house-of-neural-networks/internal/oidc"
+	"house-of-neural-networks/internal/repository"
+	"house-of-neural-networks/internal/service"
+	"house-of-neural-networks/internal/transport/grpc/auth"
+	client "house-of-neural-networks/pkg/api/auth"
+	"house-of-neural-networks/pkg/db/postgres"
+	"house-of-neural-networks/pkg/jwks"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+)
+
+const clientID = "house-of-neural-networks"
+
+// mockProvider is an OpenID provider that grants every authorization request it sees.
+type mockProvider struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+	// audience of the ID tokens issued, clientID unless a test breaks it
+	audience string
+
+	mu     sync.Mutex
+	grants map[string]url.Values
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	p := &mockProvider{key: key, audience: clientID, grants: make(map[string]url.Values)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 p.URL,
+			"authorization_endpoint": p.URL + "/authorize",
+			"token_endpoint":         p.URL + "/token",
+			"jwks_uri":               p.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		public, _ := jwks.NewKey("mock-key", jwks.RS256, &p.key.PublicKey)
+		json.NewEncoder(w).Encode(jwks.Set{Keys: []jwks.Key{public}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		grant, ok := p.grants[r.PostFormValue("code")]
+		delete(p.grants, r.PostFormValue("code"))
+		p.mu.Unlock()
+
+		challenge := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+		if !ok || grant.Get("code_challenge") != base64.RawURLEncoding.EncodeToString(challenge[:]) {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"iss":                p.URL,
+			"sub":                "248289761001",
+			"aud":                p.audience,
+			"exp":                time.Now().Add(time.Minute).Unix(),
+			"iat":                time.Now().Unix(),
+			"nonce":              grant.Get("nonce"),
+			"email":              "jane.doe@example.com",
+			"email_verified":     true,
+			"preferred_username": "jane.doe",
+		})
+		token.Header["kid"] = "mock-key"
+		idToken, _ := token.SignedString(p.key)
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "opaque", "token_type": "Bearer", "id_token": idToken})
+	})
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Close)
+	return p
+}
+
+// authorize plays the user signing in at the provider and returns the code it redirects back with.
+func (p *mockProvider) authorize(t *testing.T, authURL string) string {
+	parsed, err := url.Parse(authURL)
+	require.NoError(t, err)
+	query := parsed.Query()
+	require.Equal(t, "S256", query.Get("code_challenge_method"))
+	require.Equal(t, clientID, query.Get("client_id"))
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	code := "code-" + query.Get("state")
+	p.grants[code] = query
+	return code
+}
+
+// captured records the value of a query argument, e.g. a generated secret.
+type captured struct {
+	value *string
+}
+
+func (c captured) Match(v driver.Value) bool {
+	s, ok := v.(string)
+	*c.value = s
+	return ok
+}
+
+func newOIDCService(t *testing.T, provider *mockProvider) (*auth.AuthService, sqlmock.Sqlmock) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { mockDB.Close() })
+
+	repo := repository.NewAuthRepository(&postgres.DB{Db: sqlx.NewDb(mockDB, "sqlmock")})
+	serv := service.NewAuthService(repo, "very-secret-key")
+	serv.OIDC = oidc.NewProvider(oidc.OIDCConfig{
+		Issuer:      provider.URL,
+		ClientID:    clientID,
+		RedirectURL: "http://localhost/login/oidc/callback",
+		Scopes:      []string{"openid", "profile", "email"},
+	}, provider.Client())
+	return auth.NewAuthService(context.Background(), serv), mock
+}
+
+// startLogin runs StartOIDCLogin and expects the state to be taken back when the provider redirects.
+func startLogin(t *testing.T, authService *auth.AuthService, mock sqlmock.Sqlmock) (*client.StartOIDCLoginResponse, error) {
+	var state, nonce, verifier string
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM oidc_states WHERE expires_at < $1")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO oidc_states (state,nonce,code_verifier,expires_at) VALUES ($1,$2,$3,$4)")).
+		WithArgs(captured{&state}, captured{&nonce}, captured{&verifier}, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	resp, err := authService.StartOIDCLogin(context.Background(), &client.StartOIDCLoginRequest{})
+	if err != nil {
+		return nil, err
+	}
+	mock.ExpectQuery(regexp.QuoteMeta("DELETE FROM oidc_states WHERE state = $1 RETURNING nonce, code_verifier, expires_at")).
+		WithArgs(state).
+		WillReturnRows(sqlmock.NewRows([]string{"nonce", "code_verifier", "expires_at"}).AddRow(nonce, verifier, time.Now().Add(time.Minute)))
+	return resp, nil
+}
+
+const getIdentityQuery = "SELECT users.id, users.username, users.email, users.roles, users.disabled_at, users.totp_enabled_at FROM user_identities JOIN users ON users.id = user_identities.user_id WHERE user_identities.issuer = $1 AND user_identities.subject = $2"
+
+func TestOIDCLogin_Success(t *testing.T) {
+	provider := newMockProvider(t)
+
+	t.Run("First login provisions the user", func(t *testing.T) {
+		authService, mock := newOIDCService(t, provider)
+		start, err := startLogin(t, authService, mock)
+		require.NoError(t, err)
+		code := provider.authorize(t, start.GetAuthorizationUrl())
+
+		mock.ExpectQuery(regexp.QuoteMeta(getIdentityQuery)).
+			WithArgs(provider.URL, "248289761001").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "username", "email", "roles", "disabled_at", "totp_enabled_at"}))
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO users (username,password,email,email_verified_at) VALUES ($1,$2,$3,$4) ON CONFLICT (username) DO NOTHING RETURNING id")).
+			WithArgs("jane.doe", "", "jane.doe@example.com", sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO user_identities (user_id,issuer,subject,email) VALUES ($1,$2,$3,$4)")).
+			WithArgs(7, provider.URL, "248289761001", "jane.doe@example.com").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO refresh_tokens (user_id,token_hash,family_id,expires_at)`)).
+			WithArgs(7, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec(regexp.QuoteMeta(setLastLoginQuery)).
+			WithArgs(7).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		resp, err := authService.FinishOIDCLogin(context.Background(), &client.FinishOIDCLoginRequest{Code: code, State: start.GetState()})
+		require.NoError(t, err)
+		assert.Equal(t, int64(7), resp.GetUserId())
+		assert.NotEmpty(t, resp.GetJwt())
+		assert.NotEmpty(t, resp.GetRefreshToken())
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Known identity logs in", func(t *testing.T) {
+		authService, mock := newOIDCService(t, provider)
+		start, err := startLogin(t, authService, mock)
+		require.NoError(t, err)
+		code := provider.authorize(t, start.GetAuthorizationUrl())
+
+		mock.ExpectQuery(regexp.QuoteMeta(getIdentityQuery)).
+			WithArgs(provider.URL, "248289761001").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "username", "email", "roles", "disabled_at", "totp_enabled_at"}).AddRow(7, "jane.doe", "jane.doe@example.com", "{user}", nil, nil))
+		mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO refresh_tokens (user_id,token_hash,family_id,expires_at)`)).
+			WithArgs(7, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec(regexp.QuoteMeta(setLastLoginQuery)).
+			WithArgs(7).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		resp, err := authService.FinishOIDCLogin(context.Background(), &client.FinishOIDCLoginRequest{Code: code, State: start.GetState()})
+		require.NoError(t, err)
+		assert.Equal(t, int64(7), resp.GetUserId())
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Second factor is required", func(t *testing.T) {
+		authService, mock := newOIDCService(t, provider)
+		start, err := startLogin(t, authService, mock)
+		require.NoError(t, err)
+		code := provider.authorize(t, start.GetAuthorizationUrl())
+
+		mock.ExpectQuery(regexp.QuoteMeta(getIdentityQuery)).
+			WithArgs(provider.URL, "248289761001").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "username", "email", "roles", "disabled_at", "totp_enabled_at"}).
+				AddRow(7, "jane.doe", "jane.doe@example.com", "{user}", nil, time.Now().Add(-24*time.Hour)))
+		mock.ExpectExec(regexp.QuoteMeta("DELETE FROM login_challenges WHERE expires_at < now()")).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO login_challenges (token_hash,user_id,expires_at) VALUES ($1,$2,$3)")).
+			WithArgs(sqlmock.AnyArg(), 7, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		resp, err := authService.FinishOIDCLogin(context.Background(), &client.FinishOIDCLoginRequest{Code: code, State: start.GetState()})
+		require.NoError(t, err)
+		assert.True(t, resp.GetMfaRequired())
+		assert.NotEmpty(t, resp.GetChallengeToken())
+		assert.Empty(t, resp.GetJwt())
+		assert.Empty(t, resp.GetRefreshToken())
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestOIDCLogin_Rejected(t *testing.T) {
+	provider := newMockProvider(t)
+
+	t.Run("Unknown state", func(t *testing.T) {
+		authService, mock := newOIDCService(t, provider)
+		mock.ExpectQuery(regexp.QuoteMeta("DELETE FROM oidc_states WHERE state = $1 RETURNING nonce, code_verifier, expires_at")).
+			WithArgs("forged").
+			WillReturnRows(sqlmock.NewRows([]string{"nonce", "code_verifier", "expires_at"}))
+
+		resp, err := authService.FinishOIDCLogin(context.Background(), &client.FinishOIDCLoginRequest{Code: "code", State: "forged"})
+		require.Error(t, err)
+		assert.Nil(t, resp)
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Code redeemed without the verifier", func(t *testing.T) {
+		authService, mock := newOIDCService(t, provider)
+		start, err := startLogin(t, authService, mock)
+		require.NoError(t, err)
+		provider.authorize(t, start.GetAuthorizationUrl())
+
+		// A code intercepted from another login does not match the verifier of this one
+		resp, err := authService.FinishOIDCLogin(context.Background(), &client.FinishOIDCLoginRequest{Code: "stolen", State: start.GetState()})
+		require.Error(t, err)
+		assert.Nil(t, resp)
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("ID token for another client", func(t *testing.T) {
+		provider.audience = "another-client"
+		defer func() { provider.audience = clientID }()
+
+		authService, mock := newOIDCService(t, provider)
+		start, err := startLogin(t, authService, mock)
+		require.NoError(t, err)
+		code := provider.authorize(t, start.GetAuthorizationUrl())
+
+		resp, err := authService.FinishOIDCLogin(context.Background(), &client.FinishOIDCLoginRequest{Code: code, State: start.GetState()})
+		require.Error(t, err)
+		assert.Nil(t, resp)
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Not configured", func(t *testing.T) {
+		serv := service.NewAuthService(nil, "very-secret-key")
+		resp, err := auth.NewAuthService(context.Background(), serv).StartOIDCLogin(context.Background(), &client.StartOIDCLoginRequest{})
+		require.Error(t, err)
+		assert.Nil(t, resp)
+		assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	})
+}
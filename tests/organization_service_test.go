@@ -0,0 +1,159 @@
+package tests
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"house-of-neural-networks/internal/repository"
+	"house-of-neural-networks/internal/service"
+	"house-of-neural-networks/internal/transport/grpc/model"
+	client "house-of-neural-networks/pkg/api/model"
+	"house-of-neural-networks/pkg/db/postgres"
+	"testing"
+)
+
+const getMemberRoleQuery = "SELECT role FROM organization_members WHERE organization_id = \\$1 AND user_id = \\$2"
+
+func memberRoleRows(role string) *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"role"}).AddRow(role)
+}
+
+func TestGetModel_OrganizationMember(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	organizationModel := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"models.id", "models.name", "models.user_id", "models.organization_id", "models.public_permission", "versions.id as version_id", "versions.number as version_number", "versions.model_id as version_model_id"}).
+			AddRow(1, "team model", 1, 7, nil, 1, 1, 1)
+	}
+	mock.ExpectQuery(getModelQuery).
+		WithArgs(1).
+		WillReturnRows(organizationModel())
+	mock.ExpectQuery(getRoleQuery).
+		WithArgs(1, 1, 1, 1).
+		WillReturnRows(roleRows("infer"))
+	mock.ExpectQuery(getModelQuery).
+		WithArgs(1).
+		WillReturnRows(organizationModel())
+	mock.ExpectQuery(getRoleQuery).
+		WithArgs(1, 1, 1, 1).
+		WillReturnRows(roleRows("infer"))
+
+	db := sqlx.NewDb(mockDB, "sqlmock")
+	ctx := context.Background()
+	repo := repository.NewModelRepository(&postgres.DB{Db: db})
+	serv := service.NewModelService(repo, nil)
+	modelService := model.NewModelService(ctx, serv)
+
+	t.Run("Uploader is not the owner", func(t *testing.T) {
+		resp, err := modelService.GetModel(userContext(1), &client.GetModelRequest{Id: 1})
+		require.NoError(t, err)
+		assert.Equal(t, "infer", resp.GetModel().GetRole())
+		assert.Equal(t, int64(7), resp.GetModel().GetOrganizationId())
+	})
+
+	t.Run("Members do not upload versions", func(t *testing.T) {
+		resp, err := modelService.UploadVersion(userContext(1), &client.UploadVersionRequest{Number: 2, ModelId: 1})
+		require.Error(t, err)
+		assert.Nil(t, resp)
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUploadModel_OrganizationPermissionDenied(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	mock.ExpectQuery(getMemberRoleQuery).
+		WithArgs(7, 1).
+		WillReturnRows(memberRoleRows("member"))
+
+	db := sqlx.NewDb(mockDB, "sqlmock")
+	ctx := context.Background()
+	repo := repository.NewModelRepository(&postgres.DB{Db: db})
+	serv := service.NewModelService(repo, nil)
+	modelService := model.NewModelService(ctx, serv)
+
+	t.Run("Member", func(t *testing.T) {
+		resp, err := modelService.UploadModel(userContext(1), &client.UploadModelRequest{Name: "team model", OrganizationId: 7, Config: &client.File{Filename: "config.pbtxt"}})
+		require.Error(t, err)
+		assert.Nil(t, resp)
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSetMember_LastOwner(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	mock.ExpectQuery(getMemberRoleQuery).
+		WithArgs(7, 1).
+		WillReturnRows(memberRoleRows("owner"))
+	mock.ExpectQuery(getMemberRoleQuery).
+		WithArgs(7, 1).
+		WillReturnRows(memberRoleRows("owner"))
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM organization_members WHERE organization_id = \\$1 AND role = \\$2").
+		WithArgs(7, "owner").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	db := sqlx.NewDb(mockDB, "sqlmock")
+	ctx := context.Background()
+	repo := repository.NewModelRepository(&postgres.DB{Db: db})
+	serv := service.NewModelService(repo, nil)
+	modelService := model.NewModelService(ctx, serv)
+
+	t.Run("Demote the only owner", func(t *testing.T) {
+		resp, err := modelService.SetMember(userContext(1), &client.SetMemberRequest{OrganizationId: 7, UserId: 1, Role: "member"})
+		require.Error(t, err)
+		assert.Nil(t, resp)
+		assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	})
+
+	t.Run("Unknown role", func(t *testing.T) {
+		resp, err := modelService.SetMember(userContext(1), &client.SetMemberRequest{OrganizationId: 7, UserId: 2, Role: "admin"})
+		require.Error(t, err)
+		assert.Nil(t, resp)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRemoveMember_PermissionDenied(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	mock.ExpectQuery(getMemberRoleQuery).
+		WithArgs(7, 2).
+		WillReturnRows(memberRoleRows("maintainer"))
+	mock.ExpectQuery(getMemberRoleQuery).
+		WithArgs(7, 1).
+		WillReturnRows(memberRoleRows("owner"))
+
+	db := sqlx.NewDb(mockDB, "sqlmock")
+	ctx := context.Background()
+	repo := repository.NewModelRepository(&postgres.DB{Db: db})
+	serv := service.NewModelService(repo, nil)
+	modelService := model.NewModelService(ctx, serv)
+
+	t.Run("Maintainer removes an owner", func(t *testing.T) {
+		resp, err := modelService.RemoveMember(userContext(2), &client.RemoveMemberRequest{OrganizationId: 7, UserId: 1})
+		require.Error(t, err)
+		assert.Nil(t, resp)
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
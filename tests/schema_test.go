@@ -0,0 +1,131 @@
+package tests
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"house-of-neural-networks/internal/triton"
+	"house-of-neural-networks/pkg/tensor"
+	"testing"
+)
+
+// simpleSchema is a model with a dynamic batch dimension, a fixed-size input and an optional one.
+func simpleSchema() *triton.ModelSchema {
+	return &triton.ModelSchema{
+		Name:    "simple",
+		Version: "1",
+		Inputs: []triton.TensorSpec{
+			{Name: "INPUT0", Datatype: tensor.Fp32, Shape: []int64{-1, 4}},
+			{Name: "INPUT1", Datatype: tensor.Int32, Shape: []int64{1, 2}},
+			{Name: "MASK", Datatype: tensor.Bool, Shape: []int64{-1}, Optional: true},
+		},
+		Outputs: []triton.TensorSpec{
+			{Name: "OUTPUT0", Datatype: tensor.Fp32, Shape: []int64{-1, 4}},
+			{Name: "OUTPUT1", Datatype: tensor.Int32, Shape: []int64{1}},
+		},
+	}
+}
+
+func TestBuildInferRequest(t *testing.T) {
+	floats := tensor.Contents{Floats: []float64{1, 2, 3, 4, 5, 6, 7, 8}}
+	ints := tensor.Contents{Ints: []int64{1, 2}}
+
+	t.Run("By name", func(t *testing.T) {
+		request, err := triton.BuildInferRequest(simpleSchema(), []triton.Tensor{
+			{Name: "INPUT1", Contents: ints},
+			{Name: "INPUT0", Datatype: tensor.Fp32, Shape: []int64{2, 4}, Contents: floats},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "simple", request.GetModelName())
+		assert.Equal(t, "1", request.GetModelVersion())
+		// Inputs follow the order of the schema, the optional one is left out
+		require.Len(t, request.GetInputs(), 2)
+		assert.Equal(t, "INPUT0", request.GetInputs()[0].GetName())
+		assert.Equal(t, []int64{2, 4}, request.GetInputs()[0].GetShape())
+		assert.Equal(t, "INPUT1", request.GetInputs()[1].GetName())
+		assert.Equal(t, tensor.Int32, request.GetInputs()[1].GetDatatype())
+		assert.Equal(t, []byte{1, 0, 0, 0, 2, 0, 0, 0}, request.GetRawInputContents()[1])
+		require.Len(t, request.GetOutputs(), 2)
+		assert.Equal(t, "OUTPUT0", request.GetOutputs()[0].GetName())
+		assert.Equal(t, "OUTPUT1", request.GetOutputs()[1].GetName())
+	})
+
+	t.Run("By position", func(t *testing.T) {
+		request, err := triton.BuildInferRequest(simpleSchema(), []triton.Tensor{
+			{Contents: floats},
+			{Contents: ints},
+			{Contents: tensor.Contents{Bools: []bool{true, false}}},
+		})
+		require.NoError(t, err)
+		require.Len(t, request.GetInputs(), 3)
+		assert.Equal(t, "INPUT0", request.GetInputs()[0].GetName())
+		assert.Equal(t, tensor.Fp32, request.GetInputs()[0].GetDatatype())
+		assert.Equal(t, "INPUT1", request.GetInputs()[1].GetName())
+		assert.Equal(t, "MASK", request.GetInputs()[2].GetName())
+		assert.Equal(t, []int64{2}, request.GetInputs()[2].GetShape())
+	})
+
+	t.Run("Dynamic dimension", func(t *testing.T) {
+		request, err := triton.BuildInferRequest(simpleSchema(), []triton.Tensor{
+			{Name: "INPUT0", Contents: floats},
+			{Name: "INPUT1", Contents: ints},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []int64{2, 4}, request.GetInputs()[0].GetShape())
+		assert.Len(t, request.GetRawInputContents()[0], 8*4)
+	})
+
+	t.Run("Mismatches", func(t *testing.T) {
+		cases := []struct {
+			name   string
+			inputs []triton.Tensor
+			err    string
+		}{
+			{"Datatype", []triton.Tensor{{Name: "INPUT0", Datatype: tensor.Fp64, Contents: floats}, {Name: "INPUT1", Contents: ints}}, "expected datatype FP32, got FP64"},
+			{"Shape", []triton.Tensor{{Name: "INPUT0", Shape: []int64{4, 2}, Contents: floats}, {Name: "INPUT1", Contents: ints}}, "does not match model shape"},
+			{"Element count", []triton.Tensor{{Name: "INPUT0", Contents: tensor.Contents{Floats: []float64{1, 2, 3}}}, {Name: "INPUT1", Contents: ints}}, "do not fit shape"},
+			{"Fixed size", []triton.Tensor{{Name: "INPUT0", Contents: floats}, {Name: "INPUT1", Contents: tensor.Contents{Ints: []int64{1, 2, 3}}}}, "expects 2 elements, got 3"},
+			{"Missing input", []triton.Tensor{{Name: "INPUT0", Contents: floats}}, "missing required input INPUT1"},
+			{"Unknown input", []triton.Tensor{{Name: "INPUT0", Contents: floats}, {Name: "INPUT2", Contents: ints}}, "missing required input INPUT1"},
+			{"Unknown optional", []triton.Tensor{{Name: "INPUT0", Contents: floats}, {Name: "INPUT1", Contents: ints}, {Name: "INPUT2", Contents: ints}}, "has no input INPUT2"},
+			{"Duplicate input", []triton.Tensor{{Name: "INPUT0", Contents: floats}, {Name: "INPUT0", Contents: floats}}, "duplicate input INPUT0"},
+			{"Too many inputs", []triton.Tensor{{Contents: floats}, {Contents: ints}, {Contents: ints}, {Contents: ints}}, "accepts 3 inputs, got 4"},
+		}
+		for _, c := range cases {
+			t.Run(c.name, func(t *testing.T) {
+				_, err := triton.BuildInferRequest(simpleSchema(), c.inputs)
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), c.err)
+			})
+		}
+	})
+}
+
+func TestResolveShape(t *testing.T) {
+	cases := []struct {
+		name     string
+		shape    []int64
+		count    int
+		expected []int64
+		err      bool
+	}{
+		{"Fixed", []int64{2, 3}, 6, []int64{2, 3}, false},
+		{"Fixed mismatch", []int64{2, 3}, 5, nil, true},
+		{"Batch", []int64{-1, 4}, 12, []int64{3, 4}, false},
+		{"Batch and sequence", []int64{-1, -1}, 5, []int64{1, 5}, false},
+		{"Middle dimension", []int64{2, -1, 3}, 12, []int64{2, 2, 3}, false},
+		{"Not divisible", []int64{-1, 4}, 6, nil, true},
+		{"Zero dimension", []int64{-1, 0}, 4, nil, true},
+		{"Scalar", []int64{}, 1, []int64{}, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			shape, err := triton.ResolveShape(c.shape, c.count)
+			if c.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, c.expected, shape)
+		})
+	}
+}
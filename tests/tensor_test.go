@@ -0,0 +1,97 @@
+package tests
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"house-of-neural-networks/pkg/tensor"
+	"math"
+	"testing"
+)
+
+func TestTensor_RoundTrip(t *testing.T) {
+	cases := []struct {
+		datatype string
+		values   interface{}
+		expected interface{}
+	}{
+		{tensor.Bool, []bool{true, false, true}, []bool{true, false, true}},
+		{tensor.Uint8, []uint8{0, 1, 255}, []uint64{0, 1, 255}},
+		{tensor.Uint16, []uint16{0, 1, 65535}, []uint64{0, 1, 65535}},
+		{tensor.Uint32, []uint32{0, 1, math.MaxUint32}, []uint64{0, 1, math.MaxUint32}},
+		{tensor.Uint64, []uint64{0, 1, math.MaxUint64}, []uint64{0, 1, math.MaxUint64}},
+		{tensor.Int8, []int8{-128, 0, 127}, []int64{-128, 0, 127}},
+		{tensor.Int16, []int16{-32768, 0, 32767}, []int64{-32768, 0, 32767}},
+		{tensor.Int32, []int32{math.MinInt32, 0, math.MaxInt32}, []int64{math.MinInt32, 0, math.MaxInt32}},
+		{tensor.Int64, []int64{math.MinInt64, 0, math.MaxInt64}, []int64{math.MinInt64, 0, math.MaxInt64}},
+		{tensor.Fp16, []float32{-2, 0.5, 65504}, []float64{-2, 0.5, 65504}},
+		{tensor.Bf16, []float32{-2, 0.5, 1.5}, []float64{-2, 0.5, 1.5}},
+		{tensor.Fp32, []float32{-1.25, 0, 3.5}, []float64{-1.25, 0, 3.5}},
+		{tensor.Fp64, []float64{-1e300, 0, math.Pi}, []float64{-1e300, 0, math.Pi}},
+		{tensor.Bytes, []string{"hello", "", "мир"}, []string{"hello", "", "мир"}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.datatype, func(t *testing.T) {
+			raw, err := tensor.Encode(c.datatype, []int64{1, 3}, c.values)
+			require.NoError(t, err)
+
+			contents, err := tensor.Decode(c.datatype, []int64{1, 3}, raw)
+			require.NoError(t, err)
+			assert.Equal(t, c.expected, contents.Values())
+		})
+	}
+}
+
+func TestTensor_Layout(t *testing.T) {
+	t.Run("INT32 little endian", func(t *testing.T) {
+		raw, err := tensor.Encode(tensor.Int32, []int64{2}, []int32{1, -1})
+		require.NoError(t, err)
+		assert.Equal(t, []byte{1, 0, 0, 0, 0xff, 0xff, 0xff, 0xff}, raw)
+	})
+
+	t.Run("BYTES length prefixed", func(t *testing.T) {
+		raw, err := tensor.Encode(tensor.Bytes, []int64{2}, [][]byte{{0xff}, []byte("ab")})
+		require.NoError(t, err)
+		assert.Equal(t, []byte{1, 0, 0, 0, 0xff, 2, 0, 0, 0, 'a', 'b'}, raw)
+	})
+
+	t.Run("FP16 and BF16", func(t *testing.T) {
+		assert.Equal(t, uint16(0x3c00), tensor.Float32ToFloat16(1))
+		assert.Equal(t, uint16(0x7c00), tensor.Float32ToFloat16(1e6))
+		assert.Equal(t, uint16(0x0001), tensor.Float32ToFloat16(float32(math.Pow(2, -24))))
+		assert.Equal(t, float32(math.Pow(2, -24)), tensor.Float16ToFloat32(0x0001))
+		assert.Equal(t, uint16(0x3f80), tensor.Float32ToBFloat16(1))
+		assert.Equal(t, float32(-2), tensor.BFloat16ToFloat32(0xc000))
+	})
+}
+
+func TestTensor_Validation(t *testing.T) {
+	t.Run("Shape mismatch", func(t *testing.T) {
+		_, err := tensor.Encode(tensor.Fp32, []int64{1, 4}, []float32{1, 2, 3})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "expects 4 elements, got 3")
+	})
+
+	t.Run("Raw size mismatch", func(t *testing.T) {
+		_, err := tensor.Decode(tensor.Fp32, []int64{2}, make([]byte, 4))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "expects 2 elements, got 1")
+	})
+
+	t.Run("Overflow", func(t *testing.T) {
+		_, err := tensor.Encode(tensor.Uint8, []int64{1}, []int64{256})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "overflows UINT8")
+	})
+
+	t.Run("Truncated BYTES", func(t *testing.T) {
+		_, err := tensor.Decode(tensor.Bytes, []int64{1}, []byte{5, 0, 0, 0, 'a'})
+		require.Error(t, err)
+	})
+
+	t.Run("Unsupported datatype", func(t *testing.T) {
+		_, err := tensor.Encode("STRING", []int64{1}, []string{"a"})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unsupported datatype")
+	})
+}
@@ -0,0 +1,249 @@
+package tests
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"house-of-neural-networks/internal/models"
+	client "house-of-neural-networks/pkg/api/auth"
+	"house-of-neural-networks/pkg/totp"
+	"regexp"
+	"testing"
+	"time"
+)
+
+// totpSecret is the key of the RFC 6238 test vectors, "12345678901234567890" in base32.
+const totpSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+const getTOTPQuery = "SELECT totp_secret, totp_enabled_at, totp_last_step FROM users WHERE id = $1"
+
+const getLoginChallengeQuery = "SELECT user_id, attempts, expires_at FROM login_challenges WHERE token_hash = $1"
+
+func totpRows(secret interface{}, enabledAt interface{}) *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"totp_secret", "totp_enabled_at", "totp_last_step"}).AddRow(secret, enabledAt, nil)
+}
+
+func TestTOTP(t *testing.T) {
+	t.Run("RFC 6238 vectors", func(t *testing.T) {
+		for unix, expected := range map[int64]string{59: "287082", 1111111109: "081804", 2000000000: "279037"} {
+			code, err := totp.Code(totpSecret, totp.Step(time.Unix(unix, 0)))
+			require.NoError(t, err)
+			assert.Equal(t, expected, code)
+		}
+	})
+
+	t.Run("Validate", func(t *testing.T) {
+		now := time.Unix(1111111109, 0)
+		step, ok := totp.Validate(totpSecret, "081 804", now.Add(totp.Period))
+		assert.True(t, ok)
+		assert.Equal(t, totp.Step(now), step)
+		_, ok = totp.Validate(totpSecret, "081804", now.Add(3*totp.Period))
+		assert.False(t, ok)
+		_, ok = totp.Validate(totpSecret, "81804", now)
+		assert.False(t, ok)
+	})
+
+	t.Run("URI", func(t *testing.T) {
+		uri := totp.URI("House of Neural Networks", "john", totpSecret)
+		assert.Contains(t, uri, "otpauth://totp/House%20of%20Neural%20Networks:john?")
+		assert.Contains(t, uri, "secret="+totpSecret)
+	})
+}
+
+func TestLogIn_TOTP(t *testing.T) {
+	authService, mock := newAuthService(t)
+	ctx := context.Background()
+	password, _ := bcrypt.GenerateFromPassword([]byte("123"), bcrypt.DefaultCost)
+	enabledAt := time.Now().Add(-24 * time.Hour)
+
+	mock.ExpectQuery(regexp.QuoteMeta(getUserQuery)).
+		WithArgs("test user").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "password", "roles", "disabled_at", "last_login_at", "totp_enabled_at"}).
+			AddRow(1, "test user", password, "{user}", nil, nil, enabledAt))
+	mock.ExpectQuery(regexp.QuoteMeta(countLoginFailuresQuery)).
+		WillReturnRows(loginFailureRows(0, nil, 0, nil))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM login_challenges WHERE expires_at < now()")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO login_challenges (token_hash,user_id,expires_at) VALUES ($1,$2,$3)")).
+		WithArgs(sqlmock.AnyArg(), 1, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	var challenge string
+	t.Run("Challenge", func(t *testing.T) {
+		resp, err := authService.LogIn(ctx, &client.LogInRequest{Username: "test user", Password: "123"})
+		require.NoError(t, err)
+		assert.True(t, resp.GetMfaRequired())
+		assert.Empty(t, resp.GetJwt())
+		assert.Empty(t, resp.GetRefreshToken())
+		challenge = resp.GetChallengeToken()
+		require.NotEmpty(t, challenge)
+	})
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	expectChallenge := func() {
+		mock.ExpectQuery(regexp.QuoteMeta(getLoginChallengeQuery)).
+			WithArgs(sha256Hex(challenge)).
+			WillReturnRows(sqlmock.NewRows([]string{"user_id", "attempts", "expires_at"}).AddRow(1, 0, time.Now().Add(time.Minute)))
+		mock.ExpectQuery(regexp.QuoteMeta(getUserByIDQuery)).
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "username", "email", "roles", "disabled_at", "email_verified_at", "last_login_at", "totp_enabled_at"}).
+				AddRow(1, "test user", "", "{user}", nil, nil, nil, enabledAt))
+		mock.ExpectQuery(regexp.QuoteMeta(countLoginFailuresQuery)).
+			WillReturnRows(loginFailureRows(0, nil, 0, nil))
+		mock.ExpectQuery(regexp.QuoteMeta(getTOTPQuery)).
+			WithArgs(1).
+			WillReturnRows(totpRows(totpSecret, enabledAt))
+	}
+
+	t.Run("Wrong code", func(t *testing.T) {
+		expectChallenge()
+		mock.ExpectExec(regexp.QuoteMeta("UPDATE login_challenges SET attempts = attempts + 1 WHERE token_hash = $1")).
+			WithArgs(sha256Hex(challenge)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta("DELETE FROM login_challenges WHERE token_hash = $1 AND attempts >= $2")).
+			WithArgs(sha256Hex(challenge), 5).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(regexp.QuoteMeta(recordLoginFailureQuery)).
+			WithArgs("test user", 1, "10.0.0.1", models.LoginWrongCode).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		resp, err := authService.VerifyLogIn(ctx, &client.VerifyLogInRequest{ChallengeToken: challenge, Code: "000000", ClientIp: "10.0.0.1"})
+		require.Error(t, err)
+		assert.Nil(t, resp)
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		code, err := totp.Code(totpSecret, totp.Step(time.Now()))
+		require.NoError(t, err)
+		expectChallenge()
+		mock.ExpectExec(regexp.QuoteMeta("UPDATE users SET totp_last_step = $1 WHERE id = $2 AND (totp_last_step IS NULL OR totp_last_step < $3)")).
+			WithArgs(sqlmock.AnyArg(), 1, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta("DELETE FROM login_challenges WHERE token_hash = $1")).
+			WithArgs(sha256Hex(challenge)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO refresh_tokens (user_id,token_hash,family_id,expires_at)`)).
+			WithArgs(1, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec(regexp.QuoteMeta(setLastLoginQuery)).
+			WithArgs(1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		resp, err := authService.VerifyLogIn(ctx, &client.VerifyLogInRequest{ChallengeToken: challenge, Code: code})
+		require.NoError(t, err)
+		assert.False(t, resp.GetMfaRequired())
+		assert.NotEmpty(t, resp.GetJwt())
+		assert.NotEmpty(t, resp.GetRefreshToken())
+	})
+
+	t.Run("Replayed code", func(t *testing.T) {
+		code, err := totp.Code(totpSecret, totp.Step(time.Now()))
+		require.NoError(t, err)
+		expectChallenge()
+		mock.ExpectExec(regexp.QuoteMeta("UPDATE users SET totp_last_step")).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(regexp.QuoteMeta("UPDATE login_challenges SET attempts = attempts + 1")).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta("DELETE FROM login_challenges WHERE token_hash = $1 AND attempts >= $2")).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(regexp.QuoteMeta(recordLoginFailureQuery)).
+			WithArgs("test user", 1, "", models.LoginWrongCode).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		_, err = authService.VerifyLogIn(ctx, &client.VerifyLogInRequest{ChallengeToken: challenge, Code: code})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("Unknown challenge", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(getLoginChallengeQuery)).
+			WithArgs(sha256Hex("unknown")).
+			WillReturnRows(sqlmock.NewRows([]string{"user_id", "attempts", "expires_at"}))
+
+		_, err := authService.VerifyLogIn(ctx, &client.VerifyLogInRequest{ChallengeToken: "unknown", Code: "123456"})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestConfirmTOTP(t *testing.T) {
+	authService, mock := newAuthService(t)
+	ctx := userContext(1)
+
+	t.Run("Invalid code", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(getTOTPQuery)).
+			WithArgs(1).
+			WillReturnRows(totpRows(totpSecret, nil))
+
+		_, err := authService.ConfirmTOTP(ctx, &client.ConfirmTOTPRequest{Code: "000000"})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		code, err := totp.Code(totpSecret, totp.Step(time.Now()))
+		require.NoError(t, err)
+		mock.ExpectQuery(regexp.QuoteMeta(getTOTPQuery)).
+			WithArgs(1).
+			WillReturnRows(totpRows(totpSecret, nil))
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta("UPDATE users SET totp_enabled_at = now(), totp_last_step = $1 WHERE id = $2 AND totp_enabled_at IS NULL AND totp_secret IS NOT NULL")).
+			WithArgs(totp.Step(time.Now()), 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta("DELETE FROM recovery_codes WHERE user_id = $1")).
+			WithArgs(1).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO recovery_codes (user_id,code_hash)")).
+			WillReturnResult(sqlmock.NewResult(0, 10))
+		mock.ExpectCommit()
+
+		resp, err := authService.ConfirmTOTP(ctx, &client.ConfirmTOTPRequest{Code: code})
+		require.NoError(t, err)
+		require.Len(t, resp.GetRecoveryCodes(), 10)
+		assert.Regexp(t, `^[a-z2-7]{5}-[a-z2-7]{5}$`, resp.GetRecoveryCodes()[0])
+	})
+
+	t.Run("Already enabled", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(getTOTPQuery)).
+			WithArgs(1).
+			WillReturnRows(totpRows(totpSecret, time.Now()))
+
+		_, err := authService.EnrollTOTP(ctx, &client.EnrollTOTPRequest{})
+		assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	})
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDisableTOTP_RecoveryCode(t *testing.T) {
+	authService, mock := newAuthService(t)
+
+	mock.ExpectQuery(regexp.QuoteMeta(getTOTPQuery)).
+		WithArgs(1).
+		WillReturnRows(totpRows(totpSecret, time.Now()))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE recovery_codes SET used_at = now() WHERE code_hash = $1 AND used_at IS NULL AND user_id = $2")).
+		WithArgs(sha256Hex("abcdefghij"), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE users SET totp_secret = $1, totp_enabled_at = $2, totp_last_step = $3 WHERE id = $4")).
+		WithArgs(nil, nil, nil, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM recovery_codes WHERE user_id = $1")).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM login_challenges WHERE user_id = $1")).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	t.Run("Success", func(t *testing.T) {
+		_, err := authService.DisableTOTP(userContext(1), &client.DisableTOTPRequest{Code: "ABCDE-FGHIJ"})
+		require.NoError(t, err)
+	})
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,55 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"house-of-neural-networks/internal/triton"
+	tritonpb "house-of-neural-networks/pkg/api/triton2"
+	"testing"
+)
+
+// erroringTriton is a Triton server that fails every readiness check with err.
+type erroringTriton struct {
+	tritonpb.GRPCInferenceServiceClient
+	err error
+}
+
+func (s erroringTriton) ModelReady(context.Context, *tritonpb.ModelReadyRequest, ...grpc.CallOption) (*tritonpb.ModelReadyResponse, error) {
+	return nil, s.err
+}
+
+func TestTritonErrors(t *testing.T) {
+	cases := []struct {
+		name     string
+		err      error
+		expected codes.Code
+	}{
+		{"Not found", status.Error(codes.NotFound, "model not found"), codes.NotFound},
+		{"Invalid argument", status.Error(codes.InvalidArgument, "bad request"), codes.InvalidArgument},
+		{"Unavailable", status.Error(codes.Unavailable, "connection refused"), codes.Unavailable},
+		{"Resource exhausted", status.Error(codes.ResourceExhausted, "queue is full"), codes.ResourceExhausted},
+		{"Other codes", status.Error(codes.PermissionDenied, "denied"), codes.Internal},
+		{"Unknown model", status.Error(codes.Internal, "Request for unknown model: 'simple' has no available versions"), codes.NotFound},
+		{"Model is not found", status.Error(codes.Unknown, "Request for model 'simple' is not found"), codes.NotFound},
+		{"No version", status.Error(codes.Unavailable, "No version is available for model 'simple'"), codes.NotFound},
+		{"Unexpected input", status.Error(codes.Internal, "unexpected datatype FP64 for input 'INPUT0'"), codes.InvalidArgument},
+		{"Wrong size", status.Error(codes.Unknown, "expected 8 elements but got 3"), codes.InvalidArgument},
+		{"Wrong size with a specific code", status.Error(codes.Unavailable, "expected 8 elements but got 3"), codes.Unavailable},
+		{"Deadline", context.DeadlineExceeded, codes.DeadlineExceeded},
+		{"Canceled", context.Canceled, codes.Canceled},
+		{"Not a status", errors.New("broken pipe"), codes.Internal},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := triton.ModelReadyRequest(erroringTriton{err: c.err}, "simple", "1")
+			require.Error(t, err)
+			assert.Equal(t, c.expected, status.Code(err))
+			assert.Contains(t, status.Convert(err).Message(), "triton: couldn't get readiness of model simple")
+		})
+	}
+}
@@ -0,0 +1,343 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"house-of-neural-networks/internal/modelstore"
+	"house-of-neural-networks/internal/repository"
+	"house-of-neural-networks/internal/service"
+	"house-of-neural-networks/internal/transport/grpc/model"
+	client "house-of-neural-networks/pkg/api/model"
+	"house-of-neural-networks/pkg/db/postgres"
+	"io"
+	"path"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+)
+
+// uploadStream is the server side of UploadVersionStream that plays back the frames.
+type uploadStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	frames []*client.UploadVersionChunk
+	read   int
+}
+
+func (s *uploadStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *uploadStream) Recv() (*client.UploadVersionChunk, error) {
+	if s.read == len(s.frames) {
+		return nil, io.EOF
+	}
+	s.read++
+	return s.frames[s.read-1], nil
+}
+
+func (s *uploadStream) SendAndClose(*client.UploadVersionResponse) error {
+	return nil
+}
+
+func headerFrame(modelID int64, number int32) *client.UploadVersionChunk {
+	return &client.UploadVersionChunk{Frame: &client.UploadVersionChunk_Header{Header: &client.UploadVersionHeader{ModelId: modelID, Number: number}}}
+}
+
+func filenameFrame(filename string) *client.UploadVersionChunk {
+	return &client.UploadVersionChunk{Frame: &client.UploadVersionChunk_Filename{Filename: filename}}
+}
+
+func dataFrame(data string) *client.UploadVersionChunk {
+	return &client.UploadVersionChunk{Frame: &client.UploadVersionChunk_Data{Data: []byte(data)}}
+}
+
+func TestUploadVersionStream_NoHeader(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	db := sqlx.NewDb(mockDB, "sqlmock")
+	ctx := context.Background()
+	repo := repository.NewModelRepository(&postgres.DB{Db: db})
+	modelService := model.NewModelService(ctx, service.NewModelService(repo, nil))
+
+	t.Run("Empty stream", func(t *testing.T) {
+		err := modelService.UploadVersionStream(&uploadStream{ctx: userContext(1)})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("Files first", func(t *testing.T) {
+		stream := &uploadStream{ctx: userContext(1), frames: []*client.UploadVersionChunk{filenameFrame("model.onnx"), dataFrame("weights")}}
+		err := modelService.UploadVersionStream(stream)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUploadVersionStream_PermissionDenied(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	mock.ExpectQuery(getModelQuery).
+		WithArgs(1).
+		WillReturnRows(modelRows())
+	mock.ExpectQuery(getRoleQuery).
+		WithArgs(2, 2, 2, 1).
+		WillReturnRows(roleRows("read"))
+
+	db := sqlx.NewDb(mockDB, "sqlmock")
+	ctx := context.Background()
+	repo := repository.NewModelRepository(&postgres.DB{Db: db})
+	modelService := model.NewModelService(ctx, service.NewModelService(repo, nil))
+
+	t.Run("Read access", func(t *testing.T) {
+		stream := &uploadStream{ctx: userContext(2), frames: []*client.UploadVersionChunk{headerFrame(1, 2), filenameFrame("model.onnx"), dataFrame("weights")}}
+		err := modelService.UploadVersionStream(stream)
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+		assert.Equal(t, 1, stream.read, "files are not read before the access is checked")
+	})
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUploadVersionStream_InvalidFrames(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	db := sqlx.NewDb(mockDB, "sqlmock")
+	ctx := context.Background()
+	repo := repository.NewModelRepository(&postgres.DB{Db: db})
+	modelService := model.NewModelService(ctx, service.NewModelService(repo, nil))
+
+	tests := []struct {
+		name   string
+		frames []*client.UploadVersionChunk
+	}{
+		{"Data without filename", []*client.UploadVersionChunk{headerFrame(1, 2), dataFrame("weights")}},
+		{"Path in filename", []*client.UploadVersionChunk{headerFrame(1, 2), filenameFrame("../config.pbtxt"), dataFrame("weights")}},
+		{"Second header", []*client.UploadVersionChunk{headerFrame(1, 2), headerFrame(1, 3)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock.ExpectQuery(getModelQuery).
+				WithArgs(1).
+				WillReturnRows(modelRows())
+
+			err := modelService.UploadVersionStream(&uploadStream{ctx: userContext(1), frames: tt.frames})
+			assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		})
+	}
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+// failingStore is a model repository that stores everything but the file named failing.
+type failingStore struct {
+	modelstore.ModelStore
+	failing string
+}
+
+func (s failingStore) MoveFile(ctx context.Context, name, src string) error {
+	if path.Base(name) == s.failing {
+		return errors.New("connection reset by peer")
+	}
+	return s.ModelStore.MoveFile(ctx, name, src)
+}
+
+func (s failingStore) Put(ctx context.Context, name string, content []byte) error {
+	if path.Base(name) == s.failing {
+		return errors.New("connection reset by peer")
+	}
+	return s.ModelStore.Put(ctx, name, content)
+}
+
+func TestUploadVersionStream_StoreFailure(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	mock.ExpectQuery(getModelQuery).
+		WithArgs(1).
+		WillReturnRows(modelRows())
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO versions (number,model_id) VALUES ($1,$2) returning *")).
+		WithArgs(2, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "number", "model_id"}).AddRow(5, 2, 1))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM versions WHERE id = $1")).
+		WithArgs(5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	db := sqlx.NewDb(mockDB, "sqlmock")
+	ctx := context.Background()
+	repo := repository.NewModelRepository(&postgres.DB{Db: db})
+	root := t.TempDir()
+	serv := service.NewModelService(repo, nil)
+	serv.Store = failingStore{ModelStore: modelstore.NewLocalStore(root), failing: "model.onnx"}
+	serv.UploadDir = filepath.Join(root, ".uploads")
+	modelService := model.NewModelService(ctx, serv)
+
+	stream := &uploadStream{ctx: userContext(1), frames: []*client.UploadVersionChunk{
+		headerFrame(1, 2), filenameFrame("labels.txt"), dataFrame("cat"), filenameFrame("model.onnx"), dataFrame("weights"),
+	}}
+	err = modelService.UploadVersionStream(stream)
+	assert.Equal(t, codes.Internal, status.Code(err))
+	// The version is deleted with the files stored so far, so the upload can be retried
+	assert.NoDirExists(t, filepath.Join(root, "simple model", "2"))
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+const getUploadQuery = "SELECT id, user_id, model_id, filename, size, created_at, expires_at FROM uploads WHERE id = \\$1"
+
+func uploadRows(userID, size int64) *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "user_id", "model_id", "filename", "size", "created_at", "expires_at"}).
+		AddRow(1000001, userID, 1, "model.onnx", size, time.Now(), time.Now().Add(time.Hour))
+}
+
+// writeStream is the server side of WriteUpload that plays back the frames.
+type writeStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	frames []*client.WriteUploadChunk
+}
+
+func (s *writeStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *writeStream) Recv() (*client.WriteUploadChunk, error) {
+	if len(s.frames) == 0 {
+		return nil, io.EOF
+	}
+	frame := s.frames[0]
+	s.frames = s.frames[1:]
+	return frame, nil
+}
+
+func (s *writeStream) SendAndClose(*client.Upload) error {
+	return nil
+}
+
+func TestCreateUpload(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	db := sqlx.NewDb(mockDB, "sqlmock")
+	ctx := context.Background()
+	repo := repository.NewModelRepository(&postgres.DB{Db: db})
+	modelService := model.NewModelService(ctx, service.NewModelService(repo, nil))
+
+	t.Run("Success", func(t *testing.T) {
+		mock.ExpectQuery(getModelQuery).
+			WithArgs(1).
+			WillReturnRows(modelRows())
+		mock.ExpectQuery("INSERT INTO uploads \\(user_id,model_id,filename,size,expires_at\\) VALUES \\(\\$1,\\$2,\\$3,\\$4,\\$5\\) returning id, created_at").
+			WithArgs(1, 1, "model.onnx", 1<<32, sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1000001, time.Now()))
+
+		resp, err := modelService.CreateUpload(userContext(1), &client.CreateUploadRequest{ModelId: 1, Filename: "model.onnx", Size: 1 << 32})
+		require.NoError(t, err)
+		assert.Equal(t, int64(1000001), resp.GetId())
+		assert.Equal(t, int64(0), resp.GetOffset())
+		assert.True(t, resp.GetExpiresAt().AsTime().After(time.Now()))
+	})
+
+	t.Run("Invalid filename", func(t *testing.T) {
+		_, err := modelService.CreateUpload(userContext(1), &client.CreateUploadRequest{ModelId: 1, Filename: "1/model.onnx", Size: 10})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("Negative size", func(t *testing.T) {
+		_, err := modelService.CreateUpload(userContext(1), &client.CreateUploadRequest{ModelId: 1, Filename: "model.onnx", Size: -1})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetUpload_OtherUser(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	mock.ExpectQuery(getUploadQuery).
+		WithArgs(1000001).
+		WillReturnRows(uploadRows(2, 10))
+
+	db := sqlx.NewDb(mockDB, "sqlmock")
+	ctx := context.Background()
+	repo := repository.NewModelRepository(&postgres.DB{Db: db})
+	modelService := model.NewModelService(ctx, service.NewModelService(repo, nil))
+
+	t.Run("Not revealed", func(t *testing.T) {
+		resp, err := modelService.GetUpload(userContext(1), &client.GetUploadRequest{Id: 1000001})
+		assert.Nil(t, resp)
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWriteUpload_OffsetMismatch(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	mock.ExpectQuery(getUploadQuery).
+		WithArgs(1000001).
+		WillReturnRows(uploadRows(1, 10))
+
+	db := sqlx.NewDb(mockDB, "sqlmock")
+	ctx := context.Background()
+	repo := repository.NewModelRepository(&postgres.DB{Db: db})
+	modelService := model.NewModelService(ctx, service.NewModelService(repo, nil))
+
+	t.Run("Ahead of the upload", func(t *testing.T) {
+		stream := &writeStream{ctx: userContext(1), frames: []*client.WriteUploadChunk{
+			{Frame: &client.WriteUploadChunk_Header{Header: &client.WriteUploadHeader{Id: 1000001, Offset: 4}}},
+			{Frame: &client.WriteUploadChunk_Data{Data: []byte("weights")}},
+		}}
+		err := modelService.WriteUpload(stream)
+		assert.Equal(t, codes.Aborted, status.Code(err))
+	})
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFinishUpload_Incomplete(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	mock.ExpectQuery(getModelQuery).
+		WithArgs(1).
+		WillReturnRows(modelRows())
+	mock.ExpectQuery(getUploadQuery).
+		WithArgs(1000001).
+		WillReturnRows(uploadRows(1, 10))
+
+	db := sqlx.NewDb(mockDB, "sqlmock")
+	ctx := context.Background()
+	repo := repository.NewModelRepository(&postgres.DB{Db: db})
+	modelService := model.NewModelService(ctx, service.NewModelService(repo, nil))
+
+	t.Run("Nothing received", func(t *testing.T) {
+		resp, err := modelService.FinishUpload(userContext(1), &client.FinishUploadRequest{ModelId: 1, Number: 2, UploadIds: []int64{1000001}})
+		assert.Nil(t, resp)
+		assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	})
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}